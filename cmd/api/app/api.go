@@ -77,6 +77,15 @@ func NewAPICmd(cli *DksCli) *cobra.Command {
 			// add k8s cluster manager Runnable
 			ctrlMgr.Add(apiMgr.ClustersMgr)
 
+			// add event store retention Runnable
+			ctrlMgr.Add(ctrlmanager.RunnableFunc(apiMgr.RunEventStore))
+
+			// add terminal recording retention Runnable
+			ctrlMgr.Add(ctrlmanager.RunnableFunc(apiMgr.RunTerminalRecordings))
+
+			// add offline pod snapshot Runnable
+			ctrlMgr.Add(apiMgr.Snapshots)
+
 			logger.Info("zap debug", "SyncPeriod", rp)
 			klog.Info("starting the controllers manager...")
 			stopCh := signals.SetupSignalHandler()
@@ -91,5 +100,25 @@ func NewAPICmd(cli *DksCli) *cobra.Command {
 	cmd.PersistentFlags().BoolVar(&opt.IsMeta, "is-meta", opt.IsMeta, "Whether it is a meta cluster")
 	cmd.PersistentFlags().BoolVar(&opt.GinLogEnabled, "enable-ginlog", opt.GinLogEnabled, "Enabled will open gin run log.")
 	cmd.PersistentFlags().BoolVar(&opt.PprofEnabled, "enable-pprof", opt.PprofEnabled, "Enabled will open endpoint for go pprof.")
+	cmd.PersistentFlags().DurationVar(&opt.PromTimeout, "prom-timeout", opt.PromTimeout, "the timeout of querying the prometheus of the clusters")
+	cmd.PersistentFlags().StringVar(&opt.EventStorePath, "event-store-path", opt.EventStorePath, "the bolt file of the event history, kept in memory if empty")
+	cmd.PersistentFlags().StringVar(&opt.AuthConfigPath, "auth-config", opt.AuthConfigPath, "the authentication and authorization config of the api, the api is open if empty")
+	cmd.PersistentFlags().StringSliceVar(&opt.TrustedProxies, "trusted-proxies", opt.TrustedProxies, "the addresses or the CIDRs of the proxies whose X-Forwarded-For header is trusted for the source of the requests")
+	cmd.PersistentFlags().StringVar(&opt.TLSCertFile, "tls-cert-file", opt.TLSCertFile, "the server certificate, the api is served over https with it")
+	cmd.PersistentFlags().StringVar(&opt.TLSKeyFile, "tls-key-file", opt.TLSKeyFile, "the server private key")
+	cmd.PersistentFlags().StringVar(&opt.Audit.Path, "audit-log-path", opt.Audit.Path, "the audit file of the mutating and interactive requests, the audit is disabled if empty")
+	cmd.PersistentFlags().IntVar(&opt.Audit.MaxSize, "audit-log-maxsize", opt.Audit.MaxSize, "the megabytes of the audit file before rotated")
+	cmd.PersistentFlags().IntVar(&opt.Audit.MaxBackups, "audit-log-maxbackup", opt.Audit.MaxBackups, "the max number of the rotated audit files")
+	cmd.PersistentFlags().IntVar(&opt.Audit.MaxAge, "audit-log-maxage", opt.Audit.MaxAge, "the max days to keep the rotated audit files")
+	cmd.PersistentFlags().StringVar(&opt.Audit.WebhookEndpoint, "audit-webhook", opt.Audit.WebhookEndpoint, "the endpoint the audit events are posted to in json")
+	cmd.PersistentFlags().StringVar(&opt.TerminalRecordDir, "terminal-record-dir", opt.TerminalRecordDir, "the directory of the terminal session recordings, the sessions are not recorded if empty")
+	cmd.PersistentFlags().DurationVar(&opt.TerminalRecordRetention, "terminal-record-retention", opt.TerminalRecordRetention, "the retention of the terminal session recordings")
+	cmd.PersistentFlags().DurationVar(&opt.TerminalIdleTimeout, "terminal-idle-timeout", opt.TerminalIdleTimeout, "closes the terminal sessions without input for the duration, 0 disables")
+	cmd.PersistentFlags().DurationVar(&opt.TerminalMaxDuration, "terminal-max-duration", opt.TerminalMaxDuration, "closes the terminal sessions lasting for the duration, 0 disables")
+	cmd.PersistentFlags().DurationVar(&opt.EventRetention, "event-retention", opt.EventRetention, "the retention of the event history")
+	cmd.PersistentFlags().StringVar(&opt.OfflinePodRecordNs, "offlinepod-record-namespace", opt.OfflinePodRecordNs, "the namespace of the offline pod records in the master cluster")
+	cmd.PersistentFlags().StringVar(&opt.LogAgentImage, "log-agent-image", opt.LogAgentImage, "the image of the log agent deployed with the offline-workload DaemonSet")
+	cmd.PersistentFlags().IntVar(&opt.SnapshotWorkers, "snapshot-workers", opt.SnapshotWorkers, "the number of the workers snapshotting the pods deleted through the api")
+	cmd.PersistentFlags().IntVar(&opt.EventMemoryMaxRecords, "event-memory-max-records", opt.EventMemoryMaxRecords, "the max events of the history kept in memory without --event-store-path, the oldest are evicted")
 	return cmd
 }
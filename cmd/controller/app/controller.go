@@ -117,8 +117,18 @@ func NewControllerCmd(dksCli *DksCli) *cobra.Command {
 	cmd.PersistentFlags().BoolVar(&opt.WorkerEnabled, "enable-worker", opt.WorkerEnabled, "Enable worker controller")
 	cmd.PersistentFlags().BoolVar(&opt.ClusterEnabled, "enable-cluster", opt.ClusterEnabled, "Enable cluster controller")
 	cmd.PersistentFlags().BoolVar(&opt.OfflinePodEnabled, "enable-offlinepod", opt.OfflinePodEnabled, "Enable offline pod controller")
+	cmd.PersistentFlags().Int64Var(&opt.OfflinePodLogLines, "offlinepod-log-lines", opt.OfflinePodLogLines, "the number of the last log lines of each container in the offline pod snapshots")
+	cmd.PersistentFlags().IntVar(&opt.OfflinePodMaxSnapshots, "offlinepod-max-snapshots", opt.OfflinePodMaxSnapshots, "the number of the offline pod snapshots kept for each app")
+	cmd.PersistentFlags().DurationVar(&opt.OfflinePodTTL, "offlinepod-ttl", opt.OfflinePodTTL, "how long the offline pod records are kept")
+	cmd.PersistentFlags().StringSliceVar(&opt.OfflinePodNamespaces, "offlinepod-namespaces", opt.OfflinePodNamespaces, "the namespaces of the offline pods recorded, \"*\" for all")
+	cmd.PersistentFlags().StringVar(&opt.OfflinePodNsSelector, "offlinepod-namespace-selector", opt.OfflinePodNsSelector, "the label selector of the namespaces of the offline pods recorded in addition to --offlinepod-namespaces")
+	cmd.PersistentFlags().IntVar(&opt.OfflinePodWorkers, "offlinepod-workers", opt.OfflinePodWorkers, "the number of the workers recording and snapshotting the offline pods")
+	cmd.PersistentFlags().StringVar(&opt.OfflinePodRecordNs, "offlinepod-record-namespace", opt.OfflinePodRecordNs, "the namespace of the offline pod records in the master cluster")
 	cmd.PersistentFlags().BoolVar(&opt.EventEnabled, "enable-event", opt.EventEnabled, "Enable event exporter controller")
+	cmd.PersistentFlags().BoolVar(&opt.EventMultiCluster, "event-multi-cluster", opt.EventMultiCluster, "Export the events of all the clusters from the master instead of the local cluster")
 	cmd.PersistentFlags().StringVar(&opt.AlertEndpoint, "alert-endpoint", opt.AlertEndpoint, "the alertmanager endpoint URL")
+	cmd.PersistentFlags().StringVar(&opt.EventConfigMap, "event-config", opt.EventConfigMap, "the config map of the event exporter routes and receivers, namespace/name")
+	cmd.PersistentFlags().StringVar(&opt.ClusterNamespace, "cluster-namespace", opt.ClusterNamespace, "the namespace of the Cluster objects")
 	cmd.PersistentFlags().BoolVar(&opt.Recover, "recover", opt.Recover, "Enable recover function")
 	cmd.PersistentFlags().BoolVar(&opt.Debug, "debug", opt.Debug, "Debug mode")
 	return cmd
@@ -0,0 +1,53 @@
+/*
+Copyright 2020 The dks authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package app
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+	"gitlab.dmall.com/arch/sym-admin/pkg/logagent"
+	"k8s.io/klog"
+	"sigs.k8s.io/controller-runtime/pkg/runtime/signals"
+)
+
+// NewLogAgentCmd runs the log agent in the offline-workload DaemonSet, the namespace and
+// the node of the pod are from the POD_NAMESPACE and NODE_NAME env.
+func NewLogAgentCmd(dksCli *DksCli) *cobra.Command {
+	opt := &logagent.Options{
+		Interval:  logagent.DefaultInterval,
+		Namespace: os.Getenv("POD_NAMESPACE"),
+		NodeName:  os.Getenv("NODE_NAME"),
+	}
+	cmd := &cobra.Command{
+		Use:   "logagent",
+		Short: "Manage the app logs of the node",
+		Run: func(cmd *cobra.Command, args []string) {
+			PrintFlags(cmd.Flags())
+
+			kubeCli := dksCli.GetKubeInterfaceOrDie()
+			agent := logagent.New(kubeCli, opt)
+			if err := agent.Start(signals.SetupSignalHandler()); err != nil {
+				klog.Fatalf("log agent err: %v", err)
+			}
+		},
+	}
+
+	cmd.PersistentFlags().StringVar(&opt.Root, "root", opt.Root, "the app log directory, default /web/logs/app")
+	cmd.PersistentFlags().DurationVar(&opt.Interval, "interval", opt.Interval, "the interval of the log retention runs")
+	return cmd
+}
@@ -77,6 +77,7 @@ func GetRootCmd(args []string) *cobra.Command {
 	cli := NewDksCli(opt)
 
 	rootCmd.AddCommand(NewControllerCmd(cli))
+	rootCmd.AddCommand(NewLogAgentCmd(cli))
 	// rootCmd.AddCommand(NewOperatorCmd(cli))
 	rootCmd.AddCommand(NewCmdVersion(cli))
 	return rootCmd
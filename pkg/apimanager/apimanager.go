@@ -8,15 +8,27 @@ import (
 	apiv1 "gitlab.dmall.com/arch/sym-admin/pkg/apimanager/v1"
 	apiv2 "gitlab.dmall.com/arch/sym-admin/pkg/apimanager/v2"
 	workloadv1beta1 "gitlab.dmall.com/arch/sym-admin/pkg/apis/workload/v1beta1"
+	"gitlab.dmall.com/arch/sym-admin/pkg/audit"
+	"gitlab.dmall.com/arch/sym-admin/pkg/controllers/offlinepod"
+	"gitlab.dmall.com/arch/sym-admin/pkg/eventstore"
+	"gitlab.dmall.com/arch/sym-admin/pkg/execpolicy"
 	"gitlab.dmall.com/arch/sym-admin/pkg/healthcheck"
 	k8smanager "gitlab.dmall.com/arch/sym-admin/pkg/k8s/manager"
 	"gitlab.dmall.com/arch/sym-admin/pkg/labels"
+	"gitlab.dmall.com/arch/sym-admin/pkg/offlinelog"
+	"gitlab.dmall.com/arch/sym-admin/pkg/prom"
 	"gitlab.dmall.com/arch/sym-admin/pkg/router"
+	"gitlab.dmall.com/arch/sym-admin/pkg/termrec"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/klog"
 )
 
+// markDeletedTimeout bounds the mark of a pod deleted through the api
+const markDeletedTimeout = 5 * time.Second
+
 // APIManager ...
 type APIManager struct {
 	Opt           *Option
@@ -24,6 +36,9 @@ type APIManager struct {
 	Router        *router.Router
 	HealthHandler healthcheck.Handler
 	ClustersMgr   *k8smanager.ClusterManager
+	EventStore    eventstore.Store
+	Recordings    *termrec.Store
+	Snapshots     *offlinepod.Snapshotter
 }
 
 // Option ...
@@ -33,23 +48,70 @@ type Option struct {
 	IsMeta             bool
 	ResyncPeriod       time.Duration
 	Features           []string
+	PromTimeout        time.Duration
+
+	// EventStorePath is the bolt file of the event history, the history is kept in memory when it is empty.
+	EventStorePath string
+	EventRetention time.Duration
+	// EventMemoryMaxRecords bounds the event history kept in memory, the oldest are evicted.
+	EventMemoryMaxRecords int
 
 	// exposing the api such as /metrics, /read, /live, /pprof, /api.
 	HTTPAddr       string
 	GinLogEnabled  bool
 	GinLogSkipPath []string
 	PprofEnabled   bool
+
+	// AuthConfigPath is the authentication and the authorization of the api, the api is
+	// open when it is empty.
+	AuthConfigPath string
+	TLSCertFile    string
+	TLSKeyFile     string
+	// TrustedProxies are the proxies whose X-Forwarded-For header is trusted for the source
+	// of the requests, the peer address is the source without them.
+	TrustedProxies []string
+
+	// Audit records the mutating and interactive requests, disabled without the path.
+	Audit audit.Options
+
+	// TerminalRecordDir keeps the recordings of the terminal sessions, the sessions are
+	// not recorded when it is empty.
+	TerminalRecordDir       string
+	TerminalRecordRetention time.Duration
+	TerminalIdleTimeout     time.Duration
+	TerminalMaxDuration     time.Duration
+
+	// SnapshotWorkers capture the snapshots of the pods deleted through the api.
+	SnapshotWorkers int
+	// OfflinePodRecordNs is the namespace of the offline pod records in the master cluster.
+	OfflinePodRecordNs string
+	// LogAgentImage is the image of the log agent deployed to the clusters.
+	LogAgentImage string
 }
 
 // DefaultOption ...
 func DefaultOption() *Option {
 	return &Option{
-		HTTPAddr:           ":8080",
-		IsMeta:             true,
-		GoroutineThreshold: 1000,
-		GinLogSkipPath:     []string{"/ready", "/live"},
-		GinLogEnabled:      true,
-		PprofEnabled:       true,
+		HTTPAddr:              ":8080",
+		IsMeta:                true,
+		GoroutineThreshold:    1000,
+		GinLogSkipPath:        []string{"/ready", "/live"},
+		GinLogEnabled:         true,
+		PprofEnabled:          true,
+		PromTimeout:           30 * time.Second,
+		EventRetention:        7 * 24 * time.Hour,
+		EventMemoryMaxRecords: eventstore.DefaultMemoryMaxRecords,
+		Audit: audit.Options{
+			MaxSize:    100,
+			MaxBackups: 10,
+			MaxAge:     90,
+		},
+		TerminalRecordRetention: 90 * 24 * time.Hour,
+		TerminalIdleTimeout:     30 * time.Minute,
+		TerminalMaxDuration:     8 * time.Hour,
+		SnapshotWorkers:         offlinepod.DefaultSnapshotWorkers,
+		OfflinePodRecordNs:      offlinepod.DefaultRecordNamespace,
+		LogAgentImage:           offlinelog.AgentImage(),
 	}
 }
 
@@ -81,8 +143,46 @@ func NewAPIManager(masterCli k8smanager.MasterClient, opt *Option, componentName
 		Addr:             opt.HTTPAddr,
 		MetricsPath:      "metrics",
 		MetricsSubsystem: componentName,
+		CertFilePath:     opt.TLSCertFile,
+		KeyFilePath:      opt.TLSKeyFile,
+		TrustedProxies:   opt.TrustedProxies,
+	}
+	if opt.AuthConfigPath != "" {
+		routerOptions.Auth, err = router.LoadAuthConfig(opt.AuthConfigPath)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if routerOptions.Auth != nil && routerOptions.Auth.Exec != nil {
+		policy, err := execpolicy.New(routerOptions.Auth.Exec)
+		if err != nil {
+			return nil, fmt.Errorf("invalid exec policy: %v", err)
+		}
+		v1.ExecPolicy = policy
+		v2.ExecPolicy = policy
+	} else {
+		klog.Warning("no exec policy is configured, all the commands are allowed to exec in the containers")
 	}
 	rt := router.NewRouter(routerOptions)
+	rt.AppResolver = func(clusterName, namespace, podName string) string {
+		cluster, err := clustersMgr.Get(clusterName)
+		if err != nil || namespace == "" {
+			return ""
+		}
+		pod := &corev1.Pod{}
+		if err := cluster.Client.Get(context.TODO(), types.NamespacedName{Namespace: namespace, Name: podName}, pod); err != nil {
+			return ""
+		}
+		return pod.Labels[labels.ObserveMustLabelAppName]
+	}
+	auditor, err := audit.New(&opt.Audit)
+	if err != nil {
+		return nil, err
+	}
+	if auditor != nil {
+		rt.UseRoute(auditor.RouteMiddleware)
+		v2.Audit = auditor
+	}
 	rt.AddRoutes("index", rt.DefaultRoutes())
 	rt.AddRoutes("health", healthHandler.Routes())
 	rt.AddRoutes("cluster", v1.Routes())
@@ -91,6 +191,46 @@ func NewAPIManager(masterCli k8smanager.MasterClient, opt *Option, componentName
 	apiMgr.ClustersMgr = clustersMgr
 	v1.ClustersMgr = clustersMgr
 	v2.ClustersMgr = clustersMgr
+	v2.Prom = prom.NewClient(opt.PromTimeout)
+	v1.OfflinePods = offlinepod.NewRecordStore(masterCli.GetClient(), masterCli.GetAPIReader(), opt.OfflinePodRecordNs)
+	v1.OfflineSnapshots = offlinepod.NewArchive(masterCli.GetClient(), opt.OfflinePodRecordNs, 0)
+	v1.LogAgentImage = opt.LogAgentImage
+
+	storeOpt := &eventstore.Options{Backend: eventstore.BackendBolt, Path: opt.EventStorePath, MaxRecords: opt.EventMemoryMaxRecords}
+	if opt.EventStorePath == "" {
+		storeOpt.Backend = eventstore.BackendMemory
+	}
+	apiMgr.EventStore, err = eventstore.New(storeOpt)
+	if err != nil {
+		return nil, err
+	}
+	v2.Events = apiMgr.EventStore
+
+	v1.Terminal = apiv1.TerminalOptions{IdleTimeout: opt.TerminalIdleTimeout, MaxDuration: opt.TerminalMaxDuration}
+	if opt.TerminalRecordDir != "" {
+		apiMgr.Recordings, err = termrec.NewStore(opt.TerminalRecordDir)
+		if err != nil {
+			return nil, err
+		}
+		v1.Terminal.Recordings = apiMgr.Recordings
+	}
+
+	// the pods deleted through the api are marked before deleted and snapshotted in the
+	// background while terminating
+	apiMgr.Snapshots = offlinepod.NewSnapshotter(v1.OfflineSnapshots, nil, opt.SnapshotWorkers)
+	apiMgr.ClustersMgr.AddPodDeleteHook(func(ctx context.Context, cluster *k8smanager.Cluster, pod *corev1.Pod, user string) {
+		ctx, cancel := context.WithTimeout(ctx, markDeletedTimeout)
+		defer cancel()
+		// the pods deleted without the authentication are marked by the api itself
+		if user == "" {
+			user = "sym-admin"
+		}
+		if err := k8smanager.MarkPodDeletedBy(ctx, cluster.Client, pod, user); err != nil {
+			klog.Errorf("cluster: %s pod: %s/%s mark deleted err: %v", cluster.Name, pod.Namespace, pod.Name, err)
+		}
+		apiMgr.Snapshots.Enqueue(cluster, pod)
+	})
+
 	apiMgr.ClustersMgr.AddPreInit(func() {
 		klog.Infof("Initializing an informer for a cluster in advanced ... ")
 		for _, c := range apiMgr.ClustersMgr.GetAll() {
@@ -167,6 +307,29 @@ func (m *APIManager) registryResource(cluster *k8smanager.Cluster) error {
 	return nil
 }
 
+// RunEventStore prunes the event history by the retention and closes the store when stopped.
+func (m *APIManager) RunEventStore(stop <-chan struct{}) error {
+	eventstore.RunRetention(m.EventStore, m.Opt.EventRetention, time.Hour, stop)
+	<-stop
+	return m.EventStore.Close()
+}
+
+// RunTerminalRecordings prunes the recordings of the terminal sessions by the retention.
+func (m *APIManager) RunTerminalRecordings(stop <-chan struct{}) error {
+	if m.Recordings == nil || m.Opt.TerminalRecordRetention <= 0 {
+		return nil
+	}
+	wait.Until(func() {
+		n, err := m.Recordings.Prune(time.Now().Add(-m.Opt.TerminalRecordRetention))
+		if err != nil {
+			klog.Errorf("prune the terminal recordings err: %v", err)
+		} else if n > 0 {
+			klog.Infof("pruned the terminal recordings of %d days", n)
+		}
+	}, time.Hour, stop)
+	return nil
+}
+
 // ClusterChange ...
 func (m *APIManager) ClusterChange() {
 	for list := range m.ClustersMgr.ClusterAddInfo {
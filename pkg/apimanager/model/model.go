@@ -186,12 +186,76 @@ type Event struct {
 // OfflinePod ...
 type OfflinePod struct {
 	Name        string            `json:"name,omitempty"`
+	UID         string            `json:"uid,omitempty"`
 	ClusterName string            `json:"clusterCode,omitempty"`
 	Namespace   string            `json:"namespace,omitempty"`
 	AppName     string            `json:"appName,omitempty"`
+	OwnerKind   string            `json:"ownerKind,omitempty"`
+	NodeName    string            `json:"nodeName,omitempty"`
 	HostIP      string            `json:"hostIP,omitempty"`
 	PodIP       string            `json:"podIP,omitempty"`
 	ContainerID string            `json:"containerId,omitempty"`
 	Labels      map[string]string `json:"labels,omitempty"`
 	OfflineTime string            `json:"offlineTime,omitempty"`
+	// DeletionCause is why the pod is deleted, e.g. Evicted, OOMKilled, UserDeleted,
+	// RolloutReplaced, NodeLost, Unknown.
+	DeletionCause   string `json:"deletionCause,omitempty"`
+	DeletionMessage string `json:"deletionMessage,omitempty"`
+}
+
+// OfflinePodSnapshot is the post-mortem state of a pod captured when it is deleted.
+type OfflinePodSnapshot struct {
+	Name        string               `json:"name,omitempty"`
+	UID         string               `json:"uid,omitempty"`
+	ClusterName string               `json:"clusterCode,omitempty"`
+	Namespace   string               `json:"namespace,omitempty"`
+	AppName     string               `json:"appName,omitempty"`
+	OwnerKind   string               `json:"ownerKind,omitempty"`
+	NodeName    string               `json:"nodeName,omitempty"`
+	HostIP      string               `json:"hostIP,omitempty"`
+	PodIP       string               `json:"podIP,omitempty"`
+	Phase       string               `json:"phase,omitempty"`
+	Reason      string               `json:"reason,omitempty"`
+	Message     string               `json:"message,omitempty"`
+	StartTime   string               `json:"startTime,omitempty"`
+	CaptureTime string               `json:"captureTime,omitempty"`
+	Containers  []*ContainerSnapshot `json:"containers,omitempty"`
+	Events      []*PodEventSnapshot  `json:"events,omitempty"`
+	EventsError string               `json:"eventsError,omitempty"`
+}
+
+// ContainerSnapshot is the state and the last log lines of a container.
+type ContainerSnapshot struct {
+	Name            string               `json:"name,omitempty"`
+	Image           string               `json:"image,omitempty"`
+	ImageID         string               `json:"imageID,omitempty"`
+	Ready           bool                 `json:"ready"`
+	RestartCount    int32                `json:"restartCount"`
+	State           string               `json:"state,omitempty"`
+	Termination     *TerminationSnapshot `json:"termination,omitempty"`
+	LastTermination *TerminationSnapshot `json:"lastTermination,omitempty"`
+	Logs            string               `json:"logs,omitempty"`
+	PreviousLogs    string               `json:"previousLogs,omitempty"`
+	LogsError       string               `json:"logsError,omitempty"`
+}
+
+// TerminationSnapshot is the termination state of a container.
+type TerminationSnapshot struct {
+	ExitCode   int32  `json:"exitCode"`
+	Signal     int32  `json:"signal,omitempty"`
+	Reason     string `json:"reason,omitempty"`
+	Message    string `json:"message,omitempty"`
+	StartedAt  string `json:"startedAt,omitempty"`
+	FinishedAt string `json:"finishedAt,omitempty"`
+}
+
+// PodEventSnapshot is an event of the pod.
+type PodEventSnapshot struct {
+	Type      string `json:"type,omitempty"`
+	Reason    string `json:"reason,omitempty"`
+	Message   string `json:"message,omitempty"`
+	Source    string `json:"source,omitempty"`
+	Count     int32  `json:"count,omitempty"`
+	FirstTime string `json:"firstTime,omitempty"`
+	LastTime  string `json:"lastTime,omitempty"`
 }
@@ -3,27 +3,102 @@ package v1
 import (
 	"context"
 	"net/http"
+	"path"
 
 	"github.com/gin-gonic/gin"
+	"gitlab.dmall.com/arch/sym-admin/pkg/logagent"
+	"gitlab.dmall.com/arch/sym-admin/pkg/offlinelog"
 	"gitlab.dmall.com/arch/sym-admin/pkg/resources"
 	"gitlab.dmall.com/arch/sym-admin/pkg/utils"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/klog"
 )
 
-// HandleOfflineWorkloadDeploy get log files in a pod
+// offlineWorkloadRBAC grants the log agent to read the retention policies and to list the
+// pods, which it does only for the running pods of its node. The agent writes nothing to
+// the API server, its usage is read by the cluster controller from the usage file.
+func offlineWorkloadRBAC() []runtime.Object {
+	lb := offlinelog.Labels()
+	meta := metav1.ObjectMeta{
+		Name:      offlinelog.ServiceAccountName,
+		Namespace: offlinelog.Namespace,
+		Labels:    lb,
+	}
+	clusterMeta := metav1.ObjectMeta{
+		Name:   offlinelog.ServiceAccountName,
+		Labels: lb,
+	}
+	subjects := []rbacv1.Subject{
+		{
+			Kind:      rbacv1.ServiceAccountKind,
+			Name:      offlinelog.ServiceAccountName,
+			Namespace: offlinelog.Namespace,
+		},
+	}
+	return []runtime.Object{
+		&corev1.ServiceAccount{ObjectMeta: meta},
+		&rbacv1.Role{
+			ObjectMeta: meta,
+			Rules: []rbacv1.PolicyRule{
+				{
+					APIGroups:     []string{""},
+					Resources:     []string{"configmaps"},
+					ResourceNames: []string{logagent.ConfigMapName},
+					Verbs:         []string{"get"},
+				},
+			},
+		},
+		&rbacv1.RoleBinding{
+			ObjectMeta: meta,
+			RoleRef: rbacv1.RoleRef{
+				APIGroup: rbacv1.GroupName,
+				Kind:     "Role",
+				Name:     offlinelog.ServiceAccountName,
+			},
+			Subjects: subjects,
+		},
+		&rbacv1.ClusterRole{
+			ObjectMeta: clusterMeta,
+			Rules: []rbacv1.PolicyRule{
+				{
+					APIGroups: []string{""},
+					Resources: []string{"pods"},
+					Verbs:     []string{"list"},
+				},
+			},
+		},
+		&rbacv1.ClusterRoleBinding{
+			ObjectMeta: clusterMeta,
+			RoleRef: rbacv1.RoleRef{
+				APIGroup: rbacv1.GroupName,
+				Kind:     "ClusterRole",
+				Name:     offlinelog.ServiceAccountName,
+			},
+			Subjects: subjects,
+		},
+	}
+}
+
+// HandleOfflineWorkloadDeploy deploys the offline-workload DaemonSet to all the clusters, the
+// log-agent container enforces the log retention of the nodes. The agent image is only
+// set by the --log-agent-image flag of the server.
+// /api/cluster/:name/offlineWorkloadDeploy
 func (m *Manager) HandleOfflineWorkloadDeploy(c *gin.Context) {
-	hostPathType := corev1.HostPathDirectory
-	lb := map[string]string{
-		"app": "offline-pod-log",
+	agentImage := m.LogAgentImage
+	if agentImage == "" {
+		agentImage = offlinelog.AgentImage()
 	}
+	hostPathType := corev1.HostPathDirectory
+	lb := offlinelog.Labels()
 	ds := &appsv1.DaemonSet{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      "offline-workload-ds",
-			Namespace: "sym-admin",
+			Name:      offlinelog.DaemonSetName,
+			Namespace: offlinelog.Namespace,
 			Labels:    lb,
 		},
 		Spec: appsv1.DaemonSetSpec{
@@ -35,6 +110,7 @@ func (m *Manager) HandleOfflineWorkloadDeploy(c *gin.Context) {
 					Labels: lb,
 				},
 				Spec: corev1.PodSpec{
+					ServiceAccountName: offlinelog.ServiceAccountName,
 					Volumes: []corev1.Volume{
 						{
 							Name: "web",
@@ -45,13 +121,19 @@ func (m *Manager) HandleOfflineWorkloadDeploy(c *gin.Context) {
 								},
 							},
 						},
+						{
+							Name: "log-agent",
+							VolumeSource: corev1.VolumeSource{
+								EmptyDir: &corev1.EmptyDirVolumeSource{},
+							},
+						},
 					},
 					NodeSelector: map[string]string{
 						"beta.kubernetes.io/os": "linux",
 					},
 					Containers: []corev1.Container{
 						{
-							Name:  "offline-pod-log",
+							Name:  offlinelog.ContainerName,
 							Image: "symcn.tencentcloudcr.com/symcn/centos-base:7.8",
 							Command: []string{
 								"/bin/sh",
@@ -95,6 +177,59 @@ echo "Container will exit"
 									ReadOnly:  true,
 									MountPath: "/web",
 								},
+								// the usage file of the agent read by the cluster controller
+								{
+									Name:      "log-agent",
+									ReadOnly:  true,
+									MountPath: path.Dir(logagent.UsageFile),
+								},
+							},
+							TerminationMessagePath:   corev1.TerminationMessagePathDefault,
+							TerminationMessagePolicy: corev1.TerminationMessageReadFile,
+							ImagePullPolicy:          corev1.PullIfNotPresent,
+						},
+						{
+							Name:  offlinelog.AgentContainerName,
+							Image: agentImage,
+							Args:  []string{"logagent", "--root=" + offlinelog.AppRoot},
+							Env: []corev1.EnvVar{
+								{
+									Name: "NODE_NAME",
+									ValueFrom: &corev1.EnvVarSource{
+										FieldRef: &corev1.ObjectFieldSelector{
+											FieldPath: "spec.nodeName",
+										},
+									},
+								},
+								{
+									Name: "POD_NAMESPACE",
+									ValueFrom: &corev1.EnvVarSource{
+										FieldRef: &corev1.ObjectFieldSelector{
+											FieldPath: "metadata.namespace",
+										},
+									},
+								},
+							},
+							Resources: corev1.ResourceRequirements{
+								Limits: corev1.ResourceList{
+									corev1.ResourceCPU:    resource.MustParse("0.5"),
+									corev1.ResourceMemory: resource.MustParse("256Mi"),
+								},
+								Requests: corev1.ResourceList{
+									corev1.ResourceCPU:    resource.MustParse("0.05"),
+									corev1.ResourceMemory: resource.MustParse("32Mi"),
+								},
+							},
+							// the logs are deleted and compressed by the agent
+							VolumeMounts: []corev1.VolumeMount{
+								{
+									Name:      "web",
+									MountPath: "/web",
+								},
+								{
+									Name:      "log-agent",
+									MountPath: path.Dir(logagent.UsageFile),
+								},
 							},
 							TerminationMessagePath:   corev1.TerminationMessagePathDefault,
 							TerminationMessagePolicy: corev1.TerminationMessageReadFile,
@@ -125,6 +260,14 @@ echo "Container will exit"
 	}
 
 	for _, cluster := range m.ClustersMgr.GetAll() {
+		for _, obj := range offlineWorkloadRBAC() {
+			if _, err := resources.Reconcile(context.TODO(), cluster.Client, obj, resources.Option{}); err != nil {
+				klog.Errorf("cluster: %s apply offline workload rbac err: %v", cluster.Name, err)
+				AbortHTTPError(c, ParamInvalidError, "", err)
+				return
+			}
+		}
+
 		_, err := resources.Reconcile(context.TODO(), cluster.Client, ds, resources.Option{IsRecreate: true})
 		if err != nil {
 			klog.Errorf("cluster: %s  apply err: %v", cluster.Name, err)
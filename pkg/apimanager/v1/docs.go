@@ -96,7 +96,9 @@ isStdin: query string, this parameter determines whether open stdin. Default is
 isStdout: query string, this parameter determines whether open stdout. Default is true. <br/>
 isStderr: query string, this parameter determines whether open stderr. Default is true. <br/>
 once: query string, this parameter determines whether to execute a command and exit. <br/>
-cmd: query string, commands executed in the container. <br/>
+cmd: query string, the arguments of the command executed in the container, repeated for each argument. Default is /bin/sh. The command must be allowed by the exec policy, or 403 is returned. <br/>
+idleTimeout: query string, closes the session without input for the duration such as 10m, only shorter than the server limit. <br/>
+maxDuration: query string, closes the session lasting for the duration such as 1h, only shorter than the server limit. <br/>
 <br/>
 e.g. <br/>
 <a>ws://localhost:8080/api/cluster/tcc-bj5-dks-monit-01/terminal?namespace=default&pod=bbcc-xx-xx&container=bbcc</a><br/>
@@ -110,7 +112,7 @@ namespace: query string, the unique namespace in a cluster. Default is 'default'
 pod: query string, the unique pod name. <br/>
 container: query string, the unique container name. <br/>
 tty: query string, this parameter determines whether to output as tty. Default is false. <br/>
-cmd: query string, commands executed in the container. <br/>
+cmd: query string, the command executed in the container. It is split into the arguments like a shell with the quotes, but never interpreted by a shell, so the pipes, redirections and variables do not work. It is repeated for each argument as well. The command must be allowed by the exec policy, or 403 is returned. <br/>
 <br/>
 e.g. <br/>
 <a href="/api/cluster/tcc-bj5-dks-monit-01/exec?namespace=default&pod=bbcc-xx-xx&container=bbcc&tty=false&cmd=ls -a">/api/cluster/tcc-bj5-dks-monit-01/exec?namespace=default&pod=bbcc-xx-xx&container=bbcc&tty=false&cmd=ls -a</a><br/>
@@ -227,6 +229,12 @@ namespace: url param, namespace name <br/>
 podName: url param, the unique pod name. <br/>
 container: query string, the unique container in a pod. <br/>
 tail: query string, the log tail number, default is 1000. <br/>
+previous: query string, the log of the previous terminated container. <br/>
+timestamps: query string, adds the timestamp to each line. <br/>
+sinceSeconds: query string, the log of the last seconds. <br/>
+sinceTime: query string, the log since the time of RFC3339 or unix seconds. <br/>
+limitBytes: query string, the max bytes of the log. <br/>
+follow is not supported, use the /api/v2 logs/stream api instead. <br/>
 <br/>
 e.g. <br/>
 <a href="/api/cluster/tcc-bj5-dks-monit-01/namespace/default/pods/bbcc-xx-xx/logs?container=bbcc&tailLines=100">/api/cluster/tcc-bj5-dks-monit-01/namespace/default/pods/bbcc-xx-xx/logs?container=bbcc&tailLines=100</a><br/>
@@ -239,8 +247,8 @@ name: url param, the unique cluster name and all. <br/>
 namespace: url param, namespace name <br/>
 podName: url param, the unique pod name. <br/>
 container: query string, the unique container in a pod. <br/>
-tailLines: query string, the log tail number, default is 100. <br/>
-filepath: query string, the log file path in a container. <br/>
+tailLines: query string, the log tail number, default is 1000, at most 10000. <br/>
+filepath: query string, the absolute log file path in a container, it must be under the log directories such as /web/logs after the symlinks resolved, or 403 is returned. <br/>
 <br/>
 e.g. <br/>
 <a href="/api/cluster/tcc-bj5-dks-monit-01/namespace/default/pods/bbcc-xx-xx/logs/file?container=bbcc&filepath=thanos.shipper.json">/api/cluster/tcc-bj5-dks-monit-01/namespace/default/pods/bbcc-xx-xx/logs/file?container=bbcc&filepath=xx/xx.log</a><br/>
@@ -266,3 +274,91 @@ releaseName: url param, the unique release name. <br/>
 e.g.<br/>
 <a href="/api/cluster/tcc-bj5-dks-test-01/helm/aabb-9000-rz01a-blue">/api/cluster/tcc-bj5-dks-test-01/helm/aabb-9000-rz01a-blue</a><br/>
 `
+
+// GetOfflineLogFilesDesc ...
+var GetOfflineLogFilesDesc = `
+List the log files of an offline pod kept on the node, both the new layout /web/logs/app/$projectCode/$appCode/$podIP:$port <br/>
+and the legacy layout /web/logs/app/logback/$appName/$podIP_$containerID are searched. <br/>
+name: url param, the unique cluster name. <br/>
+hostIP: query string, the node ip of the offline pod. <br/>
+podIP: query string, the ip of the offline pod. <br/>
+projectCode: query string, the project code of the new layout. <br/>
+appCode: query string, the app code of the new layout. <br/>
+appName: query string, the app name of the legacy layout. <br/>
+containerID: query string, the container id of the legacy layout. <br/>
+<br/>
+e.g. <br/>
+<a href="/api/cluster/tcc-bj5-dks-monit-01/offlineLogs/files?hostIP=10.0.0.1&podIP=172.16.0.1&projectCode=dmall&appCode=bbcc&appName=bbcc">/api/cluster/tcc-bj5-dks-monit-01/offlineLogs/files?hostIP=10.0.0.1&podIP=172.16.0.1&projectCode=dmall&appCode=bbcc&appName=bbcc</a><br/>
+`
+
+// ReadOfflineLogFileDesc ...
+var ReadOfflineLogFileDesc = `
+Read a log file kept on the node, at most 1MiB is returned. <br/>
+name: url param, the unique cluster name. <br/>
+hostIP: query string, the node ip. <br/>
+path: query string, the file path under /web/logs. <br/>
+mode: query string, tail, head or range. Default is tail. <br/>
+lines: query string, the number of the lines of tail and head. Default is 1000. <br/>
+offset: query string, the first byte of range. Default is 0. <br/>
+length: query string, the number of the bytes of range. <br/>
+<br/>
+e.g. <br/>
+<a href="/api/cluster/tcc-bj5-dks-monit-01/offlineLogs/file?hostIP=10.0.0.1&path=app/dmall/bbcc/172.16.0.1:8080/info.log&mode=tail&lines=100">/api/cluster/tcc-bj5-dks-monit-01/offlineLogs/file?hostIP=10.0.0.1&path=app/dmall/bbcc/172.16.0.1:8080/info.log&mode=tail&lines=100</a><br/>
+`
+
+// GrepOfflineLogsDesc ...
+var GrepOfflineLogsDesc = `
+Search a log file or the files under a log directory kept on the node, at most 1MiB is returned. <br/>
+name: url param, the unique cluster name. <br/>
+hostIP: query string, the node ip. <br/>
+path: query string, the file or directory path under /web/logs. <br/>
+pattern: query string, the pattern searched. <br/>
+regexp: query string, match the pattern as an extended regular expression instead of a fixed string. Default is false. <br/>
+ignoreCase: query string, Default is false. <br/>
+maxCount: query string, the max matched lines of each file. Default is 1000. <br/>
+limit: query string, the max lines returned. Default is 1000. <br/>
+<br/>
+e.g. <br/>
+<a href="/api/cluster/tcc-bj5-dks-monit-01/offlineLogs/grep?hostIP=10.0.0.1&path=app/dmall/bbcc/172.16.0.1:8080&pattern=Exception">/api/cluster/tcc-bj5-dks-monit-01/offlineLogs/grep?hostIP=10.0.0.1&path=app/dmall/bbcc/172.16.0.1:8080&pattern=Exception</a><br/>
+`
+
+// DownloadOfflineLogsDesc ...
+var DownloadOfflineLogsDesc = `
+Download a log file, or a tar.gz of a log directory kept on the node. <br/>
+name: url param, the unique cluster name. <br/>
+hostIP: query string, the node ip. <br/>
+path: query string, the file or directory path under /web/logs. <br/>
+<br/>
+e.g. <br/>
+<a href="/api/cluster/tcc-bj5-dks-monit-01/offlineLogs/download?hostIP=10.0.0.1&path=app/dmall/bbcc/172.16.0.1:8080">/api/cluster/tcc-bj5-dks-monit-01/offlineLogs/download?hostIP=10.0.0.1&path=app/dmall/bbcc/172.16.0.1:8080</a><br/>
+`
+
+// ListTerminalSessionsDesc ...
+var ListTerminalSessionsDesc = `
+List the recorded terminal sessions, the newest first. <br/>
+name: url param, the unique cluster name and all. <br/>
+user: query string, the user of the session. <br/>
+namespace: query string, namespace name. <br/>
+pod: query string, the pod name. <br/>
+start: query string, unix timestamp. <br/>
+end: query string, unix timestamp. <br/>
+limit: query string, the limit number, default is 100. <br/>
+<br/>
+e.g. <br/>
+<a href="/api/cluster/all/terminal/sessions?user=alice">/api/cluster/all/terminal/sessions?user=alice</a><br/>
+`
+
+// GetTerminalSessionDesc ...
+var GetTerminalSessionDesc = `
+Get the metadata of a recorded terminal session. <br/>
+name: url param, the unique cluster name. <br/>
+sessionID: url param, the id of the session. <br/>
+`
+
+// GetTerminalSessionCastDesc ...
+var GetTerminalSessionCastDesc = `
+Get the recording of a terminal session in asciicast v2 for the replay by the asciinema player. <br/>
+name: url param, the unique cluster name. <br/>
+sessionID: url param, the id of the session. <br/>
+download: query string, download as a file if true. <br/>
+`
@@ -5,6 +5,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"gitlab.dmall.com/arch/sym-admin/pkg/apimanager/model"
+	k8smanager "gitlab.dmall.com/arch/sym-admin/pkg/k8s/manager"
 )
 
 // Defined error code
@@ -32,6 +33,14 @@ const (
 	RequestK8sExecError     = 5003
 	ExecCmdError            = 5004
 	CreateSPDYExecutorError = 5005
+	GetTerminalSessionError = 5006
+	ExecDeniedError         = 5007
+	RecordTerminalError     = 5008
+
+	// OfflineLogError
+	GetOfflineLogError    = 6001
+	OfflineLogPathError   = 6002
+	GetOfflineLogPodError = 6003
 
 	// OtherError
 	GetClusterError       = 9001
@@ -42,10 +51,17 @@ const (
 	CreateHelmClientError = 9006
 	GetHelmReleasesError  = 9007
 	GetConfigMapError     = 9008
+	ClusterMaintainError  = 9009
 )
 
 // AbortHTTPError ...
 func AbortHTTPError(c *gin.Context, code int, msg string, err error) {
+	AbortHTTPErrorWithStatus(c, http.StatusBadRequest, code, msg, err)
+}
+
+// AbortHTTPErrorWithStatus aborts with the error of the http status, e.g. 403 of the
+// commands denied.
+func AbortHTTPErrorWithStatus(c *gin.Context, status, code int, msg string, err error) {
 	result := &model.ErrorResponse{
 		Code:    code,
 		Success: false,
@@ -54,5 +70,16 @@ func AbortHTTPError(c *gin.Context, code int, msg string, err error) {
 	if err != nil {
 		result.Error = err.Error()
 	}
-	c.AbortWithStatusJSON(http.StatusBadRequest, result)
+	c.AbortWithStatusJSON(status, result)
+}
+
+// AbortIfMaintaining refuses the destructive operation when the cluster is under maintenance.
+func AbortIfMaintaining(c *gin.Context, cluster *k8smanager.Cluster) bool {
+	err := cluster.CheckMaintenance()
+	if err == nil {
+		return false
+	}
+
+	AbortHTTPError(c, ClusterMaintainError, "cluster is under maintenance", err)
+	return true
 }
@@ -3,12 +3,16 @@ package v1
 import (
 	"context"
 	"errors"
+	"fmt"
 	"io/ioutil"
 	"net/http"
 	"strconv"
 	"strings"
 
 	"github.com/gin-gonic/gin"
+	"gitlab.dmall.com/arch/sym-admin/pkg/execpolicy"
+	"gitlab.dmall.com/arch/sym-admin/pkg/logstream"
+	"gitlab.dmall.com/arch/sym-admin/pkg/offlinelog"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes/scheme"
@@ -20,36 +24,20 @@ func (m *Manager) HandleLogs(c *gin.Context) {
 	clusterName := c.Param("name")
 	podName := c.Param("podName")
 	namespace := c.Param("namespace")
-	container := c.DefaultQuery("container", "")
-	tailLines, _ := strconv.ParseInt(c.DefaultQuery("tail", "1000"), 10, 64)
-	// limitBytes, _ := strconv.ParseInt(c.DefaultQuery("limitBytes", "2048"), 10, 64)
-	follow, _ := strconv.ParseBool(c.DefaultQuery("follow", "false"))
-	previous, _ := strconv.ParseBool(c.DefaultQuery("previous", "false"))
-	timestamps, _ := strconv.ParseBool(c.DefaultQuery("timestamps", "false"))
-	// sinceSecond, _ := strconv.ParseInt(c.DefaultQuery("sinceSecond", "1"), 10, 64)
-
-	logOptions := &corev1.PodLogOptions{
-		Follow:     follow,
-		Previous:   previous,
-		Timestamps: timestamps,
-		TailLines:  &tailLines,
-		// LimitBytes: &limitBytes,
-	}
-
-	// sinceTime := metav1.Time{}
-	// sinceTimeStr, ok := c.GetQuery("sinceTime")
-	// if ok {
-	// 	parseTime, err := time.Parse("", sinceTimeStr)
-	// 	sinceTime.Time = parseTime
-	// 	logOptions.SinceTime = &sinceTime
-	// 	if err != nil {
-	// 		AbortHTTPError(c, ParseTimeStampError, "", err)
-	// 		return
-	// 	}
-	// }
-	// else {
-	// 	logOptions.SinceSeconds = &sinceSecond
-	// }
+
+	opts, err := logstream.ParseOptions(c.Request.URL.Query())
+	if err != nil {
+		AbortHTTPError(c, ParamInvalidError, "", err)
+		return
+	}
+	if opts.Follow {
+		AbortHTTPError(c, ParamInvalidError, "", errors.New("follow is not supported, use the logs/stream api"))
+		return
+	}
+	if opts.TailLines == nil {
+		tailLines := int64(1000)
+		opts.TailLines = &tailLines
+	}
 
 	cluster, err := m.ClustersMgr.Get(clusterName)
 	if err != nil {
@@ -69,11 +57,11 @@ func (m *Manager) HandleLogs(c *gin.Context) {
 		return
 	}
 
+	container := opts.Container
 	if len(container) == 0 {
-		logOptions.Container = pod.Spec.Containers[0].Name
-	} else {
-		logOptions.Container = container
+		container = pod.Spec.Containers[0].Name
 	}
+	logOptions := opts.PodLogOptions(container)
 
 	req, err := cluster.KubeCli.CoreV1().RESTClient().Get().
 		Namespace(namespace).
@@ -108,7 +96,11 @@ func (m *Manager) HandleFileLogs(c *gin.Context) {
 	clusterName := c.Param("name")
 	namespace := c.Param("namespace")
 	podName := c.Param("podName")
-	tailLines := c.DefaultQuery("tailLines", "1000")
+	tailLines, err := strconv.Atoi(c.DefaultQuery("tailLines", "1000"))
+	if err != nil || tailLines <= 0 || tailLines > offlinelog.MaxLines {
+		AbortHTTPError(c, ParamInvalidError, "", fmt.Errorf("tailLines must be 1-%d", offlinelog.MaxLines))
+		return
+	}
 
 	containerName, ok := c.GetQuery("container")
 	if !ok {
@@ -128,8 +120,18 @@ func (m *Manager) HandleFileLogs(c *gin.Context) {
 		return
 	}
 
-	cmd := "tail -n " + tailLines + " " + filepath
-	result, err := RunCmdOnceInContainer(cluster, namespace, podName, containerName, cmd, false)
+	run := func(argv []string) ([]byte, error) {
+		return RunCmdOnceInContainer(cluster, namespace, podName, containerName, argv, false)
+	}
+	file, err := m.ExecPolicy.ResolveLogPath(filepath, run)
+	if execpolicy.IsDenied(err) {
+		AbortHTTPErrorWithStatus(c, http.StatusForbidden, ExecDeniedError, "path denied", err)
+		return
+	}
+	var result []byte
+	if err == nil {
+		result, err = run([]string{"tail", "-n", strconv.Itoa(tailLines), "--", file})
+	}
 	if err != nil {
 		klog.Errorf("run cmd once in container error: %v", err)
 		c.IndentedJSON(http.StatusBadRequest, gin.H{
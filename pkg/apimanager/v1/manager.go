@@ -1,6 +1,8 @@
 package v1
 
 import (
+	"gitlab.dmall.com/arch/sym-admin/pkg/controllers/offlinepod"
+	"gitlab.dmall.com/arch/sym-admin/pkg/execpolicy"
 	k8smanager "gitlab.dmall.com/arch/sym-admin/pkg/k8s/manager"
 )
 
@@ -8,4 +10,13 @@ import (
 type Manager struct {
 	Cluster     k8smanager.CustomizedCluster
 	ClustersMgr *k8smanager.ClusterManager
+	Terminal    TerminalOptions
+	// ExecPolicy decides the commands exec in the containers, nil allows all
+	ExecPolicy *execpolicy.Policy
+	// OfflinePods is the store of the offline pod records
+	OfflinePods offlinepod.Store
+	// OfflineSnapshots is the archive of the offline pod snapshots
+	OfflineSnapshots *offlinepod.Archive
+	// LogAgentImage is the image of the log agent deployed with the offline-workload DaemonSet
+	LogAgentImage string
 }
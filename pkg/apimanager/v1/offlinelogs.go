@@ -0,0 +1,336 @@
+package v1
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	pkgerrors "github.com/pkg/errors"
+	k8smanager "gitlab.dmall.com/arch/sym-admin/pkg/k8s/manager"
+	"gitlab.dmall.com/arch/sym-admin/pkg/offlinelog"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/util/exec"
+	"k8s.io/klog"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// offlineLogReadTimeout bounds the reads and the greps of the offline logs
+	offlineLogReadTimeout = time.Minute
+	// offlineLogDownloadTimeout bounds the downloads of the offline logs
+	offlineLogDownloadTimeout = 30 * time.Minute
+)
+
+// offlineLogExec runs the commands in the offline-workload pod of a node.
+type offlineLogExec struct {
+	cluster *k8smanager.Cluster
+	pod     *corev1.Pod
+}
+
+func (e *offlineLogExec) run(cmd []string) (string, error) {
+	out, err := e.cluster.ExecCommand(e.pod.Namespace, e.pod.Name, offlinelog.ContainerName, cmd)
+	return string(out), err
+}
+
+// resolve confines the path to the log root with the symlinks resolved and reports
+// whether it is a directory.
+func (e *offlineLogExec) resolve(p string) (string, bool, error) {
+	p, err := offlinelog.Clean(p)
+	if err != nil {
+		return "", false, err
+	}
+	out, err := e.run(offlinelog.ResolveCommand(p))
+	if err != nil {
+		return "", false, fmt.Errorf("path: %s not found", p)
+	}
+	resolved := strings.TrimSpace(out)
+	if !offlinelog.Contains(resolved) {
+		return "", false, fmt.Errorf("path: %s is out of %s", p, offlinelog.Root)
+	}
+	stat, err := e.run(offlinelog.StatCommand(resolved))
+	if err != nil {
+		return "", false, err
+	}
+	return resolved, offlinelog.IsDir(stat), nil
+}
+
+// exitStatus returns the exit status of the command, -1 if it is not exited.
+func exitStatus(err error) int {
+	if exitErr, ok := pkgerrors.Cause(err).(exec.ExitError); ok {
+		return exitErr.ExitStatus()
+	}
+	return -1
+}
+
+// getOfflineLogExec finds the offline-workload pod of the node of the query hostIP.
+func (m *Manager) getOfflineLogExec(c *gin.Context) (*offlineLogExec, bool) {
+	hostIP, ok := c.GetQuery("hostIP")
+	if !ok {
+		AbortHTTPError(c, ParamInvalidError, "", errors.New("can not get hostIP"))
+		return nil, false
+	}
+
+	cluster, err := m.ClustersMgr.Get(c.Param("name"))
+	if err != nil {
+		klog.Errorf("get cluster error: %+v", err)
+		AbortHTTPError(c, GetClusterError, "", err)
+		return nil, false
+	}
+
+	pods := &corev1.PodList{}
+	err = cluster.Client.List(context.Background(), pods, &client.ListOptions{
+		Namespace:     offlinelog.Namespace,
+		LabelSelector: labels.SelectorFromSet(offlinelog.Labels()),
+	})
+	if err != nil {
+		klog.Errorf("list offline workload pods error: %v", err)
+		AbortHTTPError(c, GetOfflineLogPodError, "", err)
+		return nil, false
+	}
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if pod.Status.HostIP == hostIP && pod.Status.Phase == corev1.PodRunning {
+			return &offlineLogExec{cluster: cluster, pod: pod}, true
+		}
+	}
+	AbortHTTPError(c, GetOfflineLogPodError, "", fmt.Errorf("no offline workload pod running on the node: %s", hostIP))
+	return nil, false
+}
+
+// GetOfflineLogFiles lists the log files of an offline pod in both layouts
+// /api/cluster/:name/offlineLogs/files?hostIP=&podIP=&projectCode=&appCode=&appName=&containerID=
+func (m *Manager) GetOfflineLogFiles(c *gin.Context) {
+	target := &offlinelog.Target{
+		ProjectCode: c.Query("projectCode"),
+		AppCode:     c.Query("appCode"),
+		AppName:     c.Query("appName"),
+		PodIP:       c.Query("podIP"),
+		ContainerID: c.Query("containerID"),
+	}
+	if err := target.Validate(); err != nil {
+		AbortHTTPError(c, ParamInvalidError, "", err)
+		return
+	}
+
+	e, ok := m.getOfflineLogExec(c)
+	if !ok {
+		return
+	}
+
+	dirs := make([]*offlinelog.Dir, 0)
+	for _, candidate := range target.Candidates() {
+		out, err := e.run(candidate.FindDirsCommand())
+		if err != nil {
+			// the app has no logs in the layout
+			klog.V(4).Infof("find offline log dirs in %s error: %v", candidate.Dir, err)
+			continue
+		}
+		for _, dir := range strings.Split(strings.TrimSpace(out), "\n") {
+			if dir == "" {
+				continue
+			}
+			files, err := e.run(offlinelog.ListFilesCommand(dir))
+			if err != nil {
+				klog.Errorf("list offline log files in %s error: %v", dir, err)
+				AbortHTTPError(c, GetOfflineLogError, "", err)
+				return
+			}
+			dirs = append(dirs, &offlinelog.Dir{
+				Layout: candidate.Layout,
+				Path:   dir,
+				Files:  offlinelog.ParseFiles(dir, files),
+			})
+		}
+	}
+
+	c.IndentedJSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": nil,
+		"resultMap": gin.H{
+			"dirs": dirs,
+		},
+	})
+}
+
+// ReadOfflineLogFile reads the head, the tail or a byte range of a log file
+// /api/cluster/:name/offlineLogs/file?hostIP=&path=&mode=tail|head|range&lines=&offset=&length=
+func (m *Manager) ReadOfflineLogFile(c *gin.Context) {
+	mode := c.DefaultQuery("mode", "tail")
+	lines, _ := strconv.Atoi(c.DefaultQuery("lines", "1000"))
+	offset, _ := strconv.ParseInt(c.DefaultQuery("offset", "0"), 10, 64)
+	length, _ := strconv.ParseInt(c.DefaultQuery("length", strconv.Itoa(offlinelog.MaxBytes)), 10, 64)
+	if mode != "tail" && mode != "head" && mode != "range" {
+		AbortHTTPError(c, ParamInvalidError, "", fmt.Errorf("invalid mode: %s", mode))
+		return
+	}
+
+	e, ok := m.getOfflineLogExec(c)
+	if !ok {
+		return
+	}
+	p, isDir, err := e.resolve(c.Query("path"))
+	if err != nil {
+		AbortHTTPError(c, OfflineLogPathError, "", err)
+		return
+	}
+	if isDir {
+		AbortHTTPError(c, OfflineLogPathError, "", fmt.Errorf("path: %s is a directory", p))
+		return
+	}
+
+	var cmd []string
+	switch mode {
+	case "head":
+		cmd = offlinelog.HeadCommand(p, lines)
+	case "tail":
+		cmd = offlinelog.TailCommand(p, lines)
+	case "range":
+		cmd = offlinelog.RangeCommand(p, offset, length)
+	}
+	// the command is stopped once the content is truncated
+	ctx, cancel := context.WithTimeout(c.Request.Context(), offlineLogReadTimeout)
+	defer cancel()
+	out := &offlinelog.LimitWriter{Max: offlinelog.MaxBytes, Cancel: cancel}
+	if err := e.cluster.ExecStream(ctx, e.pod.Namespace, e.pod.Name, offlinelog.ContainerName, cmd, out); err != nil && !out.Truncated {
+		klog.Errorf("read offline log %s error: %v", p, err)
+		AbortHTTPError(c, GetOfflineLogError, "", err)
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": nil,
+		"resultMap": gin.H{
+			"path":      p,
+			"content":   out.String(),
+			"truncated": out.Truncated,
+		},
+	})
+}
+
+// GrepOfflineLogs searches a log file or the files under a log directory
+// /api/cluster/:name/offlineLogs/grep?hostIP=&path=&pattern=&ignoreCase=&regexp=&maxCount=&limit=
+func (m *Manager) GrepOfflineLogs(c *gin.Context) {
+	pattern := c.Query("pattern")
+	if pattern == "" {
+		AbortHTTPError(c, ParamInvalidError, "", errors.New("can not get pattern"))
+		return
+	}
+	opt := &offlinelog.GrepOptions{}
+	opt.IgnoreCase, _ = strconv.ParseBool(c.DefaultQuery("ignoreCase", "false"))
+	opt.Regexp, _ = strconv.ParseBool(c.DefaultQuery("regexp", "false"))
+	opt.MaxCount, _ = strconv.Atoi(c.DefaultQuery("maxCount", "1000"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "1000"))
+	if limit <= 0 || limit > offlinelog.MaxLines {
+		limit = offlinelog.MaxLines
+	}
+
+	e, ok := m.getOfflineLogExec(c)
+	if !ok {
+		return
+	}
+	p, _, err := e.resolve(c.Query("path"))
+	if err != nil {
+		AbortHTTPError(c, OfflineLogPathError, "", err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), offlineLogReadTimeout)
+	defer cancel()
+	out := &offlinelog.LimitWriter{Max: offlinelog.MaxBytes, Cancel: cancel}
+	err = e.cluster.ExecStream(ctx, e.pod.Namespace, e.pod.Name, offlinelog.ContainerName, offlinelog.GrepCommand(p, pattern, opt), out)
+	// grep exits 1 when nothing matched, and is stopped once the result is truncated
+	if err != nil && !out.Truncated && exitStatus(err) != 1 {
+		klog.Errorf("grep offline log %s error: %v", p, err)
+		AbortHTTPError(c, GetOfflineLogError, "", err)
+		return
+	}
+
+	result := make([]string, 0)
+	truncated := out.Truncated
+	if s := strings.TrimRight(out.String(), "\n"); s != "" {
+		result = strings.Split(s, "\n")
+	}
+	if out.Truncated && len(result) > 0 {
+		// the last line is cut by the bound
+		result = result[:len(result)-1]
+	}
+	if len(result) > limit {
+		result = result[:limit]
+		truncated = true
+	}
+
+	c.IndentedJSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": nil,
+		"resultMap": gin.H{
+			"path":      p,
+			"result":    result,
+			"truncated": truncated,
+		},
+	})
+}
+
+// downloadWriter writes the headers of the attachment with the first bytes, the errors
+// before are still reported as json.
+type downloadWriter struct {
+	c           *gin.Context
+	filename    string
+	contentType string
+	written     int64
+}
+
+func (w *downloadWriter) Write(p []byte) (int, error) {
+	if w.written == 0 {
+		w.c.Header("Content-Type", w.contentType)
+		w.c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", w.filename))
+		w.c.Status(http.StatusOK)
+	}
+	n, err := w.c.Writer.Write(p)
+	w.written += int64(n)
+	return n, err
+}
+
+// DownloadOfflineLogs downloads a log file, or a tar.gz of a log directory
+// /api/cluster/:name/offlineLogs/download?hostIP=&path=
+func (m *Manager) DownloadOfflineLogs(c *gin.Context) {
+	e, ok := m.getOfflineLogExec(c)
+	if !ok {
+		return
+	}
+	p, isDir, err := e.resolve(c.Query("path"))
+	if err != nil {
+		AbortHTTPError(c, OfflineLogPathError, "", err)
+		return
+	}
+
+	w := &downloadWriter{c: c, filename: path.Base(p), contentType: "application/octet-stream"}
+	cmd := offlinelog.CatCommand(p)
+	if isDir {
+		w.filename += ".tar.gz"
+		w.contentType = "application/gzip"
+		cmd = offlinelog.TarCommand(p)
+	}
+
+	// the download is stopped when the client goes away
+	ctx, cancel := context.WithTimeout(c.Request.Context(), offlineLogDownloadTimeout)
+	defer cancel()
+	if err := e.cluster.ExecStream(ctx, e.pod.Namespace, e.pod.Name, offlinelog.ContainerName, cmd, w); err != nil {
+		klog.Errorf("download offline log %s error: %v", p, err)
+		if w.written == 0 {
+			AbortHTTPError(c, GetOfflineLogError, "", err)
+		}
+		return
+	}
+	if w.written == 0 {
+		// empty file
+		w.Write(nil)
+	}
+}
@@ -2,17 +2,13 @@ package v1
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
-	"gitlab.dmall.com/arch/sym-admin/pkg/apimanager/model"
-	corev1 "k8s.io/api/core/v1"
-	"k8s.io/apimachinery/pkg/labels"
-	"k8s.io/apimachinery/pkg/types"
+	"gitlab.dmall.com/arch/sym-admin/pkg/controllers/offlinepod"
 	"k8s.io/klog"
-	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 // default const
@@ -20,22 +16,18 @@ const (
 	DefaultNameSpace string = "sym-admin"
 )
 
+// default offline pods page size
+const (
+	defaultOfflinePodLimit = 50
+	maxOfflinePodLimit     = 500
+)
+
 // GetAllOfflineApp ...
+// /api/offlinePodAppList/all?namespace=
 func (m *Manager) GetAllOfflineApp(c *gin.Context) {
-	lb := labels.Set{
-		"controllerOwner": "offlinePod",
-	}
-
-	listOptions := &client.ListOptions{Namespace: DefaultNameSpace, LabelSelector: lb.AsSelector()}
-
-	offlineApp := make([]string, 0, 10)
-	ctx := context.Background()
-
-	cmlist := &corev1.ConfigMapList{}
-	client := m.ClustersMgr.MasterClient.GetClient()
-	err := client.List(ctx, cmlist, listOptions)
-
+	offlineApp, err := m.OfflinePods.Apps(context.Background(), c.Query("namespace"))
 	if err != nil {
+		klog.Errorf("list offline apps error: %v", err)
 		c.IndentedJSON(GetConfigMapError, gin.H{
 			"success":   false,
 			"message":   "can not find offlineApp",
@@ -43,9 +35,6 @@ func (m *Manager) GetAllOfflineApp(c *gin.Context) {
 		})
 		return
 	}
-	for _, cm := range cmlist.Items {
-		offlineApp = append(offlineApp, cm.Name)
-	}
 	c.IndentedJSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": nil,
@@ -55,38 +44,39 @@ func (m *Manager) GetAllOfflineApp(c *gin.Context) {
 	})
 }
 
-// GetOfflinePods ...
-// /api/namespace/:namespace/appname/:appname/offlinepodlist
+// GetOfflinePods pages through the offline pods of the app, newest first.
+// /api/namespace/:namespace/appname/:appname/offlinepodlist?clusterCode=&nodeName=&limit=&continue=
 func (m *Manager) GetOfflinePods(c *gin.Context) {
 	namespace := c.Param("namespace")
-	cmname := c.Param("appname")
-	apps := []*model.OfflinePod{}
-	client := m.ClustersMgr.MasterClient.GetClient()
-	ctx := context.Background()
-	cm := &corev1.ConfigMap{}
-
-	err := client.Get(ctx, types.NamespacedName{Namespace: namespace, Name: cmname}, cm)
-
-	if err != nil {
-		klog.Errorf("get app error %v: ", err)
-		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{
-			"success":   false,
-			"message":   err.Error(),
-			"resultMap": nil,
-		})
+	appName := c.Param("appname")
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultOfflinePodLimit)))
+	if err != nil || limit <= 0 {
+		AbortHTTPError(c, ParamInvalidError, "", fmt.Errorf("invalid limit: %s", c.Query("limit")))
 		return
 	}
+	if limit > maxOfflinePodLimit {
+		limit = maxOfflinePodLimit
+	}
 
-	raw, ok := cm.Data["offlineList"]
-	if !ok {
-		klog.Info("no applist ")
+	ctx := context.Background()
+	page, err := m.OfflinePods.List(ctx, &offlinepod.Query{
+		Namespace:   namespace,
+		AppName:     appName,
+		ClusterName: c.Query("clusterCode"),
+		NodeName:    c.Query("nodeName"),
+		Limit:       limit,
+		Continue:    c.Query("continue"),
+	})
+	if err != nil {
+		klog.Errorf("list offline pods error: %v", err)
+		AbortHTTPError(c, GetConfigMapError, "", err)
 		return
 	}
-	fmt.Println(raw)
-	jerr := json.Unmarshal([]byte(raw), &apps)
-	if jerr != nil {
-		klog.Errorf("failed to Unmarshal err: %v", jerr)
-		AbortHTTPError(c, http.StatusNotFound, "", jerr)
+
+	snapshots, err := m.OfflineSnapshots.List(ctx, namespace, appName)
+	if err != nil {
+		klog.Errorf("list offline pod snapshots error: %v", err)
+		AbortHTTPError(c, GetConfigMapError, "", err)
 		return
 	}
 
@@ -94,8 +84,9 @@ func (m *Manager) GetOfflinePods(c *gin.Context) {
 		"success": true,
 		"message": nil,
 		"resultMap": gin.H{
-			"info": apps,
+			"info":      page.Items,
+			"continue":  page.Continue,
+			"snapshots": snapshots,
 		},
 	})
-
 }
@@ -9,6 +9,9 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"gitlab.dmall.com/arch/sym-admin/pkg/apimanager/model"
+	"gitlab.dmall.com/arch/sym-admin/pkg/audit"
+	k8smanager "gitlab.dmall.com/arch/sym-admin/pkg/k8s/manager"
+	"gitlab.dmall.com/arch/sym-admin/pkg/router"
 	"gitlab.dmall.com/arch/sym-admin/pkg/utils"
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
@@ -168,6 +171,9 @@ func (m *Manager) DeletePodByName(c *gin.Context) {
 		AbortHTTPError(c, GetClusterError, "", err)
 		return
 	}
+	if AbortIfMaintaining(c, cluster) {
+		return
+	}
 
 	ctx := context.Background()
 	pod := &corev1.Pod{}
@@ -181,6 +187,7 @@ func (m *Manager) DeletePodByName(c *gin.Context) {
 		return
 	}
 
+	m.ClustersMgr.BeforePodDelete(ctx, cluster, pod, router.UserName(c))
 	err = cluster.Client.Delete(ctx, pod)
 	if err != nil {
 		klog.Errorf("delete pod error: %v", err)
@@ -253,41 +260,28 @@ func (m *Manager) DeletePodByGroup(c *gin.Context) {
 	}
 
 	clusters := m.ClustersMgr.GetAll(clusterName)
-	ctx := context.Background()
-	options := labels.Set{}
-	if group != "" {
-		options["sym-group"] = group
-	}
-	if zone != "" {
-		options["sym-zone"] = zone
-	}
-	if ldcLabel != "" {
-		options["sym-ldc"] = ldcLabel
-	}
-	if appName != "all" {
-		options["app"] = appName
+	for _, cluster := range clusters {
+		if AbortIfMaintaining(c, cluster) {
+			return
+		}
 	}
-	listOptions := &client.ListOptions{Namespace: namespace, LabelSelector: options.AsSelector()}
+
+	ctx := context.Background()
+	options := k8smanager.PodGroupSelector(appName, group, zone, ldcLabel)
 
 	errorPods := []*corev1.Pod{}
 	for _, cluster := range clusters {
-		podList := &corev1.PodList{}
-		err := cluster.Client.List(ctx, podList, listOptions)
+		cluster := cluster
+		failed, err := k8smanager.DeletePodsBySelector(ctx, cluster.Client, namespace, options, func(pod *corev1.Pod) {
+			m.ClustersMgr.BeforePodDelete(ctx, cluster, pod, router.UserName(c))
+			audit.AddObjects(c, &audit.ObjectRef{Cluster: cluster.Name, Kind: "Pod", Namespace: pod.Namespace, Name: pod.Name})
+		})
 		if err != nil {
 			klog.Errorf("get pods error: %v", err)
 			AbortHTTPError(c, GetPodError, "", err)
 			return
 		}
-
-		for i := range podList.Items {
-			pod := &podList.Items[i]
-			err = cluster.Client.Delete(ctx, pod)
-			if err != nil {
-				klog.Errorf("delete pod error: %v", err)
-				errorPods = append(errorPods, pod)
-			}
-		}
-
+		errorPods = append(errorPods, failed...)
 	}
 	c.JSON(http.StatusOK, gin.H{
 		"success":   true,
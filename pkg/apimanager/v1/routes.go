@@ -25,6 +25,7 @@ func (m *Manager) Routes() []*router.Route {
 			Method:  "GET",
 			Path:    "/api/cluster/:name/appPods/labels",
 			Handler: m.GetPodByLabels,
+			Queries: &router.Queries{Namespace: "namespace", App: "appName"},
 			Desc:    GetPodByLabelsDesc,
 		},
 		{
@@ -49,18 +50,21 @@ func (m *Manager) Routes() []*router.Route {
 			Method:  "POST",
 			Path:    "/api/linthelm",
 			Handler: m.LintHelmTemplate,
+			Verb:    router.VerbRead,
 			Desc:    GetHelmReleaseInfoDesc,
 		},
 		{
 			Method:  "POST",
 			Path:    "/api/cluster/:name/namespace/:namespace/app/:appName/restart",
 			Handler: m.DeletePodByGroup,
+			Verb:    router.VerbRestart,
 			Desc:    DeletePodByGroupDesc,
 		},
 		{
 			Method:  "DELETE",
 			Path:    "/api/cluster/:name/namespace/:namespace/pod/:podName",
 			Handler: m.DeletePodByName,
+			Verb:    router.VerbDelete,
 			Desc:    DeletePodByNameDesc,
 		},
 		{
@@ -79,12 +83,38 @@ func (m *Manager) Routes() []*router.Route {
 			Method:  "GET",
 			Path:    "/api/cluster/:name/terminal",
 			Handler: m.GetTerminal,
+			Queries: &router.Queries{Namespace: "namespace", DefaultNamespace: "default", Pod: "pod"},
+			Verb:    router.VerbExec,
 			Desc:    GetTerminalDesc,
 		},
+		{
+			Method:  "GET",
+			Path:    "/api/cluster/:name/terminal/sessions",
+			Handler: m.ListTerminalSessions,
+			Queries: &router.Queries{Namespace: "namespace", Pod: "pod"},
+			Verb:    router.VerbAdmin,
+			Desc:    ListTerminalSessionsDesc,
+		},
+		{
+			Method:  "GET",
+			Path:    "/api/cluster/:name/terminal/sessions/:sessionID",
+			Handler: m.GetTerminalSession,
+			Verb:    router.VerbAdmin,
+			Desc:    GetTerminalSessionDesc,
+		},
+		{
+			Method:  "GET",
+			Path:    "/api/cluster/:name/terminal/sessions/:sessionID/cast",
+			Handler: m.GetTerminalSessionCast,
+			Verb:    router.VerbAdmin,
+			Desc:    GetTerminalSessionCastDesc,
+		},
 		{
 			Method:  "GET",
 			Path:    "/api/cluster/:name/exec",
 			Handler: m.ExecOnceWithHTTP,
+			Queries: &router.Queries{Namespace: "namespace", DefaultNamespace: "default", Pod: "pod"},
+			Verb:    router.VerbExec,
 			Desc:    ExecOnceWithHTTPDesc,
 		},
 		{
@@ -115,6 +145,7 @@ func (m *Manager) Routes() []*router.Route {
 			Method:  "GET",
 			Path:    "/api/cluster/:name/deployments/stat",
 			Handler: m.GetDeploymentsStat,
+			Queries: &router.Queries{Namespace: "namespace", App: "appName"},
 			Desc:    GetDeploymentsStatDesc,
 		},
 		{
@@ -127,13 +158,16 @@ func (m *Manager) Routes() []*router.Route {
 			Method:  "GET",
 			Path:    "/api/cluster/:name/namespace/:namespace/events/warning",
 			Handler: m.GetWarningEvents,
+			Queries: &router.Queries{App: "appName"},
 			Desc:    GetWarningEventsDesc,
 		},
 		{
 			Method:  "GET",
 			Path:    "/api/pod/logfiles",
 			Handler: m.GetFiles,
+			Queries: &router.Queries{Cluster: "clusterCode", Namespace: "namespace", App: "appName", Pod: "podName"},
 			Desc:    GetFilesDesc,
+			Audit:   router.AuditAlways,
 		},
 		{
 			Method:  "GET",
@@ -146,26 +180,60 @@ func (m *Manager) Routes() []*router.Route {
 			Path:    "/api/cluster/:name/namespace/:namespace/pods/:podName/logs/file",
 			Handler: m.HandleFileLogs,
 			Desc:    HandleFileLogsDesc,
+			Audit:   router.AuditAlways,
 		},
 		{
 			Method:  "GET",
 			Path:    "/api/cluster/:name/offlineWorkloadDeploy",
 			Handler: m.HandleOfflineWorkloadDeploy,
+			Verb:    router.VerbAdmin,
 		},
 		{
 			Method:  "GET",
 			Path:    "/api/offlinePodAppList/all",
 			Handler: m.GetAllOfflineApp,
+			Queries: &router.Queries{Namespace: "namespace"},
 		},
 		{
 			Method:  "GET",
 			Path:    "/api/namespace/:namespace/appname/:appname/offlinepodlist",
 			Handler: m.GetOfflinePods,
+			Queries: &router.Queries{Cluster: "clusterCode"},
 		},
 		{
 			Method:  "GET",
 			Path:    "/api/cluster/:name/offlineWorkloadPod/terminal",
 			Handler: m.GetOfflineLogTerminal,
+			Verb:    router.VerbExec,
+		},
+		{
+			Method:  "GET",
+			Path:    "/api/cluster/:name/offlineLogs/files",
+			Handler: m.GetOfflineLogFiles,
+			Queries: &router.Queries{App: "appName"},
+			Desc:    GetOfflineLogFilesDesc,
+			Audit:   router.AuditAlways,
+		},
+		{
+			Method:  "GET",
+			Path:    "/api/cluster/:name/offlineLogs/file",
+			Handler: m.ReadOfflineLogFile,
+			Desc:    ReadOfflineLogFileDesc,
+			Audit:   router.AuditAlways,
+		},
+		{
+			Method:  "GET",
+			Path:    "/api/cluster/:name/offlineLogs/grep",
+			Handler: m.GrepOfflineLogs,
+			Desc:    GrepOfflineLogsDesc,
+			Audit:   router.AuditAlways,
+		},
+		{
+			Method:  "GET",
+			Path:    "/api/cluster/:name/offlineLogs/download",
+			Handler: m.DownloadOfflineLogs,
+			Desc:    DownloadOfflineLogsDesc,
+			Audit:   router.AuditAlways,
 		},
 		{
 			Method:  "GET",
@@ -16,7 +16,10 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
+	"gitlab.dmall.com/arch/sym-admin/pkg/execpolicy"
 	k8smanager "gitlab.dmall.com/arch/sym-admin/pkg/k8s/manager"
+	"gitlab.dmall.com/arch/sym-admin/pkg/offlinelog"
+	"gitlab.dmall.com/arch/sym-admin/pkg/router"
 	core_v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -29,9 +32,7 @@ import (
 var wsMap sync.Map
 
 var wsUpgrader = websocket.Upgrader{
-	CheckOrigin: func(r *http.Request) bool {
-		return true
-	},
+	CheckOrigin: router.CheckOrigin,
 }
 
 // WsMessage ...
@@ -46,14 +47,14 @@ type WsConnection struct {
 	conn      *websocket.Conn
 	inChan    chan *WsMessage
 	outChan   chan *WsMessage
-	mutex     sync.Mutex
-	isClosed  bool
+	closeOnce sync.Once
 	closeChan chan byte
 }
 
 type streamHandler struct {
 	ws          *WsConnection
 	resizeEvent chan remotecommand.TerminalSize
+	session     *termSession
 }
 
 type xtermMessage struct {
@@ -86,30 +87,76 @@ func (m *Manager) GetTerminal(c *gin.Context) {
 		return
 	}
 
+	// the shell opened without the command is checked too
+	if len(cmd) == 0 && !once {
+		cmd = []string{"/bin/sh"}
+	}
+	if !m.allowExec(c, cmd) {
+		return
+	}
+
 	cluster, err := m.ClustersMgr.Get(clusterName)
 	if err != nil {
 		klog.Errorf("get cluster error: %+v", err)
 		AbortHTTPError(c, GetClusterError, "", err)
 		return
 	}
+	if AbortIfMaintaining(c, cluster) {
+		return
+	}
+
+	// the session is refused before the upgrade without the recording
+	session, err := m.newTermSession(c, clusterName, namespace, podName, containerName, cmd)
+	if err != nil {
+		AbortHTTPErrorWithStatus(c, http.StatusInternalServerError, RecordTerminalError, "", err)
+		return
+	}
 
 	rand.Seed(time.Now().UnixNano())
 	ws, err := InitWebsocket(c.Writer, c.Request, rand.Uint32())
 	if err != nil {
 		klog.Errorf("init websocket conn error: %+v", err)
+		session.finish(err)
 		AbortHTTPError(c, WebsocketError, "", err)
 		return
 	}
 	defer ws.Close()
 
+	go session.watch(ws)
 	err = startProcess(cluster, namespace, podName, containerName,
-		cmd, isStdin, isStdout, isStderr, tty, once, ws)
+		cmd, isStdin, isStdout, isStderr, tty, once, ws, session)
+	session.finish(err)
+	// the websocket may be closed by the session limits, the close is left to the defer
 	if err != nil {
 		ws.Write(websocket.BinaryMessage, []byte(err.Error()+". "))
-		ws.closeChan <- 0
 	}
 }
 
+// allowExec aborts with 403 when the exec policy denies the command to the user.
+func (m *Manager) allowExec(c *gin.Context, argv []string) bool {
+	var name string
+	var groups []string
+	if u := router.GetUser(c); u != nil {
+		name, groups = u.Name, u.Groups
+	}
+	err := m.ExecPolicy.Allow(name, groups, argv)
+	if err == nil {
+		return true
+	}
+	klog.Warningf("exec %q in pod %s/%s denied: %v", argv, c.Param("name"), c.Query("pod"), err)
+	AbortHTTPErrorWithStatus(c, http.StatusForbidden, ExecDeniedError, "command denied", err)
+	return false
+}
+
+// execArgs returns the arguments of the cmd queries, a single command line is split
+// like a shell without the expansion.
+func execArgs(cmds []string) ([]string, error) {
+	if len(cmds) == 1 {
+		return execpolicy.Split(cmds[0])
+	}
+	return cmds, nil
+}
+
 // ExecOnceWithHTTP ...
 func (m *Manager) ExecOnceWithHTTP(c *gin.Context) {
 	clusterName := c.Param("name")
@@ -121,11 +168,19 @@ func (m *Manager) ExecOnceWithHTTP(c *gin.Context) {
 		return
 	}
 
-	cmd, ok := c.GetQuery("cmd")
+	cmds, ok := c.GetQueryArray("cmd")
 	if !ok {
 		AbortHTTPError(c, ParamInvalidError, "", errors.New("no command to exec"))
 		return
 	}
+	cmd, err := execArgs(cmds)
+	if err != nil {
+		AbortHTTPError(c, ParamInvalidError, "", err)
+		return
+	}
+	if !m.allowExec(c, cmd) {
+		return
+	}
 
 	containerName, ok := c.GetQuery("container")
 	if !ok {
@@ -139,6 +194,9 @@ func (m *Manager) ExecOnceWithHTTP(c *gin.Context) {
 		AbortHTTPError(c, GetClusterError, "", err)
 		return
 	}
+	if AbortIfMaintaining(c, cluster) {
+		return
+	}
 
 	result, err := RunCmdOnceInContainer(cluster, namespace, podName, containerName, cmd, tty)
 	if err != nil {
@@ -150,6 +208,20 @@ func (m *Manager) ExecOnceWithHTTP(c *gin.Context) {
 	c.IndentedJSON(http.StatusOK, result)
 }
 
+// logDir returns the log directory of the elements under the root with a trailing slash, it
+// aborts with 403 if the elements lead out of the log roots.
+func (m *Manager) logDir(c *gin.Context, root string, elem ...string) (string, error) {
+	dir, err := m.ExecPolicy.LogPath(root + "/" + strings.Join(elem, "/"))
+	if err == nil && dir == root {
+		err = &execpolicy.DeniedError{Reason: fmt.Sprintf("invalid log directory of %q", elem)}
+	}
+	if err != nil {
+		AbortHTTPErrorWithStatus(c, http.StatusForbidden, ExecDeniedError, "path denied", err)
+		return "", err
+	}
+	return dir + "/", nil
+}
+
 // GetFiles get the log file of the specified directory
 func (m *Manager) GetFiles(c *gin.Context) {
 	clusterCode := c.Query("clusterCode")
@@ -193,10 +265,12 @@ func (m *Manager) GetFiles(c *gin.Context) {
 	}
 
 	// New logging rules: /web/logs/app/$projectCode/$appCode/$ip:$port/*.log
-	path := fmt.Sprintf("/web/logs/app/%s/%s/", projectCode, appCode)
-	cmd := "ls " + path
+	path, err := m.logDir(c, "/web/logs/app", projectCode, appCode)
+	if err != nil {
+		return
+	}
 	result, err := RunCmdOnceInContainer(
-		cluster, namespace, podName, containerName, cmd, false)
+		cluster, namespace, podName, containerName, []string{"ls", "--", path}, false)
 	if err != nil {
 		klog.Errorf("run cmd once in container error: %v", err)
 		c.IndentedJSON(http.StatusOK, gin.H{
@@ -221,9 +295,8 @@ func (m *Manager) GetFiles(c *gin.Context) {
 	}
 
 	if len(logDirectory) > 0 {
-		cmd += logDirectory
 		result, err = RunCmdOnceInContainer(
-			cluster, namespace, podName, containerName, cmd, false)
+			cluster, namespace, podName, containerName, []string{"ls", "--", path + logDirectory}, false)
 		if err != nil {
 			klog.Errorf("run cmd once in container error: %v", err)
 			c.IndentedJSON(http.StatusOK, gin.H{
@@ -255,10 +328,12 @@ func (m *Manager) GetFiles(c *gin.Context) {
 	}
 
 	// Old logging rules: /web/logs/app/logback/$appName/$podIP_$containerID/
-	path = fmt.Sprintf("/web/logs/app/logback/%s/", appName)
-	cmd = "ls " + path
+	path, err = m.logDir(c, "/web/logs/app/logback", appName)
+	if err != nil {
+		return
+	}
 	result, err = RunCmdOnceInContainer(
-		cluster, namespace, podName, containerName, cmd, false)
+		cluster, namespace, podName, containerName, []string{"ls", "--", path}, false)
 	if err != nil {
 		klog.Errorf("run cmd once in container error: %v", err)
 		c.IndentedJSON(http.StatusOK, gin.H{
@@ -281,9 +356,8 @@ func (m *Manager) GetFiles(c *gin.Context) {
 		}
 	}
 	if len(logDirectory) > 0 {
-		cmd += logDirectory
 		result, err = RunCmdOnceInContainer(
-			cluster, namespace, podName, containerName, cmd, false)
+			cluster, namespace, podName, containerName, []string{"ls", "--", path + logDirectory}, false)
 		if err != nil {
 			klog.Errorf("run cmd once in container error: %v", err)
 			c.IndentedJSON(http.StatusOK, gin.H{
@@ -328,7 +402,7 @@ func (m *Manager) GetFiles(c *gin.Context) {
 // GetOfflineLogTerminal ...
 func (m *Manager) GetOfflineLogTerminal(c *gin.Context) {
 	clusterName := c.Param("name")
-	namespace := "sym-admin"
+	namespace := offlinelog.Namespace
 
 	tty, _ := strconv.ParseBool(c.DefaultQuery("tty", "true"))
 	isStdin, _ := strconv.ParseBool(c.DefaultQuery("stdin", "true"))
@@ -338,9 +412,7 @@ func (m *Manager) GetOfflineLogTerminal(c *gin.Context) {
 
 	var podName, containerName string
 
-	lb := labels.Set{
-		"app": "offline-pod-log",
-	}
+	lb := labels.Set(offlinelog.Labels())
 
 	hostIP, ok := c.GetQuery("hostIP")
 	if !ok {
@@ -363,6 +435,36 @@ func (m *Manager) GetOfflineLogTerminal(c *gin.Context) {
 	}
 
 	path := fmt.Sprintf("/web/logs/app/%s/%s", projectCode, appCode)
+	//oldpath /web/logs/app/logback/$appName/$podIP_$containerID/
+	//newpath /web/logs/app/$projectName/$appName/$podIP:$Port
+	// the path is passed to the shell as $1 so it is never interpreted, the new path
+	// is the prefix of the directory
+	logshell := `cd -- "$1"; pwd && exec /bin/bash`
+	if offlinePodIP != "" {
+		if containerID == "" {
+			path = fmt.Sprintf("/web/logs/app/%s/%s/%s", projectCode, appCode, offlinePodIP)
+			logshell = `cd -- "$1"*; pwd && exec /bin/bash`
+		} else {
+			path = fmt.Sprintf("/web/logs/app/logback/%s/%s_%s", appCode, offlinePodIP, containerID)
+		}
+	}
+	path, err := m.ExecPolicy.LogPath(path)
+	if err != nil {
+		AbortHTTPErrorWithStatus(c, http.StatusForbidden, ExecDeniedError, "path denied", err)
+		return
+	}
+	// the wrapper is bash itself, so the argv checked is the one exec'd and the rules of
+	// /bin/bash decide the session
+	cmd := []string{
+		"/bin/bash",
+		"-c",
+		logshell,
+		"bash",
+		path,
+	}
+	if !m.allowExec(c, cmd) {
+		return
+	}
 
 	cluster, err := m.ClustersMgr.Get(clusterName)
 	if err != nil {
@@ -370,6 +472,9 @@ func (m *Manager) GetOfflineLogTerminal(c *gin.Context) {
 		AbortHTTPError(c, GetClusterError, "", err)
 		return
 	}
+	if AbortIfMaintaining(c, cluster) {
+		return
+	}
 
 	listOptions := &client.ListOptions{Namespace: namespace, LabelSelector: lb.AsSelector()}
 
@@ -389,41 +494,35 @@ func (m *Manager) GetOfflineLogTerminal(c *gin.Context) {
 		AbortHTTPError(c, GetPodNotGroup, "", errors.New("can not get offlinepod"))
 		return
 	}
+	// the session is refused before the upgrade without the recording
+	session, err := m.newTermSession(c, clusterName, namespace, podName, containerName, cmd)
+	if err != nil {
+		AbortHTTPErrorWithStatus(c, http.StatusInternalServerError, RecordTerminalError, "", err)
+		return
+	}
+
 	rand.Seed(time.Now().UnixNano())
 	ws, err := InitWebsocket(c.Writer, c.Request, rand.Uint32())
 	if err != nil {
 		klog.Errorf("init websocket conn error: %+v", err)
+		session.finish(err)
 		AbortHTTPError(c, WebsocketError, "", err)
 		return
 	}
 	defer ws.Close()
-	//oldpath /web/logs/app/logback/$appName/$podIP_$containerID/
-	//newpath /web/logs/app/$projectName/$appName/$podIP:$Port
-	if offlinePodIP != "" {
-		if containerID == "" {
-			path = fmt.Sprintf("/web/logs/app/%s/%s/%s*", projectCode, appCode, offlinePodIP)
-		} else {
-			path = fmt.Sprintf("/web/logs/app/logback/%s/%s_%s", appCode, offlinePodIP, containerID)
-		}
-	}
-
-	logshell := fmt.Sprintf("cd %s ;pwd &&/bin/bash", path)
-	cmd := []string{
-		"sh",
-		"-c",
-		logshell,
-	}
 
+	go session.watch(ws)
 	err = startProcess(cluster, namespace, podName, containerName,
-		cmd, isStdin, isStdout, isStderr, tty, once, ws)
+		cmd, isStdin, isStdout, isStderr, tty, once, ws, session)
+	session.finish(err)
+	// the websocket may be closed by the session limits, the close is left to the defer
 	if err != nil {
 		ws.Write(websocket.BinaryMessage, []byte(err.Error()+". "))
-		ws.closeChan <- 0
 	}
 }
 
 func startProcess(cluster *k8smanager.Cluster, namespace, podName, container string,
-	cmd []string, isStdin, isStdout, isStderr, tty, once bool, ws *WsConnection) error {
+	cmd []string, isStdin, isStdout, isStderr, tty, once bool, ws *WsConnection, session *termSession) error {
 	req := cluster.KubeCli.CoreV1().RESTClient().Post().
 		Resource("pods").
 		Name(podName).
@@ -467,8 +566,10 @@ func startProcess(cluster *k8smanager.Cluster, namespace, podName, container str
 		})
 		if stderr.Len() != 0 {
 			klog.Errorf("exec steam error: %v", stderr)
+			session.output(stderr.Bytes())
 			ws.Write(websocket.TextMessage, stderr.Bytes())
 		} else {
+			session.output(stdout.Bytes())
 			ws.Write(websocket.TextMessage, stdout.Bytes())
 		}
 		ws.Close()
@@ -476,6 +577,7 @@ func startProcess(cluster *k8smanager.Cluster, namespace, podName, container str
 		handler := &streamHandler{
 			ws:          ws,
 			resizeEvent: make(chan remotecommand.TerminalSize),
+			session:     session,
 		}
 		err = exec.Stream(remotecommand.StreamOptions{
 			Stdin:             handler,
@@ -493,8 +595,8 @@ func startProcess(cluster *k8smanager.Cluster, namespace, podName, container str
 	return err
 }
 
-// RunCmdOnceInContainer ...
-func RunCmdOnceInContainer(cluster *k8smanager.Cluster, namespace, pod, container, cmd string, tty bool) ([]byte, error) {
+// RunCmdOnceInContainer runs the argument vector of the command, it is not interpreted by a shell.
+func RunCmdOnceInContainer(cluster *k8smanager.Cluster, namespace, pod, container string, cmd []string, tty bool) ([]byte, error) {
 	req := cluster.KubeCli.CoreV1().RESTClient().Post().
 		Resource("pods").
 		Name(pod).
@@ -508,10 +610,9 @@ func RunCmdOnceInContainer(cluster *k8smanager.Cluster, namespace, pod, containe
 	}
 
 	parameterCodec := runtime.NewParameterCodec(scheme)
-	cmd = strings.ReplaceAll(cmd, "'", "")
-	klog.Infof("exec cmd: %s", cmd)
+	klog.Infof("exec cmd: %q", cmd)
 	req.VersionedParams(&core_v1.PodExecOptions{
-		Command:   strings.Fields(cmd),
+		Command:   cmd,
 		Container: container,
 		Stdin:     false,
 		Stdout:    true,
@@ -556,7 +657,6 @@ func InitWebsocket(resp http.ResponseWriter, req *http.Request, id uint32) (ws *
 		inChan:    make(chan *WsMessage, 1000),
 		outChan:   make(chan *WsMessage, 1000),
 		closeChan: make(chan byte),
-		isClosed:  false,
 	}
 	wsMap.Store(id, &ws)
 	klog.Infof("The total number of current websocket connections is: %d", getWsMapCount())
@@ -575,7 +675,7 @@ func (handler *streamHandler) Next() *remotecommand.TerminalSize {
 
 // Read ...
 func (handler *streamHandler) Read(p []byte) (size int, err error) {
-	if handler.ws.isClosed {
+	if handler.ws.closed() {
 		return 0, io.EOF
 	}
 	msg, err := handler.ws.Read()
@@ -599,6 +699,8 @@ func (handler *streamHandler) Read(p []byte) (size int, err error) {
 	} else {
 		xtermMsg.Input = string(msg.Data)
 	}
+	handler.session.resize(xtermMsg.Cols, xtermMsg.Rows)
+	handler.session.input(xtermMsg.Input)
 	handler.resizeEvent <- remotecommand.TerminalSize{Width: xtermMsg.Cols, Height: xtermMsg.Rows}
 	size = len(xtermMsg.Input)
 	copy(p, xtermMsg.Input)
@@ -611,6 +713,7 @@ func (handler *streamHandler) Write(p []byte) (size int, err error) {
 	copyData := make([]byte, len(p))
 	copy(copyData, p)
 	size = len(p)
+	handler.session.output(copyData)
 	err = handler.ws.Write(websocket.TextMessage, copyData)
 	if err != nil {
 		handler.ws.Close()
@@ -686,15 +789,22 @@ func getWsMapCount() int {
 	return length
 }
 
-// Close ...
+// Close closes the connection once, the later calls are no-ops.
 func (ws *WsConnection) Close() {
-	ws.mutex.Lock()
-	defer ws.mutex.Unlock()
-	wsMap.Delete(ws.id)
-	if !ws.isClosed {
-		ws.isClosed = true
+	ws.closeOnce.Do(func() {
+		wsMap.Delete(ws.id)
 		close(ws.closeChan)
 		ws.conn.Close()
 		klog.Infof("The total number of current websocket connections is: %d", getWsMapCount())
+	})
+}
+
+// closed reports whether the connection is closed.
+func (ws *WsConnection) closed() bool {
+	select {
+	case <-ws.closeChan:
+		return true
+	default:
+		return false
 	}
 }
@@ -0,0 +1,283 @@
+package v1
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"gitlab.dmall.com/arch/sym-admin/pkg/router"
+	"gitlab.dmall.com/arch/sym-admin/pkg/termrec"
+	"k8s.io/klog"
+)
+
+// the reasons of the sessions closed
+const (
+	closeReasonClosed      = "closed"
+	closeReasonIdleTimeout = "idle timeout"
+	closeReasonMaxDuration = "max duration"
+)
+
+// TerminalOptions are the limits and the recording of the terminal sessions.
+type TerminalOptions struct {
+	// IdleTimeout closes the sessions without input for the time
+	IdleTimeout time.Duration
+	// MaxDuration closes the sessions lasting for the time
+	MaxDuration time.Duration
+	// Recordings keeps the sessions in asciicast, nil disables the recording
+	Recordings *termrec.Store
+}
+
+// termSession applies the limits to a terminal session and records it.
+type termSession struct {
+	rec         *termrec.Recording
+	idleTimeout time.Duration
+	maxDuration time.Duration
+	start       time.Time
+	// lastActive is the unix nano of the last input, the output of the commands like
+	// top does not keep the session alive
+	lastActive int64
+	// width and height are the size of the terminal last recorded
+	width, height uint16
+
+	mu     sync.Mutex
+	reason string
+}
+
+// shorterDuration returns the duration of the query if it is shorter than the limit.
+func shorterDuration(c *gin.Context, key string, limit time.Duration) time.Duration {
+	d, err := time.ParseDuration(c.Query(key))
+	if err != nil || d <= 0 || (limit > 0 && d >= limit) {
+		return limit
+	}
+	return d
+}
+
+// newTermSession starts the session of the request, the clients can only shorten the limits
+// by the idleTimeout and maxDuration queries. The session is refused when the recording is
+// configured but fails to be created.
+func (m *Manager) newTermSession(c *gin.Context, cluster, namespace, pod, container string, cmd []string) (*termSession, error) {
+	now := time.Now()
+	s := &termSession{
+		idleTimeout: shorterDuration(c, "idleTimeout", m.Terminal.IdleTimeout),
+		maxDuration: shorterDuration(c, "maxDuration", m.Terminal.MaxDuration),
+		start:       now,
+		lastActive:  now.UnixNano(),
+	}
+	if m.Terminal.Recordings == nil {
+		return s, nil
+	}
+
+	session := &termrec.Session{
+		SourceIP:  router.SourceIP(c.Request),
+		Cluster:   cluster,
+		Namespace: namespace,
+		Pod:       pod,
+		Container: container,
+		Command:   cmd,
+	}
+	if u := router.GetUser(c); u != nil {
+		session.User = u.Name
+	}
+	rec, err := m.Terminal.Recordings.Create(session)
+	if err != nil {
+		klog.Errorf("create the recording of pod %s/%s/%s err: %v", cluster, namespace, pod, err)
+		return nil, err
+	}
+	s.rec = rec
+	klog.Infof("terminal session %s of pod %s/%s/%s by user %s starts", session.ID, cluster, namespace, pod, session.User)
+	return s, nil
+}
+
+func (s *termSession) touch() {
+	atomic.StoreInt64(&s.lastActive, time.Now().UnixNano())
+}
+
+func (s *termSession) input(data string) {
+	if data == "" {
+		return
+	}
+	s.touch()
+	if s.rec != nil {
+		s.rec.Input(data)
+	}
+}
+
+func (s *termSession) output(data []byte) {
+	if s.rec != nil {
+		s.rec.Output(data)
+	}
+}
+
+// resize records the size changed, the messages of the xterm carry the size.
+func (s *termSession) resize(width, height uint16) {
+	if width == 0 || height == 0 || (width == s.width && height == s.height) {
+		return
+	}
+	s.width, s.height = width, height
+	if s.rec != nil {
+		s.rec.Resize(int(width), int(height))
+	}
+}
+
+// exceeded returns the limit the session exceeds, empty if none.
+func (s *termSession) exceeded(now time.Time) string {
+	if s.maxDuration > 0 && now.Sub(s.start) >= s.maxDuration {
+		return closeReasonMaxDuration
+	}
+	if s.idleTimeout > 0 && now.Sub(time.Unix(0, atomic.LoadInt64(&s.lastActive))) >= s.idleTimeout {
+		return closeReasonIdleTimeout
+	}
+	return ""
+}
+
+// watch closes the websocket when the session exceeds the limits.
+func (s *termSession) watch(ws *WsConnection) {
+	if s.idleTimeout <= 0 && s.maxDuration <= 0 {
+		return
+	}
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ws.closeChan:
+			return
+		case now := <-ticker.C:
+			reason := s.exceeded(now)
+			if reason == "" {
+				continue
+			}
+			s.mu.Lock()
+			s.reason = reason
+			s.mu.Unlock()
+			klog.Infof("close the terminal session of websocket %d: %s", ws.id, reason)
+			ws.conn.WriteControl(websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.ClosePolicyViolation, reason), time.Now().Add(time.Second))
+			ws.Close()
+			return
+		}
+	}
+}
+
+// finish ends the recording with the reason of the close.
+func (s *termSession) finish(err error) {
+	if s.rec == nil {
+		return
+	}
+	s.mu.Lock()
+	reason := s.reason
+	s.mu.Unlock()
+	if reason == "" {
+		reason = closeReasonClosed
+		if err != nil {
+			reason = err.Error()
+		}
+	}
+	if err := s.rec.Close(reason); err != nil {
+		klog.Errorf("close the recording of session %s err: %v", s.rec.Session.ID, err)
+	}
+}
+
+// getTerminalSession returns the session of the cluster of the request.
+func (m *Manager) getTerminalSession(c *gin.Context) (*termrec.Session, bool) {
+	if m.Terminal.Recordings == nil {
+		AbortHTTPError(c, GetTerminalSessionError, "", errors.New("the terminal recording is disabled"))
+		return nil, false
+	}
+	session, err := m.Terminal.Recordings.Get(c.Param("sessionID"))
+	if err != nil || session.Cluster != c.Param("name") {
+		if err == nil || os.IsNotExist(err) {
+			AbortHTTPError(c, RecordNotExistError, "", errors.New("session not found"))
+		} else {
+			AbortHTTPError(c, GetTerminalSessionError, "", err)
+		}
+		return nil, false
+	}
+	return session, true
+}
+
+// ListTerminalSessions returns the recorded sessions, the newest first.
+func (m *Manager) ListTerminalSessions(c *gin.Context) {
+	if m.Terminal.Recordings == nil {
+		AbortHTTPError(c, GetTerminalSessionError, "", errors.New("the terminal recording is disabled"))
+		return
+	}
+
+	q := &termrec.Query{
+		User:      c.Query("user"),
+		Namespace: c.Query("namespace"),
+		Pod:       c.Query("pod"),
+	}
+	if cluster := c.Param("name"); cluster != "all" {
+		q.Cluster = cluster
+	}
+	for key, t := range map[string]*time.Time{"start": &q.Start, "end": &q.End} {
+		if s := c.Query(key); s != "" {
+			sec, err := strconv.ParseInt(s, 10, 64)
+			if err != nil {
+				AbortHTTPError(c, ParamInvalidError, "", errors.New("invalid unix timestamp: "+s))
+				return
+			}
+			*t = time.Unix(sec, 0)
+		}
+	}
+	if s := c.Query("limit"); s != "" {
+		limit, err := strconv.Atoi(s)
+		if err != nil || limit <= 0 {
+			AbortHTTPError(c, ParamInvalidError, "", errors.New("invalid limit: "+s))
+			return
+		}
+		q.Limit = limit
+	}
+
+	sessions, err := m.Terminal.Recordings.List(q)
+	if err != nil {
+		AbortHTTPError(c, GetTerminalSessionError, "", err)
+		return
+	}
+	c.IndentedJSON(http.StatusOK, gin.H{
+		"success":   true,
+		"message":   nil,
+		"resultMap": gin.H{"sessions": sessions},
+	})
+}
+
+// GetTerminalSession returns the metadata of a recorded session.
+func (m *Manager) GetTerminalSession(c *gin.Context) {
+	session, ok := m.getTerminalSession(c)
+	if !ok {
+		return
+	}
+	c.IndentedJSON(http.StatusOK, gin.H{
+		"success":   true,
+		"message":   nil,
+		"resultMap": gin.H{"session": session},
+	})
+}
+
+// GetTerminalSessionCast returns the asciicast of a recorded session for the replay, it is
+// downloaded as a file with download=true.
+func (m *Manager) GetTerminalSessionCast(c *gin.Context) {
+	session, ok := m.getTerminalSession(c)
+	if !ok {
+		return
+	}
+	p, err := m.Terminal.Recordings.CastPath(session.ID)
+	if err != nil {
+		AbortHTTPError(c, RecordNotExistError, "", err)
+		return
+	}
+
+	if download, _ := strconv.ParseBool(c.Query("download")); download {
+		c.FileAttachment(p, filepath.Base(p))
+		return
+	}
+	c.Header("Content-Type", "application/x-asciicast")
+	c.File(p)
+}
@@ -0,0 +1,85 @@
+package v2
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gitlab.dmall.com/arch/sym-admin/pkg/audit"
+)
+
+const maxAuditLimit = 5000
+
+func parseAuditQuery(c *gin.Context) (*audit.Query, error) {
+	q := &audit.Query{
+		User:      c.Query("user"),
+		Namespace: c.Query("namespace"),
+		App:       c.Query("appName"),
+		Verb:      c.Query("verb"),
+		Result:    c.Query("result"),
+	}
+	if cluster := c.Param("clusterCode"); cluster != "all" {
+		q.Cluster = cluster
+	}
+
+	var err error
+	if q.Start, err = parseUnixTime(c.Query("start"), time.Time{}); err != nil {
+		return nil, err
+	}
+	if q.End, err = parseUnixTime(c.Query("end"), time.Time{}); err != nil {
+		return nil, err
+	}
+	if !q.Start.IsZero() && !q.End.IsZero() && q.End.Before(q.Start) {
+		return nil, fmt.Errorf("end must be after start")
+	}
+
+	if s := c.Query("limit"); s != "" {
+		if q.Limit, err = strconv.Atoi(s); err != nil || q.Limit <= 0 {
+			return nil, fmt.Errorf("invalid limit: %s", s)
+		}
+	}
+	if q.Limit > maxAuditLimit {
+		q.Limit = maxAuditLimit
+	}
+	return q, nil
+}
+
+// GetAuditEvents returns the audited requests, the newest first.
+func (m *Manager) GetAuditEvents(c *gin.Context) {
+	if m.Audit == nil {
+		c.IndentedJSON(http.StatusNotFound, gin.H{
+			"success":   false,
+			"message":   "the audit is disabled",
+			"resultMap": nil,
+		})
+		return
+	}
+
+	q, err := parseAuditQuery(c)
+	if err != nil {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{
+			"success":   false,
+			"message":   err.Error(),
+			"resultMap": nil,
+		})
+		return
+	}
+
+	events, err := m.Audit.Query(q)
+	if err != nil {
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{
+			"success":   false,
+			"message":   err.Error(),
+			"resultMap": nil,
+		})
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, gin.H{
+		"success":   true,
+		"message":   nil,
+		"resultMap": gin.H{"events": events},
+	})
+}
@@ -41,11 +41,55 @@ namespace: url param, namespace name <br/>
 podName: url param, the unique pod name. <br/>
 container: query string, the unique container in a pod. <br/>
 tail: query string, the log tail number, default is 1000. <br/>
+previous: query string, the log of the previous terminated container. <br/>
+timestamps: query string, adds the timestamp to each line. <br/>
+sinceSeconds: query string, the log of the last seconds. <br/>
+sinceTime: query string, the log since the time of RFC3339 or unix seconds. <br/>
+limitBytes: query string, the max bytes of the log. <br/>
+follow is not supported, use the logs/stream api instead. <br/>
 <br/>
 e.g. <br/>
 <a href="/api/cluster/tcc-bj5-dks-monit-01/namespace/default/pods/bbcc-xx-xx/logs?container=bbcc&tailLines=100">/api/cluster/tcc-bj5-dks-monit-01/namespace/default/pods/bbcc-xx-xx/logs?container=bbcc&tailLines=100</a><br/>
 `
 
+// StreamPodLogsDesc ...
+var StreamPodLogsDesc = `
+Stream the stdout of a pod container over the websocket, or as the Server-Sent Events without the websocket upgrade. <br/>
+Each line is a websocket text message or the data of an event, the errors are prefixed with [error] or the error events, the end is the normal close or the end event. <br/>
+clusterCode: url param, the unique cluster name. <br/>
+namespace: url param, namespace name <br/>
+podName: url param, the unique pod name. <br/>
+container: query string, the unique container in a pod, default is the first. <br/>
+follow: query string, keeps streaming the new lines. <br/>
+tail: query string, the log tail number, default is all. <br/>
+previous: query string, the log of the previous terminated container. <br/>
+timestamps: query string, adds the timestamp to each line. <br/>
+sinceSeconds: query string, the log of the last seconds. <br/>
+sinceTime: query string, the log since the time of RFC3339 or unix seconds. <br/>
+limitBytes: query string, the max bytes of the log. <br/>
+prefix: query string, prefixes the lines with [cluster/pod/container], default is false. <br/>
+<br/>
+e.g. <br/>
+<a href="/api/v2/cluster/tcc-bj5-dks-monit-01/namespace/default/pods/bbcc-xx-xx/logs/stream?container=bbcc&follow=true&tail=100">/api/v2/cluster/tcc-bj5-dks-monit-01/namespace/default/pods/bbcc-xx-xx/logs/stream?container=bbcc&follow=true&tail=100</a><br/>
+`
+
+// StreamAppLogsDesc ...
+var StreamAppLogsDesc = `
+Stream the stdout of all the pods of an app merged, over the websocket or as the Server-Sent Events like the pod logs/stream api. <br/>
+The pods are those started when the stream starts, at most 100, the pods started later e.g. by a rolling update are not followed. <br/>
+The stream ends with the end event, or the normal close of the websocket, after the logs of all the pods end, the clients reconnect then to follow the current pods. <br/>
+clusterCode: url param, the unique cluster name and all. <br/>
+appName: query string, the unique app name. <br/>
+namespace: query string, namespace name, default is all. <br/>
+podSet: query string, the PodSet of the app. <br/>
+group: query string, the group such as blue, green and canary. <br/>
+prefix: query string, prefixes the lines with [cluster/pod/container], default is true. <br/>
+container, follow, tail, previous, timestamps, sinceSeconds, sinceTime and limitBytes: query string, applied to each pod like the pod logs/stream api. <br/>
+<br/>
+e.g. <br/>
+<a href="/api/v2/cluster/all/logs/stream?appName=bbcc&group=blue&follow=true&sinceSeconds=300">/api/v2/cluster/all/logs/stream?appName=bbcc&group=blue&follow=true&sinceSeconds=300</a><br/>
+`
+
 // GetPodEventDesc ...
 var GetPodEventDesc = `
 Get a limited number of pod events. <br/>
@@ -59,10 +103,88 @@ e.g. <br/>
 `
 
 // TailFileDesc ...
-var TailFileDesc = ``
+var TailFileDesc = `
+Tail a log file in a pod container. <br/>
+clusterCode: url param, the unique cluster name. <br/>
+namespace: url param, namespace name <br/>
+podName: url param, the unique pod name. <br/>
+container: query string, the unique container in a pod. <br/>
+filepath: query string, the absolute log file path, it must be under the log directories such as /web/logs after the symlinks resolved, or 403 is returned. <br/>
+tail: query string, the log tail number, default is 1000, at most 10000. <br/>
+<br/>
+e.g. <br/>
+<a href="/api/v2/cluster/tcc-bj5-dks-monit-01/namespace/default/pods/bbcc-xx-xx/tail?container=bbcc&filepath=/web/logs/app/xx.log&tail=100">/api/v2/cluster/tcc-bj5-dks-monit-01/namespace/default/pods/bbcc-xx-xx/tail?container=bbcc&filepath=/web/logs/app/xx.log&tail=100</a><br/>
+`
 
 // ListFileDesc ...
-var ListFileDesc = ``
+var ListFileDesc = `
+List the files of a log directory in a pod container. <br/>
+clusterCode: url param, the unique cluster name. <br/>
+namespace: url param, namespace name <br/>
+podName: url param, the unique pod name. <br/>
+container: query string, the unique container in a pod. <br/>
+path: query string, the absolute directory, default is /web/logs/app/. It must be under the log directories such as /web/logs after the symlinks resolved, or 403 is returned. <br/>
+<br/>
+e.g. <br/>
+<a href="/api/v2/cluster/tcc-bj5-dks-monit-01/namespace/default/pods/bbcc-xx-xx/files?container=bbcc&path=/web/logs/app/">/api/v2/cluster/tcc-bj5-dks-monit-01/namespace/default/pods/bbcc-xx-xx/files?container=bbcc&path=/web/logs/app/</a><br/>
+`
 
 // GetAppGroupVersionDesc ...
 var GetAppGroupVersionDesc = ``
+
+// GetAppMetricsDesc ...
+var GetAppMetricsDesc = `
+Get the metric of an app from the prometheus of each cluster. <br/>
+appName: url param, the unique app name. <br/>
+namespace: query string, namespace name, default is default. <br/>
+cluster: query string, the unique cluster name, default is all the clusters of the AppSet. <br/>
+metric: query string, cpu, memory, qps or errorRate, default is cpu. <br/>
+by: query string, group or podset, default is group. <br/>
+window: query string, the rate window, default is 5m. <br/>
+start: query string, unix timestamp, returns the instant value when not set. <br/>
+end: query string, unix timestamp, default is now. <br/>
+step: query string, the step of the range, default is 1m. <br/>
+<br/>
+e.g. <br/>
+<a href="/api/v2/app/bbcc/metrics?namespace=default&metric=qps&by=podset">/api/v2/app/bbcc/metrics?namespace=default&metric=qps&by=podset</a><br/>
+`
+
+// GetEventHistoryDesc ...
+var GetEventHistoryDesc = `
+Get the events kept in the event store after they are gone from the clusters, the newest first. <br/>
+clusterCode: url param, the unique cluster name and all. <br/>
+namespace: query string, namespace name. <br/>
+appName: query string, the unique app name. <br/>
+podSet: query string, the podSet (release) name. <br/>
+reason: query string, the event reason such as BackOff. <br/>
+type: query string, Warning or Normal. <br/>
+start: query string, unix timestamp. <br/>
+end: query string, unix timestamp. <br/>
+limit: query string, the limit number, default is 500. <br/>
+<br/>
+e.g. <br/>
+<a href="/api/v2/cluster/all/events/history?appName=bbcc&reason=BackOff">/api/v2/cluster/all/events/history?appName=bbcc&reason=BackOff</a><br/>
+`
+
+// PutEventsDesc ...
+var PutEventsDesc = `
+Store the events pushed by the eventStore receiver of the event exporter. <br/>
+body: a json array of the events enhanced by the event exporter. <br/>
+`
+
+// GetAuditEventsDesc ...
+var GetAuditEventsDesc = `
+Get the audited requests such as the deletes, the restarts, the execs and the terminal sessions, the newest first. <br/>
+clusterCode: url param, the unique cluster name and all. <br/>
+user: query string, the user name. <br/>
+namespace: query string, namespace name. <br/>
+appName: query string, the unique app name. <br/>
+verb: query string, restart, delete, exec or admin. <br/>
+result: query string, success, failure or denied. <br/>
+start: query string, unix timestamp. <br/>
+end: query string, unix timestamp. <br/>
+limit: query string, the limit number, default is 500. <br/>
+<br/>
+e.g. <br/>
+<a href="/api/v2/cluster/all/audit?verb=exec">/api/v2/cluster/all/audit?verb=exec</a><br/>
+`
@@ -0,0 +1,112 @@
+package v2
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gitlab.dmall.com/arch/sym-admin/pkg/controllers/eventexporter/kube"
+	"gitlab.dmall.com/arch/sym-admin/pkg/eventstore"
+)
+
+const maxEventHistoryLimit = 5000
+
+// PutEvents stores the events pushed by the event exporter of the clusters.
+func (m *Manager) PutEvents(c *gin.Context) {
+	var events []*kube.EnhancedEvent
+	if err := c.ShouldBindJSON(&events); err != nil {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{
+			"success":   false,
+			"message":   err.Error(),
+			"resultMap": nil,
+		})
+		return
+	}
+
+	records := make([]*eventstore.Record, 0, len(events))
+	for _, ev := range events {
+		if ev == nil || ev.ClusterName == "" {
+			continue
+		}
+		records = append(records, eventstore.NewRecord(ev))
+	}
+	if err := m.Events.Put(records...); err != nil {
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{
+			"success":   false,
+			"message":   err.Error(),
+			"resultMap": nil,
+		})
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, gin.H{
+		"success":   true,
+		"message":   nil,
+		"resultMap": gin.H{"stored": len(records)},
+	})
+}
+
+func parseEventQuery(c *gin.Context) (*eventstore.Query, error) {
+	q := &eventstore.Query{
+		Namespace: c.Query("namespace"),
+		App:       c.Query("appName"),
+		PodSet:    c.Query("podSet"),
+		Reason:    c.Query("reason"),
+		Type:      c.Query("type"),
+	}
+	if cluster := c.Param("clusterCode"); cluster != "all" {
+		q.Cluster = cluster
+	}
+
+	var err error
+	if q.Start, err = parseUnixTime(c.Query("start"), time.Time{}); err != nil {
+		return nil, err
+	}
+	if q.End, err = parseUnixTime(c.Query("end"), time.Time{}); err != nil {
+		return nil, err
+	}
+	if !q.Start.IsZero() && !q.End.IsZero() && q.End.Before(q.Start) {
+		return nil, fmt.Errorf("end must be after start")
+	}
+
+	if s := c.Query("limit"); s != "" {
+		if q.Limit, err = strconv.Atoi(s); err != nil || q.Limit <= 0 {
+			return nil, fmt.Errorf("invalid limit: %s", s)
+		}
+	}
+	if q.Limit > maxEventHistoryLimit {
+		q.Limit = maxEventHistoryLimit
+	}
+	return q, nil
+}
+
+// GetEventHistory returns the stored events of the clusters, the newest first.
+func (m *Manager) GetEventHistory(c *gin.Context) {
+	q, err := parseEventQuery(c)
+	if err != nil {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{
+			"success":   false,
+			"message":   err.Error(),
+			"resultMap": nil,
+		})
+		return
+	}
+
+	records, err := m.Events.Query(q)
+	if err != nil {
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{
+			"success":   false,
+			"message":   err.Error(),
+			"resultMap": nil,
+		})
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, gin.H{
+		"success":   true,
+		"message":   nil,
+		"resultMap": gin.H{"events": records},
+	})
+}
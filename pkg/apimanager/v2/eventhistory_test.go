@@ -0,0 +1,85 @@
+package v2
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gitlab.dmall.com/arch/sym-admin/pkg/controllers/eventexporter/kube"
+	"gitlab.dmall.com/arch/sym-admin/pkg/eventstore"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestEventHistory(t *testing.T) {
+	m := &Manager{Events: eventstore.NewMemoryStore(0)}
+	srv := gin.New()
+	srv.POST("/api/v2/events", m.PutEvents)
+	srv.GET("/api/v2/cluster/:clusterCode/events/history", m.GetEventHistory)
+
+	now := time.Now()
+	var events []*kube.EnhancedEvent
+	for i, cluster := range []string{"tcc-gz01", "tcc-bj01"} {
+		ev := &kube.EnhancedEvent{
+			Event: corev1.Event{
+				ObjectMeta:    metav1.ObjectMeta{Name: "bbcc." + cluster, Namespace: "default"},
+				Type:          corev1.EventTypeWarning,
+				Reason:        "BackOff",
+				LastTimestamp: metav1.NewTime(now.Add(time.Duration(i) * time.Minute)),
+			},
+			ClusterName: cluster,
+		}
+		ev.InvolvedObject.Labels = map[string]string{"app": "bbcc", "release": "bbcc-" + cluster}
+		events = append(events, ev)
+	}
+	body, _ := json.Marshal(events)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/v2/events", bytes.NewReader(body))
+	srv.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("put events: %d %s", w.Code, w.Body.String())
+	}
+
+	cases := []struct {
+		url    string
+		code   int
+		expect int
+	}{
+		{"/api/v2/cluster/all/events/history?appName=bbcc", http.StatusOK, 2},
+		{"/api/v2/cluster/tcc-gz01/events/history?reason=BackOff", http.StatusOK, 1},
+		{"/api/v2/cluster/all/events/history?podSet=bbcc-tcc-bj01", http.StatusOK, 1},
+		{"/api/v2/cluster/all/events/history?type=Normal", http.StatusOK, 0},
+		{"/api/v2/cluster/all/events/history?limit=1", http.StatusOK, 1},
+		{"/api/v2/cluster/all/events/history?limit=-1", http.StatusBadRequest, 0},
+		{"/api/v2/cluster/all/events/history?start=200&end=100", http.StatusBadRequest, 0},
+	}
+	for _, c := range cases {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", c.url, nil)
+		srv.ServeHTTP(w, req)
+		if w.Code != c.code {
+			t.Errorf("%s expect code: %d, current: %d", c.url, c.code, w.Code)
+			continue
+		}
+		if c.code != http.StatusOK {
+			continue
+		}
+
+		result := struct {
+			ResultMap struct {
+				Events []*eventstore.Record `json:"events"`
+			} `json:"resultMap"`
+		}{}
+		if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+			t.Fatal(err)
+		}
+		if len(result.ResultMap.Events) != c.expect {
+			t.Errorf("%s expect %d events, current: %d", c.url, c.expect, len(result.ResultMap.Events))
+		}
+	}
+}
@@ -1,10 +1,14 @@
 package v2
 
 import (
+	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
 
 	"github.com/gin-gonic/gin"
+	"gitlab.dmall.com/arch/sym-admin/pkg/execpolicy"
+	"gitlab.dmall.com/arch/sym-admin/pkg/offlinelog"
 	"k8s.io/klog"
 )
 
@@ -15,12 +19,33 @@ type File struct {
 	IsDirectory bool   `json:"isDirectory"`
 }
 
-// TailFile tail files in a pod
+// abortPathError responds 403 to the paths denied by the exec policy, 400 to the invalid.
+func abortPathError(c *gin.Context, err error) {
+	status := http.StatusBadRequest
+	if execpolicy.IsDenied(err) {
+		status = http.StatusForbidden
+	}
+	c.IndentedJSON(status, gin.H{
+		"success":   false,
+		"message":   err.Error(),
+		"resultMap": nil,
+	})
+}
+
+// TailFile tail files in a pod, the file is confined to the log directories
 func (m *Manager) TailFile(c *gin.Context) {
 	clusterName := c.Param("clusterCode")
 	namespace := c.Param("namespace")
 	podName := c.Param("podName")
-	tailLines := c.DefaultQuery("tail", "1000")
+	tailLines, err := strconv.Atoi(c.DefaultQuery("tail", "1000"))
+	if err != nil || tailLines <= 0 || tailLines > offlinelog.MaxLines {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{
+			"success":   false,
+			"message":   fmt.Sprintf("tail must be 1-%d", offlinelog.MaxLines),
+			"resultMap": nil,
+		})
+		return
+	}
 
 	containerName, ok := c.GetQuery("container")
 	if !ok || containerName == "" {
@@ -32,7 +57,7 @@ func (m *Manager) TailFile(c *gin.Context) {
 		return
 	}
 	filepath, ok := c.GetQuery("filepath")
-	if !ok {
+	if !ok || filepath == "" {
 		c.IndentedJSON(http.StatusBadRequest, gin.H{
 			"success":   false,
 			"message":   "filename can not be none",
@@ -40,6 +65,10 @@ func (m *Manager) TailFile(c *gin.Context) {
 		})
 		return
 	}
+	if _, err := m.ExecPolicy.LogPath(filepath); err != nil {
+		abortPathError(c, err)
+		return
+	}
 
 	cluster, err := m.ClustersMgr.Get(clusterName)
 	if err != nil {
@@ -52,12 +81,18 @@ func (m *Manager) TailFile(c *gin.Context) {
 		return
 	}
 
-	cmd := []string{
-		"sh",
-		"-c",
-		"tail -n" + tailLines + " " + filepath,
+	run := func(argv []string) ([]byte, error) {
+		return RunCmdOnceInContainer(cluster, namespace, podName, containerName, argv, false)
+	}
+	file, err := m.ExecPolicy.ResolveLogPath(filepath, run)
+	if execpolicy.IsDenied(err) {
+		abortPathError(c, err)
+		return
+	}
+	var result []byte
+	if err == nil {
+		result, err = run([]string{"tail", "-n", strconv.Itoa(tailLines), "--", file})
 	}
-	result, err := RunCmdOnceInContainer(cluster, namespace, podName, containerName, cmd, false)
 	if err != nil {
 		klog.Errorf("run cmd once in container error: %v", err)
 		c.IndentedJSON(http.StatusBadRequest, gin.H{
@@ -81,7 +116,7 @@ func (m *Manager) TailFile(c *gin.Context) {
 	})
 }
 
-// ListFiles get the log file of the specified directory
+// ListFiles get the log file of the specified directory under the log directories
 // use cmd: ls -p
 // isDirectory is true when fileName has suffix '/'
 func (m *Manager) ListFiles(c *gin.Context) {
@@ -102,6 +137,10 @@ func (m *Manager) ListFiles(c *gin.Context) {
 		})
 		return
 	}
+	if _, err := m.ExecPolicy.LogPath(path); err != nil {
+		abortPathError(c, err)
+		return
+	}
 	cluster, err := m.ClustersMgr.Get(clusterCode)
 	if err != nil {
 		klog.Errorf("get cluster error: %+v", err)
@@ -113,13 +152,18 @@ func (m *Manager) ListFiles(c *gin.Context) {
 		return
 	}
 
-	cmd := []string{
-		"sh",
-		"-c",
-		"ls -p " + path,
+	run := func(argv []string) ([]byte, error) {
+		return RunCmdOnceInContainer(cluster, namespace, podName, containerName, argv, false)
+	}
+	dir, err := m.ExecPolicy.ResolveLogPath(path, run)
+	if execpolicy.IsDenied(err) {
+		abortPathError(c, err)
+		return
+	}
+	var cmdResult []byte
+	if err == nil {
+		cmdResult, err = run([]string{"ls", "-p", "--", dir})
 	}
-	cmdResult, err := RunCmdOnceInContainer(
-		cluster, namespace, podName, containerName, cmd, false)
 	if err != nil {
 		klog.Errorf("run cmd once in container error: %v", err)
 		c.IndentedJSON(http.StatusBadRequest, gin.H{
@@ -29,9 +29,10 @@ var _ = Describe("test get files", func() {
 		},
 		Entry("incurrent podName return 400", "tcc-gz01-bj5-test", "cs", "t", "bb", "", 400),
 		Entry("incurrent container return 400", "tcc-gz01-bj5-test", "dmall-inner", "abcd-11-adb-00-gz01a-blue-955798969-jhdkt", "bb", "", 400),
-		Entry("incurrent path return 400", "tcc-gz01-bj5-test", "dmall-inner", "abcd-11-adb-00-gz01a-blue-955798969-jhdkt", "abcd-11-adb-00", "/abc", 400),
+		Entry("path out of the log directories return 403", "tcc-gz01-bj5-test", "dmall-inner", "abcd-11-adb-00-gz01a-blue-955798969-jhdkt", "abcd-11-adb-00", "/abc", 403),
+		Entry("path escaping the log directories return 403", "tcc-gz01-bj5-test", "dmall-inner", "abcd-11-adb-00-gz01a-blue-955798969-jhdkt", "abcd-11-adb-00", "/web/logs/../../etc", 403),
 		Entry("empty path return 200", "tcc-gz01-bj5-test", "dmall-inner", "abcd-11-adb-00-gz01a-blue-955798969-jhdkt", "abcd-11-adb-00", "", 200),
-		Entry("ls /etc/yum return 200", "tcc-gz01-bj5-test", "dmall-inner", "abcd-11-adb-00-gz01a-blue-955798969-jhdkt", "abcd-11-adb-00", "/etc/yum", 200),
+		Entry("ls /etc/yum return 403", "tcc-gz01-bj5-test", "dmall-inner", "abcd-11-adb-00-gz01a-blue-955798969-jhdkt", "abcd-11-adb-00", "/etc/yum", 403),
 	)
 
 	DescribeTable("tail files",
@@ -52,10 +53,12 @@ var _ = Describe("test get files", func() {
 		},
 		Entry("incurrent podName return 400", "tcc-gz01-bj5-test", "cs", "t", "bb", "", "10", 400),
 		Entry("incurrent container return 400", "tcc-gz01-bj5-test", "dmall-inner", "abcd-11-adb-00-gz01a-blue-955798969-jhdkt", "bb", "", "10", 400),
-		Entry("incurrent filepath return 400", "tcc-gz01-bj5-test", "dmall-inner", "abcd-11-adb-00-gz01a-blue-955798969-jhdkt", "abcd-11-adb-00", "/abc.yaml", "10", 400),
+		Entry("filepath out of the log directories return 403", "tcc-gz01-bj5-test", "dmall-inner", "abcd-11-adb-00-gz01a-blue-955798969-jhdkt", "abcd-11-adb-00", "/abc.yaml", "10", 403),
+		Entry("injected filepath return 403", "tcc-gz01-bj5-test", "dmall-inner", "abcd-11-adb-00-gz01a-blue-955798969-jhdkt", "abcd-11-adb-00", "/etc/passwd%3Bid", "10", 403),
+		Entry("injected tail return 400", "tcc-gz01-bj5-test", "dmall-inner", "abcd-11-adb-00-gz01a-blue-955798969-jhdkt", "abcd-11-adb-00", "/web/logs/app/a.log", "1%3Bid", 400),
 		Entry("incurrent tail return 400", "tcc-gz01-bj5-test", "dmall-inner", "abcd-11-adb-00-gz01a-blue-955798969-jhdkt", "abcd-11-adb-00", "/etc/yum/version-groups.conf", "a", 400),
 		Entry("empty path return 400", "tcc-gz01-bj5-test", "dmall-inner", "abcd-11-adb-00-gz01a-blue-955798969-jhdkt", "abcd-11-adb-00", "", "", 400),
 		Entry("empty tail return 400", "tcc-gz01-bj5-test", "dmall-inner", "abcd-11-adb-00-gz01a-blue-955798969-jhdkt", "abcd-11-adb-00", "/etc/yum/version-groups.conf", "", 400),
-		Entry("tail /etc/yum/version-groups.conf return 200", "tcc-gz01-bj5-test", "dmall-inner", "abcd-11-adb-00-gz01a-blue-955798969-jhdkt", "abcd-11-adb-00", "/etc/yum/version-groups.conf", "10", 200),
+		Entry("tail /etc/yum/version-groups.conf return 403", "tcc-gz01-bj5-test", "dmall-inner", "abcd-11-adb-00-gz01a-blue-955798969-jhdkt", "abcd-11-adb-00", "/etc/yum/version-groups.conf", "10", 403),
 	)
 })
@@ -2,12 +2,13 @@ package v2
 
 import (
 	"context"
+	"fmt"
 	"io/ioutil"
 	"net/http"
-	"strconv"
 	"strings"
 
 	"github.com/gin-gonic/gin"
+	"gitlab.dmall.com/arch/sym-admin/pkg/logstream"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes/scheme"
@@ -19,13 +20,11 @@ func (m *Manager) HandleLogs(c *gin.Context) {
 	clusterCode := c.Param("clusterCode")
 	podName := c.Param("podName")
 	namespace := c.Param("namespace")
-	container := c.DefaultQuery("container", "")
 
-	tailStr := c.DefaultQuery("tail", "1000")
-	if tailStr == "" {
-		tailStr = "1000"
+	opts, err := logstream.ParseOptions(c.Request.URL.Query())
+	if err == nil && opts.Follow {
+		err = fmt.Errorf("follow is not supported, use the logs/stream api")
 	}
-	tailLines, err := strconv.ParseInt(tailStr, 10, 64)
 	if err != nil {
 		c.IndentedJSON(http.StatusBadRequest, gin.H{
 			"success":   false,
@@ -34,16 +33,9 @@ func (m *Manager) HandleLogs(c *gin.Context) {
 		})
 		return
 	}
-
-	follow, _ := strconv.ParseBool(c.DefaultQuery("follow", "false"))
-	previous, _ := strconv.ParseBool(c.DefaultQuery("previous", "false"))
-	timestamps, _ := strconv.ParseBool(c.DefaultQuery("timestamps", "false"))
-
-	logOptions := &corev1.PodLogOptions{
-		Follow:     follow,
-		Previous:   previous,
-		Timestamps: timestamps,
-		TailLines:  &tailLines,
+	if opts.TailLines == nil {
+		tailLines := int64(1000)
+		opts.TailLines = &tailLines
 	}
 
 	cluster, err := m.ClustersMgr.Get(clusterCode)
@@ -72,11 +64,11 @@ func (m *Manager) HandleLogs(c *gin.Context) {
 		return
 	}
 
+	container := opts.Container
 	if len(container) == 0 {
-		logOptions.Container = pod.Spec.Containers[0].Name
-	} else {
-		logOptions.Container = container
+		container = pod.Spec.Containers[0].Name
 	}
+	logOptions := opts.PodLogOptions(container)
 
 	req, err := cluster.KubeCli.CoreV1().RESTClient().Get().
 		Namespace(namespace).
@@ -38,4 +38,24 @@ var _ = Describe("test pod logs interface", func() {
 		Entry("empty tail line return 200", "tcc-gz01-bj5-test", "dmall-inner", "abcd-11-adb-00-gz01a-blue-955798969-jhdkt", "abcd-11-adb-00", "", 200),
 		Entry("one tail line return 200", "tcc-gz01-bj5-test", "dmall-inner", "abcd-11-adb-00-gz01a-blue-955798969-jhdkt", "abcd-11-adb-00", "1", 200),
 	)
+
+	DescribeTable("stream app logs",
+		func(clusterCode, query string, expected int) {
+			testServer := gin.Default()
+			testServer.GET("/api/v2/cluster/:clusterCode/logs/stream", manager.StreamAppLogs)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", fmt.Sprintf("/api/v2/cluster/%s/logs/stream?%s", clusterCode, query), nil)
+			testServer.ServeHTTP(w, req)
+
+			fmt.Println(w.Body.String())
+			Expect(w.Code).To(Equal(expected))
+		},
+		Entry("no appName return 400", "all", "group=blue", 400),
+		Entry("incurrent sinceSeconds return 400", "all", "appName=abcd-11-adb-00&sinceSeconds=a", 400),
+		Entry("both sinceSeconds and sinceTime return 400", "all", "appName=abcd-11-adb-00&sinceSeconds=60&sinceTime=1603094400", 400),
+		Entry("incurrent clusterCode return 400", "aa", "appName=abcd-11-adb-00", 400),
+		Entry("no pods return 400", "all", "appName=abcd-11-adb-00&podSet=none", 400),
+		Entry("app logs return 200", "tcc-gz01-bj5-test", "appName=abcd-11-adb-00&tail=1", 200),
+	)
 })
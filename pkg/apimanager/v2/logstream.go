@@ -0,0 +1,137 @@
+package v2
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	k8smanager "gitlab.dmall.com/arch/sym-admin/pkg/k8s/manager"
+	labels2 "gitlab.dmall.com/arch/sym-admin/pkg/labels"
+	"gitlab.dmall.com/arch/sym-admin/pkg/logstream"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func abortStreamError(c *gin.Context, err error) {
+	c.IndentedJSON(http.StatusBadRequest, gin.H{
+		"success":   false,
+		"message":   err.Error(),
+		"resultMap": nil,
+	})
+}
+
+// streamLogs streams the sources until they end or the client leaves, the errors after the
+// stream starts are sent in the stream.
+func streamLogs(c *gin.Context, sources []*logstream.Source, prefix bool) {
+	err := logstream.Stream(c.Writer, c.Request, sources, prefix)
+	if err != nil && err != context.Canceled {
+		klog.Warningf("stream the logs of %d pods err: %v", len(sources), err)
+	}
+}
+
+// StreamPodLogs streams the log of a pod container over the websocket or the Server-Sent Events.
+func (m *Manager) StreamPodLogs(c *gin.Context) {
+	opts, err := logstream.ParseOptions(c.Request.URL.Query())
+	if err != nil {
+		abortStreamError(c, err)
+		return
+	}
+	cluster, err := m.ClustersMgr.Get(c.Param("clusterCode"))
+	if err != nil {
+		abortStreamError(c, err)
+		return
+	}
+
+	pod := &corev1.Pod{}
+	err = cluster.Client.Get(context.Background(), types.NamespacedName{
+		Namespace: c.Param("namespace"),
+		Name:      c.Param("podName"),
+	}, pod)
+	if err != nil {
+		klog.Errorf("get pod error: %v", err)
+		abortStreamError(c, err)
+		return
+	}
+
+	prefix, _ := strconv.ParseBool(c.DefaultQuery("prefix", "false"))
+	streamLogs(c, []*logstream.Source{logstream.PodSource(cluster, pod, opts)}, prefix)
+}
+
+// StreamAppLogs merges the logs of all the pods of an app, optionally of a PodSet or a group,
+// of the cluster or all the clusters. The pods are fixed when the stream starts, the pods
+// started later are not followed, the stream ends with the end event or the normal close
+// after the last pod ends so that the clients reconnect for the pods replaced.
+func (m *Manager) StreamAppLogs(c *gin.Context) {
+	appName := c.Query("appName")
+	if appName == "" {
+		abortStreamError(c, fmt.Errorf("no appName"))
+		return
+	}
+	opts, err := logstream.ParseOptions(c.Request.URL.Query())
+	if err != nil {
+		abortStreamError(c, err)
+		return
+	}
+
+	lb := labels.Set{labels2.ObserveMustLabelAppName: appName}
+	if podSet := c.Query("podSet"); podSet != "" {
+		lb[labels2.ObserveMustLabelReleaseName] = podSet
+	}
+	if group := c.Query("group"); group != "" {
+		lb[labels2.ObserveMustLabelGroupName] = group
+	}
+	listOptions := &client.ListOptions{Namespace: c.Query("namespace"), LabelSelector: lb.AsSelector()}
+
+	clusterCode := c.Param("clusterCode")
+	clusters := m.ClustersMgr.GetAll(clusterCode)
+	if len(clusters) == 0 {
+		abortStreamError(c, fmt.Errorf("cluster: %s not found", clusterCode))
+		return
+	}
+	sources, err := appLogSources(clusters, listOptions, opts)
+	if err != nil {
+		abortStreamError(c, err)
+		return
+	}
+	if len(sources) == 0 {
+		abortStreamError(c, fmt.Errorf("no pods of app %s with the labels %s", appName, lb))
+		return
+	}
+	if len(sources) > logstream.MaxSources {
+		abortStreamError(c, fmt.Errorf("%d pods are more than %d, narrow them by the podSet or the group", len(sources), logstream.MaxSources))
+		return
+	}
+
+	prefix, _ := strconv.ParseBool(c.DefaultQuery("prefix", "true"))
+	streamLogs(c, sources, prefix)
+}
+
+// appLogSources returns the sources of the pods started of the clusters, in the order of
+// the clusters and the pods.
+func appLogSources(clusters []*k8smanager.Cluster, listOptions *client.ListOptions, opts *logstream.Options) ([]*logstream.Source, error) {
+	var sources []*logstream.Source
+	for _, cluster := range clusters {
+		podList := &corev1.PodList{}
+		if err := cluster.Client.List(context.Background(), podList, listOptions); err != nil {
+			return nil, fmt.Errorf("list pods of cluster %s err: %v", cluster.Name, err)
+		}
+		sort.Slice(podList.Items, func(i, j int) bool {
+			return podList.Items[i].Name < podList.Items[j].Name
+		})
+		for i := range podList.Items {
+			pod := &podList.Items[i]
+			// the containers of the pending pods have no log yet
+			if pod.Status.Phase == corev1.PodPending {
+				continue
+			}
+			sources = append(sources, logstream.PodSource(cluster, pod, opts))
+		}
+	}
+	return sources, nil
+}
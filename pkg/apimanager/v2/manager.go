@@ -1,11 +1,20 @@
 package v2
 
 import (
+	"gitlab.dmall.com/arch/sym-admin/pkg/audit"
+	"gitlab.dmall.com/arch/sym-admin/pkg/eventstore"
+	"gitlab.dmall.com/arch/sym-admin/pkg/execpolicy"
 	k8smanager "gitlab.dmall.com/arch/sym-admin/pkg/k8s/manager"
+	"gitlab.dmall.com/arch/sym-admin/pkg/prom"
 )
 
 // Manager ...
 type Manager struct {
 	Cluster     k8smanager.CustomizedCluster
 	ClustersMgr *k8smanager.ClusterManager
+	Prom        *prom.Client
+	Events      eventstore.Store
+	Audit       *audit.Auditor
+	// ExecPolicy confines the log files read, nil confines them to the default roots
+	ExecPolicy *execpolicy.Policy
 }
@@ -0,0 +1,207 @@
+package v2
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	promv1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	workloadv1beta1 "gitlab.dmall.com/arch/sym-admin/pkg/apis/workload/v1beta1"
+	"gitlab.dmall.com/arch/sym-admin/pkg/prom"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+const (
+	defaultMetricWindow = "5m"
+	defaultMetricStep   = 60 * time.Second
+	maxMetricPoints     = 11000
+)
+
+// the pod labels exported by kube-state-metrics
+var metricGroupLabels = map[string]string{
+	"group":  "label_sym_group",
+	"podset": "label_release",
+}
+
+// the sample of each metric, %[1]s is the namespace and %[2]s is the rate window,
+// the samples are joined with the pod labels of the app
+var metricSamples = map[string]string{
+	"cpu":    `rate(container_cpu_usage_seconds_total{namespace="%[1]s",container!="",container!="POD"}[%[2]s])`,
+	"memory": `container_memory_working_set_bytes{namespace="%[1]s",container!="",container!="POD"}`,
+	"qps":    `rate(http_server_requests_seconds_count{namespace="%[1]s"}[%[2]s])`,
+	"error":  `rate(http_server_requests_seconds_count{namespace="%[1]s",status=~"5.."}[%[2]s])`,
+}
+
+// buildAppMetricQuery returns the PromQL of the app metric summed by the group or podSet.
+func buildAppMetricQuery(metric, by, namespace, appName, window string) (string, error) {
+	groupLabel, ok := metricGroupLabels[by]
+	if !ok {
+		return "", fmt.Errorf("unsupported by: %s, must be group or podset", by)
+	}
+	if errs := validation.IsDNS1123Label(namespace); len(errs) > 0 {
+		return "", fmt.Errorf("invalid namespace: %s", namespace)
+	}
+	if errs := validation.IsDNS1123Subdomain(appName); len(errs) > 0 {
+		return "", fmt.Errorf("invalid appName: %s", appName)
+	}
+	if _, err := time.ParseDuration(window); err != nil {
+		return "", fmt.Errorf("invalid window: %s", window)
+	}
+
+	sum := func(sample string) string {
+		return fmt.Sprintf(`sum by (%[1]s) (%[2]s * on (namespace, pod) group_left(%[1]s) kube_pod_labels{namespace="%[3]s",label_app="%[4]s"})`,
+			groupLabel, fmt.Sprintf(sample, namespace, window), namespace, appName)
+	}
+
+	switch metric {
+	case "cpu", "memory", "qps":
+		return sum(metricSamples[metric]), nil
+	case "errorRate":
+		return fmt.Sprintf("%s / %s", sum(metricSamples["error"]), sum(metricSamples["qps"])), nil
+	default:
+		return "", fmt.Errorf("unsupported metric: %s, must be cpu, memory, qps or errorRate", metric)
+	}
+}
+
+// getPrometheusTargets returns the prometheus of the clusters, all the clusters of the AppSet
+// are queried when the cluster is not specified.
+func (m *Manager) getPrometheusTargets(ctx context.Context, clusterName, namespace, appName string) ([]prom.Target, error) {
+	cli := m.ClustersMgr.GetClient()
+
+	var names []string
+	if clusterName != "" && clusterName != "all" {
+		names = append(names, clusterName)
+	} else {
+		app := &workloadv1beta1.AppSet{}
+		if err := cli.Get(ctx, types.NamespacedName{Namespace: namespace, Name: appName}, app); err != nil {
+			return nil, fmt.Errorf("get appset: %s/%s err: %v", namespace, appName, err)
+		}
+		for _, c := range app.Spec.ClusterTopology.Clusters {
+			names = append(names, c.Name)
+		}
+	}
+
+	clusters := &workloadv1beta1.ClusterList{}
+	if err := cli.List(ctx, clusters); err != nil {
+		return nil, fmt.Errorf("list clusters err: %v", err)
+	}
+	endpoints := make(map[string]string, len(clusters.Items))
+	for i := range clusters.Items {
+		st := clusters.Items[i].Status.MonitoringStatus
+		if st != nil && st.PrometheusEndpoint != nil {
+			endpoints[clusters.Items[i].Name] = *st.PrometheusEndpoint
+		}
+	}
+
+	targets := make([]prom.Target, 0, len(names))
+	for _, name := range names {
+		targets = append(targets, prom.Target{Cluster: name, Endpoint: endpoints[name]})
+	}
+	return targets, nil
+}
+
+func parseUnixTime(s string, def time.Time) (time.Time, error) {
+	if s == "" {
+		return def, nil
+	}
+	sec, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid unix timestamp: %s", s)
+	}
+	return time.Unix(0, int64(sec*float64(time.Second))), nil
+}
+
+func parseMetricRange(c *gin.Context) (*promv1.Range, error) {
+	if c.Query("start") == "" {
+		return nil, nil
+	}
+
+	now := time.Now()
+	start, err := parseUnixTime(c.Query("start"), now)
+	if err != nil {
+		return nil, err
+	}
+	end, err := parseUnixTime(c.Query("end"), now)
+	if err != nil {
+		return nil, err
+	}
+	step := defaultMetricStep
+	if s := c.Query("step"); s != "" {
+		if step, err = time.ParseDuration(s); err != nil || step <= 0 {
+			return nil, fmt.Errorf("invalid step: %s", s)
+		}
+	}
+	if !end.After(start) {
+		return nil, fmt.Errorf("end must be after start")
+	}
+	if end.Sub(start)/step > maxMetricPoints {
+		return nil, fmt.Errorf("exceeded maximum resolution of %d points, increase the step", maxMetricPoints)
+	}
+	return &promv1.Range{Start: start, End: end, Step: step}, nil
+}
+
+// GetAppMetrics returns the metric of the app summed by the group or podSet from
+// the prometheus of each cluster.
+func (m *Manager) GetAppMetrics(c *gin.Context) {
+	appName := c.Param("appName")
+	namespace := c.DefaultQuery("namespace", "default")
+	clusterName := c.DefaultQuery("cluster", "")
+	metric := c.DefaultQuery("metric", "cpu")
+	by := c.DefaultQuery("by", "group")
+	window := c.DefaultQuery("window", defaultMetricWindow)
+
+	query, err := buildAppMetricQuery(metric, by, namespace, appName, window)
+	if err != nil {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{
+			"success":   false,
+			"message":   err.Error(),
+			"resultMap": nil,
+		})
+		return
+	}
+
+	r, err := parseMetricRange(c)
+	if err != nil {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{
+			"success":   false,
+			"message":   err.Error(),
+			"resultMap": nil,
+		})
+		return
+	}
+
+	ctx := c.Request.Context()
+	targets, err := m.getPrometheusTargets(ctx, clusterName, namespace, appName)
+	if err != nil {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{
+			"success":   false,
+			"message":   err.Error(),
+			"resultMap": nil,
+		})
+		return
+	}
+
+	var result *prom.Result
+	if r == nil {
+		result = m.Prom.Query(ctx, targets, query, time.Now())
+	} else {
+		result = m.Prom.QueryRange(ctx, targets, query, *r)
+	}
+
+	c.IndentedJSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": nil,
+		"resultMap": gin.H{
+			"metric":   metric,
+			"by":       by,
+			"query":    query,
+			"series":   result.Series,
+			"errors":   result.Errors,
+			"warnings": result.Warnings,
+		},
+	})
+}
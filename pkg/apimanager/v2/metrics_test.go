@@ -0,0 +1,45 @@
+package v2
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBuildAppMetricQuery(t *testing.T) {
+	cases := []struct {
+		metric   string
+		by       string
+		app      string
+		isErr    bool
+		contains []string
+	}{
+		{"cpu", "group", "bbcc", false, []string{"sum by (label_sym_group)", "container_cpu_usage_seconds_total", `label_app="bbcc"`, "[5m]"}},
+		{"memory", "podset", "bbcc", false, []string{"sum by (label_release)", `container_memory_working_set_bytes{namespace="default",container!="",container!="POD"} *`}},
+		{"errorRate", "group", "bbcc", false, []string{`status=~"5.."`, ") / sum by"}},
+		{"disk", "group", "bbcc", true, nil},
+		{"cpu", "zone", "bbcc", true, nil},
+		{"cpu", "group", `bbcc"}) or vector(1`, true, nil},
+	}
+
+	for _, c := range cases {
+		query, err := buildAppMetricQuery(c.metric, c.by, "default", c.app, "5m")
+		if c.isErr {
+			if err == nil {
+				t.Errorf("metric: %s by: %s app: %s expect error", c.metric, c.by, c.app)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("metric: %s unexpected error: %v", c.metric, err)
+			continue
+		}
+		if strings.Contains(query, "%!") {
+			t.Errorf("metric: %s bad format: %s", c.metric, query)
+		}
+		for _, s := range c.contains {
+			if !strings.Contains(query, s) {
+				t.Errorf("metric: %s expect %q in query: %s", c.metric, s, query)
+			}
+		}
+	}
+}
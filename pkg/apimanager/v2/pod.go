@@ -11,6 +11,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"gitlab.dmall.com/arch/sym-admin/pkg/apimanager/model"
 	workloadv1beta1 "gitlab.dmall.com/arch/sym-admin/pkg/apis/workload/v1beta1"
+	"gitlab.dmall.com/arch/sym-admin/pkg/router"
 	"gitlab.dmall.com/arch/sym-admin/pkg/utils"
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
@@ -350,6 +351,15 @@ func (m *Manager) DeletePodByName(c *gin.Context) {
 		return
 	}
 
+	if err := cluster.CheckMaintenance(); err != nil {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{
+			"success":   false,
+			"message":   err.Error(),
+			"resultMap": nil,
+		})
+		return
+	}
+
 	ctx := context.Background()
 	pod := &corev1.Pod{}
 	err = cluster.Client.Get(ctx, types.NamespacedName{
@@ -366,6 +376,7 @@ func (m *Manager) DeletePodByName(c *gin.Context) {
 		return
 	}
 
+	m.ClustersMgr.BeforePodDelete(ctx, cluster, pod, router.UserName(c))
 	err = cluster.Client.Delete(ctx, pod)
 	if err != nil {
 		klog.Errorf("delete pod error: %v", err)
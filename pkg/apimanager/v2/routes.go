@@ -19,12 +19,14 @@ func (m *Manager) Routes() []*router.Route {
 			Method:  "GET",
 			Path:    "/api/v2/cluster/:clusterCode/pods",
 			Handler: m.GetPodByLabels,
+			Queries: &router.Queries{Namespace: "namespace", App: "appName"},
 			Desc:    GetPodByLabelsDesc,
 		},
 		{
 			Method:  "DELETE",
 			Path:    "/api/v2/cluster/:clusterCode/namespace/:namespace/pod/:podName",
 			Handler: m.DeletePodByName,
+			Verb:    router.VerbDelete,
 			Desc:    DeletePodByNameDesc,
 		},
 		{
@@ -33,30 +35,76 @@ func (m *Manager) Routes() []*router.Route {
 			Handler: m.HandleLogs,
 			Desc:    HandleLogsDesc,
 		},
+		{
+			Method:  "GET",
+			Path:    "/api/v2/cluster/:clusterCode/namespace/:namespace/pods/:podName/logs/stream",
+			Handler: m.StreamPodLogs,
+			Desc:    StreamPodLogsDesc,
+		},
+		{
+			Method:  "GET",
+			Path:    "/api/v2/cluster/:clusterCode/logs/stream",
+			Handler: m.StreamAppLogs,
+			Queries: &router.Queries{Namespace: "namespace", App: "appName"},
+			Desc:    StreamAppLogsDesc,
+		},
 		{
 			Method:  "GET",
 			Path:    "/api/v2/cluster/:clusterCode/namespace/:namespace/pods/:podName/event",
 			Handler: m.GetPodEvent,
 			Desc:    GetPodEventDesc,
 		},
+		{
+			Method:  "GET",
+			Path:    "/api/v2/cluster/:clusterCode/events/history",
+			Handler: m.GetEventHistory,
+			Queries: &router.Queries{Namespace: "namespace", App: "appName"},
+			Desc:    GetEventHistoryDesc,
+		},
+		{
+			Method:  "POST",
+			Path:    "/api/v2/events",
+			Handler: m.PutEvents,
+			Verb:    router.VerbAdmin,
+			Desc:    PutEventsDesc,
+			Audit:   router.AuditNever,
+		},
+		{
+			Method:  "GET",
+			Path:    "/api/v2/cluster/:clusterCode/audit",
+			Handler: m.GetAuditEvents,
+			Queries: &router.Queries{Namespace: "namespace", App: "appName"},
+			Verb:    router.VerbAdmin,
+			Desc:    GetAuditEventsDesc,
+		},
 		{
 			Method:  "GET",
 			Path:    "/api/v2/cluster/:clusterCode/namespace/:namespace/pods/:podName/tail",
 			Handler: m.TailFile,
 			Desc:    TailFileDesc,
+			Audit:   router.AuditAlways,
 		},
 		{
 			Method:  "GET",
 			Path:    "/api/v2/cluster/:clusterCode/namespace/:namespace/pods/:podName/files",
 			Handler: m.ListFiles,
 			Desc:    ListFileDesc,
+			Audit:   router.AuditAlways,
 		},
 		{
 			Method:  "GET",
 			Path:    "/api/v2/cluster/:clusterCode/app/group/version",
 			Handler: m.GetAppGroupVersion,
+			Queries: &router.Queries{Namespace: "namespace", App: "appName"},
 			Desc:    GetAppGroupVersionDesc,
 		},
+		{
+			Method:  "GET",
+			Path:    "/api/v2/app/:appName/metrics",
+			Handler: m.GetAppMetrics,
+			Queries: &router.Queries{Cluster: "cluster", Namespace: "namespace", DefaultNamespace: "default"},
+			Desc:    GetAppMetricsDesc,
+		},
 	}
 
 	routes = append(routes, apiRoutes...)
@@ -61,6 +61,21 @@ type AppSetSpec struct {
 	// Topology describes the pods distribution detail between each of subsets.
 	// +optional
 	ClusterTopology ClusterTopology `json:"clusterTopology,omitempty"`
+	// Ingress requests an external hostname of the app.
+	// +optional
+	Ingress *AppIngressSpec `json:"ingress,omitempty"`
+}
+
+// AppIngressSpec routes the external hostname to the service of the app through
+// the ingress controller of each target cluster.
+type AppIngressSpec struct {
+	Host string `json:"host"`
+	// Path is the path prefix, default /.
+	Path string `json:"path,omitempty"`
+	// ServicePort is the port of the service, default 80.
+	ServicePort int32 `json:"servicePort,omitempty"`
+	// TLSSecretName is the tls secret in the namespace of the app, default the tls secret of the cluster.
+	TLSSecretName string `json:"tlsSecretName,omitempty"`
 }
 
 type AppSetUpdateStrategy struct {
@@ -1,7 +1,10 @@
 package v1beta1
 
 import (
+	"fmt"
+
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/version"
 )
@@ -40,6 +43,96 @@ type ClusterSpec struct {
 	AlertSpec    *AlertSpec        `json:"alertSpec,omitempty"`
 	Apps         []*HelmChartSpec  `json:"apps,omitempty"`
 	Pause        bool              `json:"pause"`
+	Maintenance  *MaintenanceSpec  `json:"maintenance,omitempty"`
+	Monitoring   *MonitoringSpec   `json:"monitoring,omitempty"`
+	LogRetention *LogRetentionSpec `json:"logRetention,omitempty"`
+}
+
+// MaintenanceSpec puts the cluster into maintenance mode. While the window is
+// active, AppSet controllers freeze rollouts to the cluster and the api server
+// refuses destructive operations against it.
+type MaintenanceSpec struct {
+	Enable bool   `json:"enable"`
+	Reason string `json:"reason,omitempty"`
+	// StartTime is the beginning of the maintenance window, empty means now.
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+	// EndTime is the end of the maintenance window, empty means until disabled.
+	EndTime *metav1.Time `json:"endTime,omitempty"`
+}
+
+// LogRetentionSpec is the retention of the app logs under /web/logs/app of the nodes,
+// enforced by the log agent of the offline-workload DaemonSet.
+type LogRetentionSpec struct {
+	Enable bool `json:"enable"`
+	// Default is the policy of the apps without their own policy.
+	Default *LogRetentionPolicy `json:"default,omitempty"`
+	Apps    []*AppLogRetention  `json:"apps,omitempty"`
+}
+
+// LogRetentionPolicy bounds the logs of an app on a node, the empty fields are not enforced.
+type LogRetentionPolicy struct {
+	// MaxAge deletes the files not modified for longer, e.g. 168h.
+	MaxAge *metav1.Duration `json:"maxAge,omitempty"`
+	// MaxSize bounds the total size of the logs of the app, the oldest files are deleted first.
+	MaxSize *resource.Quantity `json:"maxSize,omitempty"`
+	// CompressAfter gzips the files not modified for longer.
+	CompressAfter *metav1.Duration `json:"compressAfter,omitempty"`
+}
+
+// AppLogRetention is the policy of an app.
+type AppLogRetention struct {
+	// App is the directory of the app under /web/logs/app, e.g. dmall/bbcc or logback/bbcc,
+	// or the app name matching the directories of both layouts.
+	App                string `json:"app"`
+	LogRetentionPolicy `json:",inline"`
+}
+
+// Validate ...
+func (in *LogRetentionSpec) Validate() error {
+	if in == nil {
+		return nil
+	}
+	if err := in.Default.Validate(); err != nil {
+		return fmt.Errorf("default: %v", err)
+	}
+	for i, app := range in.Apps {
+		if app == nil || app.App == "" {
+			return fmt.Errorf("apps[%d]: app is required", i)
+		}
+		if err := app.LogRetentionPolicy.Validate(); err != nil {
+			return fmt.Errorf("apps[%d]: %v", i, err)
+		}
+	}
+	return nil
+}
+
+// Validate ...
+func (in *LogRetentionPolicy) Validate() error {
+	if in == nil {
+		return nil
+	}
+	if in.MaxAge != nil && in.MaxAge.Duration <= 0 {
+		return fmt.Errorf("maxAge must be positive")
+	}
+	if in.MaxSize != nil && in.MaxSize.Sign() <= 0 {
+		return fmt.Errorf("maxSize must be positive")
+	}
+	if in.CompressAfter != nil && in.CompressAfter.Duration <= 0 {
+		return fmt.Errorf("compressAfter must be positive")
+	}
+	return nil
+}
+
+// Validate checks the fields of the spec which can't be expressed by the openapi schema,
+// the cluster controller skips the invalid block and reports it in the SpecValid condition.
+func (in *Cluster) Validate() error {
+	if err := in.Spec.Monitoring.Validate(); err != nil {
+		return fmt.Errorf("spec.monitoring: %v", err)
+	}
+	if err := in.Spec.LogRetention.Validate(); err != nil {
+		return fmt.Errorf("spec.logRetention: %v", err)
+	}
+	return nil
 }
 
 type HelmSpec struct {
@@ -75,6 +168,30 @@ type ClusterStatus struct {
 	Version          *version.Info      `json:"version,omitempty"`
 	MonitoringStatus *MonitoringStatus  `json:"monitoringStatus,omitempty"`
 	NodeDetail       *NodeDetail        `json:"nodeDetail"`
+	Maintenance      *MaintenanceStatus `json:"maintenance,omitempty"`
+	Conditions       []ClusterCondition `json:"conditions,omitempty"`
+}
+
+type ClusterConditionType string
+
+const (
+	// ClusterSpecValid is false while the monitoring or log retention block fails the validation.
+	ClusterSpecValid ClusterConditionType = "SpecValid"
+)
+
+type ClusterCondition struct {
+	Type               ClusterConditionType `json:"type"`
+	Status             v1.ConditionStatus   `json:"status"`
+	Reason             string               `json:"reason,omitempty"`
+	Message            string               `json:"message,omitempty"`
+	LastTransitionTime metav1.Time          `json:"lastTransitionTime,omitempty"`
+}
+
+type MaintenanceStatus struct {
+	Active    bool         `json:"active"`
+	Reason    string       `json:"reason,omitempty"`
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+	EndTime   *metav1.Time `json:"endTime,omitempty"`
 }
 
 type ComponentStatus struct {
@@ -130,4 +247,24 @@ type NodeStatus struct {
 	MemoryUsagePercent  int32           `json:"memoryUsagePercent"`
 	PodUsagePercent     int32           `json:"podUsagePercent"`
 	StorageUsagePercent int32           `json:"storageUsagePercent"`
+	LogUsage            *NodeLogUsage   `json:"logUsage,omitempty"`
+}
+
+// NodeLogUsage is the disk usage of the app logs on a node reported by the log agent.
+type NodeLogUsage struct {
+	TotalBytes int64          `json:"totalBytes"`
+	Apps       []*AppLogUsage `json:"apps,omitempty"`
+	// ReclaimedBytes are the bytes freed by the retention in the last run.
+	ReclaimedBytes int64       `json:"reclaimedBytes"`
+	UpdateTime     metav1.Time `json:"updateTime"`
+}
+
+// AppLogUsage is the disk usage of the logs of an app.
+type AppLogUsage struct {
+	App   string `json:"app"`
+	Bytes int64  `json:"bytes"`
+	Files int    `json:"files"`
+	// PodDirs are the log directories of the pods, the live and the offline ones.
+	PodDirs       int          `json:"podDirs"`
+	OldestModTime *metav1.Time `json:"oldestModTime,omitempty"`
 }
@@ -0,0 +1,136 @@
+package v1beta1
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/ghodss/yaml"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// MonitoringSpec is the expected setting of the monitoring stack (prometheus-operator) of the cluster.
+// The legacy string keys in the values of the monitor app are still accepted, the fields set here take precedence.
+type MonitoringSpec struct {
+	Prometheus   *PrometheusSpec        `json:"prometheus,omitempty"`
+	Alertmanager *AlertmanagerSpec      `json:"alertmanager,omitempty"`
+	Grafana      *GrafanaSpec           `json:"grafana,omitempty"`
+	Ingress      *MonitoringIngressSpec `json:"ingress,omitempty"`
+	// AdditionalScrapeConfigs is a yaml list of prometheus scrape configs appended to the generated ones.
+	AdditionalScrapeConfigs string `json:"additionalScrapeConfigs,omitempty"`
+	// IstioScrape adds the istio mesh scrape configs.
+	IstioScrape bool `json:"istioScrape,omitempty"`
+	// CustomResourcesConfig enables the resources and affinity setting of the prometheus operator.
+	CustomResourcesConfig bool `json:"customResourcesConfig,omitempty"`
+}
+
+type PrometheusSpec struct {
+	// Retention is how long to retain the samples, e.g. 2d.
+	// +kubebuilder:validation:Pattern=`^[0-9]+(ms|s|m|h|d|w|y)$`
+	Retention string                       `json:"retention,omitempty"`
+	Resources *corev1.ResourceRequirements `json:"resources,omitempty"`
+	Storage   *LocalStorageSpec            `json:"storage,omitempty"`
+	// SelectorOnlySystem only selects the service monitors and rules in the system namespaces.
+	SelectorOnlySystem bool `json:"selectorOnlySystem,omitempty"`
+	// Host is the ingress host, default <ingress head>.prometheus.dmall.com.
+	Host string `json:"host,omitempty"`
+}
+
+type AlertmanagerSpec struct {
+	Enabled *bool `json:"enabled,omitempty"`
+	// Host is the ingress host, default <ingress head>.alertmanager.dmall.com.
+	Host string `json:"host,omitempty"`
+}
+
+type GrafanaSpec struct {
+	Enabled *bool             `json:"enabled,omitempty"`
+	Storage *LocalStorageSpec `json:"storage,omitempty"`
+	// Host is the ingress host, default <ingress head>.grafana.dmall.com.
+	Host string `json:"host,omitempty"`
+}
+
+type MonitoringIngressSpec struct {
+	Enabled *bool `json:"enabled,omitempty"`
+	// Class is the ingress class, default the ingress class of the cluster, contour if not set.
+	Class string `json:"class,omitempty"`
+}
+
+// LocalStorageSpec is the local persistent volume on the node preserved for the add-ons.
+type LocalStorageSpec struct {
+	// Path is the absolute host directory of the volume.
+	// +kubebuilder:validation:Pattern=`^/.+`
+	Path string             `json:"path,omitempty"`
+	Size *resource.Quantity `json:"size,omitempty"`
+	// Reclaim is what happens to the host directory after the add-on is removed.
+	// +kubebuilder:validation:Enum=Retain;Delete
+	Reclaim string `json:"reclaim,omitempty"`
+}
+
+// Validate checks the fields which can't be expressed by the openapi schema.
+func (in *MonitoringSpec) Validate() error {
+	if in == nil {
+		return nil
+	}
+
+	if p := in.Prometheus; p != nil {
+		if err := validateResources(p.Resources); err != nil {
+			return fmt.Errorf("prometheus: %v", err)
+		}
+		if err := p.Storage.Validate(); err != nil {
+			return fmt.Errorf("prometheus: %v", err)
+		}
+	}
+
+	if g := in.Grafana; g != nil {
+		if err := g.Storage.Validate(); err != nil {
+			return fmt.Errorf("grafana: %v", err)
+		}
+	}
+
+	if in.AdditionalScrapeConfigs != "" {
+		scrapeConfigs := []map[string]interface{}{}
+		if err := yaml.Unmarshal([]byte(in.AdditionalScrapeConfigs), &scrapeConfigs); err != nil {
+			return fmt.Errorf("additionalScrapeConfigs must be a yaml list: %v", err)
+		}
+		for i, sc := range scrapeConfigs {
+			if _, ok := sc["job_name"]; !ok {
+				return fmt.Errorf("additionalScrapeConfigs[%d] job_name is required", i)
+			}
+		}
+	}
+	return nil
+}
+
+// Validate ...
+func (in *LocalStorageSpec) Validate() error {
+	if in == nil {
+		return nil
+	}
+
+	if in.Path != "" && (!filepath.IsAbs(in.Path) || filepath.Clean(in.Path) == "/") {
+		return fmt.Errorf("storage path: %q must be an absolute path except /", in.Path)
+	}
+	if in.Size != nil && in.Size.Sign() <= 0 {
+		return fmt.Errorf("storage size: %s must be positive", in.Size.String())
+	}
+	switch in.Reclaim {
+	case "", "Retain", "Delete":
+	default:
+		return fmt.Errorf("storage reclaim: %q must be Retain or Delete", in.Reclaim)
+	}
+	return nil
+}
+
+func validateResources(r *corev1.ResourceRequirements) error {
+	if r == nil {
+		return nil
+	}
+
+	for name, req := range r.Requests {
+		limit, ok := r.Limits[name]
+		if ok && req.Cmp(limit) > 0 {
+			return fmt.Errorf("%s request: %s must be less than or equal to limit: %s", name, req.String(), limit.String())
+		}
+	}
+	return nil
+}
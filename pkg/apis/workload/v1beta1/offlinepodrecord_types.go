@@ -0,0 +1,58 @@
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func init() {
+	SchemeBuilder.Register(&OfflinePodRecord{}, &OfflinePodRecordList{})
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:shortName=opr
+// +kubebuilder:printcolumn:name="APP",type="string",JSONPath=".spec.appName"
+// +kubebuilder:printcolumn:name="CLUSTER",type="string",JSONPath=".spec.clusterName"
+// +kubebuilder:printcolumn:name="NODE",type="string",JSONPath=".spec.nodeName"
+// +kubebuilder:printcolumn:name="CAUSE",type="string",JSONPath=".spec.deletionCause"
+// +kubebuilder:printcolumn:name="OFFLINE",type="date",JSONPath=".spec.offlineTime"
+// +genclient
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// OfflinePodRecord is a pod deleted from a member cluster, one record for each pod. The
+// records of all the namespaces are kept in the namespace of sym-admin, labeled by namespace,
+// app, cluster and node, and collected when older than the TTL of the offline pod controller.
+type OfflinePodRecord struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	Spec              OfflinePodRecordSpec `json:"spec"`
+}
+
+// +kubebuilder:object:root=true
+// OfflinePodRecordList implements list of OfflinePodRecord.
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+type OfflinePodRecordList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []OfflinePodRecord `json:"items"`
+}
+
+// OfflinePodRecordSpec is the last state of the offline pod.
+type OfflinePodRecordSpec struct {
+	PodName     string `json:"podName"`
+	PodUID      string `json:"podUID,omitempty"`
+	ClusterName string `json:"clusterName"`
+	Namespace   string `json:"namespace,omitempty"`
+	// AppName is the name of the top-level owner of the pod, e.g. the AppSet, the Deployment.
+	AppName     string            `json:"appName"`
+	OwnerKind   string            `json:"ownerKind,omitempty"`
+	NodeName    string            `json:"nodeName,omitempty"`
+	HostIP      string            `json:"hostIP,omitempty"`
+	PodIP       string            `json:"podIP,omitempty"`
+	ContainerID string            `json:"containerID,omitempty"`
+	PodLabels   map[string]string `json:"podLabels,omitempty"`
+	OfflineTime metav1.Time       `json:"offlineTime"`
+	// DeletionCause is why the pod is deleted, e.g. Evicted, OOMKilled, UserDeleted,
+	// RolloutReplaced, NodeLost, Unknown.
+	DeletionCause   string `json:"deletionCause,omitempty"`
+	DeletionMessage string `json:"deletionMessage,omitempty"`
+}
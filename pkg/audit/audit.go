@@ -0,0 +1,134 @@
+// Package audit records the mutating and interactive requests of the api, e.g. the pod
+// deletes, the restarts, the execs and the terminal sessions. The events are written to
+// a rotating file which the query api reads, and optionally pushed to a webhook.
+package audit
+
+import (
+	"time"
+
+	"github.com/gofrs/uuid"
+	"k8s.io/klog"
+)
+
+// the stages of the events, the interactive sessions record both
+const (
+	StageStarted   = "Started"
+	StageCompleted = "Completed"
+)
+
+// the results of the events
+const (
+	ResultSuccess = "success"
+	ResultFailure = "failure"
+	// ResultDenied is of the requests not authenticated or not authorized
+	ResultDenied = "denied"
+)
+
+// ObjectRef is a target of a request.
+type ObjectRef struct {
+	Cluster   string `json:"cluster,omitempty"`
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+}
+
+// Event is an audited request.
+type Event struct {
+	ID string `json:"id"`
+	// RequestID is the same of the stages of a session
+	RequestID string    `json:"requestID"`
+	Stage     string    `json:"stage"`
+	Time      time.Time `json:"time"`
+
+	User       string   `json:"user,omitempty"`
+	Groups     []string `json:"groups,omitempty"`
+	AuthMethod string   `json:"authMethod,omitempty"`
+	SourceIP   string   `json:"sourceIP"`
+	UserAgent  string   `json:"userAgent,omitempty"`
+
+	Method    string            `json:"method"`
+	Path      string            `json:"path"`
+	Verb      string            `json:"verb"`
+	Cluster   string            `json:"cluster,omitempty"`
+	Namespace string            `json:"namespace,omitempty"`
+	App       string            `json:"app,omitempty"`
+	Objects   []*ObjectRef      `json:"objects,omitempty"`
+	Params    map[string]string `json:"params,omitempty"`
+
+	Status   int           `json:"status,omitempty"`
+	Result   string        `json:"result,omitempty"`
+	Error    string        `json:"error,omitempty"`
+	Duration time.Duration `json:"duration,omitempty"`
+}
+
+// Sink receives the events.
+type Sink interface {
+	Write(ev *Event) error
+	Close() error
+}
+
+// Options ...
+type Options struct {
+	// Path is the audit file, the audit is disabled when it is empty
+	Path string
+	// MaxSize is the megabytes of the file before rotated
+	MaxSize int
+	// MaxBackups and MaxAge(days) are of the rotated files
+	MaxBackups int
+	MaxAge     int
+	// WebhookEndpoint receives the events in json if set
+	WebhookEndpoint string
+	WebhookHeaders  map[string]string
+}
+
+// Auditor writes the events to the file and the webhook.
+type Auditor struct {
+	file  *FileSink
+	sinks []Sink
+}
+
+// New creates the auditor of the options, nil if the audit is disabled.
+func New(opt *Options) (*Auditor, error) {
+	if opt.Path == "" {
+		klog.Warningf("the api requests are not audited without the audit file")
+		return nil, nil
+	}
+
+	file, err := NewFileSink(opt)
+	if err != nil {
+		return nil, err
+	}
+	a := &Auditor{file: file, sinks: []Sink{file}}
+	if opt.WebhookEndpoint != "" {
+		a.sinks = append(a.sinks, NewWebhookSink(opt.WebhookEndpoint, opt.WebhookHeaders))
+	}
+	return a, nil
+}
+
+// Log writes the event to all the sinks, the failures are logged only.
+func (a *Auditor) Log(ev *Event) {
+	if ev.ID == "" {
+		ev.ID = uuid.Must(uuid.NewV4()).String()
+	}
+	for _, s := range a.sinks {
+		if err := s.Write(ev); err != nil {
+			klog.Errorf("write audit event %s err: %v", ev.ID, err)
+		}
+	}
+}
+
+// Query reads the events of the query from the audit files.
+func (a *Auditor) Query(q *Query) ([]*Event, error) {
+	return a.file.Query(q)
+}
+
+// Close ...
+func (a *Auditor) Close() error {
+	var err error
+	for _, s := range a.sinks {
+		if e := s.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
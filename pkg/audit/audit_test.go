@@ -0,0 +1,168 @@
+package audit
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gitlab.dmall.com/arch/sym-admin/pkg/router"
+)
+
+func TestRouteMiddleware(t *testing.T) {
+	dir, err := ioutil.TempDir("", "audit")
+	if err != nil {
+		t.Fatalf("tempdir err: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	received := make(chan *Event, 10)
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ev := &Event{}
+		json.NewDecoder(r.Body).Decode(ev)
+		received <- ev
+	}))
+	defer hook.Close()
+
+	a, err := New(&Options{Path: filepath.Join(dir, "audit.log"), WebhookEndpoint: hook.URL})
+	if err != nil {
+		t.Fatalf("new auditor err: %v", err)
+	}
+	defer a.Close()
+
+	gin.SetMode(gin.TestMode)
+	rt := router.NewRouter(&router.Options{Auth: &router.AuthConfig{
+		Authentication: router.AuthenticationConfig{Tokens: []*router.StaticToken{
+			{Token: "t-alice", User: "alice", Groups: []string{"sre"}},
+			{Token: "t-bob", User: "bob"},
+		}},
+		Authorization: &router.AuthorizationConfig{Rules: []*router.Rule{
+			{Groups: []string{"sre"}, Verbs: []string{router.Wildcard}},
+		}},
+	}})
+	rt.UseRoute(a.RouteMiddleware)
+	ok := func(c *gin.Context) { c.String(http.StatusOK, "ok") }
+	rt.AddRoutes("cluster", []*router.Route{
+		{Method: "GET", Path: "/api/cluster/:name/namespace/:namespace/pod/:podName", Handler: ok},
+		{Method: "DELETE", Path: "/api/cluster/:name/namespace/:namespace/pod/:podName", Handler: ok, Verb: router.VerbDelete},
+		{Method: "POST", Path: "/api/cluster/:name/namespace/:namespace/app/:appName/restart", Verb: router.VerbRestart, Handler: func(c *gin.Context) {
+			c.PostForm("group")
+			AddObjects(c, &ObjectRef{Cluster: "tx-test", Kind: "Pod", Namespace: "dmall-inner", Name: "bbcc-0"})
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "maintaining"})
+		}},
+		{Method: "GET", Path: "/api/cluster/:name/namespace/:namespace/pods/:podName/tail", Handler: ok, Audit: router.AuditAlways},
+		{Method: "POST", Path: "/api/v2/events", Handler: ok, Audit: router.AuditNever},
+	})
+
+	requests := []struct {
+		method string
+		path   string
+		token  string
+		body   string
+	}{
+		{"GET", "/api/cluster/tx-test/namespace/dmall-inner/pods/bbcc-0/tail", "t-alice", ""},
+		{"POST", "/api/v2/events", "t-alice", ""},
+		{"GET", "/api/cluster/tx-test/namespace/dmall-inner/pod/bbcc-0", "t-alice", ""},
+		{"DELETE", "/api/cluster/tx-test/namespace/dmall-inner/pod/bbcc-0?access_token=secret", "t-alice", ""},
+		{"DELETE", "/api/cluster/tx-test/namespace/dmall-inner/pod/bbcc-1", "t-bob", ""},
+		{"POST", "/api/cluster/tx-test/namespace/dmall-inner/app/bbcc/restart", "t-alice", "group=blue"},
+	}
+	for _, r := range requests {
+		req := httptest.NewRequest(r.method, r.path, strings.NewReader(r.body))
+		req.Header.Set("Authorization", "Bearer "+r.token)
+		// the header of the untrusted peers is ignored
+		req.Header.Set("X-Forwarded-For", "10.0.0.1")
+		if r.body != "" {
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+		rt.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	events, err := a.Query(&Query{})
+	if err != nil {
+		t.Fatalf("query err: %v", err)
+	}
+	if len(events) != 4 {
+		t.Fatalf("expect 4 events, current: %d", len(events))
+	}
+
+	// the newest first
+	restart, denied, deleted, tail := events[0], events[1], events[2], events[3]
+	if tail.Verb != router.VerbRead || tail.Path != "/api/cluster/tx-test/namespace/dmall-inner/pods/bbcc-0/tail" {
+		t.Errorf("unexpected tail event: %+v", tail)
+	}
+	if deleted.User != "alice" || deleted.Verb != router.VerbDelete || deleted.Result != ResultSuccess ||
+		deleted.Cluster != "tx-test" || len(deleted.Objects) != 1 || deleted.Objects[0].Name != "bbcc-0" {
+		t.Errorf("unexpected delete event: %+v", deleted)
+	}
+	if deleted.SourceIP != "192.0.2.1" {
+		t.Errorf("expect the source of the peer, current: %s", deleted.SourceIP)
+	}
+	if _, ok := deleted.Params["access_token"]; ok {
+		t.Errorf("expect the access token redacted")
+	}
+	if denied.User != "bob" || denied.Result != ResultDenied || denied.Status != http.StatusForbidden {
+		t.Errorf("unexpected denied event: %+v", denied)
+	}
+	if restart.Result != ResultFailure || restart.Params["group"] != "blue" || len(restart.Objects) != 2 ||
+		!strings.Contains(restart.Error, "maintaining") {
+		t.Errorf("unexpected restart event: %+v", restart)
+	}
+
+	if events, _ := a.Query(&Query{Result: ResultDenied}); len(events) != 1 {
+		t.Errorf("expect 1 denied event, current: %d", len(events))
+	}
+	if events, _ := a.Query(&Query{User: "alice", Limit: 1}); len(events) != 1 || events[0].Verb != router.VerbRestart {
+		t.Errorf("expect the newest event of alice, current: %+v", events)
+	}
+
+	for i := 0; i < 4; i++ {
+		select {
+		case <-received:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("expect 4 events sent to the webhook, current: %d", i)
+		}
+	}
+}
+
+func TestQueryBackups(t *testing.T) {
+	dir, err := ioutil.TempDir("", "audit")
+	if err != nil {
+		t.Fatalf("tempdir err: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	now := time.Now()
+	old := &Event{ID: "old", User: "alice", Verb: router.VerbExec, Time: now.Add(-48 * time.Hour)}
+	raw, _ := json.Marshal(old)
+	backup := filepath.Join(dir, "audit-2020-09-01T00-00-00.000.log")
+	if err := ioutil.WriteFile(backup, append(raw, '\n'), 0640); err != nil {
+		t.Fatalf("write backup err: %v", err)
+	}
+	os.Chtimes(backup, old.Time, old.Time)
+	ioutil.WriteFile(filepath.Join(dir, "other.log"), append(raw, '\n'), 0640)
+
+	f, err := NewFileSink(&Options{Path: filepath.Join(dir, "audit.log")})
+	if err != nil {
+		t.Fatalf("new file sink err: %v", err)
+	}
+	defer f.Close()
+	f.Write(&Event{ID: "new", User: "alice", Verb: router.VerbExec, Time: now})
+
+	events, err := f.Query(&Query{Verb: router.VerbExec})
+	if err != nil {
+		t.Fatalf("query err: %v", err)
+	}
+	if len(events) != 2 || events[0].ID != "new" || events[1].ID != "old" {
+		t.Errorf("expect the events of the backup, current: %+v", events)
+	}
+	if events, _ := f.Query(&Query{Start: now.Add(-time.Hour)}); len(events) != 1 {
+		t.Errorf("expect the backup skipped by the start, current: %d", len(events))
+	}
+}
@@ -0,0 +1,196 @@
+package audit
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/natefinch/lumberjack.v2"
+	"k8s.io/klog"
+)
+
+// DefaultQueryLimit is the limit of a query without one.
+const DefaultQueryLimit = 500
+
+// maxLineSize bounds an event line read by the queries
+const maxLineSize = 1 << 20
+
+// Query selects the events, the empty fields match all.
+type Query struct {
+	User      string
+	Cluster   string
+	Namespace string
+	App       string
+	Verb      string
+	Result    string
+	Start     time.Time
+	End       time.Time
+	Limit     int
+}
+
+// Matches ...
+func (q *Query) Matches(ev *Event) bool {
+	return (q.User == "" || q.User == ev.User) &&
+		(q.Cluster == "" || q.Cluster == ev.Cluster) &&
+		(q.Namespace == "" || q.Namespace == ev.Namespace) &&
+		(q.App == "" || q.App == ev.App) &&
+		(q.Verb == "" || q.Verb == ev.Verb) &&
+		(q.Result == "" || q.Result == ev.Result) &&
+		(q.Start.IsZero() || !ev.Time.Before(q.Start)) &&
+		(q.End.IsZero() || !ev.Time.After(q.End))
+}
+
+// FileSink writes the events in json lines to the file rotated by the size.
+type FileSink struct {
+	mu     sync.Mutex
+	path   string
+	writer *lumberjack.Logger
+}
+
+// NewFileSink ...
+func NewFileSink(opt *Options) (*FileSink, error) {
+	if err := os.MkdirAll(filepath.Dir(opt.Path), 0750); err != nil {
+		return nil, err
+	}
+	return &FileSink{
+		path: opt.Path,
+		writer: &lumberjack.Logger{
+			Filename:   opt.Path,
+			MaxSize:    opt.MaxSize,
+			MaxBackups: opt.MaxBackups,
+			MaxAge:     opt.MaxAge,
+		},
+	}, nil
+}
+
+// Write ...
+func (f *FileSink) Write(ev *Event) error {
+	raw, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	_, err = f.writer.Write(append(raw, '\n'))
+	return err
+}
+
+// Close ...
+func (f *FileSink) Close() error {
+	return f.writer.Close()
+}
+
+// files returns the audit file and its backups, the newest first. The backups are named
+// by lumberjack as <name>-<time>.<ext>.
+func (f *FileSink) files() ([]os.FileInfo, []string, error) {
+	ext := filepath.Ext(f.path)
+	prefix := strings.TrimSuffix(filepath.Base(f.path), ext) + "-"
+	dir := filepath.Dir(f.path)
+
+	d, err := os.Open(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	infos, err := d.Readdir(-1)
+	d.Close()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var selected []os.FileInfo
+	for _, info := range infos {
+		name := info.Name()
+		if !info.Mode().IsRegular() {
+			continue
+		}
+		if name == filepath.Base(f.path) ||
+			(strings.HasPrefix(name, prefix) && (strings.HasSuffix(name, ext) || strings.HasSuffix(name, ext+".gz"))) {
+			selected = append(selected, info)
+		}
+	}
+	sort.Slice(selected, func(i, j int) bool {
+		return selected[i].ModTime().After(selected[j].ModTime())
+	})
+	paths := make([]string, len(selected))
+	for i, info := range selected {
+		paths[i] = filepath.Join(dir, info.Name())
+	}
+	return selected, paths, nil
+}
+
+func readEvents(p string, q *Query) ([]*Event, error) {
+	file, err := os.Open(p)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var r io.Reader = file
+	if strings.HasSuffix(p, ".gz") {
+		zr, err := gzip.NewReader(file)
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		r = zr
+	}
+
+	var events []*Event
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxLineSize)
+	for scanner.Scan() {
+		ev := &Event{}
+		// the line being written is skipped
+		if err := json.Unmarshal(scanner.Bytes(), ev); err != nil {
+			continue
+		}
+		if q.Matches(ev) {
+			events = append(events, ev)
+		}
+	}
+	return events, scanner.Err()
+}
+
+// Query scans the audit files for the events, the newest first.
+func (f *FileSink) Query(q *Query) ([]*Event, error) {
+	limit := q.Limit
+	if limit <= 0 {
+		limit = DefaultQueryLimit
+	}
+
+	infos, paths, err := f.files()
+	if err != nil {
+		return nil, err
+	}
+
+	var events []*Event
+	for i, p := range paths {
+		// the files are written in order, the older files are out of the range
+		if !q.Start.IsZero() && infos[i].ModTime().Before(q.Start) {
+			break
+		}
+		evs, err := readEvents(p, q)
+		if err != nil {
+			klog.Warningf("read audit file %s err: %v", p, err)
+		}
+		events = append(events, evs...)
+		if len(events) >= limit {
+			break
+		}
+	}
+
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Time.After(events[j].Time)
+	})
+	if len(events) > limit {
+		events = events[:limit]
+	}
+	return events, nil
+}
@@ -0,0 +1,174 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gofrs/uuid"
+	"gitlab.dmall.com/arch/sym-admin/pkg/router"
+)
+
+const (
+	objectsKey = "auditObjects"
+	// maxErrorSize bounds the response body recorded as the error of the failed requests
+	maxErrorSize = 1024
+)
+
+// redactedParams are never recorded
+var redactedParams = map[string]bool{"access_token": true, "token": true, "password": true}
+
+// AddObjects records the targets of the request besides the pod and the app of the path,
+// e.g. the pods deleted by a restart.
+func AddObjects(c *gin.Context, objects ...*ObjectRef) {
+	var refs []*ObjectRef
+	if v, ok := c.Get(objectsKey); ok {
+		refs, _ = v.([]*ObjectRef)
+	}
+	c.Set(objectsKey, append(refs, objects...))
+}
+
+// responseWriter keeps the body of the failed responses and reports the websocket upgrades.
+type responseWriter struct {
+	gin.ResponseWriter
+	body     bytes.Buffer
+	hijacked bool
+	onHijack func()
+}
+
+func (w *responseWriter) capture(b []byte) {
+	if w.Status() < http.StatusBadRequest {
+		return
+	}
+	if n := maxErrorSize - w.body.Len(); n > 0 {
+		if len(b) > n {
+			b = b[:n]
+		}
+		w.body.Write(b)
+	}
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	w.capture(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *responseWriter) WriteString(s string) (int, error) {
+	w.capture([]byte(s))
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := w.ResponseWriter.Hijack()
+	if err == nil {
+		w.hijacked = true
+		w.onHijack()
+	}
+	return conn, rw, err
+}
+
+func requestParams(r *http.Request) map[string]string {
+	values := r.URL.Query()
+	for k, v := range r.PostForm {
+		values[k] = append(values[k], v...)
+	}
+	if len(values) == 0 {
+		return nil
+	}
+	params := make(map[string]string, len(values))
+	for k, v := range values {
+		if redactedParams[strings.ToLower(k)] {
+			continue
+		}
+		params[k] = strings.Join(v, ",")
+	}
+	return params
+}
+
+// newEvent reads the request of the context, the user is known after the authentication.
+func newEvent(c *gin.Context, requestID, stage string) *Event {
+	ev := &Event{
+		RequestID: requestID,
+		Stage:     stage,
+		Time:      time.Now(),
+		SourceIP:  router.SourceIP(c.Request),
+		UserAgent: c.Request.UserAgent(),
+		Method:    c.Request.Method,
+		Path:      c.Request.URL.Path,
+		Params:    requestParams(c.Request),
+	}
+	if u := router.GetUser(c); u != nil {
+		ev.User, ev.Groups, ev.AuthMethod = u.Name, u.Groups, u.Method
+	}
+	if attrs := router.GetAttributes(c); attrs != nil {
+		ev.Verb, ev.Cluster, ev.Namespace, ev.App = attrs.Verb, attrs.Cluster, attrs.Namespace, attrs.App
+		if attrs.Pod != "" {
+			ev.Objects = append(ev.Objects, &ObjectRef{Cluster: attrs.Cluster, Kind: "Pod", Namespace: attrs.Namespace, Name: attrs.Pod})
+		} else if attrs.App != "" {
+			ev.Objects = append(ev.Objects, &ObjectRef{Cluster: attrs.Cluster, Kind: "App", Namespace: attrs.Namespace, Name: attrs.App})
+		}
+	}
+	if v, ok := c.Get(objectsKey); ok {
+		refs, _ := v.([]*ObjectRef)
+		ev.Objects = append(ev.Objects, refs...)
+	}
+	return ev
+}
+
+func resultOf(status int) string {
+	switch {
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		return ResultDenied
+	case status >= http.StatusBadRequest:
+		return ResultFailure
+	}
+	return ResultSuccess
+}
+
+// RouteMiddleware audits the routes of the verbs other than read and the routes audited
+// always, it is added to the router by UseRoute. The interactive sessions are recorded when
+// upgraded and when closed.
+func (a *Auditor) RouteMiddleware(route *router.Route) gin.HandlerFunc {
+	switch route.Audit {
+	case router.AuditNever:
+		return nil
+	case router.AuditAlways:
+	default:
+		if route.GetVerb() == router.VerbRead {
+			return nil
+		}
+	}
+	return func(c *gin.Context) {
+		start := time.Now()
+		requestID := c.GetHeader("X-Request-Id")
+		if requestID == "" {
+			requestID = uuid.Must(uuid.NewV4()).String()
+		}
+
+		w := &responseWriter{ResponseWriter: c.Writer}
+		w.onHijack = func() {
+			a.Log(newEvent(c, requestID, StageStarted))
+		}
+		c.Writer = w
+		c.Next()
+		c.Writer = w.ResponseWriter
+
+		ev := newEvent(c, requestID, StageCompleted)
+		ev.Duration = time.Since(start)
+		ev.Status = w.Status()
+		if w.hijacked {
+			ev.Status = http.StatusSwitchingProtocols
+		}
+		ev.Result = resultOf(ev.Status)
+		if len(c.Errors) > 0 {
+			ev.Error = c.Errors.String()
+		} else if w.body.Len() > 0 {
+			ev.Error = w.body.String()
+		}
+		a.Log(ev)
+	}
+}
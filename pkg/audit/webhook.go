@@ -0,0 +1,109 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"k8s.io/klog"
+)
+
+const (
+	webhookQueueSize = 1000
+	webhookTimeout   = 10 * time.Second
+)
+
+// WebhookSink posts the events in json to the endpoint in the background, the events
+// are dropped when the queue is full so the requests are never blocked by the webhook.
+type WebhookSink struct {
+	endpoint string
+	headers  map[string]string
+	client   *http.Client
+	queue    chan *Event
+	wg       sync.WaitGroup
+	mu       sync.RWMutex
+	closed   bool
+}
+
+// NewWebhookSink ...
+func NewWebhookSink(endpoint string, headers map[string]string) *WebhookSink {
+	w := &WebhookSink{
+		endpoint: endpoint,
+		headers:  headers,
+		client:   &http.Client{Timeout: webhookTimeout},
+		queue:    make(chan *Event, webhookQueueSize),
+	}
+	w.wg.Add(1)
+	go w.run()
+	return w
+}
+
+// Write ...
+func (w *WebhookSink) Write(ev *Event) error {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	if w.closed {
+		return fmt.Errorf("the webhook is closed")
+	}
+	select {
+	case w.queue <- ev:
+		return nil
+	default:
+		return fmt.Errorf("the webhook queue is full")
+	}
+}
+
+func (w *WebhookSink) run() {
+	defer w.wg.Done()
+	for ev := range w.queue {
+		if err := w.send(ev); err != nil {
+			klog.Errorf("send audit event %s to %s err: %v", ev.ID, w.endpoint, err)
+		}
+	}
+}
+
+func (w *WebhookSink) send(ev *Event) error {
+	raw, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), webhookTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.endpoint, bytes.NewReader(raw))
+	if err != nil {
+		return err
+	}
+	req.Header.Add("Content-Type", "application/json")
+	for k, v := range w.headers {
+		req.Header.Add(k, v)
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("unexpected status %d: %s", resp.StatusCode, body)
+	}
+	return nil
+}
+
+// Close sends the queued events and stops.
+func (w *WebhookSink) Close() error {
+	w.mu.Lock()
+	if !w.closed {
+		w.closed = true
+		close(w.queue)
+	}
+	w.mu.Unlock()
+	w.wg.Wait()
+	return nil
+}
@@ -20,14 +20,44 @@ func NewConstantBackoffPolicy(config *ConstantBackoffConfig) *backoff.Constant {
 	return backoff.NewConstant(config.Delay, backoff.WithMaxRetries(config.MaxRetries), backoff.WithMaxElapsedTime(config.MaxElapsedTime))
 }
 
+// ExponentialBackoffConfig holds the config for exponential backoff policy
+type ExponentialBackoffConfig struct {
+	Interval       time.Duration
+	MaxInterval    time.Duration
+	MaxElapsedTime time.Duration
+	MaxRetries     int
+}
+
+// NewExponentialBackoffPolicy creates a new exponential backoff policy
+func NewExponentialBackoffPolicy(config *ExponentialBackoffConfig) *backoff.Exponential {
+	return backoff.NewExponential(
+		backoff.WithInterval(config.Interval),
+		backoff.WithMaxInterval(config.MaxInterval),
+		backoff.WithMaxRetries(config.MaxRetries),
+		backoff.WithMaxElapsedTime(config.MaxElapsedTime),
+	)
+}
+
 // Retry retries the given function using a backoff policy
 func Retry(function func() error, backoffPolicy backoff.Policy) (err error) {
-	b, cancel := backoffPolicy.Start(context.Background())
+	return RetryWithContext(context.Background(), function, backoffPolicy)
+}
+
+// RetryWithContext retries the given function using a backoff policy until the context is done
+func RetryWithContext(ctx context.Context, function func() error, backoffPolicy backoff.Policy) (err error) {
+	b, cancel := backoffPolicy.Start(ctx)
 
 	defer cancel()
 	for {
 		select {
 		case <-b.Done():
+			if err == nil {
+				// the context is done before any attempt
+				err = errors.New("no attempt made")
+				if ctx.Err() != nil {
+					err = ctx.Err()
+				}
+			}
 			return errors.Wrap(err, "all attempts failed")
 		case <-b.Next():
 			err = function()
@@ -235,6 +235,14 @@ func makeAdvDeploymentAnnotation(app *workloadv1beta1.AppSet) map[string]string
 	if v, ok := app.Annotations[pkgLabels.WorkLoadAnnotationHpaMetrics]; ok {
 		an[pkgLabels.WorkLoadAnnotationHpaMetrics] = v
 	}
+
+	if v, ok := app.Spec.Meta[pkgLabels.AppSetMetaOwner]; ok {
+		an[pkgLabels.WorkLoadAnnotationOwner] = v
+	}
+
+	if v, ok := app.Spec.Meta[pkgLabels.AppSetMetaOwnerContact]; ok {
+		an[pkgLabels.WorkLoadAnnotationOwnerContact] = v
+	}
 	return an
 }
 
@@ -172,6 +172,10 @@ func (r *AppSetReconciler) DeleteAll(ctx context.Context, req customctrl.CustomR
 }
 
 func deleteByCluster(ctx context.Context, c *k8smanager.Cluster, req customctrl.CustomRequest) (bool, error) {
+	if err := c.CheckMaintenance(); err != nil {
+		return false, errors.Wrapf(err, "name: %s delete advdeployment", req.NamespacedName.String())
+	}
+
 	err := c.Client.Delete(ctx, &workloadv1beta1.AdvDeployment{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      req.Name,
@@ -203,6 +207,11 @@ func (r *AppSetReconciler) DeleteUnuseAdvDeployment(ctx context.Context, req cus
 
 	currentInfo := map[string]*workloadv1beta1.AdvDeployment{}
 	for _, cluster := range r.DksMgr.ClustersMgr.GetAll() {
+		// keep the advDeployment until the maintenance is over
+		if cluster.IsMaintaining() {
+			continue
+		}
+
 		b := &workloadv1beta1.AdvDeployment{}
 		err := cluster.Client.Get(ctx, req.NamespacedName, b)
 		if err == nil {
@@ -322,6 +331,13 @@ func (r *AppSetReconciler) ApplySpec(ctx context.Context, req customctrl.CustomR
 			return 0, errors.Wrapf(err, "cluster: %s is offline", v.Name)
 		}
 
+		// freeze spec changes to the cluster in maintenance
+		if err := c.CheckMaintenance(); err != nil {
+			klog.V(4).Infof("%s skip apply advDeployment, %v", req.NamespacedName.String(), err)
+			r.recorder.Event(app, corev1.EventTypeWarning, "ClusterMaintaining", err.Error())
+			continue
+		}
+
 		newObjAdv := buildAdvDeployment(app, v, r.DksMgr.Opt.Debug)
 		isChanged, err := applyAdvDeployment(ctx, c, req, app, newObjAdv)
 		if err != nil {
@@ -331,6 +347,19 @@ func (r *AppSetReconciler) ApplySpec(ctx context.Context, req customctrl.CustomR
 		if isChanged {
 			changed++
 		}
+
+		var clusterMeta map[string]string
+		if app.Spec.Ingress != nil {
+			clusterMeta = r.getClusterMeta(ctx, v.Name)
+		}
+		isChanged, err = applyIngress(ctx, c, app, clusterMeta)
+		if err != nil {
+			return 0, err
+		}
+
+		if isChanged {
+			changed++
+		}
 	}
 
 	return changed, nil
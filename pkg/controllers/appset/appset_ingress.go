@@ -0,0 +1,113 @@
+package appset
+
+import (
+	"context"
+
+	"emperror.dev/errors"
+	workloadv1beta1 "gitlab.dmall.com/arch/sym-admin/pkg/apis/workload/v1beta1"
+	"gitlab.dmall.com/arch/sym-admin/pkg/controllers/cluster/common"
+	k8smanager "gitlab.dmall.com/arch/sym-admin/pkg/k8s/manager"
+	"gitlab.dmall.com/arch/sym-admin/pkg/labels"
+	"gitlab.dmall.com/arch/sym-admin/pkg/resources"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog"
+)
+
+const defaultIngressServicePort = 80
+
+// getClusterMeta returns the meta of the Cluster, the ingress of the app follows the ingress setting of the cluster.
+func (r *AppSetReconciler) getClusterMeta(ctx context.Context, clusterName string) map[string]string {
+	cluster := &workloadv1beta1.Cluster{}
+	err := r.Client.Get(ctx, types.NamespacedName{Namespace: r.DksMgr.Opt.ClusterNamespace, Name: clusterName}, cluster)
+	if err != nil {
+		klog.V(4).Infof("get cluster: %s/%s err: %v, use the default ingress setting", r.DksMgr.Opt.ClusterNamespace, clusterName, err)
+		return nil
+	}
+	return cluster.Spec.Meta
+}
+
+func buildAppIngress(spec *workloadv1beta1.AppIngressSpec, meta map[string]string) *common.Ingress {
+	ing := common.NewIngress(meta, spec.Host)
+	if spec.Path != "" {
+		ing.Path = spec.Path
+	}
+	if spec.TLSSecretName != "" {
+		ing.TLSSecretName = spec.TLSSecretName
+	}
+	return ing
+}
+
+// applyIngress applies the ingress of the app to the cluster, the ingress is owned by the AdvDeployment
+// and deleted with it, the ingress created before is deleted when the app not requests it any more.
+func applyIngress(ctx context.Context, c *k8smanager.Cluster, app *workloadv1beta1.AppSet, meta map[string]string) (bool, error) {
+	name := types.NamespacedName{Namespace: app.Namespace, Name: app.Name}
+	if app.Spec.Ingress == nil {
+		return deleteIngress(ctx, c, name)
+	}
+
+	adv := &workloadv1beta1.AdvDeployment{}
+	if err := c.Client.Get(ctx, name, adv); err != nil {
+		if apierrors.IsNotFound(err) {
+			// the advDeployment just created is not synced, apply in the next round
+			klog.V(4).Infof("name: %s advDeployment by cluster: %s not found, skip apply ingress", name.String(), c.GetName())
+			return false, nil
+		}
+		return false, errors.Wrapf(err, "name: %s get advDeployment by cluster: %s", name.String(), c.GetName())
+	}
+
+	serviceName := app.Name
+	if app.Spec.ServiceName != nil && *app.Spec.ServiceName != "" {
+		serviceName = *app.Spec.ServiceName
+	}
+	servicePort := app.Spec.Ingress.ServicePort
+	if servicePort == 0 {
+		servicePort = defaultIngressServicePort
+	}
+
+	apiVersion, err := common.ClusterIngressAPIVersion(c)
+	if err != nil {
+		return false, errors.Wrapf(err, "name: %s apply ingress", name.String())
+	}
+	ing := buildAppIngress(app.Spec.Ingress, meta)
+	ing.APIVersion = apiVersion
+	obj := ing.Build(app.Name, app.Namespace, labels.GetLabels(c.GetName()), serviceName, servicePort)
+	obj.SetOwnerReferences([]metav1.OwnerReference{
+		*metav1.NewControllerRef(adv, workloadv1beta1.GroupVersion.WithKind("AdvDeployment")),
+	})
+
+	change, err := resources.Reconcile(ctx, c.Client, obj, resources.Option{})
+	if err != nil {
+		return false, errors.Wrapf(err, "name: %s apply ingress by cluster: %s", name.String(), c.GetName())
+	}
+	return change > 0, nil
+}
+
+func deleteIngress(ctx context.Context, c *k8smanager.Cluster, name types.NamespacedName) (bool, error) {
+	apiVersion, err := common.ClusterIngressAPIVersion(c)
+	if err != nil {
+		klog.V(4).Infof("name: %s delete ingress err: %v", name.String(), err)
+		return false, nil
+	}
+	obj := common.NewIngressObject(name.Name, name.Namespace, apiVersion)
+	err = c.Client.Get(ctx, name, obj)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return false, nil
+		}
+		klog.V(4).Infof("name: %s get ingress by cluster: %s err: %v", name.String(), c.GetName(), err)
+		return false, nil
+	}
+
+	// only delete the ingress created by the controller
+	if obj.GetLabels()[labels.LabelCreatedBy] != labels.ControllerName {
+		return false, nil
+	}
+
+	if err := c.Client.Delete(ctx, obj); err != nil && !apierrors.IsNotFound(err) {
+		return false, errors.Wrapf(err, "name: %s delete ingress by cluster: %s", name.String(), c.GetName())
+	}
+	klog.V(4).Infof("name: %s delete ingress by cluster: %s successfully", name.String(), c.GetName())
+	return true, nil
+}
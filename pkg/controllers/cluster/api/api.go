@@ -38,16 +38,7 @@ func (r *reconciler) Name() string {
 func makeOverrideSymAPI(app *workloadv1beta1.HelmChartSpec, obj *workloadv1beta1.Cluster) map[string]interface{} {
 	ingress := make(map[string]interface{})
 	if v, ok := app.Values["hosts"]; ok {
-		ingress["enabled"] = true
-		ingress["annotations"] = map[string]interface{}{
-			"kubernetes.io/ingress.class": common.GetIngressImpl(obj.Spec.Meta),
-		}
-		ingress["hosts"] = []map[string]interface{}{
-			{
-				"host":  v,
-				"paths": []string{"/"},
-			},
-		}
+		ingress = common.NewIngress(obj.Spec.Meta, v).HelmValues(common.HelmHostsWithPaths)
 	}
 
 	image := make(map[string]interface{})
@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"gitlab.dmall.com/arch/sym-admin/pkg/controllers/cluster/lokistack"
 	"sort"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/go-logr/logr"
@@ -17,6 +19,7 @@ import (
 	sym_ctl "gitlab.dmall.com/arch/sym-admin/pkg/controllers/cluster/controller"
 	"gitlab.dmall.com/arch/sym-admin/pkg/controllers/cluster/monitor"
 	"gitlab.dmall.com/arch/sym-admin/pkg/controllers/cluster/other"
+	"gitlab.dmall.com/arch/sym-admin/pkg/controllers/cluster/storage"
 	"gitlab.dmall.com/arch/sym-admin/pkg/controllers/cluster/traefik"
 	helmv3 "gitlab.dmall.com/arch/sym-admin/pkg/helm/v3"
 	k8sclient "gitlab.dmall.com/arch/sym-admin/pkg/k8s/client"
@@ -26,6 +29,7 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/equality"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	"k8s.io/client-go/kubernetes"
@@ -56,6 +60,8 @@ type Reconciler struct {
 	DksMgr     *pkgmanager.DksManager
 	HelmSyncer *helmv3.HelmIndexSyncer
 	Clusters   map[string]*k8smanager.Cluster
+	// reclaimed are the components of the clusters whose removed add-ons are all reclaimed
+	reclaimed sync.Map
 }
 
 // Add add controller to runtime manager
@@ -136,18 +142,34 @@ func (r *Reconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 		return reconcile.Result{}, err
 	}
 
+	requeueAfter, isMaintenanceChanged, err := r.reconcileMaintenance(ctx, cluster)
+	if err != nil {
+		logger.Error(err, "failed to reconcile maintenance")
+	}
+
 	if cluster.Spec.Pause {
-		return reconcile.Result{}, nil
+		if isMaintenanceChanged {
+			_, _ = r.UpdateCluster(ctx, cluster)
+		}
+		return reconcile.Result{RequeueAfter: requeueAfter}, nil
 	}
 
 	isNeedUpdate, err := r.reconcile(ctx, cluster)
 	if err != nil {
 		logger.Error(err, "after reconcile")
+		if storage.IsNotReady(err) && (requeueAfter == 0 || requeueAfter > storage.AgentRetryInterval) {
+			requeueAfter = storage.AgentRetryInterval
+		}
 	}
-	if isNeedUpdate > 0 {
+	if isNeedUpdate > 0 || isMaintenanceChanged {
 		_, _ = r.UpdateCluster(ctx, cluster)
 	}
-	return ctrl.Result{}, nil
+
+	// the log usage reported by the nodes is collected periodically
+	if cluster.Spec.LogRetention != nil && (requeueAfter == 0 || requeueAfter > logUsageInterval) {
+		requeueAfter = logUsageInterval
+	}
+	return ctrl.Result{RequeueAfter: requeueAfter}, nil
 }
 
 func (r *Reconciler) getK8SConfigForMaster(namespace string, name string) ([]byte, error) {
@@ -209,6 +231,11 @@ func (r *Reconciler) reconcile(ctx context.Context, obj *workloadv1beta1.Cluster
 	var isNeedUpdate int
 	var err error
 
+	// an invalid monitoring or log retention block only skips its own step
+	if setSpecCondition(&obj.Status, obj.Validate()) {
+		isNeedUpdate++
+	}
+
 	k, err := r.EnsureClusters(obj.Namespace, obj.Name)
 	if err != nil {
 		return isNeedUpdate, errors.Wrapf(err, "clusterName: %s EnsureClustes", obj.Name)
@@ -223,7 +250,16 @@ func (r *Reconciler) reconcile(ctx context.Context, obj *workloadv1beta1.Cluster
 		isNeedUpdate++
 	}
 
-	isNeedUpdate, err = r.reconcileComponent(ctx, k, obj)
+	isLogChanged, err := r.reconcileLogRetention(ctx, k, obj)
+	if err != nil {
+		klog.Errorf("cluster: %s reconcile log retention err: %v", obj.Name, err)
+	}
+	if isLogChanged {
+		isNeedUpdate++
+	}
+
+	isChanged, err := r.reconcileComponent(ctx, k, obj)
+	isNeedUpdate += isChanged
 	if err != nil {
 		return isNeedUpdate, err
 	}
@@ -231,6 +267,62 @@ func (r *Reconciler) reconcile(ctx context.Context, obj *workloadv1beta1.Cluster
 	return isNeedUpdate, nil
 }
 
+// setSpecCondition records the validation result of the spec in the SpecValid condition,
+// the transition time only moves when the condition flips.
+func setSpecCondition(status *workloadv1beta1.ClusterStatus, verr error) bool {
+	cond := workloadv1beta1.ClusterCondition{
+		Type:   workloadv1beta1.ClusterSpecValid,
+		Status: corev1.ConditionTrue,
+	}
+	if verr != nil {
+		cond.Status = corev1.ConditionFalse
+		cond.Reason = "InvalidSpec"
+		cond.Message = verr.Error()
+	}
+
+	for i := range status.Conditions {
+		old := &status.Conditions[i]
+		if old.Type != cond.Type {
+			continue
+		}
+		if old.Status == cond.Status && old.Reason == cond.Reason && old.Message == cond.Message {
+			return false
+		}
+		cond.LastTransitionTime = old.LastTransitionTime
+		if old.Status != cond.Status {
+			cond.LastTransitionTime = metav1.Now()
+		}
+		*old = cond
+		return true
+	}
+	cond.LastTransitionTime = metav1.Now()
+	status.Conditions = append(status.Conditions, cond)
+	return true
+}
+
+// reclaimVolumes reclaims the local volumes of the removed add-ons after the apps change,
+// until the volumes still bound are released.
+func (r *Reconciler) reclaimVolumes(kcli *k8smanager.Cluster, apps []*workloadv1beta1.HelmChartSpec) {
+	components := make([]string, 0, len(apps))
+	for _, app := range apps {
+		components = append(components, app.Name)
+	}
+	sort.Strings(components)
+	key := strings.Join(components, ",")
+	if last, ok := r.reclaimed.Load(kcli.Name); ok && last.(string) == key {
+		return
+	}
+
+	bound, err := storage.New(kcli).Reclaim(components)
+	if err != nil {
+		klog.Errorf("cluster: %s reclaim local volumes err: %v", kcli.Name, err)
+		return
+	}
+	if bound == 0 {
+		r.reclaimed.Store(kcli.Name, key)
+	}
+}
+
 // UpdateCluster ...
 func (r *Reconciler) UpdateCluster(ctx context.Context, obj *workloadv1beta1.Cluster) (*workloadv1beta1.Cluster, error) {
 	nsName := types.NamespacedName{
@@ -293,6 +385,8 @@ func (r *Reconciler) reconcileComponent(ctx context.Context, kcli *k8smanager.Cl
 		lokistack.New(kcli, obj, r.HelmSyncer.HelmEnv),
 	}
 
+	// the apps waiting for the storage agent are retried after the reconcile
+	var notReady error
 	appHelms := make([]*workloadv1beta1.AppHelmStatus, 0, len(obj.Spec.Apps))
 	for _, app := range obj.Spec.Apps {
 		phase, err := common.FindComponentReconciler(app.Name, phases)
@@ -303,6 +397,9 @@ func (r *Reconciler) reconcileComponent(ctx context.Context, kcli *k8smanager.Cl
 
 		info, rerr := phase.Reconcile(r.Log, app)
 		if rerr != nil {
+			if storage.IsNotReady(rerr) {
+				notReady = rerr
+			}
 			klog.Errorf("app: %s Reconcile err: %#v", app.Name, rerr)
 			appHelms = append(appHelms, &workloadv1beta1.AppHelmStatus{
 				Name:         app.Name,
@@ -320,6 +417,8 @@ func (r *Reconciler) reconcileComponent(ctx context.Context, kcli *k8smanager.Cl
 		return appHelms[i].RlsName < appHelms[j].RlsName
 	})
 
+	r.reclaimVolumes(kcli, obj.Spec.Apps)
+
 	var isChanged int
 	isSame := equality.Semantic.DeepEqual(appHelms, obj.Status.AppHelms)
 	if !isSame {
@@ -329,10 +428,26 @@ func (r *Reconciler) reconcileComponent(ctx context.Context, kcli *k8smanager.Cl
 		klog.V(3).Infof("clusterName:%s helm appStatus is same, ignore", kcli.Name)
 	}
 
+	var monitoringStatus *workloadv1beta1.MonitoringStatus
+	for _, app := range obj.Spec.Apps {
+		if app.Name != "monitor" {
+			continue
+		}
+		monitoringStatus, err = monitor.BuildMonitoringStatus(obj, app)
+		if err != nil {
+			klog.Errorf("cluster: %s build monitoring status err: %v", kcli.Name, err)
+			monitoringStatus = obj.Status.MonitoringStatus
+		}
+	}
+	if !equality.Semantic.DeepEqual(monitoringStatus, obj.Status.MonitoringStatus) {
+		org.Status.MonitoringStatus = monitoringStatus
+		isChanged++
+	}
+
 	if !equality.Semantic.DeepEqual(org.Annotations, obj.Annotations) {
 		org.Annotations = obj.Annotations
 		isChanged++
 	}
 
-	return isChanged, nil
+	return isChanged, notReady
 }
@@ -0,0 +1,30 @@
+package cluster
+
+import (
+	"errors"
+	"testing"
+
+	workloadv1beta1 "gitlab.dmall.com/arch/sym-admin/pkg/apis/workload/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestSetSpecCondition(t *testing.T) {
+	status := &workloadv1beta1.ClusterStatus{}
+	if !setSpecCondition(status, nil) {
+		t.Fatalf("expect the condition added")
+	}
+	if setSpecCondition(status, nil) {
+		t.Fatalf("expect the condition unchanged")
+	}
+
+	if !setSpecCondition(status, errors.New("spec.monitoring: storage size is required")) {
+		t.Fatalf("expect the condition changed")
+	}
+	if len(status.Conditions) != 1 {
+		t.Fatalf("expect one condition, got %d", len(status.Conditions))
+	}
+	cond := status.Conditions[0]
+	if cond.Type != workloadv1beta1.ClusterSpecValid || cond.Status != corev1.ConditionFalse || cond.Message == "" {
+		t.Fatalf("expect the invalid spec reported, got %+v", cond)
+	}
+}
@@ -222,34 +222,3 @@ func ConvertAppHelmReleasePtr(rls *helmv3.Release) *workloadv1beta1.AppHelmStatu
 
 	return ret
 }
-
-func GetIngressImpl(lb map[string]string) string {
-	if k, ok := lb[ClusterIngressImpl]; ok {
-		return k
-	}
-
-	return "traefik"
-}
-
-func GetLbServiceAnnotations(clusterType string, cli client.Client) map[string]interface{} {
-	serviceAnnotations := map[string]interface{}{}
-	switch clusterType {
-	case "tke":
-		svc := &corev1.Service{}
-		err := cli.Get(context.TODO(), types.NamespacedName{Name: "kube-user", Namespace: "default"}, svc)
-		if err == nil && svc.Spec.Type == corev1.ServiceTypeLoadBalancer {
-			if va, ok := svc.Annotations["service.kubernetes.io/qcloud-loadbalancer-internal-subnetid"]; ok {
-				klog.Infof("find tke cluster qcloud-loadbalancer-internal-subnetid[%s]", va)
-				serviceAnnotations["service.kubernetes.io/qcloud-loadbalancer-internal-subnetid"] = va
-			}
-		}
-	case "aks":
-		serviceAnnotations["service.beta.kubernetes.io/azure-load-balancer-internal"] = true
-	case "gke":
-		serviceAnnotations["cloud.google.com/load-balancer-type"] = "Internal"
-	case "ack":
-	case "eks":
-	}
-
-	return serviceAnnotations
-}
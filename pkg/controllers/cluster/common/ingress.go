@@ -0,0 +1,357 @@
+package common
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	k8smanager "gitlab.dmall.com/arch/sym-admin/pkg/k8s/manager"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/discovery"
+	"k8s.io/klog"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// the cluster meta keys of the ingress setting
+var (
+	// ClusterIngressClass is the ingress class name, default the name of the implementation
+	ClusterIngressClass = "clusterIngressClass"
+	// ClusterIngressTLSSecret is the default tls secret of the ingresses
+	ClusterIngressTLSSecret = "clusterIngressTLSSecret"
+	// ClusterLbAnnotations is a json object merged into the annotations of the load balancer service
+	ClusterLbAnnotations = "clusterLbAnnotations"
+)
+
+// IngressImpl is the ingress controller implementation of the cluster.
+type IngressImpl string
+
+const (
+	IngressTraefik IngressImpl = "traefik"
+	IngressContour IngressImpl = "contour"
+	// IngressNetworking is any controller which supports networking/v1 ingressClassName
+	IngressNetworking IngressImpl = "networking"
+
+	DefaultIngressImpl = IngressTraefik
+)
+
+// ParseIngressImpl returns the implementation of va, default DefaultIngressImpl.
+func ParseIngressImpl(va string) IngressImpl {
+	return ParseIngressImplOr(va, DefaultIngressImpl)
+}
+
+// ParseIngressImplOr returns the implementation of va, def if va is empty or unknown.
+func ParseIngressImplOr(va string, def IngressImpl) IngressImpl {
+	switch impl := IngressImpl(strings.ToLower(va)); impl {
+	case IngressTraefik, IngressContour, IngressNetworking:
+		return impl
+	case "":
+	default:
+		klog.Warningf("unknown ingress implementation: %s, use %s", va, def)
+	}
+	return def
+}
+
+// the api versions of the Ingress
+const (
+	IngressV1      = "networking.k8s.io/v1"
+	IngressV1beta1 = "networking.k8s.io/v1beta1"
+)
+
+// ingressVersionTTL is how long the Ingress api version of a cluster is cached
+const ingressVersionTTL = 10 * time.Minute
+
+type ingressVersion struct {
+	version string
+	expire  time.Time
+}
+
+// ingressVersions are the cached Ingress api versions of the clusters
+var ingressVersions sync.Map
+
+// IngressAPIVersion returns networking.k8s.io/v1 if the cluster serves it, otherwise
+// networking.k8s.io/v1beta1 of k8s 1.18 and before.
+func IngressAPIVersion(disc discovery.DiscoveryInterface) (string, error) {
+	resources, err := disc.ServerResourcesForGroupVersion(IngressV1)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return IngressV1beta1, nil
+		}
+		return "", err
+	}
+	for _, r := range resources.APIResources {
+		if r.Name == "ingresses" {
+			return IngressV1, nil
+		}
+	}
+	return IngressV1beta1, nil
+}
+
+// ClusterIngressAPIVersion returns the Ingress api version of the cluster, discovered once in ingressVersionTTL.
+func ClusterIngressAPIVersion(k *k8smanager.Cluster) (string, error) {
+	if v, ok := ingressVersions.Load(k.Name); ok && time.Now().Before(v.(*ingressVersion).expire) {
+		return v.(*ingressVersion).version, nil
+	}
+
+	version, err := IngressAPIVersion(k.KubeCli.Discovery())
+	if err != nil {
+		return "", fmt.Errorf("cluster: %s discover the ingress api version err: %v", k.Name, err)
+	}
+	ingressVersions.Store(k.Name, &ingressVersion{version: version, expire: time.Now().Add(ingressVersionTTL)})
+	return version, nil
+}
+
+// HelmHostsStyle is how the ingress hosts are listed in the chart values.
+type HelmHostsStyle int
+
+const (
+	// HelmHostsPlain lists the hosts as strings, e.g. prometheus-operator
+	HelmHostsPlain HelmHostsStyle = iota
+	// HelmHostsWithPaths lists the hosts as {host, paths} objects, e.g. loki
+	HelmHostsWithPaths
+)
+
+// Ingress describes how a service is exposed through the ingress controller of the cluster.
+type Ingress struct {
+	Enabled bool
+	Impl    IngressImpl
+	// Class is the ingress class name, default the name of Impl
+	Class         string
+	Hosts         []string
+	Path          string
+	TLSSecretName string
+	// APIVersion is the api version of the Ingress built, default IngressV1
+	APIVersion string
+}
+
+// NewIngress returns the ingress of the hosts with the implementation, class and tls secret
+// from the cluster meta.
+func NewIngress(meta map[string]string, hosts ...string) *Ingress {
+	return NewIngressOr(meta, DefaultIngressImpl, hosts...)
+}
+
+// NewIngressOr is NewIngress with the implementation def if the cluster meta has no
+// ClusterIngressImpl.
+func NewIngressOr(meta map[string]string, def IngressImpl, hosts ...string) *Ingress {
+	ing := &Ingress{
+		Enabled:       true,
+		Impl:          ParseIngressImplOr(meta[ClusterIngressImpl], def),
+		Class:         meta[ClusterIngressClass],
+		Hosts:         hosts,
+		Path:          "/",
+		TLSSecretName: meta[ClusterIngressTLSSecret],
+	}
+	return ing
+}
+
+// ClassName returns the ingress class name.
+func (ing *Ingress) ClassName() string {
+	if ing.Class != "" {
+		return ing.Class
+	}
+	return string(ing.Impl)
+}
+
+// Annotations returns the annotations for the implementation.
+func (ing *Ingress) Annotations() map[string]string {
+	an := map[string]string{}
+	switch ing.Impl {
+	case IngressTraefik:
+		an["kubernetes.io/ingress.class"] = ing.ClassName()
+		if ing.TLSSecretName != "" {
+			an["traefik.ingress.kubernetes.io/redirect-entry-point"] = "https"
+		}
+	case IngressContour:
+		an["kubernetes.io/ingress.class"] = ing.ClassName()
+		if ing.TLSSecretName != "" {
+			an["ingress.kubernetes.io/force-ssl-redirect"] = "true"
+		}
+	}
+	return an
+}
+
+// URL returns the external url of the first host, https when the tls secret is set.
+func (ing *Ingress) URL() string {
+	if len(ing.Hosts) == 0 {
+		return ""
+	}
+
+	scheme := "http"
+	if ing.TLSSecretName != "" {
+		scheme = "https"
+	}
+	return fmt.Sprintf("%s://%s", scheme, ing.Hosts[0])
+}
+
+func (ing *Ingress) tls() []map[string]interface{} {
+	if ing.TLSSecretName == "" {
+		return nil
+	}
+	return []map[string]interface{}{
+		{
+			"secretName": ing.TLSSecretName,
+			"hosts":      ing.Hosts,
+		},
+	}
+}
+
+// HelmValues returns the ingress values of the charts.
+func (ing *Ingress) HelmValues(style HelmHostsStyle) map[string]interface{} {
+	values := map[string]interface{}{
+		"enabled": ing.Enabled,
+	}
+	if !ing.Enabled {
+		return values
+	}
+
+	annotations := map[string]interface{}{}
+	for k, v := range ing.Annotations() {
+		annotations[k] = v
+	}
+	values["annotations"] = annotations
+	if ing.Impl == IngressNetworking {
+		values["ingressClassName"] = ing.ClassName()
+	}
+
+	switch style {
+	case HelmHostsWithPaths:
+		hosts := make([]map[string]interface{}, 0, len(ing.Hosts))
+		for _, h := range ing.Hosts {
+			hosts = append(hosts, map[string]interface{}{
+				"host":  h,
+				"paths": []string{ing.Path},
+			})
+		}
+		values["hosts"] = hosts
+	default:
+		values["hosts"] = ing.Hosts
+	}
+
+	if tls := ing.tls(); tls != nil {
+		values["tls"] = tls
+	}
+	return values
+}
+
+func (ing *Ingress) apiVersion() string {
+	if ing.APIVersion != "" {
+		return ing.APIVersion
+	}
+	return IngressV1
+}
+
+// path returns the http path of the Ingress api version routing to the service port.
+func (ing *Ingress) path(serviceName string, servicePort int32) map[string]interface{} {
+	if ing.apiVersion() == IngressV1beta1 {
+		return map[string]interface{}{
+			"path": ing.Path,
+			"backend": map[string]interface{}{
+				"serviceName": serviceName,
+				"servicePort": int64(servicePort),
+			},
+		}
+	}
+	return map[string]interface{}{
+		"path":     ing.Path,
+		"pathType": "Prefix",
+		"backend": map[string]interface{}{
+			"service": map[string]interface{}{
+				"name": serviceName,
+				"port": map[string]interface{}{
+					"number": int64(servicePort),
+				},
+			},
+		},
+	}
+}
+
+// Build returns the Ingress of APIVersion routing the hosts to the service port.
+func (ing *Ingress) Build(name, namespace string, labels map[string]string, serviceName string, servicePort int32) *unstructured.Unstructured {
+	rules := make([]interface{}, 0, len(ing.Hosts))
+	for _, h := range ing.Hosts {
+		rules = append(rules, map[string]interface{}{
+			"host": h,
+			"http": map[string]interface{}{
+				"paths": []interface{}{ing.path(serviceName, servicePort)},
+			},
+		})
+	}
+
+	spec := map[string]interface{}{
+		"rules": rules,
+	}
+	if ing.Impl == IngressNetworking {
+		spec["ingressClassName"] = ing.ClassName()
+	}
+	if ing.TLSSecretName != "" {
+		hosts := make([]interface{}, 0, len(ing.Hosts))
+		for _, h := range ing.Hosts {
+			hosts = append(hosts, h)
+		}
+		spec["tls"] = []interface{}{
+			map[string]interface{}{
+				"secretName": ing.TLSSecretName,
+				"hosts":      hosts,
+			},
+		}
+	}
+
+	obj := NewIngressObject(name, namespace, ing.apiVersion())
+	obj.SetLabels(labels)
+	if an := ing.Annotations(); len(an) > 0 {
+		obj.SetAnnotations(an)
+	}
+	obj.Object["spec"] = spec
+	return obj
+}
+
+// NewIngressObject returns an empty Ingress of the api version for get or delete.
+func NewIngressObject(name, namespace, apiVersion string) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion(apiVersion)
+	obj.SetKind("Ingress")
+	obj.SetName(name)
+	obj.SetNamespace(namespace)
+	return obj
+}
+
+// GetLbServiceAnnotations returns the annotations of the load balancer service of the ingress controller,
+// the defaults of the cloud are overridden by the json object of the cluster meta ClusterLbAnnotations.
+func GetLbServiceAnnotations(meta map[string]string, cli client.Client) map[string]interface{} {
+	serviceAnnotations := map[string]interface{}{}
+	switch meta[ClusterType] {
+	case "tke":
+		svc := &corev1.Service{}
+		err := cli.Get(context.TODO(), types.NamespacedName{Name: "kube-user", Namespace: "default"}, svc)
+		if err == nil && svc.Spec.Type == corev1.ServiceTypeLoadBalancer {
+			if va, ok := svc.Annotations["service.kubernetes.io/qcloud-loadbalancer-internal-subnetid"]; ok {
+				klog.Infof("find tke cluster qcloud-loadbalancer-internal-subnetid[%s]", va)
+				serviceAnnotations["service.kubernetes.io/qcloud-loadbalancer-internal-subnetid"] = va
+			}
+		}
+	case "aks":
+		serviceAnnotations["service.beta.kubernetes.io/azure-load-balancer-internal"] = true
+	case "gke":
+		serviceAnnotations["cloud.google.com/load-balancer-type"] = "Internal"
+	case "ack":
+	case "eks":
+	}
+
+	if va, ok := meta[ClusterLbAnnotations]; ok {
+		overrides := map[string]interface{}{}
+		if err := json.Unmarshal([]byte(va), &overrides); err != nil {
+			klog.Errorf("invalid cluster meta %s: %s, err: %v", ClusterLbAnnotations, va, err)
+		} else {
+			for k, v := range overrides {
+				serviceAnnotations[k] = v
+			}
+		}
+	}
+
+	return serviceAnnotations
+}
@@ -0,0 +1,143 @@
+package common
+
+import (
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestIngressHelmValues(t *testing.T) {
+	cases := []struct {
+		name      string
+		meta      map[string]string
+		style     HelmHostsStyle
+		class     string
+		className string
+		tls       bool
+	}{
+		{"default traefik", nil, HelmHostsPlain, "traefik", "", false},
+		{"contour with tls", map[string]string{ClusterIngressImpl: "contour", ClusterIngressTLSSecret: "tls"}, HelmHostsWithPaths, "contour", "", true},
+		{"networking", map[string]string{ClusterIngressImpl: "networking", ClusterIngressClass: "nginx"}, HelmHostsPlain, "", "nginx", false},
+		{"unknown", map[string]string{ClusterIngressImpl: "haproxy"}, HelmHostsPlain, "traefik", "", false},
+	}
+
+	for _, c := range cases {
+		values := NewIngress(c.meta, "a.dmall.com").HelmValues(c.style)
+		an := values["annotations"].(map[string]interface{})
+		if class, _ := an["kubernetes.io/ingress.class"].(string); class != c.class {
+			t.Errorf("case: %s expect class annotation: %q, current: %q", c.name, c.class, class)
+		}
+		if className, _ := values["ingressClassName"].(string); className != c.className {
+			t.Errorf("case: %s expect ingressClassName: %q, current: %q", c.name, c.className, className)
+		}
+		if _, ok := values["tls"]; ok != c.tls {
+			t.Errorf("case: %s expect tls: %v", c.name, c.tls)
+		}
+
+		switch c.style {
+		case HelmHostsWithPaths:
+			if _, ok := values["hosts"].([]map[string]interface{}); !ok {
+				t.Errorf("case: %s expect hosts with paths, current: %+v", c.name, values["hosts"])
+			}
+		default:
+			if _, ok := values["hosts"].([]string); !ok {
+				t.Errorf("case: %s expect plain hosts, current: %+v", c.name, values["hosts"])
+			}
+		}
+	}
+
+	disabled := NewIngress(nil, "a.dmall.com")
+	disabled.Enabled = false
+	if values := disabled.HelmValues(HelmHostsPlain); len(values) != 1 || values["enabled"] != false {
+		t.Errorf("expect only enabled false, current: %+v", values)
+	}
+}
+
+func TestIngressBuild(t *testing.T) {
+	ing := NewIngress(map[string]string{
+		ClusterIngressImpl:      "networking",
+		ClusterIngressClass:     "nginx",
+		ClusterIngressTLSSecret: "tls",
+	}, "a.dmall.com")
+	obj := ing.Build("app", "default", nil, "app-svc", 8080)
+
+	if obj.GetAPIVersion() != "networking.k8s.io/v1" || obj.GetKind() != "Ingress" {
+		t.Fatalf("unexpected gvk: %s", obj.GroupVersionKind().String())
+	}
+	if className, _, _ := unstructured.NestedString(obj.Object, "spec", "ingressClassName"); className != "nginx" {
+		t.Errorf("expect ingressClassName nginx, current: %q", className)
+	}
+	if len(obj.GetAnnotations()) != 0 {
+		t.Errorf("expect no class annotations, current: %v", obj.GetAnnotations())
+	}
+
+	rules, _, _ := unstructured.NestedSlice(obj.Object, "spec", "rules")
+	if len(rules) != 1 {
+		t.Fatalf("expect 1 rule, current: %d", len(rules))
+	}
+	paths, _, _ := unstructured.NestedSlice(rules[0].(map[string]interface{}), "http", "paths")
+	svc, _, _ := unstructured.NestedString(paths[0].(map[string]interface{}), "backend", "service", "name")
+	port, _, _ := unstructured.NestedInt64(paths[0].(map[string]interface{}), "backend", "service", "port", "number")
+	if svc != "app-svc" || port != 8080 {
+		t.Errorf("unexpected backend: %s:%d", svc, port)
+	}
+
+	tls, _, _ := unstructured.NestedSlice(obj.Object, "spec", "tls")
+	if len(tls) != 1 {
+		t.Errorf("expect tls, current: %+v", tls)
+	}
+}
+
+func TestIngressBuildV1beta1(t *testing.T) {
+	ing := NewIngressOr(nil, IngressContour, "a.dmall.com")
+	if ing.Impl != IngressContour {
+		t.Errorf("expect the fallback contour, current: %s", ing.Impl)
+	}
+	ing.APIVersion = IngressV1beta1
+	obj := ing.Build("app", "default", nil, "app-svc", 8080)
+
+	if obj.GetAPIVersion() != IngressV1beta1 {
+		t.Fatalf("unexpected api version: %s", obj.GetAPIVersion())
+	}
+	if class := obj.GetAnnotations()["kubernetes.io/ingress.class"]; class != "contour" {
+		t.Errorf("expect the contour class annotation, current: %q", class)
+	}
+	rules, _, _ := unstructured.NestedSlice(obj.Object, "spec", "rules")
+	paths, _, _ := unstructured.NestedSlice(rules[0].(map[string]interface{}), "http", "paths")
+	svc, _, _ := unstructured.NestedString(paths[0].(map[string]interface{}), "backend", "serviceName")
+	port, _, _ := unstructured.NestedInt64(paths[0].(map[string]interface{}), "backend", "servicePort")
+	if svc != "app-svc" || port != 8080 {
+		t.Errorf("unexpected backend: %s:%d", svc, port)
+	}
+
+	if impl := NewIngressOr(map[string]string{ClusterIngressImpl: "traefik"}, IngressContour).Impl; impl != IngressTraefik {
+		t.Errorf("expect the implementation of the cluster meta, current: %s", impl)
+	}
+}
+
+func TestIngressAPIVersion(t *testing.T) {
+	cases := []struct {
+		name      string
+		resources []*metav1.APIResourceList
+		expect    string
+	}{
+		{"k8s 1.18", []*metav1.APIResourceList{
+			{GroupVersion: IngressV1beta1, APIResources: []metav1.APIResource{{Name: "ingresses"}}},
+			{GroupVersion: IngressV1, APIResources: []metav1.APIResource{{Name: "networkpolicies"}}},
+		}, IngressV1beta1},
+		{"k8s 1.19", []*metav1.APIResourceList{
+			{GroupVersion: IngressV1beta1, APIResources: []metav1.APIResource{{Name: "ingresses"}}},
+			{GroupVersion: IngressV1, APIResources: []metav1.APIResource{{Name: "ingresses"}, {Name: "ingressclasses"}}},
+		}, IngressV1},
+	}
+	for _, c := range cases {
+		cli := fake.NewSimpleClientset()
+		cli.Resources = c.resources
+		version, err := IngressAPIVersion(cli.Discovery())
+		if err != nil || version != c.expect {
+			t.Errorf("case: %s expect %s, current: %s, err: %v", c.name, c.expect, version, err)
+		}
+	}
+}
@@ -62,7 +62,7 @@ func (r *reconciler) makeOverrideContour() map[string]interface{} {
 		},
 		"envoy": map[string]interface{}{
 			"service": map[string]interface{}{
-				"annotations": common.GetLbServiceAnnotations(r.clusterType, r.k.Client),
+				"annotations": common.GetLbServiceAnnotations(r.obj.Spec.Meta, r.k.Client),
 			},
 			"resources": map[string]interface{}{
 				"limits": map[string]interface{}{
@@ -0,0 +1,210 @@
+package cluster
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	workloadv1beta1 "gitlab.dmall.com/arch/sym-admin/pkg/apis/workload/v1beta1"
+	k8smanager "gitlab.dmall.com/arch/sym-admin/pkg/k8s/manager"
+	"gitlab.dmall.com/arch/sym-admin/pkg/logagent"
+	"gitlab.dmall.com/arch/sym-admin/pkg/offlinelog"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/klog"
+)
+
+// logUsageInterval is the interval of collecting the log usage of the nodes of the
+// clusters with the log retention.
+const logUsageInterval = 5 * time.Minute
+
+const (
+	// logUsageWorkers bounds the execs reading the usage files at once
+	logUsageWorkers = 10
+	// logUsageExecTimeout bounds the exec reading the usage file of a node
+	logUsageExecTimeout = 10 * time.Second
+)
+
+// applyLogRetentionPolicy writes the policies into the ConfigMap read by the log agents,
+// the ConfigMap is deleted without the policies.
+func (r *Reconciler) applyLogRetentionPolicy(ctx context.Context, k *k8smanager.Cluster, spec *workloadv1beta1.LogRetentionSpec) error {
+	cms := k.KubeCli.CoreV1().ConfigMaps(offlinelog.Namespace)
+	if spec == nil {
+		err := cms.Delete(ctx, logagent.ConfigMapName, metav1.DeleteOptions{})
+		if err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+		return nil
+	}
+
+	raw, err := json.Marshal(spec)
+	if err != nil {
+		return err
+	}
+	data := map[string]string{logagent.PolicyKey: string(raw)}
+
+	cm, err := cms.Get(ctx, logagent.ConfigMapName, metav1.GetOptions{})
+	if err != nil {
+		if !apierrors.IsNotFound(err) {
+			return err
+		}
+		cm = &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      logagent.ConfigMapName,
+				Namespace: offlinelog.Namespace,
+				Labels:    offlinelog.Labels(),
+			},
+			Data: data,
+		}
+		_, err = cms.Create(ctx, cm, metav1.CreateOptions{})
+		return err
+	}
+	if equality.Semantic.DeepEqual(cm.Data, data) {
+		return nil
+	}
+	cm.Data = data
+	if _, err := cms.Update(ctx, cm, metav1.UpdateOptions{}); err != nil {
+		return err
+	}
+	klog.Infof("cluster: %s update log retention policies: %s", k.Name, raw)
+	return nil
+}
+
+// collectLogUsage reads the usage files written by the log agents of the nodes, the nodes
+// whose file is not read are left without the usage.
+func collectLogUsage(ctx context.Context, k *k8smanager.Cluster) (map[string]*workloadv1beta1.NodeLogUsage, error) {
+	pods, err := k.KubeCli.CoreV1().Pods(offlinelog.Namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: labels.SelectorFromSet(offlinelog.Labels()).String(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var running []*corev1.Pod
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if pod.Spec.NodeName != "" && pod.Status.Phase == corev1.PodRunning {
+			running = append(running, pod)
+		}
+	}
+
+	var mu sync.Mutex
+	usages := make(map[string]*workloadv1beta1.NodeLogUsage, len(running))
+	workqueue.ParallelizeUntil(ctx, logUsageWorkers, len(running), func(i int) {
+		pod := running[i]
+		usage, err := readLogUsage(ctx, k, pod)
+		if err != nil {
+			klog.Warningf("cluster: %s pod: %s read log usage err: %v", k.Name, pod.Name, err)
+			return
+		}
+		mu.Lock()
+		usages[pod.Spec.NodeName] = usage
+		mu.Unlock()
+	})
+	return usages, nil
+}
+
+// readLogUsage reads the usage file of the agent pod from the container sharing it.
+func readLogUsage(ctx context.Context, k *k8smanager.Cluster, pod *corev1.Pod) (*workloadv1beta1.NodeLogUsage, error) {
+	ctx, cancel := context.WithTimeout(ctx, logUsageExecTimeout)
+	defer cancel()
+
+	out := &bytes.Buffer{}
+	err := k.ExecStream(ctx, pod.Namespace, pod.Name, offlinelog.ContainerName, []string{"cat", logagent.UsageFile}, out)
+	if err != nil {
+		return nil, err
+	}
+	usage := &workloadv1beta1.NodeLogUsage{}
+	if err := json.Unmarshal(out.Bytes(), usage); err != nil {
+		return nil, fmt.Errorf("invalid log usage: %v", err)
+	}
+	return usage, nil
+}
+
+// listNodeNames returns the names of the nodes of the cluster.
+func listNodeNames(ctx context.Context, k *k8smanager.Cluster) (map[string]bool, error) {
+	nodes, err := k.KubeCli.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	names := make(map[string]bool, len(nodes.Items))
+	for i := range nodes.Items {
+		names[nodes.Items[i].Name] = true
+	}
+	return names, nil
+}
+
+// mergeLogUsage sets the log usage of the nodes in the status, the nodes without the usage
+// are cleared and the nodes no longer in the cluster are removed.
+func mergeLogUsage(status *workloadv1beta1.ClusterStatus, usages map[string]*workloadv1beta1.NodeLogUsage, nodes map[string]bool) bool {
+	if status.NodeDetail == nil {
+		if len(usages) == 0 {
+			return false
+		}
+		status.NodeDetail = &workloadv1beta1.NodeDetail{}
+	}
+
+	var isChanged bool
+	seen := make(map[string]bool, len(usages))
+	kept := status.NodeDetail.NodeStatus[:0]
+	for _, node := range status.NodeDetail.NodeStatus {
+		if !nodes[node.NodeName] {
+			isChanged = true
+			continue
+		}
+		kept = append(kept, node)
+		usage := usages[node.NodeName]
+		seen[node.NodeName] = true
+		if !equality.Semantic.DeepEqual(usage, node.LogUsage) {
+			node.LogUsage = usage
+			isChanged = true
+		}
+	}
+	status.NodeDetail.NodeStatus = kept
+
+	names := make([]string, 0, len(usages))
+	for name := range usages {
+		if !seen[name] && nodes[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		status.NodeDetail.NodeStatus = append(status.NodeDetail.NodeStatus, &workloadv1beta1.NodeStatus{
+			NodeName: name,
+			LogUsage: usages[name],
+		})
+		isChanged = true
+	}
+	return isChanged
+}
+
+// reconcileLogRetention hands the log retention policies to the log agents of the cluster
+// and surfaces the log usage of the nodes in the cluster status.
+func (r *Reconciler) reconcileLogRetention(ctx context.Context, k *k8smanager.Cluster, obj *workloadv1beta1.Cluster) (bool, error) {
+	if err := obj.Spec.LogRetention.Validate(); err != nil {
+		return false, fmt.Errorf("spec.logRetention: %v", err)
+	}
+	if err := r.applyLogRetentionPolicy(ctx, k, obj.Spec.LogRetention); err != nil {
+		return false, err
+	}
+
+	usages, err := collectLogUsage(ctx, k)
+	if err != nil {
+		return false, err
+	}
+	nodes, err := listNodeNames(ctx, k)
+	if err != nil {
+		return false, err
+	}
+	return mergeLogUsage(&obj.Status, usages, nodes), nil
+}
@@ -0,0 +1,39 @@
+package cluster
+
+import (
+	"testing"
+
+	workloadv1beta1 "gitlab.dmall.com/arch/sym-admin/pkg/apis/workload/v1beta1"
+)
+
+func TestMergeLogUsage(t *testing.T) {
+	status := &workloadv1beta1.ClusterStatus{
+		NodeDetail: &workloadv1beta1.NodeDetail{
+			NodeStatus: []*workloadv1beta1.NodeStatus{
+				{NodeName: "node-a", LogUsage: &workloadv1beta1.NodeLogUsage{}},
+				{NodeName: "node-gone", LogUsage: &workloadv1beta1.NodeLogUsage{}},
+			},
+		},
+	}
+	usages := map[string]*workloadv1beta1.NodeLogUsage{
+		"node-a":    {},
+		"node-b":    {},
+		"node-gone": {},
+	}
+	nodes := map[string]bool{"node-a": true, "node-b": true}
+
+	if !mergeLogUsage(status, usages, nodes) {
+		t.Fatalf("expect the status changed")
+	}
+	var names []string
+	for _, node := range status.NodeDetail.NodeStatus {
+		names = append(names, node.NodeName)
+	}
+	if len(names) != 2 || names[0] != "node-a" || names[1] != "node-b" {
+		t.Fatalf("expect the nodes [node-a node-b], got %v", names)
+	}
+
+	if mergeLogUsage(status, usages, nodes) {
+		t.Fatalf("expect the status unchanged")
+	}
+}
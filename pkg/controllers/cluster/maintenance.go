@@ -0,0 +1,133 @@
+package cluster
+
+import (
+	"context"
+	"time"
+
+	workloadv1beta1 "gitlab.dmall.com/arch/sym-admin/pkg/apis/workload/v1beta1"
+	k8smanager "gitlab.dmall.com/arch/sym-admin/pkg/k8s/manager"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/klog"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var maintenanceKeys = []string{
+	k8smanager.KeyMaintenance,
+	k8smanager.KeyMaintenanceReason,
+	k8smanager.KeyMaintenanceStartTime,
+	k8smanager.KeyMaintenanceEndTime,
+}
+
+func buildMaintenanceWindow(spec *workloadv1beta1.MaintenanceSpec) *k8smanager.MaintenanceWindow {
+	if spec == nil || !spec.Enable {
+		return nil
+	}
+
+	w := &k8smanager.MaintenanceWindow{
+		Reason: spec.Reason,
+	}
+	if spec.StartTime != nil {
+		w.Start = &spec.StartTime.Time
+	}
+	if spec.EndTime != nil {
+		w.End = &spec.EndTime.Time
+	}
+	return w
+}
+
+func buildMaintenanceData(w *k8smanager.MaintenanceWindow) map[string]string {
+	if w == nil {
+		return nil
+	}
+
+	data := map[string]string{
+		k8smanager.KeyMaintenance:       "true",
+		k8smanager.KeyMaintenanceReason: w.Reason,
+	}
+	if w.Start != nil {
+		data[k8smanager.KeyMaintenanceStartTime] = w.Start.Format(time.RFC3339)
+	}
+	if w.End != nil {
+		data[k8smanager.KeyMaintenanceEndTime] = w.End.Format(time.RFC3339)
+	}
+	return data
+}
+
+// nextMaintenanceCheck returns the duration until the window starts or ends, 0 means no need.
+func nextMaintenanceCheck(w *k8smanager.MaintenanceWindow, now time.Time) time.Duration {
+	if w == nil {
+		return 0
+	}
+	if w.Start != nil && now.Before(*w.Start) {
+		return w.Start.Sub(now)
+	}
+	if w.End != nil && now.Before(*w.End) {
+		return w.End.Sub(now)
+	}
+	return 0
+}
+
+// reconcileMaintenance writes the maintenance window into the cluster configmap which
+// is watched by ClusterManager, and surfaces the window in the cluster status.
+func (r *Reconciler) reconcileMaintenance(ctx context.Context, obj *workloadv1beta1.Cluster) (time.Duration, bool, error) {
+	now := time.Now()
+	w := buildMaintenanceWindow(obj.Spec.Maintenance)
+
+	var status *workloadv1beta1.MaintenanceStatus
+	if w != nil {
+		status = &workloadv1beta1.MaintenanceStatus{
+			Active:    w.Active(now),
+			Reason:    obj.Spec.Maintenance.Reason,
+			StartTime: obj.Spec.Maintenance.StartTime.DeepCopy(),
+			EndTime:   obj.Spec.Maintenance.EndTime.DeepCopy(),
+		}
+	}
+
+	var isChanged bool
+	if !equality.Semantic.DeepEqual(status, obj.Status.Maintenance) {
+		obj.Status.Maintenance = status
+		isChanged = true
+	}
+
+	cm := &corev1.ConfigMap{}
+	err := r.Client.Get(ctx, client.ObjectKey{Namespace: obj.Namespace, Name: obj.Name}, cm)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			klog.Warningf("cluster: %s configmap not found, ignore maintenance", obj.Name)
+			return nextMaintenanceCheck(w, now), isChanged, nil
+		}
+		return 0, isChanged, err
+	}
+
+	expect := buildMaintenanceData(w)
+	var isDataChanged bool
+	for _, key := range maintenanceKeys {
+		va, ok := expect[key]
+		if !ok {
+			if _, exist := cm.Data[key]; exist {
+				delete(cm.Data, key)
+				isDataChanged = true
+			}
+			continue
+		}
+
+		if cm.Data[key] != va {
+			if cm.Data == nil {
+				cm.Data = map[string]string{}
+			}
+			cm.Data[key] = va
+			isDataChanged = true
+		}
+	}
+
+	if isDataChanged {
+		if err := r.Client.Update(ctx, cm); err != nil {
+			return 0, isChanged, err
+		}
+		klog.Infof("cluster: %s update maintenance: %v", obj.Name, expect)
+	}
+
+	return nextMaintenanceCheck(w, now), isChanged, nil
+}
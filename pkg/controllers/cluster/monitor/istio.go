@@ -1,6 +1,7 @@
 package monitor
 
 import (
+	"emperror.dev/errors"
 	"github.com/ghodss/yaml"
 	workloadv1beta1 "gitlab.dmall.com/arch/sym-admin/pkg/apis/workload/v1beta1"
 )
@@ -262,12 +263,24 @@ var additionalScrapeConfigsStr = `
     target_label: pod_name
 `
 
-func builAadditionalScrapeConfigs(app *workloadv1beta1.HelmChartSpec) []map[string]interface{} {
-	if _, ok := app.Values["istioScrape"]; !ok {
-		return nil
-	}
-	b, _ := yaml.YAMLToJSON([]byte(additionalScrapeConfigsStr))
+func buildAdditionalScrapeConfigs(spec *workloadv1beta1.MonitoringSpec) ([]map[string]interface{}, error) {
 	scrapeConfig := []map[string]interface{}{}
-	yaml.Unmarshal(b, &scrapeConfig)
-	return scrapeConfig
+	if spec.IstioScrape {
+		if err := yaml.Unmarshal([]byte(additionalScrapeConfigsStr), &scrapeConfig); err != nil {
+			return nil, errors.Wrapf(err, "unmarshal istio scrape configs")
+		}
+	}
+
+	if spec.AdditionalScrapeConfigs != "" {
+		custom := []map[string]interface{}{}
+		if err := yaml.Unmarshal([]byte(spec.AdditionalScrapeConfigs), &custom); err != nil {
+			return nil, errors.Wrapf(err, "unmarshal additional scrape configs")
+		}
+		scrapeConfig = append(scrapeConfig, custom...)
+	}
+
+	if len(scrapeConfig) == 0 {
+		return nil, nil
+	}
+	return scrapeConfig, nil
 }
@@ -10,15 +10,11 @@ import (
 	"github.com/go-logr/logr"
 	workloadv1beta1 "gitlab.dmall.com/arch/sym-admin/pkg/apis/workload/v1beta1"
 	"gitlab.dmall.com/arch/sym-admin/pkg/controllers/cluster/common"
-	"gitlab.dmall.com/arch/sym-admin/pkg/controllers/cluster/utils"
+	"gitlab.dmall.com/arch/sym-admin/pkg/controllers/cluster/storage"
 	helmv3 "gitlab.dmall.com/arch/sym-admin/pkg/helm/v3"
 	k8smanager "gitlab.dmall.com/arch/sym-admin/pkg/k8s/manager"
 	pkgLabels "gitlab.dmall.com/arch/sym-admin/pkg/labels"
-	"gitlab.dmall.com/arch/sym-admin/pkg/resources"
 	corev1 "k8s.io/api/core/v1"
-	storagev1 "k8s.io/api/storage/v1"
-	"k8s.io/apimachinery/pkg/api/resource"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/klog"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
@@ -32,7 +28,6 @@ type reconciler struct {
 	env         *helmv3.HelmEnv
 	clusterType string
 	urlHead     string
-	ingressImpl string
 }
 
 // New ...
@@ -53,7 +48,6 @@ func New(mgr manager.Manager, k *k8smanager.Cluster, obj *workloadv1beta1.Cluste
 		r.urlHead = h
 	}
 
-	r.ingressImpl = "contour"
 	return r
 }
 
@@ -79,62 +73,6 @@ func getPromSwitch(clusterType string) (isSysEnable bool, isAlertManagerEnable b
 	return
 }
 
-func getPromStorageSize(app *workloadv1beta1.HelmChartSpec) string {
-	if va, ok := app.Values["lpv-size"]; ok {
-		return va
-	}
-	return "30Gi"
-}
-
-func getPromPvPath(app *workloadv1beta1.HelmChartSpec) string {
-	if va, ok := app.Values["lpv-path"]; ok {
-		return va
-	}
-	return "/root/prometheus-data"
-}
-
-func getGrafanaStorageSize(app *workloadv1beta1.HelmChartSpec) string {
-	if va, ok := app.Values["lpv-grafana-size"]; ok {
-		return va
-	}
-	return "1Gi"
-}
-
-func getGrafanaPvPath(app *workloadv1beta1.HelmChartSpec) string {
-	if va, ok := app.Values["lpv-grafana-path"]; ok {
-		return va
-	}
-	return "/root/grafana-data"
-}
-
-func getPromLimitsCPU(app *workloadv1beta1.HelmChartSpec) string {
-	if va, ok := app.Values["prom-limit-cpu"]; ok {
-		return va
-	}
-	return "1"
-}
-
-func getPromLimitsMemory(app *workloadv1beta1.HelmChartSpec) string {
-	if va, ok := app.Values["prom-limit-memory"]; ok {
-		return va
-	}
-	return "1Gi"
-}
-
-func getPromReqCPU(app *workloadv1beta1.HelmChartSpec) string {
-	if va, ok := app.Values["prom-req-cpu"]; ok {
-		return va
-	}
-	return "0.5"
-}
-
-func getPromReqMemory(app *workloadv1beta1.HelmChartSpec) string {
-	if va, ok := app.Values["prom-req-memory"]; ok {
-		return va
-	}
-	return "500Mi"
-}
-
 func getClusterEnv(c *workloadv1beta1.Cluster) string {
 	var env string
 	if strings.Contains(c.Name, "test") {
@@ -145,11 +83,11 @@ func getClusterEnv(c *workloadv1beta1.Cluster) string {
 	return env
 }
 
-func getPromSelector(app *workloadv1beta1.HelmChartSpec) map[string]interface{} {
+func getPromSelector(spec *workloadv1beta1.PrometheusSpec) map[string]interface{} {
 	sel := make(map[string]interface{})
 
 	// default selector all namespace
-	if _va, ok := app.Values["selector-only-system"]; ok && _va == "enable" {
+	if spec.SelectorOnlySystem {
 		sel["matchExpressions"] = []map[string]interface{}{
 			{
 				"key":      "name",
@@ -162,13 +100,6 @@ func getPromSelector(app *workloadv1beta1.HelmChartSpec) map[string]interface{}
 	return sel
 }
 
-func getPromRetention(app *workloadv1beta1.HelmChartSpec) string {
-	if va, ok := app.Values["prom-retention"]; ok {
-		return va
-	}
-	return "2d"
-}
-
 func preInstallMonitoringGetEtcd(k *k8smanager.Cluster) []string {
 	nodes := &corev1.NodeList{}
 	err := k.Client.List(context.TODO(), nodes, &client.ListOptions{})
@@ -191,175 +122,54 @@ func preInstallMonitoringGetEtcd(k *k8smanager.Cluster) []string {
 	return nodeIps
 }
 
-func preInstallLpv(k *k8smanager.Cluster, app *workloadv1beta1.HelmChartSpec, c *workloadv1beta1.Cluster) error {
-	reclaimPolicy := corev1.PersistentVolumeReclaimDelete
-	volumeBindingMode := storagev1.VolumeBindingWaitForFirstConsumer
-	sc := &storagev1.StorageClass{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:   common.LocalStorageName,
-			Labels: pkgLabels.GetLabels(k.Name),
-		},
-		Provisioner:       "kubernetes.io/no-provisioner",
-		ReclaimPolicy:     &reclaimPolicy,
-		VolumeBindingMode: &volumeBindingMode,
-	}
-
-	klog.Infof("start reconcile StorageClasses: %s", sc.Name)
-	_, err := resources.Reconcile(context.TODO(), k.Client, sc, resources.Option{})
+func preInstallLpv(k *k8smanager.Cluster, app *workloadv1beta1.HelmChartSpec, c *workloadv1beta1.Cluster, spec *workloadv1beta1.MonitoringSpec) error {
+	p := storage.New(k)
+	promStorage := spec.Prometheus.Storage
+	grafanaStorage := spec.Grafana.Storage
+
+	err := p.Provision(&storage.Volume{
+		Name:           common.PrometheusPvName,
+		Component:      app.Name,
+		Path:           promStorage.Path,
+		Size:           promStorage.Size.String(),
+		NodeName:       c.Spec.SymNodeName,
+		NodeSelectorVa: common.NodeSelectorVa,
+		Reclaim:        storage.ParseReclaimPolicy(promStorage.Reclaim),
+	})
 	if err != nil {
+		klog.Errorf("provision node: %s path: %s err: %v", c.Spec.SymNodeName, promStorage.Path, err)
 		return err
 	}
 
-	promPath := getPromPvPath(app)
-	grafanaPath := getGrafanaPvPath(app)
-	if v := pkgLabels.GetAnnotationKey(c.Annotations, pkgLabels.ClusterAnnotationMonitor); v == "" {
-		err = utils.ApplyLauncherPod(k.KubeCli, app.Namespace, c.Spec.SymNodeName, promPath)
-		if err != nil {
-			klog.Errorf("ApplyLauncherPod node: %s path: %s err: %v", c.Spec.SymNodeName, promPath, err)
-			return err
-		}
-		err = utils.ApplyLauncherPod(k.KubeCli, app.Namespace, c.Spec.SymNodeName, grafanaPath)
-		if err != nil {
-			klog.Errorf("ApplyLauncherPod node: %s path: %s err: %v", c.Spec.SymNodeName, grafanaPath, err)
-			return err
-		}
-
-		if c.Annotations == nil {
-			c.Annotations = make(map[string]string)
-		}
-
-		c.Annotations[pkgLabels.ClusterAnnotationMonitor] =
-			fmt.Sprintf("{node: %s, prometheusDir: %s, grafanaDir: %s}", c.Spec.SymNodeName, promPath, grafanaPath)
-	}
-
-	promPv := &corev1.PersistentVolume{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:   common.PrometheusPvName,
-			Labels: pkgLabels.GetLabels(k.Name),
-		},
-		Spec: corev1.PersistentVolumeSpec{
-			AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
-			Capacity: corev1.ResourceList{
-				"storage": resource.MustParse(getPromStorageSize(app)),
-			},
-			PersistentVolumeSource: corev1.PersistentVolumeSource{
-				Local: &corev1.LocalVolumeSource{
-					Path: promPath,
-				},
-			},
-			PersistentVolumeReclaimPolicy: corev1.PersistentVolumeReclaimRetain,
-			StorageClassName:              common.LocalStorageName,
-			VolumeMode: func() *corev1.PersistentVolumeMode {
-				volumeMode := corev1.PersistentVolumeFilesystem
-				return &volumeMode
-			}(),
-			NodeAffinity: &corev1.VolumeNodeAffinity{
-				Required: &corev1.NodeSelector{
-					NodeSelectorTerms: []corev1.NodeSelectorTerm{
-						{
-							MatchExpressions: []corev1.NodeSelectorRequirement{
-								{
-									Key:      common.NodeSelectorKey,
-									Operator: corev1.NodeSelectorOpIn,
-									Values:   []string{common.NodeSelectorVa},
-								},
-							},
-						},
-					},
-				},
-			},
-		},
-	}
-
-	klog.Infof("start reconcile pv: %s", promPv.Name)
-	_, err = resources.Reconcile(context.TODO(), k.Client, promPv, resources.Option{})
+	err = p.Provision(&storage.Volume{
+		Name:           common.GrafanaPvName,
+		Component:      app.Name,
+		Path:           grafanaStorage.Path,
+		Size:           grafanaStorage.Size.String(),
+		NodeName:       c.Spec.SymNodeName,
+		NodeSelectorVa: common.NodeSelectorVa,
+		Reclaim:        storage.ParseReclaimPolicy(grafanaStorage.Reclaim),
+	})
 	if err != nil {
+		klog.Errorf("provision node: %s path: %s err: %v", c.Spec.SymNodeName, grafanaStorage.Path, err)
 		return err
 	}
 
-	grafanaPv := &corev1.PersistentVolume{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:   common.GrafanaPvName,
-			Labels: pkgLabels.GetLabels(k.Name),
-		},
-		Spec: corev1.PersistentVolumeSpec{
-			AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
-			Capacity: corev1.ResourceList{
-				"storage": resource.MustParse(getGrafanaStorageSize(app)),
-			},
-			PersistentVolumeSource: corev1.PersistentVolumeSource{
-				Local: &corev1.LocalVolumeSource{
-					Path: grafanaPath,
-				},
-			},
-			PersistentVolumeReclaimPolicy: corev1.PersistentVolumeReclaimRetain,
-			StorageClassName:              common.LocalStorageName,
-			VolumeMode: func() *corev1.PersistentVolumeMode {
-				volumeMode := corev1.PersistentVolumeFilesystem
-				return &volumeMode
-			}(),
-			NodeAffinity: &corev1.VolumeNodeAffinity{
-				Required: &corev1.NodeSelector{
-					NodeSelectorTerms: []corev1.NodeSelectorTerm{
-						{
-							MatchExpressions: []corev1.NodeSelectorRequirement{
-								{
-									Key:      common.NodeSelectorKey,
-									Operator: corev1.NodeSelectorOpIn,
-									Values:   []string{common.NodeSelectorVa},
-								},
-							},
-						},
-					},
-				},
-			},
-		},
-	}
-
-	klog.Infof("start reconcile pv: %s", grafanaPv.Name)
-	_, err = resources.Reconcile(context.TODO(), k.Client, grafanaPv, resources.Option{})
-	if err != nil {
-		return err
+	if c.Annotations == nil {
+		c.Annotations = make(map[string]string)
 	}
-
+	c.Annotations[pkgLabels.ClusterAnnotationMonitor] =
+		fmt.Sprintf("{node: %s, prometheusDir: %s, grafanaDir: %s}", c.Spec.SymNodeName, promStorage.Path, grafanaStorage.Path)
 	return nil
 }
 
-func makeOverrideIngress(enabled bool, ingressImpl string, host string) map[string]interface{} {
-	ing := make(map[string]interface{})
-	if enabled {
-		ing["enabled"] = enabled
-		ing["annotations"] = map[string]interface{}{
-			"kubernetes.io/ingress.class": ingressImpl,
-		}
-		ing["hosts"] = []string{host}
-	} else {
-		ing["enabled"] = enabled
-	}
-
-	return ing
-}
-
-func getIngressName(urlhead string, componentName string, app *workloadv1beta1.HelmChartSpec) string {
-	switch componentName {
-	case "grafana":
-		if va, ok := app.Values["grafana-ing"]; ok {
-			return va
-		}
-		return fmt.Sprintf("%s.grafana.dmall.com", urlhead)
-	case "prometheus":
-		if va, ok := app.Values["prom-ing"]; ok {
-			return va
-		}
-		return fmt.Sprintf("%s.prometheus.dmall.com", urlhead)
-	case "alertmanager":
-		if va, ok := app.Values["alertmanager-ing"]; ok {
-			return va
-		}
-		return fmt.Sprintf("%s.alertmanager.dmall.com", urlhead)
-	default:
-		return ""
+func (r *reconciler) makeOverrideIngress(spec *workloadv1beta1.MonitoringSpec, host string) map[string]interface{} {
+	ing := common.NewIngressOr(r.obj.Spec.Meta, common.IngressContour, host)
+	ing.Enabled = *spec.Ingress.Enabled
+	if spec.Ingress.Class != "" {
+		ing.Class = spec.Ingress.Class
 	}
+	return ing.HelmValues(common.HelmHostsPlain)
 }
 
 func makeAlertManagerConfig(c *workloadv1beta1.Cluster) map[string]interface{} {
@@ -441,23 +251,35 @@ func makeAlertManagerConfig(c *workloadv1beta1.Cluster) map[string]interface{} {
 	return ing
 }
 
-func (r *reconciler) buildMonitorValues(app *workloadv1beta1.HelmChartSpec) map[string]interface{} {
+func (r *reconciler) buildMonitorValues(app *workloadv1beta1.HelmChartSpec) (map[string]interface{}, error) {
 	var (
 		env     string
 		etcdips []string
 	)
 
 	env = getClusterEnv(r.obj)
-	isSysEnable, isAlertManagerEnable, isGrafanaEnable, isIngress, isKubeletHTTPS := getPromSwitch(r.clusterType)
+	isSysEnable, _, _, _, isKubeletHTTPS := getPromSwitch(r.clusterType)
+	spec, err := buildMonitoringSpec(r.obj, app, r.urlHead, r.clusterType)
+	if err != nil {
+		return nil, err
+	}
+	prom := spec.Prometheus
+	isAlertManagerEnable := *spec.Alertmanager.Enabled
+	isGrafanaEnable := *spec.Grafana.Enabled
 
 	if isSysEnable {
 		etcdips = preInstallMonitoringGetEtcd(r.k)
 		klog.Infof("master etcdips: %+v", etcdips)
 	}
 
-	err := preInstallLpv(r.k, app, r.obj)
+	err = preInstallLpv(r.k, app, r.obj, spec)
 	if err != nil {
-		return nil
+		return nil, err
+	}
+
+	scrapeConfigs, err := buildAdditionalScrapeConfigs(spec)
+	if err != nil {
+		return nil, err
 	}
 
 	affinity := common.MakeNodeAffinity()
@@ -467,7 +289,7 @@ func (r *reconciler) buildMonitorValues(app *workloadv1beta1.HelmChartSpec) map[
 	overrideValueMap := map[string]interface{}{
 		"prometheus": map[string]interface{}{
 			"enabled": true,
-			"ingress": makeOverrideIngress(isIngress, r.ingressImpl, getIngressName(r.urlHead, "prometheus", app)),
+			"ingress": r.makeOverrideIngress(spec, prom.Host),
 			"prometheusSpec": map[string]interface{}{
 				// "image": map[string]interface{}{
 				// 	"repository": RepositoryHub + "prometheus",
@@ -481,21 +303,21 @@ func (r *reconciler) buildMonitorValues(app *workloadv1beta1.HelmChartSpec) map[
 				},
 				"replicaExternalLabelNameClear":    true,
 				"prometheusExternalLabelNameClear": true,
-				"serviceMonitorNamespaceSelector":  getPromSelector(app),
-				"ruleNamespaceSelector":            getPromSelector(app),
+				"serviceMonitorNamespaceSelector":  getPromSelector(prom),
+				"ruleNamespaceSelector":            getPromSelector(prom),
 				"affinity":                         affinity,
 				"tolerations":                      tolerations,
 				"resources": map[string]interface{}{
 					"limits": map[string]interface{}{
-						"cpu":    getPromLimitsCPU(app),
-						"memory": getPromLimitsMemory(app),
+						"cpu":    quantityString(prom.Resources.Limits, corev1.ResourceCPU),
+						"memory": quantityString(prom.Resources.Limits, corev1.ResourceMemory),
 					},
 					"requests": map[string]interface{}{
-						"cpu":    getPromReqCPU(app),
-						"memory": getPromReqMemory(app),
+						"cpu":    quantityString(prom.Resources.Requests, corev1.ResourceCPU),
+						"memory": quantityString(prom.Resources.Requests, corev1.ResourceMemory),
 					},
 				},
-				"retention": getPromRetention(app),
+				"retention": prom.Retention,
 				"storageSpec": map[string]interface{}{
 					"volumeClaimTemplate": map[string]interface{}{
 						"spec": map[string]interface{}{
@@ -503,13 +325,13 @@ func (r *reconciler) buildMonitorValues(app *workloadv1beta1.HelmChartSpec) map[
 							"accessModes":      []string{"ReadWriteOnce"},
 							"resources": map[string]interface{}{
 								"requests": map[string]interface{}{
-									"storage": getPromStorageSize(app),
+									"storage": prom.Storage.Size.String(),
 								},
 							},
 						},
 					},
 				},
-				"additionalScrapeConfigs": builAadditionalScrapeConfigs(app),
+				"additionalScrapeConfigs": scrapeConfigs,
 			},
 		},
 		"grafana": map[string]interface{}{
@@ -529,9 +351,9 @@ func (r *reconciler) buildMonitorValues(app *workloadv1beta1.HelmChartSpec) map[
 			"persistence": map[string]interface{}{
 				"enabled":          true,
 				"storageClassName": common.LocalStorageName,
-				"size":             getGrafanaStorageSize(app),
+				"size":             spec.Grafana.Storage.Size.String(),
 			},
-			"ingress": makeOverrideIngress(isIngress, r.ingressImpl, getIngressName(r.urlHead, "grafana", app)),
+			"ingress": r.makeOverrideIngress(spec, spec.Grafana.Host),
 		},
 		"alertmanager": map[string]interface{}{
 			"enabled": isAlertManagerEnable,
@@ -544,7 +366,7 @@ func (r *reconciler) buildMonitorValues(app *workloadv1beta1.HelmChartSpec) map[
 				"affinity":    affinity,
 				"tolerations": tolerations,
 			},
-			"ingress": makeOverrideIngress(isIngress, r.ingressImpl, getIngressName(r.urlHead, "alertmanager", app)),
+			"ingress": r.makeOverrideIngress(spec, spec.Alertmanager.Host),
 		},
 		"kubeApiServer": map[string]interface{}{
 			"enabled": true,
@@ -591,7 +413,7 @@ func (r *reconciler) buildMonitorValues(app *workloadv1beta1.HelmChartSpec) map[
 		},
 	}
 
-	if _va, ok := app.Values["custom-resources-config"]; ok && _va == "enable" {
+	if spec.CustomResourcesConfig {
 		overrideValueMap["prometheusOperator"] = map[string]interface{}{
 			"createCustomResource": false,
 			"affinity":             affinity,
@@ -662,7 +484,7 @@ func (r *reconciler) buildMonitorValues(app *workloadv1beta1.HelmChartSpec) map[
 			"etcd":          isSysEnable,
 		},
 	}
-	return overrideValueMap
+	return overrideValueMap, nil
 }
 
 func (r *reconciler) Reconcile(log logr.Logger, obj interface{}) (interface{}, error) {
@@ -690,7 +512,10 @@ func (r *reconciler) Reconcile(log logr.Logger, obj interface{}) (interface{}, e
 	// monitor rls name need add cluster name
 	rlsName := "monitor-" + r.obj.Name
 
-	va := r.buildMonitorValues(app)
+	va, err := r.buildMonitorValues(app)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cluster: %s build monitor values", r.obj.Name)
+	}
 	vaByte, err := yaml.Marshal(va)
 	if err != nil {
 		klog.Errorf("app[%s] Marshal overrideValueMap err:%+v", app.Name, err)
@@ -0,0 +1,268 @@
+package monitor
+
+import (
+	"fmt"
+
+	"emperror.dev/errors"
+	workloadv1beta1 "gitlab.dmall.com/arch/sym-admin/pkg/apis/workload/v1beta1"
+	"gitlab.dmall.com/arch/sym-admin/pkg/utils"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// the legacy keys in the values of the monitor app
+const (
+	keyPromStorageSize       = "lpv-size"
+	keyPromPvPath            = "lpv-path"
+	keyGrafanaStorageSize    = "lpv-grafana-size"
+	keyGrafanaPvPath         = "lpv-grafana-path"
+	keyStorageReclaim        = "lpv-reclaim"
+	keyPromLimitCPU          = "prom-limit-cpu"
+	keyPromLimitMemory       = "prom-limit-memory"
+	keyPromReqCPU            = "prom-req-cpu"
+	keyPromReqMemory         = "prom-req-memory"
+	keyPromRetention         = "prom-retention"
+	keySelectorOnlySystem    = "selector-only-system"
+	keyCustomResourcesConfig = "custom-resources-config"
+	keyIstioScrape           = "istioScrape"
+	keyPromIngress           = "prom-ing"
+	keyGrafanaIngress        = "grafana-ing"
+	keyAlertmanagerIngress   = "alertmanager-ing"
+)
+
+const (
+	defaultPromStorageSize    = "30Gi"
+	defaultPromPvPath         = "/root/prometheus-data"
+	defaultGrafanaStorageSize = "1Gi"
+	defaultGrafanaPvPath      = "/root/grafana-data"
+	defaultPromLimitCPU       = "1"
+	defaultPromLimitMemory    = "1Gi"
+	defaultPromReqCPU         = "0.5"
+	defaultPromReqMemory      = "500Mi"
+	defaultPromRetention      = "2d"
+	defaultStorageReclaim     = "Retain"
+)
+
+func newMonitoringSpec() *workloadv1beta1.MonitoringSpec {
+	return &workloadv1beta1.MonitoringSpec{
+		Prometheus: &workloadv1beta1.PrometheusSpec{
+			Resources: &corev1.ResourceRequirements{
+				Limits:   corev1.ResourceList{},
+				Requests: corev1.ResourceList{},
+			},
+			Storage: &workloadv1beta1.LocalStorageSpec{},
+		},
+		Alertmanager: &workloadv1beta1.AlertmanagerSpec{},
+		Grafana: &workloadv1beta1.GrafanaSpec{
+			Storage: &workloadv1beta1.LocalStorageSpec{},
+		},
+		Ingress: &workloadv1beta1.MonitoringIngressSpec{},
+	}
+}
+
+func parseQuantity(key, va string) (*resource.Quantity, error) {
+	q, err := resource.ParseQuantity(va)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid value of %s: %s", key, va)
+	}
+	return &q, nil
+}
+
+// convertLegacyValues converts the legacy string keys of the monitor app values to MonitoringSpec,
+// the fields of the keys not set are left empty.
+func convertLegacyValues(values map[string]string) (*workloadv1beta1.MonitoringSpec, error) {
+	spec := newMonitoringSpec()
+	prom := spec.Prometheus
+	grafana := spec.Grafana
+
+	quantities := []struct {
+		key string
+		set func(q *resource.Quantity)
+	}{
+		{keyPromStorageSize, func(q *resource.Quantity) { prom.Storage.Size = q }},
+		{keyGrafanaStorageSize, func(q *resource.Quantity) { grafana.Storage.Size = q }},
+		{keyPromLimitCPU, func(q *resource.Quantity) { prom.Resources.Limits[corev1.ResourceCPU] = *q }},
+		{keyPromLimitMemory, func(q *resource.Quantity) { prom.Resources.Limits[corev1.ResourceMemory] = *q }},
+		{keyPromReqCPU, func(q *resource.Quantity) { prom.Resources.Requests[corev1.ResourceCPU] = *q }},
+		{keyPromReqMemory, func(q *resource.Quantity) { prom.Resources.Requests[corev1.ResourceMemory] = *q }},
+	}
+	for _, item := range quantities {
+		va, ok := values[item.key]
+		if !ok {
+			continue
+		}
+		q, err := parseQuantity(item.key, va)
+		if err != nil {
+			return nil, err
+		}
+		item.set(q)
+	}
+
+	prom.Storage.Path = values[keyPromPvPath]
+	grafana.Storage.Path = values[keyGrafanaPvPath]
+	if va, ok := values[keyStorageReclaim]; ok {
+		prom.Storage.Reclaim = va
+		grafana.Storage.Reclaim = va
+	}
+
+	prom.Retention = values[keyPromRetention]
+	prom.SelectorOnlySystem = values[keySelectorOnlySystem] == "enable"
+	prom.Host = values[keyPromIngress]
+	grafana.Host = values[keyGrafanaIngress]
+	spec.Alertmanager.Host = values[keyAlertmanagerIngress]
+	spec.CustomResourcesConfig = values[keyCustomResourcesConfig] == "enable"
+	_, spec.IstioScrape = values[keyIstioScrape]
+	return spec, nil
+}
+
+func mergeStorage(dst, src *workloadv1beta1.LocalStorageSpec) {
+	if src == nil {
+		return
+	}
+	if src.Path != "" {
+		dst.Path = src.Path
+	}
+	if src.Size != nil {
+		dst.Size = src.Size
+	}
+	if src.Reclaim != "" {
+		dst.Reclaim = src.Reclaim
+	}
+}
+
+// mergeMonitoringSpec overrides the spec with the fields set in the typed spec.
+func mergeMonitoringSpec(spec, typed *workloadv1beta1.MonitoringSpec) {
+	if typed == nil {
+		return
+	}
+
+	if p := typed.Prometheus; p != nil {
+		if p.Retention != "" {
+			spec.Prometheus.Retention = p.Retention
+		}
+		if p.Host != "" {
+			spec.Prometheus.Host = p.Host
+		}
+		if p.Resources != nil {
+			for name, q := range p.Resources.Limits {
+				spec.Prometheus.Resources.Limits[name] = q
+			}
+			for name, q := range p.Resources.Requests {
+				spec.Prometheus.Resources.Requests[name] = q
+			}
+		}
+		mergeStorage(spec.Prometheus.Storage, p.Storage)
+		spec.Prometheus.SelectorOnlySystem = spec.Prometheus.SelectorOnlySystem || p.SelectorOnlySystem
+	}
+
+	if g := typed.Grafana; g != nil {
+		if g.Enabled != nil {
+			spec.Grafana.Enabled = g.Enabled
+		}
+		if g.Host != "" {
+			spec.Grafana.Host = g.Host
+		}
+		mergeStorage(spec.Grafana.Storage, g.Storage)
+	}
+
+	if a := typed.Alertmanager; a != nil {
+		if a.Enabled != nil {
+			spec.Alertmanager.Enabled = a.Enabled
+		}
+		if a.Host != "" {
+			spec.Alertmanager.Host = a.Host
+		}
+	}
+
+	if ing := typed.Ingress; ing != nil {
+		if ing.Enabled != nil {
+			spec.Ingress.Enabled = ing.Enabled
+		}
+		if ing.Class != "" {
+			spec.Ingress.Class = ing.Class
+		}
+	}
+
+	if typed.AdditionalScrapeConfigs != "" {
+		spec.AdditionalScrapeConfigs = typed.AdditionalScrapeConfigs
+	}
+	spec.IstioScrape = spec.IstioScrape || typed.IstioScrape
+	spec.CustomResourcesConfig = spec.CustomResourcesConfig || typed.CustomResourcesConfig
+}
+
+func setDefaultStorage(s *workloadv1beta1.LocalStorageSpec, path, size string) {
+	if s.Path == "" {
+		s.Path = path
+	}
+	if s.Size == nil {
+		q := resource.MustParse(size)
+		s.Size = &q
+	}
+	if s.Reclaim == "" {
+		s.Reclaim = defaultStorageReclaim
+	}
+}
+
+func setDefaultQuantity(list corev1.ResourceList, name corev1.ResourceName, va string) {
+	if _, ok := list[name]; !ok {
+		list[name] = resource.MustParse(va)
+	}
+}
+
+// setMonitoringDefaults fills the fields left empty, the switches default by the cluster type.
+func setMonitoringDefaults(spec *workloadv1beta1.MonitoringSpec, urlHead, clusterType string) {
+	_, isAlertManagerEnable, isGrafanaEnable, isIngress, _ := getPromSwitch(clusterType)
+
+	prom := spec.Prometheus
+	if prom.Retention == "" {
+		prom.Retention = defaultPromRetention
+	}
+	if prom.Host == "" {
+		prom.Host = fmt.Sprintf("%s.prometheus.dmall.com", urlHead)
+	}
+	setDefaultQuantity(prom.Resources.Limits, corev1.ResourceCPU, defaultPromLimitCPU)
+	setDefaultQuantity(prom.Resources.Limits, corev1.ResourceMemory, defaultPromLimitMemory)
+	setDefaultQuantity(prom.Resources.Requests, corev1.ResourceCPU, defaultPromReqCPU)
+	setDefaultQuantity(prom.Resources.Requests, corev1.ResourceMemory, defaultPromReqMemory)
+	setDefaultStorage(prom.Storage, defaultPromPvPath, defaultPromStorageSize)
+
+	if spec.Grafana.Enabled == nil {
+		spec.Grafana.Enabled = utils.BoolPointer(isGrafanaEnable)
+	}
+	if spec.Grafana.Host == "" {
+		spec.Grafana.Host = fmt.Sprintf("%s.grafana.dmall.com", urlHead)
+	}
+	setDefaultStorage(spec.Grafana.Storage, defaultGrafanaPvPath, defaultGrafanaStorageSize)
+
+	if spec.Alertmanager.Enabled == nil {
+		spec.Alertmanager.Enabled = utils.BoolPointer(isAlertManagerEnable)
+	}
+	if spec.Alertmanager.Host == "" {
+		spec.Alertmanager.Host = fmt.Sprintf("%s.alertmanager.dmall.com", urlHead)
+	}
+
+	if spec.Ingress.Enabled == nil {
+		spec.Ingress.Enabled = utils.BoolPointer(isIngress)
+	}
+}
+
+// buildMonitoringSpec returns the effective MonitoringSpec of the cluster, the legacy keys of
+// the monitor app values are converted and overridden by the typed spec of the cluster.
+func buildMonitoringSpec(obj *workloadv1beta1.Cluster, app *workloadv1beta1.HelmChartSpec, urlHead, clusterType string) (*workloadv1beta1.MonitoringSpec, error) {
+	spec, err := convertLegacyValues(app.Values)
+	if err != nil {
+		return nil, err
+	}
+
+	mergeMonitoringSpec(spec, obj.Spec.Monitoring)
+	setMonitoringDefaults(spec, urlHead, clusterType)
+	if err := spec.Validate(); err != nil {
+		return nil, errors.Wrapf(err, "cluster: %s invalid monitoring spec", obj.Name)
+	}
+	return spec, nil
+}
+
+func quantityString(list corev1.ResourceList, name corev1.ResourceName) string {
+	q := list[name]
+	return q.String()
+}
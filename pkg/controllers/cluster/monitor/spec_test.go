@@ -0,0 +1,152 @@
+package monitor
+
+import (
+	"testing"
+
+	workloadv1beta1 "gitlab.dmall.com/arch/sym-admin/pkg/apis/workload/v1beta1"
+	"gitlab.dmall.com/arch/sym-admin/pkg/controllers/cluster/common"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestBuildMonitoringSpec(t *testing.T) {
+	size := resource.MustParse("500Gi")
+	cases := []struct {
+		name      string
+		values    map[string]string
+		typed     *workloadv1beta1.MonitoringSpec
+		isErr     bool
+		retention string
+		promSize  string
+		promPath  string
+		limitCPU  string
+		promHost  string
+	}{
+		{
+			name:      "default",
+			retention: defaultPromRetention,
+			promSize:  defaultPromStorageSize,
+			promPath:  defaultPromPvPath,
+			limitCPU:  defaultPromLimitCPU,
+			promHost:  "head.prometheus.dmall.com",
+		},
+		{
+			name: "legacy",
+			values: map[string]string{
+				keyPromRetention:   "90d",
+				keyPromStorageSize: "200Gi",
+				keyPromPvPath:      "/web/prometheus-data",
+				keyPromLimitCPU:    "24",
+				keyPromReqCPU:      "16",
+				keyPromIngress:     "prom.dmall.com",
+			},
+			retention: "90d",
+			promSize:  "200Gi",
+			promPath:  "/web/prometheus-data",
+			limitCPU:  "24",
+			promHost:  "prom.dmall.com",
+		},
+		{
+			name: "typed override legacy",
+			values: map[string]string{
+				keyPromRetention:   "90d",
+				keyPromStorageSize: "200Gi",
+			},
+			typed: &workloadv1beta1.MonitoringSpec{
+				Prometheus: &workloadv1beta1.PrometheusSpec{
+					Retention: "30d",
+					Storage:   &workloadv1beta1.LocalStorageSpec{Size: &size},
+				},
+			},
+			retention: "30d",
+			promSize:  "500Gi",
+			promPath:  defaultPromPvPath,
+			limitCPU:  defaultPromLimitCPU,
+			promHost:  "head.prometheus.dmall.com",
+		},
+		{
+			name:   "invalid legacy quantity",
+			values: map[string]string{keyPromLimitMemory: "48G-i"},
+			isErr:  true,
+		},
+		{
+			name:   "request exceeds limit",
+			values: map[string]string{keyPromReqCPU: "16"},
+			isErr:  true,
+		},
+		{
+			name:   "invalid scrape configs",
+			values: map[string]string{},
+			typed:  &workloadv1beta1.MonitoringSpec{AdditionalScrapeConfigs: "- static_configs: []"},
+			isErr:  true,
+		},
+	}
+
+	for _, c := range cases {
+		obj := &workloadv1beta1.Cluster{
+			ObjectMeta: metav1.ObjectMeta{Name: c.name},
+			Spec:       workloadv1beta1.ClusterSpec{Monitoring: c.typed},
+		}
+		app := &workloadv1beta1.HelmChartSpec{Name: "monitor", Values: c.values}
+
+		spec, err := buildMonitoringSpec(obj, app, "head", "aks")
+		if c.isErr {
+			if err == nil {
+				t.Errorf("case: %s expect error", c.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("case: %s unexpected error: %v", c.name, err)
+			continue
+		}
+
+		prom := spec.Prometheus
+		limitCPU := prom.Resources.Limits[corev1.ResourceCPU]
+		if prom.Retention != c.retention || prom.Storage.Size.String() != c.promSize || prom.Storage.Path != c.promPath ||
+			limitCPU.String() != c.limitCPU || prom.Host != c.promHost {
+			t.Errorf("case: %s unexpected prometheus spec: %+v, storage: %+v", c.name, prom, prom.Storage)
+		}
+	}
+}
+
+func TestBuildMonitoringStatus(t *testing.T) {
+	disabled := false
+	obj := &workloadv1beta1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "tcc"},
+		Spec: workloadv1beta1.ClusterSpec{
+			Meta: map[string]string{
+				common.ClusterIngressHead:      "tcc",
+				common.ClusterIngressTLSSecret: "tls",
+			},
+			Monitoring: &workloadv1beta1.MonitoringSpec{
+				Alertmanager: &workloadv1beta1.AlertmanagerSpec{Enabled: &disabled},
+			},
+		},
+	}
+	app := &workloadv1beta1.HelmChartSpec{Name: "monitor"}
+
+	status, err := BuildMonitoringStatus(obj, app)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if status.PrometheusEndpoint == nil || *status.PrometheusEndpoint != "https://tcc.prometheus.dmall.com" {
+		t.Errorf("unexpected prometheus endpoint: %v", status.PrometheusEndpoint)
+	}
+	if status.GrafanaEndpoint == nil || *status.GrafanaEndpoint != "https://tcc.grafana.dmall.com" {
+		t.Errorf("unexpected grafana endpoint: %v", status.GrafanaEndpoint)
+	}
+	if status.AlertManagerEndpoint != nil {
+		t.Errorf("expect no alertmanager endpoint, current: %s", *status.AlertManagerEndpoint)
+	}
+
+	obj.Spec.Monitoring.Ingress = &workloadv1beta1.MonitoringIngressSpec{Enabled: &disabled}
+	status, err = BuildMonitoringStatus(obj, app)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if status.PrometheusEndpoint != nil || status.GrafanaEndpoint != nil {
+		t.Errorf("expect no endpoints without ingress, current: %+v", status)
+	}
+}
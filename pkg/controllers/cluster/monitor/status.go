@@ -0,0 +1,33 @@
+package monitor
+
+import (
+	workloadv1beta1 "gitlab.dmall.com/arch/sym-admin/pkg/apis/workload/v1beta1"
+	"gitlab.dmall.com/arch/sym-admin/pkg/controllers/cluster/common"
+)
+
+// BuildMonitoringStatus returns the endpoints of the monitor app exposed by the ingress,
+// the endpoint of a disabled component is left empty.
+func BuildMonitoringStatus(obj *workloadv1beta1.Cluster, app *workloadv1beta1.HelmChartSpec) (*workloadv1beta1.MonitoringStatus, error) {
+	spec, err := buildMonitoringSpec(obj, app, obj.Spec.Meta[common.ClusterIngressHead], obj.Spec.Meta[common.ClusterType])
+	if err != nil {
+		return nil, err
+	}
+
+	status := &workloadv1beta1.MonitoringStatus{}
+	if !*spec.Ingress.Enabled {
+		return status, nil
+	}
+
+	endpoint := func(host string) *string {
+		u := common.NewIngressOr(obj.Spec.Meta, common.IngressContour, host).URL()
+		return &u
+	}
+	status.PrometheusEndpoint = endpoint(spec.Prometheus.Host)
+	if *spec.Grafana.Enabled {
+		status.GrafanaEndpoint = endpoint(spec.Grafana.Host)
+	}
+	if *spec.Alertmanager.Enabled {
+		status.AlertManagerEndpoint = endpoint(spec.Alertmanager.Host)
+	}
+	return status, nil
+}
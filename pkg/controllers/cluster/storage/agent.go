@@ -0,0 +1,350 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"gitlab.dmall.com/arch/sym-admin/pkg/controllers/cluster/common"
+	pkgLabels "gitlab.dmall.com/arch/sym-admin/pkg/labels"
+	"gitlab.dmall.com/arch/sym-admin/pkg/resources"
+	"gitlab.dmall.com/arch/sym-admin/pkg/utils"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	AgentName      = "sym-local-storage-agent"
+	AgentNamespace = "sym-admin"
+	AgentImage     = "busybox:1.30"
+	// AgentHostRoot is the directory the roots of the host paths are mounted under in the agent,
+	// the root /data of a host path /data/loki is mounted at /host/data
+	AgentHostRoot = "/host"
+)
+
+// AgentRetryInterval is the interval the provision is retried at while the agent of the node
+// is rolling out.
+var AgentRetryInterval = 10 * time.Second
+
+// systemRoots are never mounted in the agent
+var systemRoots = map[string]bool{
+	"/bin": true, "/boot": true, "/dev": true, "/etc": true, "/lib": true, "/lib64": true,
+	"/proc": true, "/run": true, "/sbin": true, "/sys": true, "/usr": true,
+}
+
+// NotReadyError is returned while the agent of the node mounting the path is not ready, the
+// provision is retried after AgentRetryInterval instead of waiting.
+type NotReadyError struct {
+	msg string
+}
+
+func (e *NotReadyError) Error() string {
+	return e.msg
+}
+
+// IsNotReady returns true if the cause of the err is a NotReadyError.
+func IsNotReady(err error) bool {
+	_, ok := errors.Cause(err).(*NotReadyError)
+	return ok
+}
+
+func agentLabels() map[string]string {
+	return map[string]string{
+		"app": AgentName,
+	}
+}
+
+// cleanPath returns the absolute clean host path, the host root is refused.
+func cleanPath(path string) (string, error) {
+	p := filepath.Clean("/" + path)
+	if p == "/" {
+		return "", fmt.Errorf("refuse to operate on host root path: %q", path)
+	}
+	return p, nil
+}
+
+// hostRoot returns the top directory of the clean host path, which is mounted in the agent
+// for all the volumes under it. The system directories are refused.
+func hostRoot(path string) (string, error) {
+	p, err := cleanPath(path)
+	if err != nil {
+		return "", err
+	}
+	root := "/" + strings.SplitN(strings.TrimPrefix(p, "/"), "/", 2)[0]
+	if systemRoots[root] {
+		return "", fmt.Errorf("refuse to operate on system path: %q", path)
+	}
+	return root, nil
+}
+
+// agentVolumes mounts each of the roots under AgentHostRoot once, so the agent is not rolled
+// for the new volumes under the roots mounted.
+func agentVolumes(roots []string) ([]corev1.Volume, []corev1.VolumeMount) {
+	hostPathType := corev1.HostPathDirectoryOrCreate
+	volumes := make([]corev1.Volume, 0, len(roots))
+	mounts := make([]corev1.VolumeMount, 0, len(roots))
+	for i, path := range roots {
+		name := fmt.Sprintf("host-path-%d", i)
+		volumes = append(volumes, corev1.Volume{
+			Name: name,
+			VolumeSource: corev1.VolumeSource{
+				HostPath: &corev1.HostPathVolumeSource{
+					Path: path,
+					Type: &hostPathType,
+				},
+			},
+		})
+		mounts = append(mounts, corev1.VolumeMount{
+			Name:      name,
+			MountPath: filepath.Join(AgentHostRoot, path),
+		})
+	}
+	return volumes, mounts
+}
+
+// buildAgentDaemonSet builds the agent mounting the roots, which are clean and sorted.
+func buildAgentDaemonSet(clusterName, namespace string, roots []string) *appsv1.DaemonSet {
+	lb := agentLabels()
+	dsLabels := pkgLabels.GetLabels(clusterName)
+	for k, v := range lb {
+		dsLabels[k] = v
+	}
+	volumes, mounts := agentVolumes(roots)
+
+	return &appsv1.DaemonSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      AgentName,
+			Namespace: namespace,
+			Labels:    dsLabels,
+		},
+		Spec: appsv1.DaemonSetSpec{
+			Selector: &metav1.LabelSelector{
+				MatchLabels: lb,
+			},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: lb,
+				},
+				Spec: corev1.PodSpec{
+					Volumes: volumes,
+					Containers: []corev1.Container{
+						{
+							Name:    AgentName,
+							Image:   AgentImage,
+							Command: []string{"/bin/sh", "-c", "trap 'exit 0' TERM; while true; do sleep 3600 & wait $!; done"},
+							Resources: corev1.ResourceRequirements{
+								Limits: corev1.ResourceList{
+									corev1.ResourceCPU:    resource.MustParse("200m"),
+									corev1.ResourceMemory: resource.MustParse("64Mi"),
+								},
+								Requests: corev1.ResourceList{
+									corev1.ResourceCPU:    resource.MustParse("10m"),
+									corev1.ResourceMemory: resource.MustParse("16Mi"),
+								},
+							},
+							VolumeMounts:    mounts,
+							ImagePullPolicy: corev1.PullIfNotPresent,
+						},
+					},
+					RestartPolicy:                 corev1.RestartPolicyAlways,
+					TerminationGracePeriodSeconds: utils.Int64Pointer(5),
+					// only run on the nodes preserved for the add-ons
+					Affinity: &corev1.Affinity{
+						NodeAffinity: &corev1.NodeAffinity{
+							RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{
+								NodeSelectorTerms: []corev1.NodeSelectorTerm{
+									{
+										MatchExpressions: []corev1.NodeSelectorRequirement{
+											{
+												Key:      common.NodeSelectorKey,
+												Operator: corev1.NodeSelectorOpExists,
+											},
+										},
+									},
+								},
+							},
+						},
+					},
+					Tolerations: []corev1.Toleration{
+						{
+							Key:      common.NodeSelectorKey,
+							Operator: corev1.TolerationOpExists,
+						},
+					},
+				},
+			},
+			UpdateStrategy: appsv1.DaemonSetUpdateStrategy{
+				RollingUpdate: &appsv1.RollingUpdateDaemonSet{
+					MaxUnavailable: utils.IntstrPointer(1),
+				},
+			},
+		},
+	}
+}
+
+// EnsureNamespace creates the namespace of the agent, which is not in the member clusters.
+func (p *Provisioner) EnsureNamespace() error {
+	ns := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   p.namespace,
+			Labels: pkgLabels.GetLabels(p.k.Name),
+		},
+	}
+	_, err := resources.Reconcile(context.TODO(), p.k.Client, ns, resources.Option{})
+	if err != nil {
+		return errors.Wrapf(err, "cluster: %s apply storage agent namespace: %s", p.k.Name, p.namespace)
+	}
+	return nil
+}
+
+// volumeRoots returns the sorted roots of the host paths of the pvs provisioned and the extra ones.
+func (p *Provisioner) volumeRoots(extra ...string) ([]string, error) {
+	pvs := &corev1.PersistentVolumeList{}
+	err := p.k.Client.List(context.TODO(), pvs, &client.ListOptions{
+		LabelSelector: labels.SelectorFromSet(pkgLabels.GetLabels(p.k.Name)),
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "cluster: %s list pv", p.k.Name)
+	}
+
+	set := make(map[string]struct{})
+	for i := range pvs.Items {
+		pv := &pvs.Items[i]
+		if _, ok := pv.Labels[LabelComponent]; ok && pv.Spec.Local != nil {
+			extra = append(extra, pv.Spec.Local.Path)
+		}
+	}
+	for _, path := range extra {
+		if root, err := hostRoot(path); err == nil {
+			set[root] = struct{}{}
+		}
+	}
+
+	roots := make([]string, 0, len(set))
+	for root := range set {
+		roots = append(roots, root)
+	}
+	sort.Strings(roots)
+	return roots, nil
+}
+
+// EnsureAgent applies the node agent DaemonSet mounting the roots of the host paths of the
+// volumes provisioned and the extra paths.
+func (p *Provisioner) EnsureAgent(extra ...string) error {
+	if err := p.EnsureNamespace(); err != nil {
+		return err
+	}
+	roots, err := p.volumeRoots(extra...)
+	if err != nil {
+		return err
+	}
+
+	ds := buildAgentDaemonSet(p.k.Name, p.namespace, roots)
+	_, err = resources.Reconcile(context.TODO(), p.k.Client, ds, resources.Option{})
+	if err != nil {
+		return errors.Wrapf(err, "cluster: %s apply storage agent", p.k.Name)
+	}
+	return nil
+}
+
+// agentPod returns the ready agent pod running on the node which mounts the root, a
+// NotReadyError if the pod is rolling out.
+func (p *Provisioner) agentPod(nodeName, root string) (*corev1.Pod, error) {
+	pods := &corev1.PodList{}
+	err := p.k.Client.List(context.TODO(), pods, &client.ListOptions{
+		Namespace:     p.namespace,
+		LabelSelector: labels.SelectorFromSet(agentLabels()),
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "cluster: %s list storage agent pods", p.k.Name)
+	}
+
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if pod.Spec.NodeName != nodeName || pod.DeletionTimestamp != nil || !isPathMounted(pod, root) {
+			continue
+		}
+		if !isPodReady(pod) {
+			return nil, &NotReadyError{msg: fmt.Sprintf("cluster: %s storage agent pod: %s on node: %s is not ready", p.k.Name, pod.Name, nodeName)}
+		}
+		return pod, nil
+	}
+
+	return nil, &NotReadyError{msg: fmt.Sprintf("cluster: %s storage agent mounting: %s not found on node: %s", p.k.Name, root, nodeName)}
+}
+
+func isPathMounted(pod *corev1.Pod, path string) bool {
+	for _, v := range pod.Spec.Volumes {
+		if v.HostPath != nil && v.HostPath.Path == path {
+			return true
+		}
+	}
+	return false
+}
+
+func isPodReady(pod *corev1.Pod) bool {
+	if pod.Status.Phase != corev1.PodRunning {
+		return false
+	}
+	for _, c := range pod.Status.Conditions {
+		if c.Type == corev1.PodReady {
+			return c.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// PrepareDir creates the host directory on the node by the agent mounting its root.
+func (p *Provisioner) PrepareDir(nodeName, path string) error {
+	path, err := cleanPath(path)
+	if err != nil {
+		return err
+	}
+	root, err := hostRoot(path)
+	if err != nil {
+		return err
+	}
+
+	pod, err := p.agentPod(nodeName, root)
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Join(AgentHostRoot, path)
+	if _, err := p.k.ExecCommand(pod.Namespace, pod.Name, AgentName, []string{"mkdir", "-p", "--", dir}); err != nil {
+		return errors.Wrapf(err, "create dir: %s on node: %s", path, nodeName)
+	}
+	return nil
+}
+
+// RemoveDir removes the host directory on the node with its content.
+func (p *Provisioner) RemoveDir(nodeName, path string) error {
+	path, err := cleanPath(path)
+	if err != nil {
+		return err
+	}
+	root, err := hostRoot(path)
+	if err != nil {
+		return err
+	}
+	if root == path {
+		return fmt.Errorf("refuse to remove the root: %q", path)
+	}
+
+	pod, err := p.agentPod(nodeName, root)
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Join(AgentHostRoot, path)
+	_, err = p.k.ExecCommand(pod.Namespace, pod.Name, AgentName, []string{"find", dir, "-delete"})
+	return err
+}
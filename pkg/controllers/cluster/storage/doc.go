@@ -0,0 +1,3 @@
+// Package storage provisions the local persistent volumes used by the cluster add-ons,
+// the host directories are prepared through a node agent DaemonSet.
+package storage
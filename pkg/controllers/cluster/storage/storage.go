@@ -0,0 +1,259 @@
+package storage
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"gitlab.dmall.com/arch/sym-admin/pkg/controllers/cluster/common"
+	k8smanager "gitlab.dmall.com/arch/sym-admin/pkg/k8s/manager"
+	pkgLabels "gitlab.dmall.com/arch/sym-admin/pkg/labels"
+	"gitlab.dmall.com/arch/sym-admin/pkg/resources"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	LabelComponent = "sym-component"
+
+	// AnnotationNodeName is the node holding the host directory of the volume
+	AnnotationNodeName = "storage.dmall.com/node-name"
+	// AnnotationPreparedPath is set after the host directory is prepared
+	AnnotationPreparedPath = "storage.dmall.com/prepared-path"
+	// AnnotationReclaim is the host directory reclaim policy after the add-on is removed
+	AnnotationReclaim = "storage.dmall.com/reclaim"
+)
+
+// ReclaimPolicy describes what happens to the host directory after the add-on is removed.
+type ReclaimPolicy string
+
+const (
+	ReclaimRetain ReclaimPolicy = "Retain"
+	ReclaimDelete ReclaimPolicy = "Delete"
+)
+
+// ParseReclaimPolicy returns ReclaimDelete only if va is delete, default ReclaimRetain.
+func ParseReclaimPolicy(va string) ReclaimPolicy {
+	if strings.EqualFold(va, string(ReclaimDelete)) {
+		return ReclaimDelete
+	}
+	return ReclaimRetain
+}
+
+// Volume is a local persistent volume of an add-on.
+type Volume struct {
+	Name      string
+	Component string
+	Path      string
+	Size      string
+	// NodeName is the node the host directory is prepared on
+	NodeName string
+	// NodeSelectorVa is the value of common.NodeSelectorKey the volume is pinned to
+	NodeSelectorVa string
+	Reclaim        ReclaimPolicy
+}
+
+// Provisioner prepares local persistent volumes for the add-ons.
+type Provisioner struct {
+	k         *k8smanager.Cluster
+	namespace string
+}
+
+// New ...
+func New(k *k8smanager.Cluster) *Provisioner {
+	return &Provisioner{
+		k:         k,
+		namespace: AgentNamespace,
+	}
+}
+
+// EnsureStorageClass applies the local storage class.
+func (p *Provisioner) EnsureStorageClass() error {
+	reclaimPolicy := corev1.PersistentVolumeReclaimDelete
+	volumeBindingMode := storagev1.VolumeBindingWaitForFirstConsumer
+	sc := &storagev1.StorageClass{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   common.LocalStorageName,
+			Labels: pkgLabels.GetLabels(p.k.Name),
+		},
+		Provisioner:       "kubernetes.io/no-provisioner",
+		ReclaimPolicy:     &reclaimPolicy,
+		VolumeBindingMode: &volumeBindingMode,
+	}
+
+	klog.V(4).Infof("cluster: %s start reconcile StorageClasses: %s", p.k.Name, sc.Name)
+	_, err := resources.Reconcile(context.TODO(), p.k.Client, sc, resources.Option{})
+	if err != nil {
+		return errors.Wrapf(err, "cluster: %s apply storage class", p.k.Name)
+	}
+	return nil
+}
+
+func buildPv(clusterName string, vol *Volume) *corev1.PersistentVolume {
+	lb := pkgLabels.GetLabels(clusterName)
+	lb[LabelComponent] = vol.Component
+
+	return &corev1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   vol.Name,
+			Labels: lb,
+			Annotations: map[string]string{
+				AnnotationNodeName:     vol.NodeName,
+				AnnotationPreparedPath: vol.Path,
+				AnnotationReclaim:      string(vol.Reclaim),
+			},
+		},
+		Spec: corev1.PersistentVolumeSpec{
+			AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+			Capacity: corev1.ResourceList{
+				corev1.ResourceStorage: resource.MustParse(vol.Size),
+			},
+			PersistentVolumeSource: corev1.PersistentVolumeSource{
+				Local: &corev1.LocalVolumeSource{
+					Path: vol.Path,
+				},
+			},
+			PersistentVolumeReclaimPolicy: corev1.PersistentVolumeReclaimRetain,
+			StorageClassName:              common.LocalStorageName,
+			VolumeMode: func() *corev1.PersistentVolumeMode {
+				volumeMode := corev1.PersistentVolumeFilesystem
+				return &volumeMode
+			}(),
+			NodeAffinity: &corev1.VolumeNodeAffinity{
+				Required: &corev1.NodeSelector{
+					NodeSelectorTerms: []corev1.NodeSelectorTerm{
+						{
+							MatchExpressions: []corev1.NodeSelectorRequirement{
+								{
+									Key:      common.NodeSelectorKey,
+									Operator: corev1.NodeSelectorOpIn,
+									Values:   []string{vol.NodeSelectorVa},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// provisioned are the volumes last provisioned of the clusters by cluster/name, the volumes
+// not changed are not provisioned again.
+var provisioned sync.Map
+
+func provisionedKey(clusterName, name string) string {
+	return clusterName + "/" + name
+}
+
+// Provision prepares the host directory of the volume and applies the pv, it returns a
+// NotReadyError while the agent of the node is rolling out. The capacity of an existing pv
+// follows the size, the bound claim is not expanded since the local volumes have no quota.
+// Only the volumes changed since the last provision are provisioned.
+func (p *Provisioner) Provision(vol *Volume) error {
+	key := provisionedKey(p.k.Name, vol.Name)
+	if last, ok := provisioned.Load(key); ok && last.(Volume) == *vol {
+		return nil
+	}
+	if _, err := resource.ParseQuantity(vol.Size); err != nil {
+		return errors.Wrapf(err, "volume: %s invalid size: %s", vol.Name, vol.Size)
+	}
+
+	if err := p.EnsureStorageClass(); err != nil {
+		return err
+	}
+	if err := p.EnsureAgent(vol.Path); err != nil {
+		return err
+	}
+
+	current := &corev1.PersistentVolume{}
+	err := p.k.Client.Get(context.TODO(), types.NamespacedName{Name: vol.Name}, current)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return errors.Wrapf(err, "cluster: %s get pv: %s", p.k.Name, vol.Name)
+	}
+
+	isExist := err == nil
+	if !isExist || current.Annotations[AnnotationPreparedPath] != vol.Path || current.Annotations[AnnotationNodeName] != vol.NodeName {
+		if err := p.PrepareDir(vol.NodeName, vol.Path); err != nil {
+			return errors.Wrapf(err, "cluster: %s prepare pv: %s dir", p.k.Name, vol.Name)
+		}
+		klog.Infof("cluster: %s prepare pv: %s dir: %s on node: %s success", p.k.Name, vol.Name, vol.Path, vol.NodeName)
+	}
+
+	_, err = resources.Reconcile(context.TODO(), p.k.Client, buildPv(p.k.Name, vol), resources.Option{})
+	if err != nil {
+		return errors.Wrapf(err, "cluster: %s apply pv: %s", p.k.Name, vol.Name)
+	}
+	provisioned.Store(key, *vol)
+	return nil
+}
+
+// Reclaim deletes the released pvs of the add-ons which are not in the components,
+// the host directory is removed when the reclaim policy is Delete. It returns the number of
+// the pvs still bound, which are reclaimed by the next call.
+func (p *Provisioner) Reclaim(components []string) (int, error) {
+	expect := make(map[string]struct{}, len(components))
+	for _, c := range components {
+		expect[c] = struct{}{}
+	}
+
+	pvs := &corev1.PersistentVolumeList{}
+	ls := pkgLabels.GetLabels(p.k.Name)
+	err := p.k.Client.List(context.TODO(), pvs, &client.ListOptions{
+		LabelSelector: labels.SelectorFromSet(ls),
+	})
+	if err != nil {
+		return 0, errors.Wrapf(err, "cluster: %s list pv", p.k.Name)
+	}
+
+	var bound, deleted int
+	for i := range pvs.Items {
+		pv := &pvs.Items[i]
+		component, ok := pv.Labels[LabelComponent]
+		if !ok {
+			continue
+		}
+		if _, ok := expect[component]; ok {
+			continue
+		}
+
+		// wait for the add-on released the claim
+		if pv.Status.Phase == corev1.VolumeBound {
+			klog.V(4).Infof("cluster: %s pv: %s of removed component: %s is still bound", p.k.Name, pv.Name, component)
+			bound++
+			continue
+		}
+
+		if ParseReclaimPolicy(pv.Annotations[AnnotationReclaim]) == ReclaimDelete && pv.Spec.Local != nil {
+			err := p.RemoveDir(pv.Annotations[AnnotationNodeName], pv.Spec.Local.Path)
+			if err != nil {
+				return bound, errors.Wrapf(err, "cluster: %s remove pv: %s dir", p.k.Name, pv.Name)
+			}
+			klog.Infof("cluster: %s remove pv: %s dir: %s success", p.k.Name, pv.Name, pv.Spec.Local.Path)
+		}
+
+		err := p.k.Client.Delete(context.TODO(), pv)
+		if err != nil && !apierrors.IsNotFound(err) {
+			return bound, errors.Wrapf(err, "cluster: %s delete pv: %s", p.k.Name, pv.Name)
+		}
+		provisioned.Delete(provisionedKey(p.k.Name, pv.Name))
+		klog.Infof("cluster: %s reclaim pv: %s of removed component: %s", p.k.Name, pv.Name, component)
+		deleted++
+	}
+
+	// the agent stops mounting the directories of the deleted pvs
+	if deleted > 0 {
+		if err := p.EnsureAgent(); err != nil {
+			return bound, err
+		}
+	}
+	return bound, nil
+}
@@ -0,0 +1,155 @@
+package storage
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	k8sclient "gitlab.dmall.com/arch/sym-admin/pkg/k8s/client"
+	k8smanager "gitlab.dmall.com/arch/sym-admin/pkg/k8s/manager"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func newTestProvisioner(objs ...runtime.Object) *Provisioner {
+	return New(&k8smanager.Cluster{
+		Name:   "tx-test",
+		Client: fake.NewFakeClientWithScheme(k8sclient.GetScheme(), objs...),
+	})
+}
+
+func testPv(name, component, path string, reclaim ReclaimPolicy, phase corev1.PersistentVolumePhase) *corev1.PersistentVolume {
+	pv := buildPv("tx-test", &Volume{
+		Name:           name,
+		Component:      component,
+		Path:           path,
+		Size:           "10Gi",
+		NodeName:       "node-1",
+		NodeSelectorVa: "loki-data",
+		Reclaim:        reclaim,
+	})
+	pv.Status.Phase = phase
+	return pv
+}
+
+func TestParseReclaimPolicy(t *testing.T) {
+	for va, expect := range map[string]ReclaimPolicy{
+		"delete": ReclaimDelete,
+		"Delete": ReclaimDelete,
+		"retain": ReclaimRetain,
+		"":       ReclaimRetain,
+		"remove": ReclaimRetain,
+	} {
+		if current := ParseReclaimPolicy(va); current != expect {
+			t.Errorf("reclaim policy of %q: expect %s, current %s", va, expect, current)
+		}
+	}
+}
+
+func TestCleanPath(t *testing.T) {
+	for path, expect := range map[string]string{
+		"/root/loki-data":  "/root/loki-data",
+		"root/loki-data/":  "/root/loki-data",
+		"/data/../prom":    "/prom",
+		"/":                "",
+		"/data/..":         "",
+		"":                 "",
+		"../../..":         "",
+		"/web/./grafana//": "/web/grafana",
+	} {
+		current, err := cleanPath(path)
+		if current != expect || (expect == "") != (err != nil) {
+			t.Errorf("clean path of %q: expect %q, current %q, err: %v", path, expect, current, err)
+		}
+	}
+}
+
+func TestHostRoot(t *testing.T) {
+	for path, expect := range map[string]string{
+		"/root/loki-data":   "/root",
+		"web/prometheus/":   "/web",
+		"/data":             "/data",
+		"/etc/loki":         "",
+		"/usr/local/loki":   "",
+		"/":                 "",
+		"/data/../etc/loki": "",
+	} {
+		current, err := hostRoot(path)
+		if current != expect || (expect == "") != (err != nil) {
+			t.Errorf("host root of %q: expect %q, current %q, err: %v", path, expect, current, err)
+		}
+	}
+}
+
+func TestAgentDaemonSet(t *testing.T) {
+	p := newTestProvisioner(
+		testPv("loki-pv", "loki", "/root/loki-data", ReclaimRetain, corev1.VolumeBound),
+		testPv("prometheus-pv", "monitor", "/web/prometheus/", ReclaimRetain, corev1.VolumeBound),
+	)
+	roots, err := p.volumeRoots("/web/grafana", "/", "/root/loki-data", "/data/loki")
+	if err != nil {
+		t.Fatalf("volume roots err: %v", err)
+	}
+	expectRoots := []string{"/data", "/root", "/web"}
+	if !reflect.DeepEqual(roots, expectRoots) {
+		t.Errorf("expect host roots %q, current %q", expectRoots, roots)
+	}
+
+	ds := buildAgentDaemonSet("tx-test", AgentNamespace, roots)
+	var hostPaths, mounts []string
+	for _, v := range ds.Spec.Template.Spec.Volumes {
+		if *v.HostPath.Type != corev1.HostPathDirectoryOrCreate {
+			t.Errorf("unexpected host path type: %s", *v.HostPath.Type)
+		}
+		hostPaths = append(hostPaths, v.HostPath.Path)
+	}
+	for _, m := range ds.Spec.Template.Spec.Containers[0].VolumeMounts {
+		mounts = append(mounts, m.MountPath)
+	}
+	if !reflect.DeepEqual(hostPaths, expectRoots) {
+		t.Errorf("expect the volumes of %q, current %q", expectRoots, hostPaths)
+	}
+	expectMounts := []string{"/host/data", "/host/root", "/host/web"}
+	if !reflect.DeepEqual(mounts, expectMounts) {
+		t.Errorf("expect mounts %q, current %q", expectMounts, mounts)
+	}
+
+	// the agent is not waited for
+	err = p.PrepareDir("node-1", "/web/grafana")
+	if !IsNotReady(err) {
+		t.Errorf("expect not ready without the agent, current: %v", err)
+	}
+}
+
+func TestReclaim(t *testing.T) {
+	p := newTestProvisioner(
+		testPv("prometheus-pv", "monitor", "/web/prometheus", ReclaimDelete, corev1.VolumeBound),
+		testPv("grafana-pv", "grafana", "/web/grafana", ReclaimDelete, corev1.VolumeReleased),
+	)
+
+	bound, err := p.Reclaim([]string{"grafana"})
+	if err != nil {
+		t.Fatalf("reclaim err: %v", err)
+	}
+	if bound != 1 {
+		t.Errorf("expect the bound pv of monitor waiting, current: %d", bound)
+	}
+	for _, name := range []string{"prometheus-pv", "grafana-pv"} {
+		if err := p.k.Client.Get(context.TODO(), types.NamespacedName{Name: name}, &corev1.PersistentVolume{}); err != nil {
+			t.Errorf("expect pv: %s kept, err: %v", name, err)
+		}
+	}
+
+	// the directory is not removed without the agent, the pv is kept to retry
+	p = newTestProvisioner(testPv("loki-pv", "loki", "/root/loki-data", ReclaimDelete, corev1.VolumeReleased))
+	if _, err := p.Reclaim(nil); err == nil {
+		t.Errorf("expect the reclaim failed without the agent")
+	}
+	err = p.k.Client.Get(context.TODO(), types.NamespacedName{Name: "loki-pv"}, &corev1.PersistentVolume{})
+	if apierrors.IsNotFound(err) {
+		t.Errorf("expect the pv kept when its directory is not removed")
+	}
+}
@@ -56,7 +56,7 @@ func (r *reconciler) makeOverrideTraefikMap() map[string]interface{} {
 			},
 		},
 		"service": map[string]interface{}{
-			"annotations": common.GetLbServiceAnnotations(r.clusterType, r.k.Client),
+			"annotations": common.GetLbServiceAnnotations(r.obj.Spec.Meta, r.k.Client),
 		},
 		"debug": map[string]interface{}{
 			"enabled": false,
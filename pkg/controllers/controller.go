@@ -36,7 +36,11 @@ func AddToManager(m manager.Manager, dksMgr *pkgmanager.DksManager) error {
 		}
 
 		if dksMgr.Opt.EventEnabled {
-			AddToManagerWithCMFuncs = append(AddToManagerWithCMFuncs, eventexporter.Add)
+			if dksMgr.Opt.EventMultiCluster {
+				AddToManagerWithCMFuncs = append(AddToManagerWithCMFuncs, eventexporter.AddMultiCluster)
+			} else {
+				AddToManagerWithCMFuncs = append(AddToManagerWithCMFuncs, eventexporter.Add)
+			}
 		}
 	}
 
@@ -0,0 +1,41 @@
+package eventexporter
+
+import (
+	k8smanager "gitlab.dmall.com/arch/sym-admin/pkg/k8s/manager"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// localClients acts on the cluster of the controller whatever the cluster name of the events,
+// the cluster is refused under the maintenance if it is known to the ClusterManager.
+type localClients struct {
+	client      client.Client
+	clustersMgr *k8smanager.ClusterManager
+}
+
+func (c *localClients) Client(name string) (client.Client, error) {
+	if c.clustersMgr != nil && name != "" {
+		if cluster, err := c.clustersMgr.Get(name); err == nil {
+			if err := cluster.CheckMaintenance(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return c.client, nil
+}
+
+// clusterManagerClients acts on the clusters of the ClusterManager, the clusters under
+// maintenance are refused.
+type clusterManagerClients struct {
+	clustersMgr *k8smanager.ClusterManager
+}
+
+func (c *clusterManagerClients) Client(name string) (client.Client, error) {
+	cluster, err := c.clustersMgr.Get(name)
+	if err != nil {
+		return nil, err
+	}
+	if err := cluster.CheckMaintenance(); err != nil {
+		return nil, err
+	}
+	return cluster.Client, nil
+}
@@ -0,0 +1,183 @@
+package eventexporter
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"gitlab.dmall.com/arch/sym-admin/pkg/controllers/eventexporter/exporter"
+	"gitlab.dmall.com/arch/sym-admin/pkg/controllers/eventexporter/sinks"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog"
+)
+
+const (
+	// DefaultConfigMap is the ConfigMap of the routes and receivers, namespace/name
+	DefaultConfigMap = "sym-admin/sym-event-exporter"
+	// ConfigMapKey is the key of the config in the ConfigMap
+	ConfigMapKey = "config.yaml"
+)
+
+// configLoader loads the exporter config from the ConfigMap and reloads the engine
+// when the ConfigMap changes, the built-in EventConfig is used when the ConfigMap is absent.
+type configLoader struct {
+	kubeCli       kubernetes.Interface
+	namespace     string
+	name          string
+	alertEndpoint string
+	clients       sinks.ClusterClients
+	engine        *exporter.Engine
+	// actionStates keeps the rate limits and the cooldowns of the action receivers across
+	// the reloads, by the name and the type of the receivers
+	actionStates map[string]*sinks.ActionState
+}
+
+func newConfigLoader(kubeCli kubernetes.Interface, configMap, alertEndpoint string, clients sinks.ClusterClients) (*configLoader, error) {
+	if configMap == "" {
+		configMap = DefaultConfigMap
+	}
+	s := strings.Split(configMap, "/")
+	if len(s) != 2 || s[0] == "" || s[1] == "" {
+		return nil, fmt.Errorf("invalid event config map: %s, must be namespace/name", configMap)
+	}
+
+	return &configLoader{
+		kubeCli:       kubeCli,
+		namespace:     s[0],
+		name:          s[1],
+		alertEndpoint: alertEndpoint,
+		clients:       clients,
+		actionStates:  make(map[string]*sinks.ActionState),
+	}, nil
+}
+
+// resolveReceivers fills the endpoint of the alertmanager receivers left empty and the
+// clients and the states of the action receivers.
+func (l *configLoader) resolveReceivers(cfg *exporter.Config) error {
+	for i := range cfg.Receivers {
+		receiver := &cfg.Receivers[i]
+		if receiver.Action != nil {
+			receiver.Action.Clients = l.clients
+			receiver.Action.State = l.actionState(receiver.Name + "/" + receiver.Action.Type)
+		}
+		if receiver.AlertManager == nil || receiver.AlertManager.Endpoint != "" {
+			continue
+		}
+
+		if len(l.alertEndpoint) > 0 {
+			receiver.AlertManager.Endpoint = l.alertEndpoint
+			continue
+		}
+
+		list, err := l.kubeCli.CoreV1().Services("monitoring").List(context.TODO(),
+			metav1.ListOptions{LabelSelector: fmt.Sprintf("app=%s", "prometheus-operator-alertmanager")})
+		if err != nil {
+			return fmt.Errorf("get alertmanager service err: %v", err)
+		}
+		if len(list.Items) == 0 {
+			return fmt.Errorf("receiver: %s no alertmanager service found", receiver.Name)
+		}
+		receiver.AlertManager.Endpoint = fmt.Sprintf("http://%s.%s.svc:9093", list.Items[0].Name, list.Items[0].Namespace)
+	}
+	return nil
+}
+
+func (l *configLoader) actionState(key string) *sinks.ActionState {
+	state, ok := l.actionStates[key]
+	if !ok {
+		state = sinks.NewActionState()
+		l.actionStates[key] = state
+	}
+	return state
+}
+
+func (l *configLoader) parse(cm *corev1.ConfigMap) (*exporter.Config, string, error) {
+	data := EventConfig
+	if cm != nil {
+		va, ok := cm.Data[ConfigMapKey]
+		if !ok {
+			return nil, "", fmt.Errorf("configmap: %s/%s has no key: %s", l.namespace, l.name, ConfigMapKey)
+		}
+		data = va
+	}
+
+	cfg, version, err := exporter.LoadConfig([]byte(data))
+	if err != nil {
+		return nil, "", err
+	}
+	if err := l.resolveReceivers(cfg); err != nil {
+		return nil, "", err
+	}
+	return cfg, version, nil
+}
+
+// load builds the engine from the current ConfigMap, it falls back to the built-in
+// config when the ConfigMap is absent or invalid.
+func (l *configLoader) load() (*exporter.Engine, error) {
+	cm, err := l.kubeCli.CoreV1().ConfigMaps(l.namespace).Get(context.TODO(), l.name, metav1.GetOptions{})
+	if err != nil {
+		if !apierrors.IsNotFound(err) {
+			return nil, err
+		}
+		klog.Infof("event config map: %s/%s not found, use the built-in config", l.namespace, l.name)
+		cm = nil
+	}
+
+	cfg, version, err := l.parse(cm)
+	if err == nil {
+		l.engine, err = exporter.NewEngine(cfg, version)
+	}
+	if err != nil && cm != nil {
+		klog.Errorf("event config map: %s/%s err: %v, use the built-in config", l.namespace, l.name, err)
+		cfg, version, err = l.parse(nil)
+		if err == nil {
+			l.engine, err = exporter.NewEngine(cfg, version)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return l.engine, nil
+}
+
+func (l *configLoader) reload(cm *corev1.ConfigMap) {
+	cfg, version, err := l.parse(cm)
+	if err != nil {
+		klog.Errorf("event config map: %s/%s err: %v, keep the config version: %s", l.namespace, l.name, err, l.engine.Version())
+		return
+	}
+	if version == l.engine.Version() {
+		return
+	}
+
+	if err := l.engine.Reload(cfg, version); err != nil {
+		klog.Errorf("event config map: %s/%s reload err: %v, keep the config version: %s", l.namespace, l.name, err, l.engine.Version())
+	}
+}
+
+// Start watches the ConfigMap until the stop channel is closed.
+func (l *configLoader) Start(stop <-chan struct{}) error {
+	lw := cache.NewListWatchFromClient(l.kubeCli.CoreV1().RESTClient(), "configmaps", l.namespace,
+		fields.OneTermEqualSelector("metadata.name", l.name))
+	_, informer := cache.NewInformer(lw, &corev1.ConfigMap{}, 0, cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			l.reload(obj.(*corev1.ConfigMap))
+		},
+		UpdateFunc: func(_, obj interface{}) {
+			l.reload(obj.(*corev1.ConfigMap))
+		},
+		DeleteFunc: func(obj interface{}) {
+			klog.Infof("event config map: %s/%s deleted, use the built-in config", l.namespace, l.name)
+			l.reload(nil)
+		},
+	})
+
+	informer.Run(stop)
+	l.engine.Close()
+	return nil
+}
@@ -2,19 +2,17 @@ package eventexporter
 
 import (
 	"context"
-	"time"
-
 	"fmt"
+	"time"
 
 	"github.com/go-logr/logr"
 	"gitlab.dmall.com/arch/sym-admin/pkg/controllers/eventexporter/exporter"
 	"gitlab.dmall.com/arch/sym-admin/pkg/controllers/eventexporter/kube"
+	"gitlab.dmall.com/arch/sym-admin/pkg/controllers/eventexporter/sinks"
 	pkgLabels "gitlab.dmall.com/arch/sym-admin/pkg/labels"
 	pkgmanager "gitlab.dmall.com/arch/sym-admin/pkg/manager"
-	"gopkg.in/yaml.v2"
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/klog"
@@ -33,32 +31,99 @@ const (
 	controllerName = "events-controller"
 )
 
+// EventConfig is the built-in config, used when the config map of the cluster is absent.
 const (
 	EventConfig = `
 route:
   routes:
     - drop:
-        - namespace: "*test*"
+        - namespace: ".*test.*"
         - type: "Normal"
       match:
         - receiver: "alert"
 receivers:
   - name: "alert"
-    alertmanager:
+    alertManager:
       headers:
         User-Agent: "event-exporter"
+aggregation:
+  window: 5m
+rateLimit:
+  app:
+    qps: 0.1
+    burst: 10
 `
 )
 
 type eventReconciler struct {
 	client.Client
-	Name            string
-	Log             logr.Logger
-	Mgr             manager.Manager
-	engine          *exporter.Engine
+	Name        string
+	Log         logr.Logger
+	Mgr         manager.Manager
+	engine      *exporter.Engine
+	objects     *objectCache
+	ClusterName string
+}
+
+// objectCache enhances the events of a cluster with the labels, the annotations and the
+// owner chain context of the involved objects.
+type objectCache struct {
 	labelCache      *kube.LabelCache
 	annotationCache *kube.AnnotationCache
-	ClusterName     string
+	resolver        *contextResolver
+}
+
+func newObjectCache(kubeCli kubernetes.Interface, dynClient dynamic.Interface, resolver *contextResolver) *objectCache {
+	return &objectCache{
+		labelCache:      kube.NewLabelCache(kubeCli, dynClient),
+		annotationCache: kube.NewAnnotationCache(kubeCli, dynClient),
+		resolver:        resolver,
+	}
+}
+
+func (c *objectCache) enhance(e *corev1.Event, logger logr.Logger) *kube.EnhancedEvent {
+	ev := &kube.EnhancedEvent{
+		Event: *e,
+	}
+	ev.InvolvedObject.ObjectReference = e.InvolvedObject
+
+	labels, err := c.labelCache.GetLabelsWithCache(&e.InvolvedObject)
+	if err != nil {
+		logger.Error(err, "Cannot list labels of the object")
+	} else {
+		ev.InvolvedObject.Labels = labels
+	}
+
+	annotations, err := c.annotationCache.GetAnnotationsWithCache(&e.InvolvedObject)
+	if err != nil {
+		logger.Error(err, "Cannot list annotations of the object")
+	} else {
+		ev.InvolvedObject.Annotations = annotations
+	}
+
+	ec, err := c.resolver.Resolve(context.TODO(), &e.InvolvedObject, ev.InvolvedObject.Labels)
+	if err != nil {
+		logger.Error(err, "Cannot resolve the owner chain of the object")
+	} else {
+		ev.Context = ec
+	}
+	return ev
+}
+
+// newEngine loads the engine from the config map and adds the loader to the manager for the hot reload.
+func newEngine(mgr manager.Manager, cMgr *pkgmanager.DksManager, kubeCli kubernetes.Interface, clients sinks.ClusterClients) (*exporter.Engine, error) {
+	loader, err := newConfigLoader(kubeCli, cMgr.Opt.EventConfigMap, cMgr.Opt.AlertEndpoint, clients)
+	if err != nil {
+		return nil, err
+	}
+	engine, err := loader.load()
+	if err != nil {
+		return nil, fmt.Errorf("cannot load event exporter config err: %v", err)
+	}
+	if err := mgr.Add(loader); err != nil {
+		return nil, err
+	}
+	return engine, nil
 }
 
 func onEvent(obj interface{}) bool {
@@ -114,31 +179,12 @@ func Add(mgr manager.Manager, cMgr *pkgmanager.DksManager) error {
 		return err
 	}
 
-	eventCfg := &exporter.Config{}
-	err = yaml.Unmarshal([]byte(EventConfig), eventCfg)
+	r.engine, err = newEngine(mgr, cMgr, kubeCli, &localClients{client: mgr.GetClient(), clustersMgr: cMgr.ClustersMgr})
 	if err != nil {
-		klog.Fatalf("cannot parse config err: %+v, yaml: \n%s", err, EventConfig)
-	}
-
-	for i := range eventCfg.Receivers {
-		receiver := &eventCfg.Receivers[i]
-		if receiver.AlertManager != nil {
-			if len(cMgr.Opt.AlertEndpoint) > 0 {
-				receiver.AlertManager.Endpoint = cMgr.Opt.AlertEndpoint
-			} else {
-				list, err := kubeCli.CoreV1().Services("monitoring").List(context.TODO(),
-					metav1.ListOptions{LabelSelector: fmt.Sprintf("app=%s", "prometheus-operator-alertmanager")})
-				if err != nil {
-					klog.Fatalf("get alertmanager service err: %+v", err)
-				}
-
-				receiver.AlertManager.Endpoint = fmt.Sprintf("http://%s.%s.svc:9093", list.Items[0].Name, list.Items[0].Namespace)
-			}
-		}
+		klog.Fatalf("%v", err)
 	}
-	r.engine = exporter.NewEngine(eventCfg)
-	r.labelCache = kube.NewLabelCache(kubeCli, dynClient)
-	r.annotationCache = kube.NewAnnotationCache(kubeCli, dynClient)
+
+	r.objects = newObjectCache(kubeCli, dynClient, newContextResolver(mgr.GetAPIReader(), nil))
 	return nil
 }
 
@@ -175,26 +221,7 @@ func (r *eventReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 	logger.Info("Received event", "namespace", e.Namespace,
 		"reason", e.Reason, "involvedObject", e.InvolvedObject.Name, "msg", e.Message)
 
-	ev := &kube.EnhancedEvent{
-		Event: *e,
-	}
-
-	labels, err := r.labelCache.GetLabelsWithCache(&e.InvolvedObject)
-	if err != nil {
-		logger.Error(err, "Cannot list labels of the object")
-	} else {
-		ev.InvolvedObject.Labels = labels
-		ev.InvolvedObject.ObjectReference = e.InvolvedObject
-	}
-
-	annotations, err := r.annotationCache.GetAnnotationsWithCache(&e.InvolvedObject)
-	if err != nil {
-		logger.Error(err, "Cannot list annotations of the object")
-	} else {
-		ev.InvolvedObject.Annotations = annotations
-		ev.InvolvedObject.ObjectReference = e.InvolvedObject
-	}
-
+	ev := r.objects.enhance(e, logger)
 	if len(r.ClusterName) == 0 {
 		if name, ok := ev.InvolvedObject.Labels[pkgLabels.ObserveMustLabelClusterName]; ok {
 			r.ClusterName = name
@@ -202,6 +229,6 @@ func (r *eventReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 	}
 
 	ev.ClusterName = r.ClusterName
-	r.engine.Route.ProcessEvent(ev, r.engine.Registry)
+	r.engine.ProcessEvent(ev)
 	return reconcile.Result{}, nil
 }
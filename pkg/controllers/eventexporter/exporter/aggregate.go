@@ -0,0 +1,228 @@
+package exporter
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"gitlab.dmall.com/arch/sym-admin/pkg/controllers/eventexporter/kube"
+	pkgLabels "gitlab.dmall.com/arch/sym-admin/pkg/labels"
+	"k8s.io/client-go/util/flowcontrol"
+)
+
+const aggregationFlushInterval = time.Second
+
+// limiterIdleTTL is how long the limiter of an app is kept without events, a limiter is
+// kept at least until its bucket is full again so the eviction does not reset the limit.
+const limiterIdleTTL = 10 * time.Minute
+
+// AggregationConfig groups the events of the same involved object, reason and app in the
+// window, the first event is sent at once and the others are summarized in one event
+// sent at the end of the window. The aggregation is disabled when the window is 0.
+type AggregationConfig struct {
+	Window time.Duration `json:"window,omitempty" yaml:"window"`
+}
+
+// RateLimitConfig is a token bucket.
+type RateLimitConfig struct {
+	QPS   float32 `json:"qps,omitempty" yaml:"qps"`
+	Burst int     `json:"burst,omitempty" yaml:"burst"`
+}
+
+// RateLimitsConfig limits the events sent to each receiver, and to each receiver per app.
+type RateLimitsConfig struct {
+	Receiver *RateLimitConfig `json:"receiver,omitempty" yaml:"receiver"`
+	App      *RateLimitConfig `json:"app,omitempty" yaml:"app"`
+}
+
+func (c *RateLimitConfig) validate() error {
+	if c == nil {
+		return nil
+	}
+	if c.QPS <= 0 || c.Burst <= 0 {
+		return fmt.Errorf("qps and burst must be positive, current: %v %d", c.QPS, c.Burst)
+	}
+	return nil
+}
+
+func eventApp(ev *kube.EnhancedEvent) string {
+	return ev.InvolvedObject.Labels[pkgLabels.ObserveMustLabelAppName]
+}
+
+type aggregateKey struct {
+	cluster   string
+	namespace string
+	kind      string
+	name      string
+	reason    string
+	app       string
+}
+
+type aggregateGroup struct {
+	start  time.Time
+	latest *kube.EnhancedEvent
+	total  int32
+}
+
+// Aggregator deduplicates and aggregates the events by the cluster, involved object, reason and app.
+type Aggregator struct {
+	mu     sync.Mutex
+	window time.Duration
+	groups map[aggregateKey]*aggregateGroup
+	now    func() time.Time
+}
+
+// NewAggregator ...
+func NewAggregator(window time.Duration) *Aggregator {
+	return &Aggregator{
+		window: window,
+		groups: make(map[aggregateKey]*aggregateGroup),
+		now:    time.Now,
+	}
+}
+
+// SetWindow changes the window, the groups in progress are kept.
+func (a *Aggregator) SetWindow(window time.Duration) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.window = window
+}
+
+// Add returns true when the event is the first of its group and should be sent now.
+func (a *Aggregator) Add(ev *kube.EnhancedEvent) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.window <= 0 {
+		return true
+	}
+
+	key := aggregateKey{
+		cluster:   ev.ClusterName,
+		namespace: ev.InvolvedObject.Namespace,
+		kind:      ev.InvolvedObject.Kind,
+		name:      ev.InvolvedObject.Name,
+		reason:    ev.Reason,
+		app:       eventApp(ev),
+	}
+	g, ok := a.groups[key]
+	if !ok {
+		a.groups[key] = &aggregateGroup{start: a.now(), latest: ev, total: 1}
+		return true
+	}
+
+	// the same event is reconciled again, e.g. on resync
+	if g.latest.UID == ev.UID && g.latest.ResourceVersion == ev.ResourceVersion {
+		return false
+	}
+	g.latest = ev
+	g.total++
+	return false
+}
+
+// Flush returns the summaries of the groups whose window is over, all the groups
+// are flushed when force is true.
+func (a *Aggregator) Flush(force bool) []*kube.EnhancedEvent {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	var summaries []*kube.EnhancedEvent
+	now := a.now()
+	for key, g := range a.groups {
+		if !force && now.Sub(g.start) < a.window {
+			continue
+		}
+
+		delete(a.groups, key)
+		if g.total <= 1 {
+			continue
+		}
+		summary := *g.latest
+		summary.Aggregated = g.total
+		summaries = append(summaries, &summary)
+	}
+	return summaries
+}
+
+// rateLimitedRegistry drops the events exceeding the rate limits of the receiver or the app.
+type rateLimitedRegistry struct {
+	ReceiverRegistry
+	limits *RateLimitsConfig
+
+	mu        sync.Mutex
+	limiters  map[string]*limiterEntry
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+type limiterEntry struct {
+	flowcontrol.RateLimiter
+	lastUsed time.Time
+	// idle is how long the limiter is kept without events
+	idle time.Duration
+}
+
+func newRateLimitedRegistry(registry ReceiverRegistry, limits *RateLimitsConfig) ReceiverRegistry {
+	if limits == nil || (limits.Receiver == nil && limits.App == nil) {
+		return registry
+	}
+
+	return &rateLimitedRegistry{
+		ReceiverRegistry: registry,
+		limits:           limits,
+		limiters:         make(map[string]*limiterEntry),
+		now:              time.Now,
+	}
+}
+
+func (r *rateLimitedRegistry) tryAccept(key string, cfg *RateLimitConfig) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.now()
+	r.sweep(now)
+	l, ok := r.limiters[key]
+	if !ok {
+		idle := time.Duration(float64(cfg.Burst) / float64(cfg.QPS) * float64(time.Second))
+		if idle < limiterIdleTTL {
+			idle = limiterIdleTTL
+		}
+		l = &limiterEntry{
+			RateLimiter: flowcontrol.NewTokenBucketRateLimiter(cfg.QPS, cfg.Burst),
+			idle:        idle,
+		}
+		r.limiters[key] = l
+	}
+	l.lastUsed = now
+	return l.TryAccept()
+}
+
+// sweep evicts the idle limiters, at most once in limiterIdleTTL.
+func (r *rateLimitedRegistry) sweep(now time.Time) {
+	if now.Sub(r.lastSweep) < limiterIdleTTL {
+		return
+	}
+	r.lastSweep = now
+	for key, l := range r.limiters {
+		if now.Sub(l.lastUsed) >= l.idle {
+			delete(r.limiters, key)
+		}
+	}
+}
+
+func (r *rateLimitedRegistry) SendEvent(name string, ev *kube.EnhancedEvent) {
+	// the app limit is checked first so that a noisy app does not use up the budget of the receiver
+	if app := eventApp(ev); r.limits.App != nil && app != "" {
+		if !r.tryAccept(name+"/"+app, r.limits.App) {
+			eventsSuppressedTotal.WithLabelValues("app_limit", name).Inc()
+			return
+		}
+	}
+
+	if r.limits.Receiver != nil && !r.tryAccept(name, r.limits.Receiver) {
+		eventsSuppressedTotal.WithLabelValues("receiver_limit", name).Inc()
+		return
+	}
+
+	r.ReceiverRegistry.SendEvent(name, ev)
+}
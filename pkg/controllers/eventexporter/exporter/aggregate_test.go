@@ -0,0 +1,170 @@
+package exporter
+
+import (
+	"testing"
+	"time"
+
+	"gitlab.dmall.com/arch/sym-admin/pkg/controllers/eventexporter/kube"
+	"gitlab.dmall.com/arch/sym-admin/pkg/controllers/eventexporter/sinks"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func newTestEvent(name, reason, app string, count int32) *kube.EnhancedEvent {
+	ev := &kube.EnhancedEvent{
+		Event: corev1.Event{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            name,
+				UID:             types.UID(name),
+				ResourceVersion: string(rune('0' + count)),
+			},
+			Reason: reason,
+			Count:  count,
+		},
+	}
+	ev.InvolvedObject.Kind = "Pod"
+	ev.InvolvedObject.Name = name
+	ev.InvolvedObject.Namespace = "default"
+	ev.InvolvedObject.Labels = map[string]string{"app": app}
+	return ev
+}
+
+func TestAggregator(t *testing.T) {
+	now := time.Now()
+	a := NewAggregator(time.Minute)
+	a.now = func() time.Time { return now }
+
+	if !a.Add(newTestEvent("p1", "BackOff", "bbcc", 1)) {
+		t.Error("expect the first event sent")
+	}
+	if a.Add(newTestEvent("p1", "BackOff", "bbcc", 1)) {
+		t.Error("expect the same event deduplicated")
+	}
+	for i := int32(2); i <= 5; i++ {
+		if a.Add(newTestEvent("p1", "BackOff", "bbcc", i)) {
+			t.Errorf("expect event count: %d aggregated", i)
+		}
+	}
+	if !a.Add(newTestEvent("p1", "Unhealthy", "bbcc", 1)) {
+		t.Error("expect the event of another reason sent")
+	}
+
+	if summaries := a.Flush(false); len(summaries) != 0 {
+		t.Errorf("expect no summaries in the window, current: %d", len(summaries))
+	}
+
+	now = now.Add(time.Minute)
+	summaries := a.Flush(false)
+	if len(summaries) != 1 {
+		t.Fatalf("expect 1 summary, current: %d", len(summaries))
+	}
+	if s := summaries[0]; s.Aggregated != 5 || s.Count != 5 || s.Reason != "BackOff" {
+		t.Errorf("unexpected summary: aggregated: %d count: %d reason: %s", s.Aggregated, s.Count, s.Reason)
+	}
+
+	if !a.Add(newTestEvent("p1", "BackOff", "bbcc", 6)) {
+		t.Error("expect the event of a new window sent")
+	}
+
+	a.SetWindow(0)
+	if !a.Add(newTestEvent("p1", "BackOff", "bbcc", 7)) {
+		t.Error("expect the event sent when the aggregation is disabled")
+	}
+}
+
+func TestAggregatorClusters(t *testing.T) {
+	now := time.Now()
+	a := NewAggregator(time.Minute)
+	a.now = func() time.Time { return now }
+
+	for _, cluster := range []string{"cluster-a", "cluster-b"} {
+		ev := newTestEvent("p1", "BackOff", "bbcc", 1)
+		ev.ClusterName = cluster
+		if !a.Add(ev) {
+			t.Errorf("expect the first event of cluster: %s sent", cluster)
+		}
+		ev = newTestEvent("p1", "BackOff", "bbcc", 2)
+		ev.ClusterName = cluster
+		if a.Add(ev) {
+			t.Errorf("expect the second event of cluster: %s aggregated", cluster)
+		}
+	}
+
+	now = now.Add(time.Minute)
+	summaries := a.Flush(false)
+	if len(summaries) != 2 {
+		t.Fatalf("expect 1 summary per cluster, current: %d", len(summaries))
+	}
+	if summaries[0].ClusterName == summaries[1].ClusterName {
+		t.Errorf("expect the summaries of both clusters, current: %s", summaries[0].ClusterName)
+	}
+}
+
+type countRegistry struct {
+	counts map[string]int
+}
+
+func (r *countRegistry) SendEvent(name string, ev *kube.EnhancedEvent) {
+	r.counts[name]++
+}
+
+func (r *countRegistry) Register(string, sinks.Sink, *sinks.DeliveryConfig) {}
+
+func (r *countRegistry) Close() {}
+
+func TestRateLimitedRegistry(t *testing.T) {
+	base := &countRegistry{counts: map[string]int{}}
+	registry := newRateLimitedRegistry(base, &RateLimitsConfig{
+		Receiver: &RateLimitConfig{QPS: 0.001, Burst: 5},
+		App:      &RateLimitConfig{QPS: 0.001, Burst: 2},
+	})
+
+	for i := 0; i < 10; i++ {
+		registry.SendEvent("alert", newTestEvent("p1", "BackOff", "noisy", 1))
+	}
+	if base.counts["alert"] != 2 {
+		t.Errorf("expect 2 events of the noisy app, current: %d", base.counts["alert"])
+	}
+
+	for _, app := range []string{"a", "b", "c", "d"} {
+		registry.SendEvent("alert", newTestEvent("p1", "BackOff", app, 1))
+	}
+	if base.counts["alert"] != 5 {
+		t.Errorf("expect 5 events of the receiver, current: %d", base.counts["alert"])
+	}
+
+	registry.SendEvent("webhook", newTestEvent("p1", "BackOff", "e", 1))
+	if base.counts["webhook"] != 1 {
+		t.Errorf("expect the limits per receiver, current: %d", base.counts["webhook"])
+	}
+
+	if r := newRateLimitedRegistry(base, nil); r != base {
+		t.Error("expect no wrapper without limits")
+	}
+}
+
+func TestRateLimitedRegistryEviction(t *testing.T) {
+	base := &countRegistry{counts: map[string]int{}}
+	registry := newRateLimitedRegistry(base, &RateLimitsConfig{
+		App: &RateLimitConfig{QPS: 1, Burst: 1},
+	}).(*rateLimitedRegistry)
+	now := time.Now()
+	registry.now = func() time.Time { return now }
+
+	for _, app := range []string{"a", "b", "c"} {
+		registry.SendEvent("alert", newTestEvent("p1", "BackOff", app, 1))
+	}
+	now = now.Add(limiterIdleTTL / 2)
+	registry.SendEvent("alert", newTestEvent("p1", "BackOff", "a", 1))
+	if len(registry.limiters) != 3 {
+		t.Fatalf("expect 3 limiters, current: %d", len(registry.limiters))
+	}
+
+	// b and c are idle, a is used recently
+	now = now.Add(limiterIdleTTL * 3 / 4)
+	registry.SendEvent("alert", newTestEvent("p1", "BackOff", "d", 1))
+	if _, ok := registry.limiters["alert/a"]; len(registry.limiters) != 2 || !ok {
+		t.Errorf("expect the idle limiters evicted, current: %d", len(registry.limiters))
+	}
+}
@@ -0,0 +1,104 @@
+package exporter
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"gitlab.dmall.com/arch/sym-admin/pkg/controllers/eventexporter/sinks"
+	"gopkg.in/yaml.v2"
+)
+
+// LoadConfig parses the yaml config and validates it, the version is the hash of the content.
+func LoadConfig(data []byte) (*Config, string, error) {
+	cfg := &Config{}
+	if err := yaml.UnmarshalStrict(data, cfg); err != nil {
+		return nil, "", fmt.Errorf("cannot parse config: %v", err)
+	}
+
+	sum := sha256.Sum256(data)
+	return cfg, hex.EncodeToString(sum[:])[:12], nil
+}
+
+// Validate compiles the rules and the receivers of the rules are declared,
+// the config is validated before activated so that a bad config never replaces the running one.
+func (c *Config) Validate() error {
+	names := make(map[string]bool, len(c.Receivers))
+	for i := range c.Receivers {
+		r := &c.Receivers[i]
+		if err := r.Validate(); err != nil {
+			return fmt.Errorf("receivers[%d]: %v", i, err)
+		}
+		if names[r.Name] {
+			return fmt.Errorf("receivers[%d]: duplicate name: %s", i, r.Name)
+		}
+		names[r.Name] = true
+	}
+
+	if c.DeadLetter != nil && c.DeadLetter.Path == "" {
+		return fmt.Errorf("deadLetter: path is empty")
+	}
+	if c.Aggregation != nil && c.Aggregation.Window < 0 {
+		return fmt.Errorf("aggregation: window must not be negative")
+	}
+	if c.RateLimit != nil {
+		if err := c.RateLimit.Receiver.validate(); err != nil {
+			return fmt.Errorf("rateLimit.receiver: %v", err)
+		}
+		if err := c.RateLimit.App.validate(); err != nil {
+			return fmt.Errorf("rateLimit.app: %v", err)
+		}
+	}
+
+	return c.Route.validate("route", names)
+}
+
+func (c *Config) aggregationWindow() time.Duration {
+	if c.Aggregation == nil {
+		return 0
+	}
+	return c.Aggregation.Window
+}
+
+func (r *Route) validate(path string, receivers map[string]bool) error {
+	for i := range r.Drop {
+		if err := r.Drop[i].validate(receivers); err != nil {
+			return fmt.Errorf("%s.drop[%d]: %v", path, i, err)
+		}
+	}
+	for i := range r.Match {
+		if err := r.Match[i].validate(receivers); err != nil {
+			return fmt.Errorf("%s.match[%d]: %v", path, i, err)
+		}
+	}
+	for i := range r.Routes {
+		if err := r.Routes[i].validate(fmt.Sprintf("%s.routes[%d]", path, i), receivers); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validate compiles the rule, the compiled rule is kept for matching the events.
+func (r *Rule) validate(receivers map[string]bool) error {
+	m, err := r.compile()
+	if err != nil {
+		return err
+	}
+	r.matcher = m
+
+	if r.Receiver != "" && !receivers[r.Receiver] {
+		return fmt.Errorf("receiver: %s is not declared", r.Receiver)
+	}
+	return nil
+}
+
+// receiverNames is used to log the receivers of a config.
+func receiverNames(receivers []sinks.ReceiverConfig) []string {
+	names := make([]string, 0, len(receivers))
+	for _, r := range receivers {
+		names = append(names, r.Name)
+	}
+	return names
+}
@@ -0,0 +1,160 @@
+package exporter
+
+import (
+	"testing"
+
+	"gitlab.dmall.com/arch/sym-admin/pkg/controllers/eventexporter/kube"
+	"gitlab.dmall.com/arch/sym-admin/pkg/controllers/eventexporter/sinks"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestLoadConfig(t *testing.T) {
+	cases := []struct {
+		name  string
+		data  string
+		isErr bool
+	}{
+		{
+			name: "valid",
+			data: `
+route:
+  match:
+    - namespace: ".*test.*"
+      receiver: "dump"
+receivers:
+  - name: "dump"
+    file:
+      path: "/tmp/events.log"
+`,
+		},
+		{
+			name: "aggregation and rate limits",
+			data: `
+receivers:
+  - name: "dump"
+    file:
+      path: "/tmp/events.log"
+aggregation:
+  window: 5m
+rateLimit:
+  receiver:
+    qps: 1
+    burst: 10
+`,
+		},
+		{
+			name: "invalid rate limit",
+			data: `
+rateLimit:
+  app:
+    qps: 1
+`,
+			isErr: true,
+		},
+		{
+			name: "invalid regex",
+			data: `
+route:
+  drop:
+    - namespace: "*test*"
+receivers: []
+`,
+			isErr: true,
+		},
+		{
+			name: "undeclared receiver",
+			data: `
+route:
+  routes:
+    - match:
+        - receiver: "alert"
+receivers:
+  - name: "dump"
+    file:
+      path: "/tmp/events.log"
+`,
+			isErr: true,
+		},
+		{
+			name: "unknown field",
+			data: `
+receivers:
+  - name: "alert"
+    alertmanager:
+      endpoint: "http://127.0.0.1:9093"
+`,
+			isErr: true,
+		},
+		{
+			name: "no sink",
+			data: `
+receivers:
+  - name: "alert"
+`,
+			isErr: true,
+		},
+	}
+
+	for _, c := range cases {
+		cfg, version, err := LoadConfig([]byte(c.data))
+		if err == nil {
+			err = cfg.Validate()
+		}
+		if c.isErr {
+			if err == nil {
+				t.Errorf("case: %s expect error", c.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("case: %s unexpected error: %v", c.name, err)
+		}
+		if version == "" {
+			t.Errorf("case: %s expect version", c.name)
+		}
+	}
+}
+
+func TestEngineReload(t *testing.T) {
+	newConfig := func() (*Config, *sinks.InMemoryConfig) {
+		mem := &sinks.InMemoryConfig{}
+		return &Config{
+			Route:     Route{Match: []Rule{{Receiver: "mem"}}},
+			Receivers: []sinks.ReceiverConfig{{Name: "mem", InMemory: mem}},
+		}, mem
+	}
+
+	cfg1, mem1 := newConfig()
+	e, err := NewEngine(cfg1, "v1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ev := &kube.EnhancedEvent{Event: corev1.Event{ObjectMeta: metav1.ObjectMeta{Name: "e1"}, Message: "m1"}}
+	for i := 0; i < 10; i++ {
+		e.ProcessEvent(ev)
+	}
+
+	bad := &Config{Route: Route{Match: []Rule{{Receiver: "unknown"}}}}
+	if err := e.Reload(bad, "v2"); err == nil {
+		t.Fatal("expect error of the invalid config")
+	}
+	if e.Version() != "v1" {
+		t.Errorf("expect version v1 kept, current: %s", e.Version())
+	}
+
+	cfg3, mem3 := newConfig()
+	if err := e.Reload(cfg3, "v3"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	e.ProcessEvent(ev)
+	e.Close()
+
+	if e.Version() != "v3" {
+		t.Errorf("expect version v3, current: %s", e.Version())
+	}
+	if len(mem1.Ref.Events) != 10 || len(mem3.Ref.Events) != 1 {
+		t.Errorf("expect all events delivered, current: %d %d", len(mem1.Ref.Events), len(mem3.Ref.Events))
+	}
+}
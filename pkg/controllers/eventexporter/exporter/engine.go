@@ -1,8 +1,12 @@
 package exporter
 
 import (
+	"fmt"
 	"reflect"
+	"sync"
+	"time"
 
+	"gitlab.dmall.com/arch/sym-admin/pkg/controllers/eventexporter/kube"
 	"gitlab.dmall.com/arch/sym-admin/pkg/controllers/eventexporter/sinks"
 	"k8s.io/klog"
 )
@@ -12,28 +16,166 @@ type Config struct {
 	Namespace string                 `json:"namespace,omitempty" yaml:"namespace"`
 	Route     Route                  `json:"route,omitempty" yaml:"route"`
 	Receivers []sinks.ReceiverConfig `json:"receivers,omitempty" yaml:"receivers"`
+
+	Aggregation *AggregationConfig `json:"aggregation,omitempty" yaml:"aggregation"`
+	RateLimit   *RateLimitsConfig  `json:"rateLimit,omitempty" yaml:"rateLimit"`
+	// DeadLetter is the file of the events failed after the retries or dropped by a full queue.
+	DeadLetter *sinks.FileConfig `json:"deadLetter,omitempty" yaml:"deadLetter"`
 }
 
-// Engine is responsible for initializing the receivers from sinks
+// Engine is responsible for initializing the receivers from sinks, the config
+// can be swapped at runtime by Reload.
 type Engine struct {
-	Route    Route
-	Registry ReceiverRegistry
+	mu       sync.RWMutex
+	route    Route
+	registry ReceiverRegistry
+	version  string
+	closing  sync.WaitGroup
+
+	aggregator *Aggregator
+	stopCh     chan struct{}
+	flushDone  chan struct{}
 }
 
-func NewEngine(config *Config) *Engine {
-	registry := &ChannelBasedReceiverRegistry{}
-	for _, v := range config.Receivers {
+// NewEngine validates the config and registers the receivers.
+func NewEngine(config *Config, version string) (*Engine, error) {
+	e := &Engine{
+		aggregator: NewAggregator(0),
+		stopCh:     make(chan struct{}),
+		flushDone:  make(chan struct{}),
+	}
+	if err := e.Reload(config, version); err != nil {
+		return nil, err
+	}
+
+	go e.flushLoop()
+	return e, nil
+}
+
+// flushLoop sends the summaries of the aggregated events at the end of their window.
+func (e *Engine) flushLoop() {
+	defer close(e.flushDone)
+
+	ticker := time.NewTicker(aggregationFlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			for _, ev := range e.aggregator.Flush(false) {
+				e.dispatch(ev)
+			}
+		case <-e.stopCh:
+			for _, ev := range e.aggregator.Flush(true) {
+				e.dispatch(ev)
+			}
+			return
+		}
+	}
+}
+
+func newRegistry(config *Config) (ReceiverRegistry, error) {
+	sinkList := make(map[string]sinks.Sink, len(config.Receivers))
+	for i := range config.Receivers {
+		v := &config.Receivers[i]
 		sink, err := v.GetSink()
 		if err != nil {
-			klog.Fatalf("Cannot initialize sink name: %s", v.Name)
+			for _, s := range sinkList {
+				s.Close()
+			}
+			return nil, fmt.Errorf("cannot initialize sink name: %s err: %v", v.Name, err)
 		}
+		sinkList[v.Name] = sink
+	}
 
+	var deadLetter *sinks.DeadLetter
+	if config.DeadLetter != nil {
+		deadLetter = sinks.NewDeadLetter(config.DeadLetter)
+	}
+
+	registry := NewChannelBasedReceiverRegistry(deadLetter)
+	for i := range config.Receivers {
+		v := &config.Receivers[i]
+		sink := sinkList[v.Name]
 		klog.Infof("name: %s type: %s Registering sink", v.Name, reflect.TypeOf(sink).String())
-		registry.Register(v.Name, sink)
+		registry.Register(v.Name, sink, v.Delivery)
+	}
+	return registry, nil
+}
+
+// Reload validates the config and swaps it into the engine, the previous receivers
+// are closed after the events already sent to them are delivered.
+func (e *Engine) Reload(config *Config, version string) error {
+	if err := config.Validate(); err != nil {
+		configReloadTotal.WithLabelValues("failure").Inc()
+		return fmt.Errorf("invalid config version: %s err: %v", version, err)
+	}
+
+	registry, err := newRegistry(config)
+	if err != nil {
+		configReloadTotal.WithLabelValues("failure").Inc()
+		return err
 	}
 
-	return &Engine{
-		Route:    config.Route,
-		Registry: registry,
+	e.mu.Lock()
+	old := e.registry
+	e.route = config.Route
+	e.registry = newRateLimitedRegistry(registry, config.RateLimit)
+	e.version = version
+	e.mu.Unlock()
+	e.aggregator.SetWindow(config.aggregationWindow())
+
+	if old != nil {
+		e.closing.Add(1)
+		go func() {
+			defer e.closing.Done()
+			old.Close()
+		}()
+	}
+
+	configReloadTotal.WithLabelValues("success").Inc()
+	configVersion.Reset()
+	configVersion.WithLabelValues(version).Set(1)
+	klog.Infof("event exporter config version: %s activated, receivers: %v", version, receiverNames(config.Receivers))
+	return nil
+}
+
+// Version returns the version of the active config.
+func (e *Engine) Version() string {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.version
+}
+
+// ProcessEvent aggregates the event and routes it with the active config.
+func (e *Engine) ProcessEvent(ev *kube.EnhancedEvent) {
+	if !e.aggregator.Add(ev) {
+		eventsSuppressedTotal.WithLabelValues("aggregation", "").Inc()
+		return
+	}
+	e.dispatch(ev)
+}
+
+func (e *Engine) dispatch(ev *kube.EnhancedEvent) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	if e.registry == nil {
+		klog.Warningf("event exporter is closed, drop event: %s", ev.Message)
+		return
+	}
+	e.route.ProcessEvent(ev, e.registry)
+}
+
+// Close sends the pending summaries, closes the receivers of the active config
+// and waits for the previous ones.
+func (e *Engine) Close() {
+	close(e.stopCh)
+	<-e.flushDone
+
+	e.mu.Lock()
+	if e.registry != nil {
+		e.registry.Close()
+		e.registry = nil
 	}
+	e.mu.Unlock()
+	e.closing.Wait()
 }
@@ -1,12 +1,17 @@
 package exporter
 
 import (
+	"fmt"
 	"regexp"
+	"strings"
+	"time"
 
 	"gitlab.dmall.com/arch/sym-admin/pkg/controllers/eventexporter/kube"
+	"k8s.io/apimachinery/pkg/labels"
 )
 
-// Rule is for matching an event
+// Rule is for matching an event. The string fields, labels and annotations are regular
+// expressions, a pattern prefixed with "!" matches the values the rest does not match.
 type Rule struct {
 	Labels      map[string]string
 	Annotations map[string]string
@@ -20,6 +25,38 @@ type Rule struct {
 	Component   string
 	Host        string
 	Receiver    string
+
+	// LabelSelector selects the labels of the involved object, e.g. "app in (bbcc,aabb),sym-group!=canary".
+	LabelSelector string `yaml:"labelSelector"`
+	// MinAge and MaxAge bound the time since the event first happened.
+	MinAge time.Duration `yaml:"minAge"`
+	MaxAge time.Duration `yaml:"maxAge"`
+	// Rate matches the events happened at least Count times within the Window.
+	Rate *RateCondition `yaml:"rate"`
+	// Schedule matches the events happened in any of the windows.
+	Schedule []ScheduleWindow `yaml:"schedule"`
+
+	// matcher is compiled from the rule when the config is validated
+	matcher *ruleMatcher
+}
+
+// RateCondition is a threshold on the count of an event over time, the rate is averaged
+// over the time between the first and the last occurrence.
+type RateCondition struct {
+	Count  int32         `yaml:"count"`
+	Window time.Duration `yaml:"window"`
+}
+
+// ScheduleWindow is a daily time window such as the business hours, the window
+// crosses midnight when the end is before the start.
+type ScheduleWindow struct {
+	// Days are the weekdays of the window such as Mon, an empty list is every day.
+	Days []string `yaml:"days"`
+	// Start and End are the time of day in the format 15:04.
+	Start string `yaml:"start"`
+	End   string `yaml:"end"`
+	// Timezone is the IANA name of the location, default is the local time.
+	Timezone string `yaml:"timezone"`
 }
 
 // Route allows using rules to drop events or match events to specific receivers.
@@ -31,12 +68,195 @@ type Route struct {
 	Routes []Route
 }
 
-// matchString is a method to clean the code. Error handling is omitted here because these
-// rules are validated before use. According to regexp.MatchString, the only way it fails its
-// that the pattern does not compile.
-func matchString(pattern, s string) bool {
-	matched, _ := regexp.MatchString(pattern, s)
-	return matched
+// now is replaced in the tests.
+var now = time.Now
+
+type fieldMatcher struct {
+	re     *regexp.Regexp
+	negate bool
+}
+
+func compileField(pattern string) (*fieldMatcher, error) {
+	m := &fieldMatcher{}
+	if strings.HasPrefix(pattern, "!") {
+		m.negate = true
+		pattern = pattern[1:]
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	m.re = re
+	return m, nil
+}
+
+func (m *fieldMatcher) match(s string) bool {
+	return m.re.MatchString(s) != m.negate
+}
+
+type scheduleMatcher struct {
+	days       map[time.Weekday]bool
+	start, end int
+	loc        *time.Location
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day %q, must be 15:04", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+func compileSchedule(w *ScheduleWindow) (*scheduleMatcher, error) {
+	m := &scheduleMatcher{loc: time.Local}
+	if w.Timezone != "" {
+		loc, err := time.LoadLocation(w.Timezone)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timezone %q: %v", w.Timezone, err)
+		}
+		m.loc = loc
+	}
+
+	if len(w.Days) > 0 {
+		m.days = make(map[time.Weekday]bool, len(w.Days))
+		for _, d := range w.Days {
+			wd, ok := weekdays[strings.ToLower(d)]
+			if !ok {
+				return nil, fmt.Errorf("invalid day %q, must be one of Mon, Tue, Wed, Thu, Fri, Sat, Sun", d)
+			}
+			m.days[wd] = true
+		}
+	}
+
+	var err error
+	if m.start, err = parseClock(w.Start); err != nil {
+		return nil, err
+	}
+	if m.end, err = parseClock(w.End); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (m *scheduleMatcher) match(t time.Time) bool {
+	t = t.In(m.loc)
+	clock := t.Hour()*60 + t.Minute()
+	day := t.Weekday()
+	if m.end <= m.start && clock < m.end {
+		// the part of the window after midnight belongs to the day before
+		day = (day + 6) % 7
+	}
+	if m.days != nil && !m.days[day] {
+		return false
+	}
+
+	if m.start < m.end {
+		return clock >= m.start && clock < m.end
+	}
+	return clock >= m.start || clock < m.end
+}
+
+type ruleMatcher struct {
+	fields      []*fieldMatcher
+	labels      map[string]*fieldMatcher
+	annotations map[string]*fieldMatcher
+	selector    labels.Selector
+	schedule    []*scheduleMatcher
+}
+
+// ruleFields are the names of the string fields of a rule, in the order of patterns and eventFields.
+var ruleFields = []string{"message", "apiVersion", "kind", "namespace", "reason", "type", "component", "host"}
+
+func (r *Rule) patterns() []string {
+	return []string{r.Message, r.APIVersion, r.Kind, r.Namespace, r.Reason, r.Type, r.Component, r.Host}
+}
+
+func eventFields(ev *kube.EnhancedEvent) []string {
+	return []string{
+		ev.Message,
+		ev.InvolvedObject.APIVersion,
+		ev.InvolvedObject.Kind,
+		ev.Namespace,
+		ev.Reason,
+		ev.Type,
+		ev.Source.Component,
+		ev.Source.Host,
+	}
+}
+
+// compile precompiles the regexes, the selector and the schedule of the rule.
+func (r *Rule) compile() (*ruleMatcher, error) {
+	m := &ruleMatcher{}
+
+	for i, p := range r.patterns() {
+		if p == "" {
+			m.fields = append(m.fields, nil)
+			continue
+		}
+		f, err := compileField(p)
+		if err != nil {
+			return nil, fmt.Errorf("%s: invalid regex %q: %v", ruleFields[i], p, err)
+		}
+		m.fields = append(m.fields, f)
+	}
+
+	var err error
+	if m.labels, err = compileFields("labels", r.Labels); err != nil {
+		return nil, err
+	}
+	if m.annotations, err = compileFields("annotations", r.Annotations); err != nil {
+		return nil, err
+	}
+
+	if r.LabelSelector != "" {
+		if m.selector, err = labels.Parse(r.LabelSelector); err != nil {
+			return nil, fmt.Errorf("labelSelector: %v", err)
+		}
+	}
+
+	if r.MinAge < 0 || r.MaxAge < 0 {
+		return nil, fmt.Errorf("minAge and maxAge must not be negative")
+	}
+	if r.Rate != nil && (r.Rate.Count <= 0 || r.Rate.Window <= 0) {
+		return nil, fmt.Errorf("rate: count and window must be positive")
+	}
+
+	for i := range r.Schedule {
+		s, err := compileSchedule(&r.Schedule[i])
+		if err != nil {
+			return nil, fmt.Errorf("schedule[%d]: %v", i, err)
+		}
+		m.schedule = append(m.schedule, s)
+	}
+	return m, nil
+}
+
+func compileFields(name string, patterns map[string]string) (map[string]*fieldMatcher, error) {
+	if len(patterns) == 0 {
+		return nil, nil
+	}
+
+	result := make(map[string]*fieldMatcher, len(patterns))
+	for k, p := range patterns {
+		f, err := compileField(p)
+		if err != nil {
+			return nil, fmt.Errorf("%s.%s: invalid regex %q: %v", name, k, p, err)
+		}
+		result[k] = f
+	}
+	return result, nil
 }
 
 func (r *Route) ProcessEvent(ev *kube.EnhancedEvent, registry ReceiverRegistry) {
@@ -72,61 +292,95 @@ func (r *Route) ProcessEvent(ev *kube.EnhancedEvent, registry ReceiverRegistry)
 // whether the event is compatible with the rule. All fields are compared as regular expressions
 // so the user must keep that in mind while writing rules.
 func (r *Rule) MatchesEvent(ev *kube.EnhancedEvent) bool {
+	m := r.matcher
+	if m == nil {
+		// the rule is not loaded from a validated config, compile it for this event only
+		var err error
+		if m, err = r.compile(); err != nil {
+			return false
+		}
+	}
+
 	// These rules are just basic comparison rules, if one of them fails, it means the event does not match the rule
-	rules := [][2]string{
-		{r.Message, ev.Message},
-		{r.APIVersion, ev.InvolvedObject.APIVersion},
-		{r.Kind, ev.InvolvedObject.Kind},
-		{r.Namespace, ev.Namespace},
-		{r.Reason, ev.Reason},
-		{r.Type, ev.Type},
-		{r.Component, ev.Source.Component},
-		{r.Host, ev.Source.Host},
-	}
-
-	for _, v := range rules {
-		rule := v[0]
-		value := v[1]
-		if rule != "" {
-			matches := matchString(rule, value)
-			if !matches {
-				return false
-			}
+	values := eventFields(ev)
+	for i, f := range m.fields {
+		if f != nil && !f.match(values[i]) {
+			return false
 		}
 	}
 
-	// Labels are also mutually exclusive, they all need to be present
-	if r.Labels != nil && len(r.Labels) > 0 {
-		for k, v := range r.Labels {
-			if val, ok := ev.InvolvedObject.Labels[k]; !ok {
-				return false
-			} else {
-				matches := matchString(val, v)
-				if !matches {
-					return false
-				}
+	// Labels are also mutually exclusive, they all need to be present unless negated
+	if !matchFields(m.labels, ev.InvolvedObject.Labels) {
+		return false
+	}
+	// Annotations are also mutually exclusive, they all need to be present unless negated
+	if !matchFields(m.annotations, ev.InvolvedObject.Annotations) {
+		return false
+	}
+
+	if m.selector != nil && !m.selector.Matches(labels.Set(ev.InvolvedObject.Labels)) {
+		return false
+	}
+
+	if r.MinAge > 0 || r.MaxAge > 0 {
+		first := ev.FirstTimestamp.Time
+		if first.IsZero() {
+			first = ev.GetTimestamp()
+		}
+		age := now().Sub(first)
+		if age < r.MinAge || (r.MaxAge > 0 && age > r.MaxAge) {
+			return false
+		}
+	}
+
+	if r.Rate != nil && !r.Rate.matches(ev) {
+		return false
+	}
+
+	if len(m.schedule) > 0 {
+		ts := ev.GetTimestamp()
+		in := false
+		for _, s := range m.schedule {
+			if s.match(ts) {
+				in = true
+				break
 			}
 		}
+		if !in {
+			return false
+		}
 	}
 
-	// Annotations are also mutually exclusive, they all need to be present
-	if r.Annotations != nil && len(r.Annotations) > 0 {
-		for k, v := range r.Annotations {
-			if val, ok := ev.InvolvedObject.Annotations[k]; !ok {
+	// If minCount is not given via a config, it's already 0 and the count is already 1 and this passes.
+	return ev.Count >= r.MinCount
+}
+
+func matchFields(matchers map[string]*fieldMatcher, values map[string]string) bool {
+	for k, f := range matchers {
+		val, ok := values[k]
+		if !ok {
+			// a missing key only satisfies a negated pattern
+			if !f.negate {
 				return false
-			} else {
-				matches := matchString(v, val)
-				if !matches {
-					return false
-				}
 			}
+			continue
+		}
+		if !f.match(val) {
+			return false
 		}
 	}
+	return true
+}
 
-	// If minCount is not given via a config, it's already 0 and the count is already 1 and this passes.
-	if ev.Count >= r.MinCount {
-		return true
-	} else {
-		return false
+func (c *RateCondition) matches(ev *kube.EnhancedEvent) bool {
+	count := float64(ev.Count)
+	if count == 0 {
+		count = 1
+	}
+
+	span := ev.LastTimestamp.Sub(ev.FirstTimestamp.Time)
+	if !ev.FirstTimestamp.IsZero() && span > c.Window {
+		count = count * float64(c.Window) / float64(span)
 	}
+	return count >= float64(c.Count)
 }
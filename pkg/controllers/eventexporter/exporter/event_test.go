@@ -0,0 +1,126 @@
+package exporter
+
+import (
+	"testing"
+	"time"
+
+	"gitlab.dmall.com/arch/sym-admin/pkg/controllers/eventexporter/kube"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestRuleMatchesEvent(t *testing.T) {
+	// 2020-07-01 is a Wednesday
+	base := time.Date(2020, 7, 1, 10, 0, 0, 0, time.UTC)
+	now = func() time.Time { return base }
+	defer func() { now = time.Now }()
+
+	newEvent := func(namespace string, count int32, first, last time.Time) *kube.EnhancedEvent {
+		ev := &kube.EnhancedEvent{
+			Event: corev1.Event{
+				ObjectMeta:     metav1.ObjectMeta{Namespace: namespace},
+				Type:           corev1.EventTypeWarning,
+				Reason:         "BackOff",
+				Count:          count,
+				FirstTimestamp: metav1.NewTime(first),
+				LastTimestamp:  metav1.NewTime(last),
+			},
+		}
+		ev.InvolvedObject.Labels = map[string]string{"app": "bbcc", "sym-group": "blue"}
+		return ev
+	}
+	ev := newEvent("default", 1, base.Add(-time.Minute), base)
+
+	utc := "UTC"
+	cases := []struct {
+		name   string
+		rule   Rule
+		ev     *kube.EnhancedEvent
+		expect bool
+	}{
+		{"regex", Rule{Namespace: "^def"}, ev, true},
+		{"negated", Rule{Namespace: "!.*test.*"}, ev, true},
+		{"negated not matched", Rule{Namespace: "!^default$"}, ev, false},
+		{"label regex", Rule{Labels: map[string]string{"app": "^bb"}}, ev, true},
+		{"label missing", Rule{Labels: map[string]string{"release": ".*"}}, ev, false},
+		{"negated label missing", Rule{Labels: map[string]string{"release": "!canary"}}, ev, true},
+		{"selector", Rule{LabelSelector: "app in (bbcc,aabb),sym-group!=canary"}, ev, true},
+		{"selector not matched", Rule{LabelSelector: "sym-group=green"}, ev, false},
+		{"selector exists", Rule{LabelSelector: "!release"}, ev, true},
+		{"max age", Rule{MaxAge: 30 * time.Second}, ev, false},
+		{"min age", Rule{MinAge: 30 * time.Second}, ev, true},
+		{"rate", Rule{Rate: &RateCondition{Count: 5, Window: 10 * time.Minute}},
+			newEvent("default", 12, base.Add(-20*time.Minute), base), true},
+		{"rate too low", Rule{Rate: &RateCondition{Count: 5, Window: 10 * time.Minute}},
+			newEvent("default", 12, base.Add(-time.Hour), base), false},
+		{"schedule", Rule{Schedule: []ScheduleWindow{{Days: []string{"Mon", "Wed"}, Start: "09:00", End: "18:00", Timezone: utc}}}, ev, true},
+		{"schedule other day", Rule{Schedule: []ScheduleWindow{{Days: []string{"Sat", "Sun"}, Start: "09:00", End: "18:00", Timezone: utc}}}, ev, false},
+		{"overnight window", Rule{Schedule: []ScheduleWindow{{Days: []string{"Tue"}, Start: "22:00", End: "11:00", Timezone: utc}}}, ev, true},
+		{"any window", Rule{Schedule: []ScheduleWindow{
+			{Start: "00:00", End: "06:00", Timezone: utc},
+			{Start: "09:30", End: "10:30", Timezone: utc},
+		}}, ev, true},
+	}
+
+	for _, c := range cases {
+		// the rules are compiled by the validation as loaded from a config
+		if err := c.rule.validate(nil); err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		if c.rule.MatchesEvent(c.ev) != c.expect {
+			t.Errorf("%s: expect %v", c.name, c.expect)
+		}
+	}
+
+	invalid := []Rule{
+		{Reason: "!*"},
+		{LabelSelector: "app in bbcc"},
+		{Rate: &RateCondition{Count: 1}},
+		{Schedule: []ScheduleWindow{{Start: "9am", End: "18:00"}}},
+		{Schedule: []ScheduleWindow{{Days: []string{"Someday"}, Start: "09:00", End: "18:00"}}},
+		{Schedule: []ScheduleWindow{{Start: "09:00", End: "18:00", Timezone: "Mars/Olympus"}}},
+	}
+	for i := range invalid {
+		if err := invalid[i].validate(nil); err == nil {
+			t.Errorf("invalid[%d]: expect error", i)
+		}
+	}
+}
+
+func TestLoadRuleExtensions(t *testing.T) {
+	cfg, _, err := LoadConfig([]byte(`
+route:
+  drop:
+    - namespace: "!^(default|sym-admin)$"
+  match:
+    - labelSelector: "app in (bbcc)"
+      maxAge: 10m
+      rate:
+        count: 5
+        window: 10m
+      schedule:
+        - days: ["Mon", "Tue", "Wed", "Thu", "Fri"]
+          start: "09:00"
+          end: "18:00"
+          timezone: "Asia/Shanghai"
+      receiver: "dump"
+receivers:
+  - name: "dump"
+    file:
+      path: "/tmp/events.log"
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	rule := cfg.Route.Match[0]
+	if rule.matcher == nil || rule.matcher.selector == nil || len(rule.matcher.schedule) != 1 {
+		t.Errorf("expect the rule compiled by the validation")
+	}
+	if rule.MaxAge != 10*time.Minute || rule.Rate.Window != 10*time.Minute {
+		t.Errorf("unexpected durations: %v %v", rule.MaxAge, rule.Rate.Window)
+	}
+}
@@ -2,8 +2,8 @@ package exporter
 
 import (
 	"context"
-
 	"sync"
+	"time"
 
 	"gitlab.dmall.com/arch/sym-admin/pkg/controllers/eventexporter/kube"
 	"gitlab.dmall.com/arch/sym-admin/pkg/controllers/eventexporter/sinks"
@@ -13,72 +13,152 @@ import (
 // ReceiverRegistry registers a receiver with the appropriate sink
 type ReceiverRegistry interface {
 	SendEvent(string, *kube.EnhancedEvent)
-	Register(string, sinks.Sink)
+	Register(string, sinks.Sink, *sinks.DeliveryConfig)
 	Close()
 }
 
+type receiver struct {
+	name     string
+	sink     sinks.Sink
+	delivery *sinks.DeliveryConfig
+	queue    chan *kube.EnhancedEvent
+}
+
+// ChannelBasedReceiverRegistry queues the events of each receiver in a bounded channel,
+// the events failed after the retries or dropped by a full queue go to the dead letter.
 type ChannelBasedReceiverRegistry struct {
-	ch     map[string]chan kube.EnhancedEvent
-	exitCh map[string]chan interface{}
-	wg     *sync.WaitGroup
+	receivers  map[string]*receiver
+	deadLetter *sinks.DeadLetter
+	wg         sync.WaitGroup
+}
+
+// NewChannelBasedReceiverRegistry ...
+func NewChannelBasedReceiverRegistry(deadLetter *sinks.DeadLetter) *ChannelBasedReceiverRegistry {
+	return &ChannelBasedReceiverRegistry{
+		receivers:  make(map[string]*receiver),
+		deadLetter: deadLetter,
+	}
 }
 
 func (r *ChannelBasedReceiverRegistry) SendEvent(name string, event *kube.EnhancedEvent) {
-	ch := r.ch[name]
-	if ch == nil {
+	rcv := r.receivers[name]
+	if rcv == nil {
 		klog.Errorf("There is no channel name: %s ", name)
 		return
 	}
 
-	go func() {
-		ch <- *event
-	}()
+	select {
+	case rcv.queue <- event:
+		sinkEventsTotal.WithLabelValues(name, "queued").Inc()
+		sinkQueueLength.WithLabelValues(name).Set(float64(len(rcv.queue)))
+	default:
+		sinkEventsTotal.WithLabelValues(name, "dropped").Inc()
+		klog.Errorf("the queue of sink: %s is full, drop event: %s", name, event.Message)
+		r.writeDeadLetter(name, "queue full", event)
+	}
 }
 
-func (r *ChannelBasedReceiverRegistry) Register(name string, receiver sinks.Sink) {
-	if r.ch == nil {
-		r.ch = make(map[string]chan kube.EnhancedEvent)
-		r.exitCh = make(map[string]chan interface{})
+func (r *ChannelBasedReceiverRegistry) Register(name string, sink sinks.Sink, delivery *sinks.DeliveryConfig) {
+	if r.receivers == nil {
+		r.receivers = make(map[string]*receiver)
 	}
 
-	ch := make(chan kube.EnhancedEvent)
-	exitCh := make(chan interface{})
+	delivery = delivery.WithDefaults()
+	rcv := &receiver{
+		name:     name,
+		sink:     sink,
+		delivery: delivery,
+		queue:    make(chan *kube.EnhancedEvent, delivery.QueueSize),
+	}
+	r.receivers[name] = rcv
 
-	r.ch[name] = ch
-	r.exitCh[name] = exitCh
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		r.run(rcv)
+		sink.Close()
+		klog.Infof("Closed the sink: %s ", name)
+	}()
+}
 
-	if r.wg == nil {
-		r.wg = &sync.WaitGroup{}
+// run sends the events of the queue until it is closed, the events are batched
+// when the sink supports it.
+func (r *ChannelBasedReceiverRegistry) run(rcv *receiver) {
+	batchSink, isBatch := rcv.sink.(sinks.BatchSink)
+	if !isBatch || rcv.delivery.BatchSize <= 1 {
+		for ev := range rcv.queue {
+			sinkQueueLength.WithLabelValues(rcv.name).Set(float64(len(rcv.queue)))
+			r.deliver(rcv, []*kube.EnhancedEvent{ev}, func(ctx context.Context) error {
+				return rcv.sink.Send(ctx, ev)
+			})
+		}
+		return
 	}
-	r.wg.Add(1)
 
-	go func() {
-	Loop:
-		for {
+	for ev := range rcv.queue {
+		batch := []*kube.EnhancedEvent{ev}
+		timer := time.NewTimer(rcv.delivery.BatchWait)
+	Collect:
+		for len(batch) < rcv.delivery.BatchSize {
 			select {
-			case ev := <-ch:
-				klog.Infof("sending event to sink: %s event: %s", name, ev.Message)
-				err := receiver.Send(context.Background(), &ev)
-				if err != nil {
-					klog.Errorf("Cannot send event sink: %s event: %s err: %+v", name, ev.Message, err)
+			case next, ok := <-rcv.queue:
+				if !ok {
+					break Collect
 				}
-			case <-exitCh:
-				klog.Infof("Closing the sink: %s ", name)
-				break Loop
+				batch = append(batch, next)
+			case <-timer.C:
+				break Collect
 			}
 		}
-		receiver.Close()
-		klog.Infof("Closed the sink: %s ", name)
-		r.wg.Done()
-	}()
+		timer.Stop()
+
+		sinkQueueLength.WithLabelValues(rcv.name).Set(float64(len(rcv.queue)))
+		r.deliver(rcv, batch, func(ctx context.Context) error {
+			return batchSink.SendBatch(ctx, batch)
+		})
+	}
+}
+
+func (r *ChannelBasedReceiverRegistry) deliver(rcv *receiver, batch []*kube.EnhancedEvent, send func(ctx context.Context) error) {
+	ctx := context.Background()
+	err := rcv.delivery.Retry.Retry(ctx, func() error {
+		err := send(ctx)
+		if err != nil {
+			klog.Warningf("Cannot send %d events to sink: %s err: %v", len(batch), rcv.name, err)
+		}
+		return err
+	})
+	if err == nil {
+		sinkEventsTotal.WithLabelValues(rcv.name, "sent").Add(float64(len(batch)))
+		return
+	}
+
+	klog.Errorf("Cannot send %d events to sink: %s err: %+v", len(batch), rcv.name, err)
+	sinkEventsTotal.WithLabelValues(rcv.name, "failed").Add(float64(len(batch)))
+	for _, ev := range batch {
+		r.writeDeadLetter(rcv.name, err.Error(), ev)
+	}
 }
 
-// Close signals closing to all sinks and waits for them to complete.
-// The wait could block indefinitely depending on the sink implementations.
+func (r *ChannelBasedReceiverRegistry) writeDeadLetter(name, reason string, ev *kube.EnhancedEvent) {
+	if r.deadLetter == nil {
+		return
+	}
+	if err := r.deadLetter.Write(name, reason, ev); err != nil {
+		klog.Errorf("Cannot write dead letter of sink: %s event: %s err: %v", name, ev.Message, err)
+	}
+}
+
+// Close closes the queues and waits for the events queued to be delivered, then closes
+// all sinks. No event must be sent after Close is called. The wait could block up to
+// the max elapsed time of the retries.
 func (r *ChannelBasedReceiverRegistry) Close() {
-	// Send exit command and wait for exit of all sinks
-	for _, ec := range r.exitCh {
-		ec <- 1
+	for name, rcv := range r.receivers {
+		klog.Infof("Closing the sink: %s ", name)
+		close(rcv.queue)
 	}
 	r.wg.Wait()
+	if r.deadLetter != nil {
+		r.deadLetter.Close()
+	}
 }
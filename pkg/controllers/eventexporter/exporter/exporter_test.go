@@ -0,0 +1,143 @@
+package exporter
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"gitlab.dmall.com/arch/sym-admin/pkg/controllers/eventexporter/kube"
+	"gitlab.dmall.com/arch/sym-admin/pkg/controllers/eventexporter/sinks"
+)
+
+type fakeSink struct {
+	mu      sync.Mutex
+	fails   int
+	sent    int
+	batches []int
+	block   chan struct{}
+}
+
+func (s *fakeSink) send(n int) error {
+	if s.block != nil {
+		<-s.block
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.fails != 0 {
+		s.fails--
+		return errors.New("unavailable")
+	}
+	s.sent += n
+	s.batches = append(s.batches, n)
+	return nil
+}
+
+func (s *fakeSink) Send(ctx context.Context, ev *kube.EnhancedEvent) error {
+	return s.send(1)
+}
+
+func (s *fakeSink) Close() {}
+
+type fakeBatchSink struct {
+	fakeSink
+}
+
+func (s *fakeBatchSink) SendBatch(ctx context.Context, evs []*kube.EnhancedEvent) error {
+	return s.send(len(evs))
+}
+
+var fastRetry = &sinks.RetryConfig{Interval: time.Millisecond, MaxRetries: 2}
+
+func tempFile(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "deadletter")
+	if err != nil {
+		t.Fatalf("create temp dir err: %v", err)
+	}
+	return filepath.Join(dir, "deadletter.log"), func() { os.RemoveAll(dir) }
+}
+
+func countLines(t *testing.T, path string) int {
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("open dead letter err: %v", err)
+	}
+	defer f.Close()
+
+	var n int
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		n++
+	}
+	return n
+}
+
+func TestChannelBasedReceiverRegistry(t *testing.T) {
+	path, cleanup := tempFile(t)
+	defer cleanup()
+	registry := NewChannelBasedReceiverRegistry(sinks.NewDeadLetter(&sinks.FileConfig{Path: path}))
+
+	flaky := &fakeSink{fails: 1}
+	down := &fakeSink{fails: -1}
+	batch := &fakeBatchSink{}
+	registry.Register("flaky", flaky, &sinks.DeliveryConfig{Retry: fastRetry})
+	registry.Register("down", down, &sinks.DeliveryConfig{Retry: fastRetry})
+	registry.Register("batch", batch, &sinks.DeliveryConfig{BatchSize: 5, BatchWait: time.Minute})
+
+	ev := &kube.EnhancedEvent{}
+	for i := 0; i < 3; i++ {
+		registry.SendEvent("flaky", ev)
+		registry.SendEvent("down", ev)
+	}
+	for i := 0; i < 7; i++ {
+		registry.SendEvent("batch", ev)
+	}
+	registry.Close()
+
+	if flaky.sent != 3 {
+		t.Errorf("expect 3 events sent after retry, current: %d", flaky.sent)
+	}
+	if down.sent != 0 {
+		t.Errorf("expect no events sent, current: %d", down.sent)
+	}
+	if batch.sent != 7 || len(batch.batches) != 2 || batch.batches[0] != 5 {
+		t.Errorf("expect batches of 5 and 2, current: %v", batch.batches)
+	}
+	if n := countLines(t, path); n != 3 {
+		t.Errorf("expect 3 dead letters, current: %d", n)
+	}
+}
+
+func TestChannelBasedReceiverRegistryQueueFull(t *testing.T) {
+	path, cleanup := tempFile(t)
+	defer cleanup()
+	registry := NewChannelBasedReceiverRegistry(sinks.NewDeadLetter(&sinks.FileConfig{Path: path}))
+
+	slow := &fakeSink{block: make(chan struct{})}
+	registry.Register("slow", slow, &sinks.DeliveryConfig{QueueSize: 2})
+
+	ev := &kube.EnhancedEvent{}
+	registry.SendEvent("slow", ev)
+	// wait for the first event taken by the sender
+	for i := 0; i < 100 && len(registry.receivers["slow"].queue) != 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	for i := 0; i < 4; i++ {
+		registry.SendEvent("slow", ev)
+	}
+	close(slow.block)
+	registry.Close()
+
+	if slow.sent != 3 {
+		t.Errorf("expect 3 events sent, current: %d", slow.sent)
+	}
+	if n := countLines(t, path); n != 2 {
+		t.Errorf("expect 2 dead letters of the full queue, current: %d", n)
+	}
+}
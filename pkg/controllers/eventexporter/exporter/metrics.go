@@ -0,0 +1,46 @@
+package exporter
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	configVersion = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "sym",
+		Subsystem: "event_exporter",
+		Name:      "config_version",
+		Help:      "The version of the active event exporter config, the value is always 1.",
+	}, []string{"version"})
+
+	configReloadTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "sym",
+		Subsystem: "event_exporter",
+		Name:      "config_reload_total",
+		Help:      "The number of the event exporter config reloads by result.",
+	}, []string{"result"})
+
+	eventsSuppressedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "sym",
+		Subsystem: "event_exporter",
+		Name:      "events_suppressed_total",
+		Help:      "The number of the events suppressed by the aggregation or the rate limits.",
+	}, []string{"stage", "receiver"})
+
+	sinkEventsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "sym",
+		Subsystem: "event_exporter",
+		Name:      "sink_events_total",
+		Help:      "The number of the events of each sink by result: queued, sent, failed or dropped.",
+	}, []string{"receiver", "result"})
+
+	sinkQueueLength = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "sym",
+		Subsystem: "event_exporter",
+		Name:      "sink_queue_length",
+		Help:      "The number of the events waiting in the queue of each sink.",
+	}, []string{"receiver"})
+)
+
+func init() {
+	prometheus.MustRegister(configVersion, configReloadTotal, eventsSuppressedTotal, sinkEventsTotal, sinkQueueLength)
+}
@@ -11,6 +11,33 @@ type EnhancedEvent struct {
 	corev1.Event   `json:",inline"`
 	ClusterName    string                  `json:"clusterName"`
 	InvolvedObject EnhancedObjectReference `json:"involvedObject"`
+	// Aggregated is the number of the events summarized in this one by the aggregation.
+	Aggregated int32 `json:"aggregated,omitempty"`
+	// Context is the rollout and the owner of the involved object.
+	Context *EventContext `json:"context,omitempty"`
+}
+
+// EventContext is resolved from the owner chain of the involved object, such as
+// Pod -> ReplicaSet -> Deployment -> AdvDeployment -> AppSet.
+type EventContext struct {
+	// Owners is the owner chain from the controller of the involved object up to the AppSet.
+	Owners        []OwnerReference `json:"owners,omitempty"`
+	AppSet        string           `json:"appSet,omitempty"`
+	AdvDeployment string           `json:"advDeployment,omitempty"`
+	PodSet        string           `json:"podSet,omitempty"`
+	Group         string           `json:"group,omitempty"`
+	Zone          string           `json:"zone,omitempty"`
+	Ldc           string           `json:"ldc,omitempty"`
+	Image         string           `json:"image,omitempty"`
+	Version       string           `json:"version,omitempty"`
+	NodeName      string           `json:"nodeName,omitempty"`
+	Owner         string           `json:"owner,omitempty"`
+	OwnerContact  string           `json:"ownerContact,omitempty"`
+}
+
+type OwnerReference struct {
+	Kind string `json:"kind"`
+	Name string `json:"name"`
 }
 
 type EnhancedObjectReference struct {
@@ -29,3 +56,17 @@ func (e *EnhancedEvent) ToJSON() []byte {
 func (e *EnhancedEvent) GetTimestampMs() int64 {
 	return e.FirstTimestamp.UnixNano() / (int64(time.Millisecond) / int64(time.Nanosecond))
 }
+
+// GetTimestamp returns the time the event last happened, the series events only have the event time.
+func (e *EnhancedEvent) GetTimestamp() time.Time {
+	switch {
+	case !e.LastTimestamp.IsZero():
+		return e.LastTimestamp.Time
+	case !e.FirstTimestamp.IsZero():
+		return e.FirstTimestamp.Time
+	case !e.EventTime.IsZero():
+		return e.EventTime.Time
+	default:
+		return time.Now()
+	}
+}
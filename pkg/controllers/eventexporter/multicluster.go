@@ -0,0 +1,236 @@
+package eventexporter
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"gitlab.dmall.com/arch/sym-admin/pkg/controllers/eventexporter/exporter"
+	k8smanager "gitlab.dmall.com/arch/sym-admin/pkg/k8s/manager"
+	pkgmanager "gitlab.dmall.com/arch/sym-admin/pkg/manager"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/klog"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+)
+
+const (
+	multiClusterControllerName = "multicluster-events-controller"
+)
+
+// eventRequest is the key of an event in a cluster.
+type eventRequest struct {
+	Cluster string
+	types.NamespacedName
+}
+
+// clusterEvents is a cluster watched by the multi cluster exporter.
+type clusterEvents struct {
+	cluster *k8smanager.Cluster
+	objects *objectCache
+}
+
+// multiClusterExporter watches the events of all the clusters of the ClusterManager from
+// the master controller and routes them through one engine, the cluster name of the
+// events is the name of the cluster registration.
+type multiClusterExporter struct {
+	Name        string
+	Log         logr.Logger
+	ClustersMgr *k8smanager.ClusterManager
+	// Master reads the AppSets of the events
+	Master      client.Reader
+	WorkQueue   workqueue.RateLimitingInterface
+	Threadiness int
+	engine      *exporter.Engine
+
+	mu       sync.RWMutex
+	clusters map[string]*clusterEvents
+}
+
+// AddMultiCluster adds the exporter of the events of all the clusters to the manager.
+func AddMultiCluster(mgr manager.Manager, cMgr *pkgmanager.DksManager) error {
+	if cMgr.ClustersMgr == nil {
+		return fmt.Errorf("the multi cluster event exporter requires the clusters manager")
+	}
+
+	engine, err := newEngine(mgr, cMgr, kubernetes.NewForConfigOrDie(mgr.GetConfig()), &clusterManagerClients{clustersMgr: cMgr.ClustersMgr})
+	if err != nil {
+		return err
+	}
+
+	r := &multiClusterExporter{
+		Name:        multiClusterControllerName,
+		Log:         ctrl.Log.WithName("controllers").WithName(multiClusterControllerName),
+		ClustersMgr: cMgr.ClustersMgr,
+		Master:      mgr.GetClient(),
+		WorkQueue:   workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), multiClusterControllerName),
+		Threadiness: cMgr.Opt.Threadiness,
+		engine:      engine,
+		clusters:    make(map[string]*clusterEvents),
+	}
+	return mgr.Add(r)
+}
+
+// registerCluster watches the events of the cluster, a reconnected cluster replaces the previous one.
+func (r *multiClusterExporter) registerCluster(cluster *k8smanager.Cluster) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if c, ok := r.clusters[cluster.Name]; ok && c.cluster == cluster {
+		return nil
+	}
+
+	dynClient, err := dynamic.NewForConfig(cluster.RestConfig)
+	if err != nil {
+		return fmt.Errorf("cluster name:%s can't create dynamic client, err: %v", cluster.Name, err)
+	}
+
+	eventInformer, err := cluster.Cache.GetInformer(context.TODO(), &corev1.Event{})
+	if err != nil {
+		return fmt.Errorf("cluster name:%s can't add event InformerEntry, err: %v", cluster.Name, err)
+	}
+
+	clusterName := cluster.Name
+	enqueue := func(obj interface{}) {
+		if !onEvent(obj) {
+			return
+		}
+		e := obj.(*corev1.Event)
+		r.WorkQueue.Add(eventRequest{
+			Cluster:        clusterName,
+			NamespacedName: types.NamespacedName{Namespace: e.Namespace, Name: e.Name},
+		})
+	}
+	eventInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: enqueue,
+		UpdateFunc: func(_, newObj interface{}) {
+			enqueue(newObj)
+		},
+	})
+
+	r.clusters[cluster.Name] = &clusterEvents{
+		cluster: cluster,
+		objects: newObjectCache(cluster.KubeCli, dynClient, newContextResolver(cluster.Mgr.GetAPIReader(), r.Master)),
+	}
+	klog.Infof("cluster name:%s AddEventHandler event key to queue", cluster.Name)
+	return nil
+}
+
+func (r *multiClusterExporter) getCluster(name string) *clusterEvents {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.clusters[name]
+}
+
+// syncClusters registers the clusters of the ClusterManager added or reconnected and
+// unregisters the clusters removed, the events queued of the removed clusters are skipped.
+func (r *multiClusterExporter) syncClusters() {
+	for _, cluster := range r.ClustersMgr.GetAll() {
+		if err := r.registerCluster(cluster); err != nil {
+			klog.Errorf("%v", err)
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for name := range r.clusters {
+		// the offline clusters are kept until they are removed
+		if !r.ClustersMgr.Exist(name) {
+			delete(r.clusters, name)
+			klog.Infof("cluster name:%s removed, stop exporting its events", name)
+		}
+	}
+}
+
+// watchClusters syncs the clusters on each change of the ClusterManager.
+func (r *multiClusterExporter) watchClusters(changed <-chan struct{}) {
+	for range changed {
+		r.syncClusters()
+	}
+}
+
+func (r *multiClusterExporter) Start(stopCh <-chan struct{}) error {
+	defer runtime.HandleCrash()
+	defer r.WorkQueue.ShutDown()
+
+	// subscribe before listing so that no cluster changed in between is missed
+	changed := r.ClustersMgr.SubscribeClusterChange()
+	r.syncClusters()
+	go r.watchClusters(changed)
+
+	threadiness := r.Threadiness
+	if threadiness < 1 {
+		threadiness = 1
+	}
+	for i := 0; i < threadiness; i++ {
+		go wait.Until(r.runWorker, time.Second, stopCh)
+	}
+
+	<-stopCh
+	klog.Infof("Shutting down workers, name: %s", r.Name)
+	return nil
+}
+
+func (r *multiClusterExporter) runWorker() {
+	for r.processNextWorkItem() {
+	}
+}
+
+func (r *multiClusterExporter) processNextWorkItem() bool {
+	obj, shutdown := r.WorkQueue.Get()
+	if shutdown {
+		return false
+	}
+	defer r.WorkQueue.Done(obj)
+
+	req, ok := obj.(eventRequest)
+	if !ok {
+		r.WorkQueue.Forget(obj)
+		return true
+	}
+
+	if err := r.reconcile(context.TODO(), req); err != nil {
+		r.WorkQueue.AddRateLimited(req)
+		klog.V(3).Infof("cluster: %s event: %s reconcile failed. err: %v", req.Cluster, req.NamespacedName, err)
+		return true
+	}
+
+	r.WorkQueue.Forget(req)
+	return true
+}
+
+func (r *multiClusterExporter) reconcile(ctx context.Context, req eventRequest) error {
+	logger := r.Log.WithValues("cluster", req.Cluster, "key", req.NamespacedName.String())
+
+	c := r.getCluster(req.Cluster)
+	if c == nil {
+		return nil
+	}
+
+	e := &corev1.Event{}
+	if err := c.cluster.Client.Get(ctx, req.NamespacedName, e); err != nil {
+		if apierrors.IsNotFound(err) {
+			klog.V(3).Infof("cluster: %s not find event with name: %s, skip", req.Cluster, req.NamespacedName)
+			return nil
+		}
+		logger.Error(err, "failed to get event")
+		return err
+	}
+
+	logger.V(4).Info("Received event", "reason", e.Reason, "involvedObject", e.InvolvedObject.Name, "msg", e.Message)
+	ev := c.objects.enhance(e, logger)
+	ev.ClusterName = req.Cluster
+	r.engine.ProcessEvent(ev)
+	return nil
+}
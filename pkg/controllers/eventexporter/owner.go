@@ -0,0 +1,221 @@
+package eventexporter
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	lru "github.com/hashicorp/golang-lru"
+	workloadv1beta1 "gitlab.dmall.com/arch/sym-admin/pkg/apis/workload/v1beta1"
+	"gitlab.dmall.com/arch/sym-admin/pkg/controllers/eventexporter/kube"
+	pkgLabels "gitlab.dmall.com/arch/sym-admin/pkg/labels"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// maxOwnerDepth bounds the walk of the owner chain
+	maxOwnerDepth = 8
+	// contextTTL is how long a resolved context is cached, the owners and the AppSet meta
+	// changed are resolved again after it
+	contextTTL = 5 * time.Minute
+
+	kindPod           = "Pod"
+	kindAdvDeployment = "AdvDeployment"
+	kindAppSet        = "AppSet"
+)
+
+// contextResolver resolves the EventContext of the involved objects of a cluster, the
+// AppSet is read from the master cluster when the master client is set, otherwise the
+// owner of the app is read from the annotations of the AdvDeployment. The client should
+// be uncached, the owners are of many kinds and a cached client starts an informer of each.
+type contextResolver struct {
+	client client.Reader
+	master client.Reader
+	cache  *lru.ARCCache
+	now    func() time.Time
+}
+
+type cachedContext struct {
+	ec     *kube.EventContext
+	expire time.Time
+}
+
+func newContextResolver(c client.Reader, master client.Reader) *contextResolver {
+	cache, err := lru.NewARC(1024)
+	if err != nil {
+		panic("cannot init cache: " + err.Error())
+	}
+	return &contextResolver{
+		client: c,
+		master: master,
+		cache:  cache,
+		now:    time.Now,
+	}
+}
+
+func (r *contextResolver) get(ctx context.Context, apiVersion, kind, namespace, name string) (*unstructured.Unstructured, error) {
+	obj := &unstructured.Unstructured{}
+	obj.SetGroupVersionKind(schema.FromAPIVersionAndKind(apiVersion, kind))
+	if err := r.client.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, obj); err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
+// Resolve walks the controllers of the involved object up to the AppSet, the objects
+// already deleted end the walk and the context resolved so far is returned. The contexts of
+// the objects or the owners not found are not cached, they may be created a moment later.
+func (r *contextResolver) Resolve(ctx context.Context, ref *corev1.ObjectReference, labels map[string]string) (*kube.EventContext, error) {
+	key := string(ref.UID)
+	if key == "" {
+		key = strings.Join([]string{ref.Kind, ref.Namespace, ref.Name}, "/")
+	}
+	if val, ok := r.cache.Get(key); ok {
+		cached := val.(*cachedContext)
+		if r.now().Before(cached.expire) {
+			return cached.ec, nil
+		}
+		r.cache.Remove(key)
+	}
+
+	ec := &kube.EventContext{
+		PodSet: labels[pkgLabels.ObserveMustLabelReleaseName],
+		Group:  labels[pkgLabels.ObserveMustLabelGroupName],
+		Zone:   labels[pkgLabels.LabelKeyZone],
+		Ldc:    labels[pkgLabels.ObserveMustLabelLdcName],
+	}
+
+	obj, err := r.get(ctx, ref.APIVersion, ref.Kind, ref.Namespace, ref.Name)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return ec, nil
+		}
+		return nil, err
+	}
+
+	found := true
+	var previous *unstructured.Unstructured
+	for i := 0; obj != nil && i < maxOwnerDepth; i++ {
+		switch obj.GetKind() {
+		case kindPod:
+			if err := r.resolvePod(obj, ec); err != nil {
+				return nil, err
+			}
+		case kindAdvDeployment:
+			if err := r.resolveAdvDeployment(ctx, obj, previous, ec); err != nil {
+				return nil, err
+			}
+		}
+
+		owner := metav1.GetControllerOf(obj)
+		if owner == nil {
+			break
+		}
+		ec.Owners = append(ec.Owners, kube.OwnerReference{Kind: owner.Kind, Name: owner.Name})
+
+		previous = obj
+		obj, err = r.get(ctx, owner.APIVersion, owner.Kind, ref.Namespace, owner.Name)
+		if err != nil {
+			if !apierrors.IsNotFound(err) {
+				return nil, err
+			}
+			found = false
+			obj = nil
+		}
+	}
+
+	if found {
+		r.cache.Add(key, &cachedContext{ec: ec, expire: r.now().Add(contextTTL)})
+	}
+	return ec, nil
+}
+
+func (r *contextResolver) resolvePod(obj *unstructured.Unstructured, ec *kube.EventContext) error {
+	pod := &corev1.Pod{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.UnstructuredContent(), pod); err != nil {
+		return err
+	}
+
+	ec.NodeName = pod.Spec.NodeName
+	if len(pod.Spec.Containers) > 0 {
+		ec.Image, ec.Version = splitImage(pod.Spec.Containers[0].Image)
+	}
+	return nil
+}
+
+// resolveAdvDeployment fills the PodSet of the workload owned by the AdvDeployment, the
+// AdvDeployment is named after the AppSet which is not in the same cluster.
+func (r *contextResolver) resolveAdvDeployment(ctx context.Context, obj, workload *unstructured.Unstructured, ec *kube.EventContext) error {
+	adv := &workloadv1beta1.AdvDeployment{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.UnstructuredContent(), adv); err != nil {
+		return err
+	}
+
+	ec.AdvDeployment = adv.Name
+	ec.AppSet = adv.Name
+	ec.Owners = append(ec.Owners, kube.OwnerReference{Kind: kindAppSet, Name: adv.Name})
+	if ec.Zone == "" {
+		ec.Zone = adv.Labels[pkgLabels.LabelKeyZone]
+	}
+	ec.Owner = adv.Annotations[pkgLabels.WorkLoadAnnotationOwner]
+	ec.OwnerContact = adv.Annotations[pkgLabels.WorkLoadAnnotationOwnerContact]
+
+	if workload != nil {
+		ec.PodSet = workload.GetName()
+	}
+	for _, podSet := range adv.Spec.Topology.PodSets {
+		if podSet.Name != ec.PodSet {
+			continue
+		}
+		if podSet.Image != "" {
+			ec.Image = podSet.Image
+		}
+		if podSet.Version != "" {
+			ec.Version = podSet.Version
+		}
+		if va, ok := podSet.Mata[pkgLabels.ObserveMustLabelGroupName]; ok {
+			ec.Group = va
+		}
+		if va, ok := podSet.Mata[pkgLabels.ObserveMustLabelLdcName]; ok {
+			ec.Ldc = va
+		}
+		break
+	}
+
+	if r.master == nil {
+		return nil
+	}
+	app := &workloadv1beta1.AppSet{}
+	if err := r.master.Get(ctx, types.NamespacedName{Namespace: adv.Namespace, Name: adv.Name}, app); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	if va, ok := app.Spec.Meta[pkgLabels.AppSetMetaOwner]; ok {
+		ec.Owner = va
+	}
+	if va, ok := app.Spec.Meta[pkgLabels.AppSetMetaOwnerContact]; ok {
+		ec.OwnerContact = va
+	}
+	return nil
+}
+
+// splitImage splits the image into the repository and the tag, the digest is kept in the repository.
+func splitImage(image string) (string, string) {
+	if strings.Contains(image, "@") {
+		return image, ""
+	}
+	i := strings.LastIndex(image, ":")
+	if i < 0 || strings.Contains(image[i:], "/") {
+		return image, ""
+	}
+	return image[:i], image[i+1:]
+}
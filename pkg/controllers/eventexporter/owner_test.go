@@ -0,0 +1,200 @@
+package eventexporter
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	workloadv1beta1 "gitlab.dmall.com/arch/sym-admin/pkg/apis/workload/v1beta1"
+	k8sclient "gitlab.dmall.com/arch/sym-admin/pkg/k8s/client"
+	pkgLabels "gitlab.dmall.com/arch/sym-admin/pkg/labels"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func controllerRef(apiVersion, kind, name string) []metav1.OwnerReference {
+	isController := true
+	return []metav1.OwnerReference{{APIVersion: apiVersion, Kind: kind, Name: name, UID: types.UID("uid-" + name), Controller: &isController}}
+}
+
+func newOwnerChain() []runtime.Object {
+	adv := &workloadv1beta1.AdvDeployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "bbcc",
+			Namespace: "default",
+			Labels:    map[string]string{pkgLabels.LabelKeyZone: "gz01"},
+			Annotations: map[string]string{
+				pkgLabels.WorkLoadAnnotationOwner:        "team-a",
+				pkgLabels.WorkLoadAnnotationOwnerContact: "a@example.com",
+			},
+		},
+	}
+	adv.Spec.Topology.PodSets = []*workloadv1beta1.PodSet{
+		{Name: "bbcc-gz01b-blue", Image: "registry/bbcc", Version: "v1", Mata: map[string]string{pkgLabels.ObserveMustLabelGroupName: "blue"}},
+		{Name: "bbcc-gz01b-green", Image: "registry/bbcc", Version: "v2", Mata: map[string]string{
+			pkgLabels.ObserveMustLabelGroupName: "green",
+			pkgLabels.ObserveMustLabelLdcName:   "gz01b",
+		}},
+	}
+
+	deploy := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{
+		Name:            "bbcc-gz01b-green",
+		Namespace:       "default",
+		OwnerReferences: controllerRef("workload.dmall.com/v1beta1", "AdvDeployment", "bbcc"),
+	}}
+	rs := &appsv1.ReplicaSet{ObjectMeta: metav1.ObjectMeta{
+		Name:            "bbcc-gz01b-green-7d9f",
+		Namespace:       "default",
+		OwnerReferences: controllerRef("apps/v1", "Deployment", "bbcc-gz01b-green"),
+	}}
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "bbcc-gz01b-green-7d9f-x2k4q",
+			Namespace:       "default",
+			OwnerReferences: controllerRef("apps/v1", "ReplicaSet", "bbcc-gz01b-green-7d9f"),
+		},
+		Spec: corev1.PodSpec{
+			NodeName:   "node-1",
+			Containers: []corev1.Container{{Name: "bbcc", Image: "registry/bbcc:v2"}},
+		},
+	}
+	return []runtime.Object{adv, deploy, rs, pod}
+}
+
+func podRef() *corev1.ObjectReference {
+	return &corev1.ObjectReference{APIVersion: "v1", Kind: "Pod", Namespace: "default", Name: "bbcc-gz01b-green-7d9f-x2k4q", UID: "uid-pod"}
+}
+
+func TestResolveOwnerChain(t *testing.T) {
+	c := fake.NewFakeClientWithScheme(k8sclient.GetScheme(), newOwnerChain()...)
+	r := newContextResolver(c, nil)
+
+	ec, err := r.Resolve(context.TODO(), podRef(), map[string]string{pkgLabels.ObserveMustLabelReleaseName: "bbcc-gz01b-green"})
+	if err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+
+	wantOwners := []string{"ReplicaSet/bbcc-gz01b-green-7d9f", "Deployment/bbcc-gz01b-green", "AdvDeployment/bbcc", "AppSet/bbcc"}
+	if len(ec.Owners) != len(wantOwners) {
+		t.Fatalf("Owners = %v, want %v", ec.Owners, wantOwners)
+	}
+	for i, o := range ec.Owners {
+		if got := o.Kind + "/" + o.Name; got != wantOwners[i] {
+			t.Errorf("Owners[%d] = %s, want %s", i, got, wantOwners[i])
+		}
+	}
+
+	checks := map[string][2]string{
+		"AppSet":        {ec.AppSet, "bbcc"},
+		"AdvDeployment": {ec.AdvDeployment, "bbcc"},
+		"PodSet":        {ec.PodSet, "bbcc-gz01b-green"},
+		"Group":         {ec.Group, "green"},
+		"Zone":          {ec.Zone, "gz01"},
+		"Ldc":           {ec.Ldc, "gz01b"},
+		"Image":         {ec.Image, "registry/bbcc"},
+		"Version":       {ec.Version, "v2"},
+		"NodeName":      {ec.NodeName, "node-1"},
+		"Owner":         {ec.Owner, "team-a"},
+		"OwnerContact":  {ec.OwnerContact, "a@example.com"},
+	}
+	for name, v := range checks {
+		if v[0] != v[1] {
+			t.Errorf("%s = %q, want %q", name, v[0], v[1])
+		}
+	}
+}
+
+func TestResolveAppSetOwnerFromMaster(t *testing.T) {
+	c := fake.NewFakeClientWithScheme(k8sclient.GetScheme(), newOwnerChain()...)
+	app := &workloadv1beta1.AppSet{ObjectMeta: metav1.ObjectMeta{Name: "bbcc", Namespace: "default"}}
+	app.Spec.Meta = map[string]string{
+		pkgLabels.AppSetMetaOwner:        "team-b",
+		pkgLabels.AppSetMetaOwnerContact: "b@example.com",
+	}
+	master := fake.NewFakeClientWithScheme(k8sclient.GetScheme(), app)
+	r := newContextResolver(c, master)
+
+	ec, err := r.Resolve(context.TODO(), podRef(), nil)
+	if err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+	if ec.Owner != "team-b" || ec.OwnerContact != "b@example.com" {
+		t.Errorf("owner = %q %q, want the AppSet meta", ec.Owner, ec.OwnerContact)
+	}
+}
+
+func TestResolveDeletedObject(t *testing.T) {
+	c := fake.NewFakeClientWithScheme(k8sclient.GetScheme())
+	r := newContextResolver(c, nil)
+
+	labels := map[string]string{
+		pkgLabels.ObserveMustLabelReleaseName: "bbcc-gz01b-blue",
+		pkgLabels.ObserveMustLabelGroupName:   "blue",
+	}
+	ec, err := r.Resolve(context.TODO(), podRef(), labels)
+	if err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+	if ec.PodSet != "bbcc-gz01b-blue" || ec.Group != "blue" || len(ec.Owners) != 0 {
+		t.Errorf("Resolve() = %+v, want the context of the labels", ec)
+	}
+}
+
+func TestResolveCache(t *testing.T) {
+	c := fake.NewFakeClientWithScheme(k8sclient.GetScheme())
+	r := newContextResolver(c, nil)
+	now := time.Now()
+	r.now = func() time.Time { return now }
+
+	// the pod not found yet is not cached
+	if ec, err := r.Resolve(context.TODO(), podRef(), nil); err != nil || len(ec.Owners) != 0 {
+		t.Fatalf("Resolve() = %+v, %v, want no owners", ec, err)
+	}
+	for _, obj := range newOwnerChain() {
+		if err := c.Create(context.TODO(), obj); err != nil {
+			t.Fatalf("create err: %v", err)
+		}
+	}
+	ec, err := r.Resolve(context.TODO(), podRef(), nil)
+	if err != nil || ec.AppSet != "bbcc" {
+		t.Fatalf("Resolve() = %+v, %v, want the AppSet resolved", ec, err)
+	}
+
+	adv := &workloadv1beta1.AdvDeployment{}
+	if err := c.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: "bbcc"}, adv); err != nil {
+		t.Fatalf("get err: %v", err)
+	}
+	adv.Annotations[pkgLabels.WorkLoadAnnotationOwner] = "team-c"
+	if err := c.Update(context.TODO(), adv); err != nil {
+		t.Fatalf("update err: %v", err)
+	}
+	if ec, _ := r.Resolve(context.TODO(), podRef(), nil); ec.Owner != "team-a" {
+		t.Errorf("Owner = %q, want the cached team-a", ec.Owner)
+	}
+	now = now.Add(contextTTL)
+	if ec, _ := r.Resolve(context.TODO(), podRef(), nil); ec.Owner != "team-c" {
+		t.Errorf("Owner = %q, want team-c resolved after the ttl", ec.Owner)
+	}
+}
+
+func TestSplitImage(t *testing.T) {
+	tests := []struct {
+		image, repo, tag string
+	}{
+		{"nginx", "nginx", ""},
+		{"nginx:1.19", "nginx", "1.19"},
+		{"registry:5000/arch/bbcc:v2", "registry:5000/arch/bbcc", "v2"},
+		{"registry:5000/arch/bbcc", "registry:5000/arch/bbcc", ""},
+		{"nginx@sha256:abcd", "nginx@sha256:abcd", ""},
+	}
+	for _, tt := range tests {
+		repo, tag := splitImage(tt.image)
+		if repo != tt.repo || tag != tt.tag {
+			t.Errorf("splitImage(%q) = %q, %q, want %q, %q", tt.image, repo, tag, tt.repo, tt.tag)
+		}
+	}
+}
@@ -0,0 +1,550 @@
+package sinks
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"gitlab.dmall.com/arch/sym-admin/pkg/backoff"
+	"gitlab.dmall.com/arch/sym-admin/pkg/controllers/eventexporter/kube"
+	k8smanager "gitlab.dmall.com/arch/sym-admin/pkg/k8s/manager"
+	pkgLabels "gitlab.dmall.com/arch/sym-admin/pkg/labels"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// ActionDeletePod deletes the involved pod, a terminating pod is deleted immediately.
+	ActionDeletePod = "deletePod"
+	// ActionRestartPodSet deletes the pods of the group, zone and ldc of the involved pod in
+	// batches in the background, the next batch waits for the pods ready again.
+	ActionRestartPodSet = "restartPodSet"
+	// ActionCordonNode marks the involved node unschedulable.
+	ActionCordonNode = "cordonNode"
+
+	// the reasons of the audit events
+	ReasonAutoRemediation       = "AutoRemediation"
+	ReasonAutoRemediationDryRun = "AutoRemediationDryRun"
+	ReasonAutoRemediationFailed = "AutoRemediationFailed"
+
+	actionComponent = "sym-event-exporter"
+
+	defaultActionCount    = 10
+	defaultActionWindow   = time.Hour
+	defaultActionCooldown = 10 * time.Minute
+	defaultBatchSize      = 1
+	defaultBatchTimeout   = 5 * time.Minute
+
+	// auditTimeout bounds the audit of the restarts done in the background
+	auditTimeout = 30 * time.Second
+)
+
+// batchInterval is how often the pods are checked between the batches of a restart
+var batchInterval = 5 * time.Second
+
+var actionsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "sym",
+	Subsystem: "event_exporter",
+	Name:      "actions_total",
+	Help:      "The number of the remediation actions by result: executed, dryrun, limited, skipped or failed.",
+}, []string{"action", "result"})
+
+func init() {
+	prometheus.MustRegister(actionsTotal)
+}
+
+// ClusterClients returns the client of the cluster of the events.
+type ClusterClients interface {
+	Client(cluster string) (client.Client, error)
+}
+
+// ActionRateLimit bounds the number of the actions within the window.
+type ActionRateLimit struct {
+	Count  int           `json:"count,omitempty" yaml:"count"`
+	Window time.Duration `json:"window,omitempty" yaml:"window"`
+}
+
+// ActionConfig remediates the objects of the matching events. The actions are bounded by
+// the rate limit and the cooldown of each target, and audited as the events of the targets.
+type ActionConfig struct {
+	// Type is deletePod, restartPodSet or cordonNode.
+	Type string `json:"type,omitempty" yaml:"type"`
+	// DryRun only audits the actions.
+	DryRun bool `json:"dryRun,omitempty" yaml:"dryRun"`
+	// RateLimit of the receiver, default is 10 actions per hour.
+	RateLimit *ActionRateLimit `json:"rateLimit,omitempty" yaml:"rateLimit"`
+	// Cooldown skips the targets acted on within it, default is 10m.
+	Cooldown time.Duration `json:"cooldown,omitempty" yaml:"cooldown"`
+	// Namespaces limits the pod actions to the namespaces, empty is all.
+	Namespaces []string `json:"namespaces,omitempty" yaml:"namespaces"`
+	// BatchSize is the number of the pods restarted at a time by restartPodSet, default is 1.
+	BatchSize int `json:"batchSize,omitempty" yaml:"batchSize"`
+	// BatchTimeout stops the restart if the pods are not ready again within it, default is 5m.
+	BatchTimeout time.Duration `json:"batchTimeout,omitempty" yaml:"batchTimeout"`
+
+	// Clients is set by the exporter.
+	Clients ClusterClients `json:"-" yaml:"-"`
+	// State is set by the exporter to keep the rate limit and the cooldowns across the
+	// reloads of the config, a new state is used if nil.
+	State *ActionState `json:"-" yaml:"-"`
+}
+
+func (c *ActionConfig) Validate() error {
+	switch c.Type {
+	case ActionDeletePod, ActionRestartPodSet, ActionCordonNode:
+	default:
+		return fmt.Errorf("invalid action type: %q, must be one of %s, %s, %s", c.Type, ActionDeletePod, ActionRestartPodSet, ActionCordonNode)
+	}
+	if c.RateLimit != nil && (c.RateLimit.Count <= 0 || c.RateLimit.Window <= 0) {
+		return fmt.Errorf("action rateLimit: count and window must be positive")
+	}
+	if c.Cooldown < 0 {
+		return fmt.Errorf("action cooldown must not be negative")
+	}
+	if c.BatchSize < 0 || c.BatchTimeout < 0 {
+		return fmt.Errorf("action batchSize and batchTimeout must not be negative")
+	}
+	return nil
+}
+
+// ActionState is the actions done by a receiver, for the rate limit, the cooldowns and
+// the restarts in progress.
+type ActionState struct {
+	mu         sync.Mutex
+	history    []time.Time
+	acted      map[string]time.Time
+	restarting map[string]bool
+}
+
+func NewActionState() *ActionState {
+	return &ActionState{acted: make(map[string]time.Time), restarting: make(map[string]bool)}
+}
+
+// actionTarget is the object of an action.
+type actionTarget struct {
+	cluster   string
+	ref       corev1.ObjectReference
+	selector  map[string]string
+	namespace string
+}
+
+func (t *actionTarget) key() string {
+	if t.selector != nil {
+		return fmt.Sprintf("%s/%s/%s", t.cluster, t.namespace, podSetLabels(t.selector))
+	}
+	return fmt.Sprintf("%s/%s/%s/%s", t.cluster, t.ref.Kind, t.ref.Namespace, t.ref.Name)
+}
+
+func podSetLabels(set map[string]string) string {
+	keys := []string{"app", "sym-group", "sym-zone", "sym-ldc"}
+	values := make([]string, 0, len(keys))
+	for _, k := range keys {
+		if v, ok := set[k]; ok {
+			values = append(values, k+"="+v)
+		}
+	}
+	return strings.Join(values, ",")
+}
+
+type Action struct {
+	cfg          *ActionConfig
+	count        int
+	window       time.Duration
+	cooldown     time.Duration
+	batchSize    int
+	batchTimeout time.Duration
+
+	state *ActionState
+	now   func() time.Time
+	// restarts are the restarts running in the background
+	restarts sync.WaitGroup
+}
+
+func NewAction(cfg *ActionConfig) (Sink, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	if cfg.Clients == nil {
+		return nil, fmt.Errorf("action %s has no cluster clients", cfg.Type)
+	}
+
+	a := &Action{
+		cfg:          cfg,
+		count:        defaultActionCount,
+		window:       defaultActionWindow,
+		cooldown:     cfg.Cooldown,
+		batchSize:    cfg.BatchSize,
+		batchTimeout: cfg.BatchTimeout,
+		state:        cfg.State,
+		now:          time.Now,
+	}
+	if a.state == nil {
+		a.state = NewActionState()
+	}
+	if cfg.RateLimit != nil {
+		a.count = cfg.RateLimit.Count
+		a.window = cfg.RateLimit.Window
+	}
+	if a.cooldown == 0 {
+		a.cooldown = defaultActionCooldown
+	}
+	if a.batchSize == 0 {
+		a.batchSize = defaultBatchSize
+	}
+	if a.batchTimeout == 0 {
+		a.batchTimeout = defaultBatchTimeout
+	}
+	return a, nil
+}
+
+func (a *Action) Close() {
+	// No-op
+}
+
+// target resolves the object acted on by the event, nil if the event does not fit the action.
+func (a *Action) target(ev *kube.EnhancedEvent) *actionTarget {
+	obj := ev.InvolvedObject
+	t := &actionTarget{cluster: ev.ClusterName, ref: obj.ObjectReference, namespace: obj.Namespace}
+
+	switch a.cfg.Type {
+	case ActionCordonNode:
+		if obj.Kind != "Node" {
+			return nil
+		}
+		return t
+	case ActionDeletePod, ActionRestartPodSet:
+		if obj.Kind != "Pod" || !a.allowNamespace(obj.Namespace) {
+			return nil
+		}
+	}
+	if a.cfg.Type == ActionDeletePod {
+		return t
+	}
+
+	// the pods of the PodSet are selected like DeletePodByGroup
+	group := obj.Labels[pkgLabels.ObserveMustLabelGroupName]
+	zone := obj.Labels["sym-zone"]
+	ldc := obj.Labels[pkgLabels.ObserveMustLabelLdcName]
+	if ev.Context != nil {
+		if ev.Context.Group != "" {
+			group = ev.Context.Group
+		}
+		if ev.Context.Ldc != "" {
+			ldc = ev.Context.Ldc
+		}
+	}
+	app := obj.Labels[pkgLabels.ObserveMustLabelAppName]
+	if app == "" || group == "" {
+		return nil
+	}
+	t.selector = k8smanager.PodGroupSelector(app, group, zone, ldc)
+	return t
+}
+
+func (a *Action) allowNamespace(namespace string) bool {
+	if len(a.cfg.Namespaces) == 0 {
+		return true
+	}
+	for _, ns := range a.cfg.Namespaces {
+		if ns == namespace {
+			return true
+		}
+	}
+	return false
+}
+
+// allow checks the cooldown of the target and the rate limit, and records the action. A
+// target is restarted once at a time, the restart is recorded until done.
+func (a *Action) allow(key string) (bool, string) {
+	s := a.state
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := a.now()
+	for k, t := range s.acted {
+		if now.Sub(t) >= a.cooldown {
+			delete(s.acted, k)
+		}
+	}
+	if _, ok := s.acted[key]; ok || s.restarting[key] {
+		return false, "skipped"
+	}
+
+	i := 0
+	for i < len(s.history) && now.Sub(s.history[i]) >= a.window {
+		i++
+	}
+	s.history = s.history[i:]
+	if len(s.history) >= a.count {
+		return false, "limited"
+	}
+
+	s.history = append(s.history, now)
+	s.acted[key] = now
+	if a.cfg.Type == ActionRestartPodSet && !a.cfg.DryRun {
+		s.restarting[key] = true
+	}
+	return true, ""
+}
+
+func (a *Action) restarted(key string) {
+	s := a.state
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.restarting, key)
+}
+
+func (a *Action) Send(ctx context.Context, ev *kube.EnhancedEvent) error {
+	t := a.target(ev)
+	if t == nil {
+		klog.V(4).Infof("action %s does not apply to %s %s/%s, skip", a.cfg.Type, ev.InvolvedObject.Kind, ev.InvolvedObject.Namespace, ev.InvolvedObject.Name)
+		return nil
+	}
+
+	cli, err := a.cfg.Clients.Client(t.cluster)
+	if err != nil {
+		return err
+	}
+
+	ok, result := a.allow(t.key())
+	if !ok {
+		klog.Infof("action %s on %s is %s", a.cfg.Type, t.key(), result)
+		actionsTotal.WithLabelValues(a.cfg.Type, result).Inc()
+		return nil
+	}
+
+	if a.cfg.DryRun {
+		actionsTotal.WithLabelValues(a.cfg.Type, "dryrun").Inc()
+		a.audit(ctx, cli, t, ev, corev1.EventTypeNormal, ReasonAutoRemediationDryRun, "would "+a.describe(t))
+		return nil
+	}
+
+	if a.cfg.Type == ActionRestartPodSet {
+		// the restart waits for the pods between the batches, it does not hold the delivery
+		a.restarts.Add(1)
+		go func() {
+			defer a.restarts.Done()
+			defer a.restarted(t.key())
+			err := a.restartPodSet(context.Background(), cli, t)
+
+			actx, cancel := context.WithTimeout(context.Background(), auditTimeout)
+			defer cancel()
+			a.done(actx, cli, t, ev, err)
+		}()
+		return nil
+	}
+
+	err = a.execute(ctx, cli, t)
+	a.done(ctx, cli, t, ev, err)
+	if err != nil {
+		// the target is in the cooldown, the failed action is not retried
+		return backoff.MarkErrorPermanent(err)
+	}
+	return nil
+}
+
+// done counts and audits the result of the action.
+func (a *Action) done(ctx context.Context, cli client.Client, t *actionTarget, ev *kube.EnhancedEvent, err error) {
+	if err != nil {
+		actionsTotal.WithLabelValues(a.cfg.Type, "failed").Inc()
+		a.audit(ctx, cli, t, ev, corev1.EventTypeWarning, ReasonAutoRemediationFailed, fmt.Sprintf("failed to %s: %v", a.describe(t), err))
+		return
+	}
+	actionsTotal.WithLabelValues(a.cfg.Type, "executed").Inc()
+	a.audit(ctx, cli, t, ev, corev1.EventTypeNormal, ReasonAutoRemediation, a.describe(t))
+}
+
+func (a *Action) describe(t *actionTarget) string {
+	switch a.cfg.Type {
+	case ActionRestartPodSet:
+		return fmt.Sprintf("restart the pods of %s in %s", podSetLabels(t.selector), t.namespace)
+	case ActionCordonNode:
+		return fmt.Sprintf("cordon node %s", t.ref.Name)
+	}
+	return fmt.Sprintf("delete pod %s/%s", t.ref.Namespace, t.ref.Name)
+}
+
+func (a *Action) execute(ctx context.Context, cli client.Client, t *actionTarget) error {
+	switch a.cfg.Type {
+	case ActionDeletePod:
+		pod := &corev1.Pod{}
+		if err := cli.Get(ctx, types.NamespacedName{Namespace: t.ref.Namespace, Name: t.ref.Name}, pod); err != nil {
+			if apierrors.IsNotFound(err) {
+				return nil
+			}
+			return err
+		}
+		return deletePod(ctx, cli, pod)
+	case ActionCordonNode:
+		return cordonNode(ctx, cli, t.ref.Name)
+	}
+	return nil
+}
+
+// restartPodSet deletes the pods of the PodSet batchSize at a time, the next batch waits
+// for the ready pods of the PodSet back to the number before the restart, the restart stops
+// if they are not within batchTimeout. The whole restart is bounded by batchTimeout per batch.
+func (a *Action) restartPodSet(ctx context.Context, cli client.Client, t *actionTarget) error {
+	lctx, cancel := context.WithTimeout(ctx, a.batchTimeout)
+	pods, err := listPods(lctx, cli, t.namespace, t.selector)
+	cancel()
+	if err != nil {
+		return err
+	}
+	sort.Slice(pods, func(i, j int) bool {
+		return pods[i].Name < pods[j].Name
+	})
+
+	total := len(pods)
+	batches := (total + a.batchSize - 1) / a.batchSize
+	ctx, cancel = context.WithTimeout(ctx, time.Duration(batches+1)*a.batchTimeout)
+	defer cancel()
+	ready := 0
+	for _, pod := range pods {
+		if pod.DeletionTimestamp == nil && isPodReady(pod) {
+			ready++
+		}
+	}
+	failed := 0
+	for i := 0; i < total; i += a.batchSize {
+		end := i + a.batchSize
+		if end > total {
+			end = total
+		}
+		for _, pod := range pods[i:end] {
+			if err := deletePod(ctx, cli, pod); err != nil {
+				klog.Errorf("cluster: %s delete pod: %s/%s err: %v", t.cluster, pod.Namespace, pod.Name, err)
+				failed++
+			}
+		}
+		if end == total {
+			break
+		}
+		if err := a.waitPodsReady(ctx, cli, t, ready); err != nil {
+			return fmt.Errorf("restart stopped after %d of %d pods: %v", end, total, err)
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d pods failed to delete", failed)
+	}
+	return nil
+}
+
+// waitPodsReady waits for the ready pods of the PodSet not terminating back to the expected.
+func (a *Action) waitPodsReady(ctx context.Context, cli client.Client, t *actionTarget, expect int) error {
+	ctx, cancel := context.WithTimeout(ctx, a.batchTimeout)
+	defer cancel()
+
+	ready := 0
+	err := wait.PollImmediateUntil(batchInterval, func() (bool, error) {
+		pods, err := listPods(ctx, cli, t.namespace, t.selector)
+		if err != nil {
+			klog.Warningf("cluster: %s list pods of %s err: %v", t.cluster, podSetLabels(t.selector), err)
+			return false, nil
+		}
+		ready = 0
+		for _, pod := range pods {
+			if pod.DeletionTimestamp == nil && isPodReady(pod) {
+				ready++
+			}
+		}
+		return ready >= expect, nil
+	}, ctx.Done())
+	if err != nil {
+		return fmt.Errorf("%d of %d pods ready in %v", ready, expect, a.batchTimeout)
+	}
+	return nil
+}
+
+func listPods(ctx context.Context, cli client.Client, namespace string, selector map[string]string) ([]*corev1.Pod, error) {
+	podList := &corev1.PodList{}
+	listOptions := &client.ListOptions{Namespace: namespace, LabelSelector: labels.Set(selector).AsSelector()}
+	if err := cli.List(ctx, podList, listOptions); err != nil {
+		return nil, err
+	}
+	pods := make([]*corev1.Pod, 0, len(podList.Items))
+	for i := range podList.Items {
+		pods = append(pods, &podList.Items[i])
+	}
+	return pods, nil
+}
+
+func isPodReady(pod *corev1.Pod) bool {
+	for _, c := range pod.Status.Conditions {
+		if c.Type == corev1.PodReady {
+			return c.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// deletePod marks the pod deleted by the exporter for the offline pod record and deletes it.
+func deletePod(ctx context.Context, cli client.Client, pod *corev1.Pod) error {
+	if err := k8smanager.MarkPodDeletedBy(ctx, cli, pod, actionComponent); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		klog.Errorf("pod: %s/%s mark deleted err: %v", pod.Namespace, pod.Name, err)
+	}
+
+	var opts []client.DeleteOption
+	if pod.DeletionTimestamp != nil {
+		// the pod is stuck terminating
+		opts = append(opts, client.GracePeriodSeconds(0))
+	}
+	if err := cli.Delete(ctx, pod, opts...); err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+func cordonNode(ctx context.Context, cli client.Client, name string) error {
+	node := &corev1.Node{}
+	if err := cli.Get(ctx, types.NamespacedName{Name: name}, node); err != nil {
+		return err
+	}
+	if node.Spec.Unschedulable {
+		return nil
+	}
+
+	patch := client.MergeFrom(node.DeepCopy())
+	node.Spec.Unschedulable = true
+	return cli.Patch(ctx, node, patch)
+}
+
+// audit records the action as an event of the target, the failure of the audit is only logged.
+func (a *Action) audit(ctx context.Context, cli client.Client, t *actionTarget, ev *kube.EnhancedEvent, eventType, reason, message string) {
+	namespace := t.ref.Namespace
+	if namespace == "" {
+		namespace = metav1.NamespaceDefault
+	}
+	now := metav1.NewTime(a.now())
+	e := &corev1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("%v.%x", t.ref.Name, now.UnixNano()),
+			Namespace: namespace,
+		},
+		InvolvedObject: t.ref,
+		Reason:         reason,
+		Message:        fmt.Sprintf("%s, triggered by event %s: %s", message, ev.Reason, ev.Message),
+		Source:         corev1.EventSource{Component: actionComponent},
+		FirstTimestamp: now,
+		LastTimestamp:  now,
+		Count:          1,
+		Type:           eventType,
+	}
+	if err := cli.Create(ctx, e); err != nil {
+		klog.Errorf("cluster: %s audit action %s err: %v", t.cluster, a.cfg.Type, err)
+	}
+	klog.Infof("cluster: %s %s: %s", t.cluster, reason, e.Message)
+}
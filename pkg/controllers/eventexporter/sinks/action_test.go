@@ -0,0 +1,263 @@
+package sinks
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	lbackoff "github.com/lestrrat-go/backoff"
+	"gitlab.dmall.com/arch/sym-admin/pkg/controllers/eventexporter/kube"
+	k8sclient "gitlab.dmall.com/arch/sym-admin/pkg/k8s/client"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+type fakeClusterClients struct {
+	client client.Client
+}
+
+func (c *fakeClusterClients) Client(string) (client.Client, error) {
+	return c.client, nil
+}
+
+func newTestPod(name, group string) *corev1.Pod {
+	return &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Name:      name,
+		Namespace: "default",
+		Labels:    map[string]string{"app": "bbcc", "sym-group": group},
+	}}
+}
+
+func newActionEvent(kind, namespace, name string, labels map[string]string) *kube.EnhancedEvent {
+	ev := newTestEvent("action."+name, time.Now())
+	ev.Reason = "Evicted"
+	ev.InvolvedObject.Kind = kind
+	ev.InvolvedObject.Name = name
+	ev.InvolvedObject.Namespace = namespace
+	ev.InvolvedObject.Labels = labels
+	return ev
+}
+
+func newTestAction(t *testing.T, cfg *ActionConfig, objs ...runtime.Object) (*Action, client.Client) {
+	cli := fake.NewFakeClientWithScheme(k8sclient.GetScheme(), objs...)
+	cfg.Clients = &fakeClusterClients{client: cli}
+	sink, err := NewAction(cfg)
+	if err != nil {
+		t.Fatalf("NewAction() error = %v", err)
+	}
+	return sink.(*Action), cli
+}
+
+func auditEvents(t *testing.T, cli client.Client, namespace string) []corev1.Event {
+	list := &corev1.EventList{}
+	if err := cli.List(context.TODO(), list, client.InNamespace(namespace)); err != nil {
+		t.Fatalf("list events error = %v", err)
+	}
+	return list.Items
+}
+
+func podExists(cli client.Client, name string) bool {
+	return cli.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: name}, &corev1.Pod{}) == nil
+}
+
+func TestActionDeletePod(t *testing.T) {
+	a, cli := newTestAction(t, &ActionConfig{Type: ActionDeletePod}, newTestPod("bbcc-1", "blue"), newTestPod("bbcc-2", "blue"))
+
+	if err := a.Send(context.TODO(), newActionEvent("Pod", "default", "bbcc-1", nil)); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if podExists(cli, "bbcc-1") || !podExists(cli, "bbcc-2") {
+		t.Errorf("expect only the involved pod deleted")
+	}
+
+	events := auditEvents(t, cli, "default")
+	if len(events) != 1 || events[0].Reason != ReasonAutoRemediation || events[0].InvolvedObject.Name != "bbcc-1" {
+		t.Fatalf("expect an audit event of the pod, current: %+v", events)
+	}
+
+	// the node events do not apply to the pod action
+	if err := a.Send(context.TODO(), newActionEvent("Node", "", "node-1", nil)); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if len(auditEvents(t, cli, "default")) != 1 {
+		t.Errorf("expect the node event skipped")
+	}
+}
+
+func TestActionDryRun(t *testing.T) {
+	a, cli := newTestAction(t, &ActionConfig{Type: ActionDeletePod, DryRun: true}, newTestPod("bbcc-1", "blue"))
+
+	if err := a.Send(context.TODO(), newActionEvent("Pod", "default", "bbcc-1", nil)); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if !podExists(cli, "bbcc-1") {
+		t.Errorf("expect the pod kept by the dry run")
+	}
+	events := auditEvents(t, cli, "default")
+	if len(events) != 1 || events[0].Reason != ReasonAutoRemediationDryRun {
+		t.Fatalf("expect a dry run audit event, current: %+v", events)
+	}
+}
+
+func TestActionRestartPodSet(t *testing.T) {
+	a, cli := newTestAction(t, &ActionConfig{Type: ActionRestartPodSet, BatchSize: 2},
+		newTestPod("bbcc-blue-1", "blue"), newTestPod("bbcc-blue-2", "blue"), newTestPod("bbcc-green-1", "green"))
+
+	labels := map[string]string{"app": "bbcc", "sym-group": "blue"}
+	if err := a.Send(context.TODO(), newActionEvent("Pod", "default", "bbcc-blue-1", labels)); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	a.restarts.Wait()
+	if podExists(cli, "bbcc-blue-1") || podExists(cli, "bbcc-blue-2") || !podExists(cli, "bbcc-green-1") {
+		t.Errorf("expect the pods of the blue group deleted")
+	}
+
+	// the pod without group is not restarted
+	if err := a.Send(context.TODO(), newActionEvent("Pod", "default", "bbcc-green-1", map[string]string{"app": "bbcc"})); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if !podExists(cli, "bbcc-green-1") {
+		t.Errorf("expect the pod without group kept")
+	}
+}
+
+func TestActionRestartPodSetBatches(t *testing.T) {
+	interval := batchInterval
+	batchInterval = 10 * time.Millisecond
+	defer func() { batchInterval = interval }()
+
+	readyPod := func(name string) *corev1.Pod {
+		pod := newTestPod(name, "blue")
+		pod.Status.Conditions = []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}}
+		return pod
+	}
+	// the cooldown passes at once, the restart in progress still skips the target
+	a, cli := newTestAction(t, &ActionConfig{Type: ActionRestartPodSet, BatchTimeout: 100 * time.Millisecond, Cooldown: time.Nanosecond},
+		readyPod("bbcc-blue-1"), readyPod("bbcc-blue-2"), readyPod("bbcc-blue-3"))
+
+	// the replacement of the first pod becomes ready, the second one never
+	go func() {
+		for podExists(cli, "bbcc-blue-1") {
+			time.Sleep(5 * time.Millisecond)
+		}
+		cli.Create(context.TODO(), readyPod("bbcc-blue-4"))
+	}()
+
+	labels := map[string]string{"app": "bbcc", "sym-group": "blue"}
+	if err := a.Send(context.TODO(), newActionEvent("Pod", "default", "bbcc-blue-1", labels)); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if err := a.Send(context.TODO(), newActionEvent("Pod", "default", "bbcc-blue-3", labels)); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	a.restarts.Wait()
+
+	events := auditEvents(t, cli, "default")
+	if len(events) != 1 || events[0].Reason != ReasonAutoRemediationFailed {
+		t.Fatalf("expect one restart stopped, current: %+v", events)
+	}
+	if podExists(cli, "bbcc-blue-1") || podExists(cli, "bbcc-blue-2") || !podExists(cli, "bbcc-blue-3") {
+		t.Errorf("expect the restart stopped after the second batch")
+	}
+}
+
+func TestActionStateKept(t *testing.T) {
+	state := NewActionState()
+	objs := []runtime.Object{newTestPod("bbcc-1", "blue"), newTestPod("bbcc-2", "blue")}
+	cfg := &ActionConfig{Type: ActionDeletePod, RateLimit: &ActionRateLimit{Count: 1, Window: time.Hour}, State: state}
+	a, _ := newTestAction(t, cfg, objs...)
+	if err := a.Send(context.TODO(), newActionEvent("Pod", "default", "bbcc-1", nil)); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	// the action of the reloaded config is still limited
+	reloaded := &ActionConfig{Type: ActionDeletePod, RateLimit: &ActionRateLimit{Count: 1, Window: time.Hour}, State: state}
+	a, cli := newTestAction(t, reloaded, objs[1])
+	if err := a.Send(context.TODO(), newActionEvent("Pod", "default", "bbcc-2", nil)); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if !podExists(cli, "bbcc-2") {
+		t.Errorf("expect the action limited after the reload")
+	}
+}
+
+func TestActionCordonNode(t *testing.T) {
+	node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1"}}
+	a, cli := newTestAction(t, &ActionConfig{Type: ActionCordonNode}, node)
+
+	if err := a.Send(context.TODO(), newActionEvent("Node", "", "node-1", nil)); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	got := &corev1.Node{}
+	if err := cli.Get(context.TODO(), types.NamespacedName{Name: "node-1"}, got); err != nil {
+		t.Fatalf("get node error = %v", err)
+	}
+	if !got.Spec.Unschedulable {
+		t.Errorf("expect the node cordoned")
+	}
+	if events := auditEvents(t, cli, metav1.NamespaceDefault); len(events) != 1 {
+		t.Errorf("expect the audit event of the node in the default namespace, current: %d", len(events))
+	}
+
+	// the failure is audited and not retried
+	a.now = func() time.Time { return time.Now().Add(time.Hour) }
+	err := a.Send(context.TODO(), newActionEvent("Node", "", "node-2", nil))
+	if err == nil || !lbackoff.IsPermanentError(err) {
+		t.Fatalf("expect a permanent error, current: %v", err)
+	}
+	events := auditEvents(t, cli, metav1.NamespaceDefault)
+	if len(events) != 2 {
+		t.Fatalf("expect the failure audited, current: %+v", events)
+	}
+}
+
+func TestActionRateLimit(t *testing.T) {
+	now := time.Now()
+	a, cli := newTestAction(t, &ActionConfig{
+		Type:      ActionDeletePod,
+		RateLimit: &ActionRateLimit{Count: 2, Window: time.Hour},
+		Cooldown:  time.Minute,
+	}, newTestPod("bbcc-1", "blue"), newTestPod("bbcc-2", "blue"), newTestPod("bbcc-3", "blue"))
+	a.now = func() time.Time { return now }
+
+	for _, name := range []string{"bbcc-1", "bbcc-1", "bbcc-2", "bbcc-3"} {
+		if err := a.Send(context.TODO(), newActionEvent("Pod", "default", name, nil)); err != nil {
+			t.Fatalf("Send() error = %v", err)
+		}
+	}
+	if podExists(cli, "bbcc-1") || podExists(cli, "bbcc-2") || !podExists(cli, "bbcc-3") {
+		t.Errorf("expect 2 actions within the window")
+	}
+	if events := auditEvents(t, cli, "default"); len(events) != 2 {
+		t.Errorf("expect the cooldown and the limit skip the audit, current: %d", len(events))
+	}
+
+	now = now.Add(time.Hour)
+	if err := a.Send(context.TODO(), newActionEvent("Pod", "default", "bbcc-3", nil)); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if podExists(cli, "bbcc-3") {
+		t.Errorf("expect the action allowed after the window")
+	}
+}
+
+func TestActionValidate(t *testing.T) {
+	cases := []*ActionConfig{
+		{},
+		{Type: "scale"},
+		{Type: ActionDeletePod, RateLimit: &ActionRateLimit{Count: 1}},
+		{Type: ActionDeletePod, Cooldown: -time.Second},
+	}
+	for i, c := range cases {
+		if err := c.Validate(); err == nil {
+			t.Errorf("case %d expect error", i)
+		}
+	}
+	if _, err := NewAction(&ActionConfig{Type: ActionDeletePod}); err == nil {
+		t.Errorf("expect error without the cluster clients")
+	}
+}
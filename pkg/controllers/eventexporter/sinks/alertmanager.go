@@ -3,30 +3,160 @@ package sinks
 import (
 	"bytes"
 	"context"
-	"errors"
+	"encoding/json"
+	"fmt"
 	"io/ioutil"
 	"net/http"
-
+	"regexp"
 	"strconv"
+	"strings"
+	"time"
 
-	json "github.com/json-iterator/go"
 	"github.com/prometheus/common/model"
 	"gitlab.dmall.com/arch/sym-admin/pkg/controllers/eventexporter/kube"
 	pkgLabels "gitlab.dmall.com/arch/sym-admin/pkg/labels"
-	"k8s.io/klog"
 )
 
+const (
+	alertManagerPushPath = "/api/v1/alerts"
+
+	// alertGroupLabel joins the values of the GroupBy labels, the routes of the
+	// Alertmanager can group the alerts by it.
+	alertGroupLabel = "alertgroup"
+
+	defaultAlertSeverity       = "warning"
+	defaultAlertResolveTimeout = 5 * time.Minute
+)
+
+var (
+	defaultAlertGroupBy = []string{"cluster", "namespace", "app"}
+
+	// defaultSeverities are used when no severity is configured.
+	defaultSeverities = []SeverityMapping{
+		{Reason: "^(OOMKilled|OOMKilling|Evicted|SystemOOM|NodeNotReady)$", Severity: "critical"},
+		{Reason: "BackOff", Severity: "warning"},
+	}
+)
+
+// AlertManagerConfig posts the events as alerts to the Alertmanager. The labels identify the
+// alert, so the volatile fields such as the count and the message are annotations. An alert
+// is resolved by the Alertmanager when the event has not recurred for the ResolveTimeout.
 type AlertManagerConfig struct {
 	Endpoint string            `json:"endpoint,omitempty" yaml:"endpoint"`
 	Headers  map[string]string `json:"headers,omitempty" yaml:"headers"`
+	// Labels and Annotations are rendered by the layout templating and override the default
+	// ones, a label rendered to an empty value is removed.
+	Labels      map[string]string `json:"labels,omitempty" yaml:"labels"`
+	Annotations map[string]string `json:"annotations,omitempty" yaml:"annotations"`
+	// Severities maps the reason and the kind of the events to the severity, the first match wins.
+	Severities      []SeverityMapping `json:"severities,omitempty" yaml:"severities"`
+	DefaultSeverity string            `json:"defaultSeverity,omitempty" yaml:"defaultSeverity"`
+	// ResolveTimeout is the time since the last occurrence of the event after which the alert is resolved, default is 5m.
+	ResolveTimeout time.Duration `json:"resolveTimeout,omitempty" yaml:"resolveTimeout"`
+	// GroupBy are the labels joined into the alertgroup label, default is cluster, namespace and app.
+	GroupBy      []string   `json:"groupBy,omitempty" yaml:"groupBy"`
+	GeneratorURL string     `json:"generatorURL,omitempty" yaml:"generatorURL"`
+	TLS          *TLSConfig `json:"tls,omitempty" yaml:"tls"`
 }
 
-func NewAlertManager(cfg *AlertManagerConfig) (Sink, error) {
-	return &AlertManager{cfg: cfg}, nil
+// SeverityMapping sets the severity of the events matching the regular expressions of the
+// reason and the kind of the involved object, an empty expression matches all.
+type SeverityMapping struct {
+	Reason   string `json:"reason,omitempty" yaml:"reason"`
+	Kind     string `json:"kind,omitempty" yaml:"kind"`
+	Severity string `json:"severity,omitempty" yaml:"severity"`
+}
+
+type severityMatcher struct {
+	reason   *regexp.Regexp
+	kind     *regexp.Regexp
+	severity string
+}
+
+func (m *severityMatcher) match(ev *kube.EnhancedEvent) bool {
+	if m.reason != nil && !m.reason.MatchString(ev.Reason) {
+		return false
+	}
+	return m.kind == nil || m.kind.MatchString(ev.InvolvedObject.Kind)
+}
+
+func compileSeverities(mappings []SeverityMapping) ([]*severityMatcher, error) {
+	result := make([]*severityMatcher, 0, len(mappings))
+	for i, s := range mappings {
+		if s.Severity == "" {
+			return nil, fmt.Errorf("alertManager severities[%d]: severity is empty", i)
+		}
+		m := &severityMatcher{severity: s.Severity}
+		var err error
+		if s.Reason != "" {
+			if m.reason, err = regexp.Compile(s.Reason); err != nil {
+				return nil, fmt.Errorf("alertManager severities[%d]: invalid reason regex %q: %v", i, s.Reason, err)
+			}
+		}
+		if s.Kind != "" {
+			if m.kind, err = regexp.Compile(s.Kind); err != nil {
+				return nil, fmt.Errorf("alertManager severities[%d]: invalid kind regex %q: %v", i, s.Kind, err)
+			}
+		}
+		result = append(result, m)
+	}
+	return result, nil
+}
+
+func (c *AlertManagerConfig) Validate() error {
+	if c.Endpoint == "" {
+		return fmt.Errorf("alertManager endpoint is empty")
+	}
+	if c.ResolveTimeout < 0 {
+		return fmt.Errorf("alertManager resolveTimeout must not be negative")
+	}
+	for k, v := range c.Labels {
+		if !model.LabelName(k).IsValid() {
+			return fmt.Errorf("invalid alertManager label name: %s", k)
+		}
+		if err := validateTemplate(v); err != nil {
+			return fmt.Errorf("alertManager label %s: %v", k, err)
+		}
+	}
+	for k, v := range c.Annotations {
+		if !model.LabelName(k).IsValid() {
+			return fmt.Errorf("invalid alertManager annotation name: %s", k)
+		}
+		if err := validateTemplate(v); err != nil {
+			return fmt.Errorf("alertManager annotation %s: %v", k, err)
+		}
+	}
+	if err := validateTemplate(c.GeneratorURL); err != nil {
+		return fmt.Errorf("alertManager generatorURL: %v", err)
+	}
+	_, err := compileSeverities(c.Severities)
+	return err
 }
 
 type AlertManager struct {
-	cfg *AlertManagerConfig
+	cfg        *AlertManagerConfig
+	client     *http.Client
+	severities []*severityMatcher
+}
+
+func NewAlertManager(cfg *AlertManagerConfig) (Sink, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	client, err := newHTTPClient(cfg.TLS)
+	if err != nil {
+		return nil, err
+	}
+
+	mappings := cfg.Severities
+	if len(mappings) == 0 {
+		mappings = defaultSeverities
+	}
+	severities, err := compileSeverities(mappings)
+	if err != nil {
+		return nil, err
+	}
+	return &AlertManager{cfg: cfg, client: client, severities: severities}, nil
 }
 
 func (w *AlertManager) Close() {
@@ -34,17 +164,31 @@ func (w *AlertManager) Close() {
 }
 
 func (w *AlertManager) Send(ctx context.Context, ev *kube.EnhancedEvent) error {
+	return w.SendBatch(ctx, []*kube.EnhancedEvent{ev})
+}
+
+func (w *AlertManager) severity(ev *kube.EnhancedEvent) string {
+	for _, m := range w.severities {
+		if m.match(ev) {
+			return m.severity
+		}
+	}
+	if w.cfg.DefaultSeverity != "" {
+		return w.cfg.DefaultSeverity
+	}
+	return defaultAlertSeverity
+}
+
+func (w *AlertManager) buildAlert(ev *kube.EnhancedEvent) (*model.Alert, error) {
 	data := map[string]string{}
 	data["job"] = "event-alert"
 	data["service"] = "event-exporter"
-	data["severity"] = "warning"
+	data["severity"] = w.severity(ev)
 	data["cluster"] = ev.ClusterName
 	data["kind"] = ev.Event.InvolvedObject.Kind
 	data["reason"] = ev.Event.Reason
 	data["name"] = ev.Event.InvolvedObject.Name
 	data["namespace"] = ev.Event.InvolvedObject.Namespace
-	data["count"] = strconv.Itoa(int(ev.Event.Count))
-	data["message"] = ev.Event.Message
 	data["component"] = ev.Event.Source.Component
 	data["host"] = ev.Event.Source.Host
 
@@ -56,24 +200,126 @@ func (w *AlertManager) Send(ctx context.Context, ev *kube.EnhancedEvent) error {
 		data["group"] = group
 	}
 
-	alertList := model.Alerts{}
+	annotations := map[string]string{
+		"summary":     fmt.Sprintf("%s %s/%s: %s", ev.InvolvedObject.Kind, ev.InvolvedObject.Namespace, ev.InvolvedObject.Name, ev.Reason),
+		"description": ev.Message,
+		"message":     ev.Message,
+		"count":       strconv.Itoa(int(ev.Count)),
+	}
+	if c := ev.Context; c != nil {
+		setNotEmpty(data, "appset", c.AppSet)
+		setNotEmpty(data, "podset", c.PodSet)
+		setNotEmpty(data, "group", c.Group)
+		setNotEmpty(data, "zone", c.Zone)
+		setNotEmpty(data, "ldc", c.Ldc)
+
+		setNotEmpty(annotations, "owner", c.Owner)
+		setNotEmpty(annotations, "ownerContact", c.OwnerContact)
+		setNotEmpty(annotations, "image", c.Image)
+		setNotEmpty(annotations, "version", c.Version)
+		setNotEmpty(annotations, "node", c.NodeName)
+		if len(c.Owners) > 0 {
+			owners := make([]string, 0, len(c.Owners))
+			for _, o := range c.Owners {
+				owners = append(owners, o.Kind+"/"+o.Name)
+			}
+			annotations["owners"] = strings.Join(owners, " -> ")
+		}
+	}
+
+	if err := renderTemplates(ev, w.cfg.Labels, data); err != nil {
+		return nil, err
+	}
+	if err := renderTemplates(ev, w.cfg.Annotations, annotations); err != nil {
+		return nil, err
+	}
+
+	groupBy := w.cfg.GroupBy
+	if len(groupBy) == 0 {
+		groupBy = defaultAlertGroupBy
+	}
+	values := make([]string, 0, len(groupBy))
+	for _, k := range groupBy {
+		values = append(values, data[k])
+	}
+	data[alertGroupLabel] = strings.Join(values, "/")
+
 	a := &model.Alert{}
 	a.Labels = map[model.LabelName]model.LabelValue{}
 	for k, v := range data {
+		if v == "" {
+			continue
+		}
 		a.Labels[model.LabelName(k)] = model.LabelValue(v)
 	}
 	a.Annotations = map[model.LabelName]model.LabelValue{}
-	a.Annotations[model.LabelName("description")] = model.LabelValue("k8s event alert")
-	a.Annotations[model.LabelName("summary")] = model.LabelValue("Prometheus is failing rule evaluations.")
+	for k, v := range annotations {
+		if v == "" {
+			continue
+		}
+		a.Annotations[model.LabelName(k)] = model.LabelValue(v)
+	}
 
-	alertList = append(alertList, a)
+	last := ev.GetTimestamp()
+	a.StartsAt = ev.FirstTimestamp.Time
+	if a.StartsAt.IsZero() || a.StartsAt.After(last) {
+		a.StartsAt = last
+	}
+	resolveTimeout := w.cfg.ResolveTimeout
+	if resolveTimeout == 0 {
+		resolveTimeout = defaultAlertResolveTimeout
+	}
+	a.EndsAt = last.Add(resolveTimeout)
+
+	if w.cfg.GeneratorURL != "" {
+		url, err := GetString(ev, w.cfg.GeneratorURL)
+		if err != nil {
+			return nil, err
+		}
+		a.GeneratorURL = url
+	}
+	return a, nil
+}
+
+// renderTemplates renders the templates into the values, an empty result removes the key.
+func renderTemplates(ev *kube.EnhancedEvent, templates, values map[string]string) error {
+	for k, v := range templates {
+		va, err := GetString(ev, v)
+		if err != nil {
+			return err
+		}
+		if va == "" {
+			delete(values, k)
+			continue
+		}
+		values[k] = va
+	}
+	return nil
+}
+
+func setNotEmpty(m map[string]string, key, value string) {
+	if value != "" {
+		m[key] = value
+	}
+}
+
+// SendBatch posts the alerts of the events in one request.
+func (w *AlertManager) SendBatch(ctx context.Context, evs []*kube.EnhancedEvent) error {
+	alertList := make(model.Alerts, 0, len(evs))
+	for _, ev := range evs {
+		a, err := w.buildAlert(ev)
+		if err != nil {
+			return err
+		}
+		alertList = append(alertList, a)
+	}
 	reqBody, err := json.Marshal(alertList)
 	if err != nil {
 		return err
 	}
 
-	url := w.cfg.Endpoint + "/api/v1/alerts"
-	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(reqBody))
+	url := strings.TrimSuffix(w.cfg.Endpoint, "/") + alertManagerPushPath
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(reqBody))
 	if err != nil {
 		return err
 	}
@@ -82,7 +328,7 @@ func (w *AlertManager) Send(ctx context.Context, ev *kube.EnhancedEvent) error {
 		req.Header.Add(k, v)
 	}
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := w.client.Do(req)
 	if err != nil {
 		return err
 	}
@@ -93,10 +339,5 @@ func (w *AlertManager) Send(ctx context.Context, ev *kube.EnhancedEvent) error {
 		return err
 	}
 
-	if resp.StatusCode != 200 && resp.StatusCode != 201 {
-		klog.Errorf("not 200/201 body: %s", string(body))
-		return errors.New("not 200/201: " + string(body))
-	}
-
-	return nil
+	return checkResponse(resp, body)
 }
@@ -0,0 +1,200 @@
+package sinks
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"gitlab.dmall.com/arch/sym-admin/pkg/controllers/eventexporter/kube"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// fakeAlertManager keeps the latest alert of each fingerprint like the Alertmanager.
+type fakeAlertManager struct {
+	mu     sync.Mutex
+	alerts map[model.Fingerprint]*model.Alert
+	posts  int
+}
+
+func newFakeAlertManager() (*fakeAlertManager, *httptest.Server) {
+	am := &fakeAlertManager{alerts: map[model.Fingerprint]*model.Alert{}}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != alertManagerPushPath {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		var alerts []*model.Alert
+		if err := json.NewDecoder(r.Body).Decode(&alerts); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		am.mu.Lock()
+		defer am.mu.Unlock()
+		am.posts++
+		for _, a := range alerts {
+			if err := a.Validate(); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			am.alerts[a.Fingerprint()] = a
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	return am, srv
+}
+
+func (am *fakeAlertManager) list() []*model.Alert {
+	am.mu.Lock()
+	defer am.mu.Unlock()
+	result := make([]*model.Alert, 0, len(am.alerts))
+	for _, a := range am.alerts {
+		result = append(result, a)
+	}
+	return result
+}
+
+func newAlertEvent(reason string, count int32, last time.Time) *kube.EnhancedEvent {
+	ev := newTestEvent("bbcc-7d9f.16", last)
+	ev.ClusterName = "tcc-gz01"
+	ev.Reason = reason
+	ev.Count = count
+	ev.Message = reason + " message"
+	ev.FirstTimestamp = metav1.NewTime(last.Add(-10 * time.Minute))
+	ev.InvolvedObject.Kind = "Pod"
+	ev.InvolvedObject.Name = "bbcc-7d9f-x2k4q"
+	ev.InvolvedObject.Namespace = "default"
+	ev.InvolvedObject.Labels = map[string]string{"app": "bbcc"}
+	ev.Context = &kube.EventContext{PodSet: "bbcc-gz01b-blue", Owner: "team-a"}
+	return ev
+}
+
+func TestAlertManagerSend(t *testing.T) {
+	am, srv := newFakeAlertManager()
+	defer srv.Close()
+
+	sink, err := NewAlertManager(&AlertManagerConfig{
+		Endpoint: srv.URL,
+		Labels: map[string]string{
+			"team":      `{{ .Context.Owner }}`,
+			"component": "",
+		},
+		Annotations: map[string]string{
+			"runbook": `https://runbook/{{ .Reason }}`,
+		},
+		Severities: []SeverityMapping{
+			{Reason: "^OOMKill", Severity: "critical"},
+			{Reason: "BackOff", Kind: "Pod", Severity: "warning"},
+		},
+		DefaultSeverity: "info",
+		ResolveTimeout:  10 * time.Minute,
+		GroupBy:         []string{"cluster", "podset"},
+	})
+	if err != nil {
+		t.Fatalf("NewAlertManager() error = %v", err)
+	}
+
+	last := time.Now().Truncate(time.Second)
+	evs := []*kube.EnhancedEvent{
+		newAlertEvent("OOMKilling", 1, last),
+		newAlertEvent("BackOff", 3, last),
+		newAlertEvent("Pulled", 1, last),
+	}
+	if err := sink.(*AlertManager).SendBatch(context.TODO(), evs); err != nil {
+		t.Fatalf("SendBatch() error = %v", err)
+	}
+
+	alerts := am.list()
+	if len(alerts) != 3 {
+		t.Fatalf("expect 3 alerts, current: %d", len(alerts))
+	}
+	severities := map[string]string{}
+	for _, a := range alerts {
+		severities[string(a.Labels["reason"])] = string(a.Labels["severity"])
+
+		if a.Labels["team"] != "team-a" || a.Labels["podset"] != "bbcc-gz01b-blue" {
+			t.Errorf("expect the templated and context labels, current: %v", a.Labels)
+		}
+		if _, ok := a.Labels["component"]; ok {
+			t.Errorf("expect the empty label removed, current: %v", a.Labels)
+		}
+		if _, ok := a.Labels["count"]; ok {
+			t.Errorf("expect the count in the annotations, current: %v", a.Labels)
+		}
+		if a.Labels[alertGroupLabel] != "tcc-gz01/bbcc-gz01b-blue" {
+			t.Errorf("expect the group hint, current: %s", a.Labels[alertGroupLabel])
+		}
+		if a.Annotations["runbook"] != model.LabelValue("https://runbook/"+string(a.Labels["reason"])) {
+			t.Errorf("expect the templated annotation, current: %v", a.Annotations)
+		}
+		if !a.StartsAt.Equal(last.Add(-10*time.Minute)) || !a.EndsAt.Equal(last.Add(10*time.Minute)) {
+			t.Errorf("expect startsAt %v endsAt %v, current: %v %v", last.Add(-10*time.Minute), last.Add(10*time.Minute), a.StartsAt, a.EndsAt)
+		}
+		if a.Resolved() {
+			t.Errorf("expect the alert of a recent event firing")
+		}
+	}
+	want := map[string]string{"OOMKilling": "critical", "BackOff": "warning", "Pulled": "info"}
+	for reason, severity := range want {
+		if severities[reason] != severity {
+			t.Errorf("reason %s expect severity %s, current: %s", reason, severity, severities[reason])
+		}
+	}
+}
+
+func TestAlertManagerResolve(t *testing.T) {
+	am, srv := newFakeAlertManager()
+	defer srv.Close()
+
+	sink, err := NewAlertManager(&AlertManagerConfig{Endpoint: srv.URL, ResolveTimeout: time.Minute})
+	if err != nil {
+		t.Fatalf("NewAlertManager() error = %v", err)
+	}
+
+	// the recurring event updates the same alert
+	old := time.Now().Add(-time.Hour)
+	if err := sink.Send(context.TODO(), newAlertEvent("BackOff", 1, old)); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	alerts := am.list()
+	if len(alerts) != 1 || !alerts[0].Resolved() {
+		t.Fatalf("expect the alert of a stopped event resolved, current: %v", alerts)
+	}
+	if alerts[0].Labels["severity"] != "warning" {
+		t.Errorf("expect the default severities, current: %s", alerts[0].Labels["severity"])
+	}
+
+	if err := sink.Send(context.TODO(), newAlertEvent("BackOff", 5, time.Now())); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	alerts = am.list()
+	if len(alerts) != 1 || alerts[0].Resolved() {
+		t.Fatalf("expect the recurring event to fire the same alert, current: %v", alerts)
+	}
+	if alerts[0].Annotations["count"] != "5" {
+		t.Errorf("expect the count updated, current: %s", alerts[0].Annotations["count"])
+	}
+}
+
+func TestAlertManagerValidate(t *testing.T) {
+	cases := []*AlertManagerConfig{
+		{},
+		{Endpoint: "http://am", Labels: map[string]string{"bad-name": "x"}},
+		{Endpoint: "http://am", Annotations: map[string]string{"summary": "{{ .Reason "}},
+		{Endpoint: "http://am", Severities: []SeverityMapping{{Reason: "("}}},
+		{Endpoint: "http://am", Severities: []SeverityMapping{{Reason: "OOM"}}},
+		{Endpoint: "http://am", ResolveTimeout: -time.Second},
+	}
+	for i, c := range cases {
+		if err := c.Validate(); err == nil {
+			t.Errorf("case %d expect error", i)
+		}
+	}
+	if err := (&AlertManagerConfig{Endpoint: "http://am"}).Validate(); err != nil {
+		t.Errorf("expect no error, current: %v", err)
+	}
+}
@@ -0,0 +1,169 @@
+package sinks
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"gitlab.dmall.com/arch/sym-admin/pkg/backoff"
+	"gitlab.dmall.com/arch/sym-admin/pkg/controllers/eventexporter/kube"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+const (
+	defaultQueueSize      = 1000
+	defaultBatchWait      = time.Second
+	defaultRetryInterval  = time.Second
+	defaultRetryMax       = 30 * time.Second
+	defaultRetryMaxTimes  = 5
+	defaultRetryMaxElapse = 2 * time.Minute
+)
+
+// BatchSink is a sink able to send a batch of events in one request.
+type BatchSink interface {
+	Sink
+	SendBatch(ctx context.Context, evs []*kube.EnhancedEvent) error
+}
+
+// RetryConfig is the exponential backoff of the failed sends.
+type RetryConfig struct {
+	Interval       time.Duration `json:"interval,omitempty" yaml:"interval"`
+	MaxInterval    time.Duration `json:"maxInterval,omitempty" yaml:"maxInterval"`
+	MaxElapsedTime time.Duration `json:"maxElapsedTime,omitempty" yaml:"maxElapsedTime"`
+	MaxRetries     int           `json:"maxRetries,omitempty" yaml:"maxRetries"`
+}
+
+// DeliveryConfig is the queue, batching and retry of a receiver.
+type DeliveryConfig struct {
+	QueueSize int           `json:"queueSize,omitempty" yaml:"queueSize"`
+	BatchSize int           `json:"batchSize,omitempty" yaml:"batchSize"`
+	BatchWait time.Duration `json:"batchWait,omitempty" yaml:"batchWait"`
+	Retry     *RetryConfig  `json:"retry,omitempty" yaml:"retry"`
+}
+
+// WithDefaults returns a copy of the config with the fields left empty defaulted.
+func (c *DeliveryConfig) WithDefaults() *DeliveryConfig {
+	out := &DeliveryConfig{}
+	if c != nil {
+		*out = *c
+	}
+	if out.QueueSize <= 0 {
+		out.QueueSize = defaultQueueSize
+	}
+	if out.BatchSize <= 0 {
+		out.BatchSize = 1
+	}
+	if out.BatchWait <= 0 {
+		out.BatchWait = defaultBatchWait
+	}
+
+	retry := &RetryConfig{}
+	if out.Retry != nil {
+		*retry = *out.Retry
+	}
+	if retry.Interval <= 0 {
+		retry.Interval = defaultRetryInterval
+	}
+	if retry.MaxInterval <= 0 {
+		retry.MaxInterval = defaultRetryMax
+	}
+	if retry.MaxElapsedTime <= 0 {
+		retry.MaxElapsedTime = defaultRetryMaxElapse
+	}
+	if retry.MaxRetries <= 0 {
+		retry.MaxRetries = defaultRetryMaxTimes
+	}
+	out.Retry = retry
+	return out
+}
+
+// Validate ...
+func (c *DeliveryConfig) Validate() error {
+	if c == nil {
+		return nil
+	}
+	if c.QueueSize < 0 || c.BatchSize < 0 || c.BatchWait < 0 {
+		return fmt.Errorf("queueSize, batchSize and batchWait must not be negative")
+	}
+	if c.BatchSize > c.WithDefaults().QueueSize {
+		return fmt.Errorf("batchSize: %d exceeds queueSize", c.BatchSize)
+	}
+	if r := c.Retry; r != nil && (r.Interval < 0 || r.MaxInterval < 0 || r.MaxElapsedTime < 0 || r.MaxRetries < 0) {
+		return fmt.Errorf("retry settings must not be negative")
+	}
+	return nil
+}
+
+// Retry calls the function with the exponential backoff of the config.
+func (c *RetryConfig) Retry(ctx context.Context, function func() error) error {
+	return backoff.RetryWithContext(ctx, function, backoff.NewExponentialBackoffPolicy(&backoff.ExponentialBackoffConfig{
+		Interval:       c.Interval,
+		MaxInterval:    c.MaxInterval,
+		MaxElapsedTime: c.MaxElapsedTime,
+		MaxRetries:     c.MaxRetries,
+	}))
+}
+
+// checkResponse returns an error for the non 2xx response, the client errors except
+// 429 are permanent since retrying the same request does not help.
+func checkResponse(resp *http.Response, body []byte) error {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+
+	err := fmt.Errorf("unexpected status: %d body: %s", resp.StatusCode, string(body))
+	if resp.StatusCode >= 400 && resp.StatusCode < 500 && resp.StatusCode != http.StatusTooManyRequests {
+		return backoff.MarkErrorPermanent(err)
+	}
+	return err
+}
+
+// DeadLetter writes the events that could not be delivered to a file.
+type DeadLetter struct {
+	mu      sync.Mutex
+	writer  *lumberjack.Logger
+	encoder *json.Encoder
+}
+
+type deadLetterEntry struct {
+	Time     time.Time           `json:"time"`
+	Receiver string              `json:"receiver"`
+	Reason   string              `json:"reason"`
+	Event    *kube.EnhancedEvent `json:"event"`
+}
+
+// NewDeadLetter ...
+func NewDeadLetter(config *FileConfig) *DeadLetter {
+	writer := &lumberjack.Logger{
+		Filename:   config.Path,
+		MaxSize:    config.MaxSize,
+		MaxBackups: config.MaxBackups,
+		MaxAge:     config.MaxAge,
+	}
+	return &DeadLetter{
+		writer:  writer,
+		encoder: json.NewEncoder(writer),
+	}
+}
+
+// Write records the event with the receiver and the reason it is not delivered.
+func (d *DeadLetter) Write(receiver, reason string, ev *kube.EnhancedEvent) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.encoder.Encode(&deadLetterEntry{
+		Time:     time.Now(),
+		Receiver: receiver,
+		Reason:   reason,
+		Event:    ev,
+	})
+}
+
+// Close ...
+func (d *DeadLetter) Close() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	_ = d.writer.Close()
+}
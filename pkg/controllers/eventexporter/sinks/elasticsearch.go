@@ -0,0 +1,173 @@
+package sinks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"gitlab.dmall.com/arch/sym-admin/pkg/controllers/eventexporter/kube"
+)
+
+const (
+	defaultElasticsearchIndex      = "sym-events"
+	defaultElasticsearchDateFormat = "2006.01.02"
+)
+
+// ElasticsearchConfig writes the events by the bulk api to the daily index: <index>-<date>.
+type ElasticsearchConfig struct {
+	Hosts      []string               `json:"hosts,omitempty" yaml:"hosts"`
+	Index      string                 `json:"index,omitempty" yaml:"index"`
+	DateFormat string                 `json:"dateFormat,omitempty" yaml:"dateFormat"`
+	Username   string                 `json:"username,omitempty" yaml:"username"`
+	Password   string                 `json:"password,omitempty" yaml:"password"`
+	Headers    map[string]string      `json:"headers,omitempty" yaml:"headers"`
+	Layout     map[string]interface{} `json:"layout,omitempty" yaml:"layout"`
+	TLS        *TLSConfig             `json:"tls,omitempty" yaml:"tls"`
+}
+
+func (c *ElasticsearchConfig) Validate() error {
+	if len(c.Hosts) == 0 {
+		return fmt.Errorf("elasticsearch hosts is empty")
+	}
+	return nil
+}
+
+type Elasticsearch struct {
+	cfg    *ElasticsearchConfig
+	client *http.Client
+}
+
+func NewElasticsearch(cfg *ElasticsearchConfig) (Sink, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	client, err := newHTTPClient(cfg.TLS)
+	if err != nil {
+		return nil, err
+	}
+	return &Elasticsearch{cfg: cfg, client: client}, nil
+}
+
+func (e *Elasticsearch) Close() {
+	// No-op
+}
+
+func (e *Elasticsearch) index(ev *kube.EnhancedEvent) string {
+	index, format := e.cfg.Index, e.cfg.DateFormat
+	if index == "" {
+		index = defaultElasticsearchIndex
+	}
+	if format == "" {
+		format = defaultElasticsearchDateFormat
+	}
+	return index + "-" + ev.GetTimestamp().UTC().Format(format)
+}
+
+func (e *Elasticsearch) Send(ctx context.Context, ev *kube.EnhancedEvent) error {
+	return e.SendBatch(ctx, []*kube.EnhancedEvent{ev})
+}
+
+type bulkAction struct {
+	Index bulkIndex `json:"index"`
+}
+
+type bulkIndex struct {
+	Index string `json:"_index"`
+	ID    string `json:"_id,omitempty"`
+}
+
+type bulkResponse struct {
+	Errors bool `json:"errors"`
+	Items  []map[string]struct {
+		Status int             `json:"status"`
+		Error  json.RawMessage `json:"error"`
+	} `json:"items"`
+}
+
+// SendBatch writes the events by one bulk request, the document id is derived from the
+// event so that a retried request does not duplicate the documents.
+func (e *Elasticsearch) SendBatch(ctx context.Context, evs []*kube.EnhancedEvent) error {
+	buf := &bytes.Buffer{}
+	for _, ev := range evs {
+		doc, err := serializeEventWithLayout(e.cfg.Layout, ev)
+		if err != nil {
+			return err
+		}
+
+		action := bulkAction{Index: bulkIndex{Index: e.index(ev)}}
+		if ev.UID != "" {
+			action.Index.ID = fmt.Sprintf("%s-%s", ev.UID, ev.ResourceVersion)
+		}
+		meta, err := json.Marshal(action)
+		if err != nil {
+			return err
+		}
+		buf.Write(meta)
+		buf.WriteByte('\n')
+		buf.Write(doc)
+		buf.WriteByte('\n')
+	}
+
+	var lastErr error
+	for _, host := range e.cfg.Hosts {
+		lastErr = e.bulk(ctx, host, buf.Bytes())
+		if lastErr == nil {
+			return nil
+		}
+	}
+	return lastErr
+}
+
+func (e *Elasticsearch) bulk(ctx context.Context, host string, body []byte) error {
+	url := strings.TrimSuffix(host, "/") + "/_bulk"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	for k, v := range e.cfg.Headers {
+		req.Header.Add(k, v)
+	}
+	if e.cfg.Username != "" {
+		req.SetBasicAuth(e.cfg.Username, e.cfg.Password)
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if err := checkResponse(resp, respBody); err != nil {
+		return err
+	}
+
+	result := &bulkResponse{}
+	if err := json.Unmarshal(respBody, result); err != nil {
+		return fmt.Errorf("decode bulk response err: %v", err)
+	}
+	if !result.Errors {
+		return nil
+	}
+
+	var failed int
+	var first string
+	for _, item := range result.Items {
+		for _, v := range item {
+			if v.Status >= 300 {
+				failed++
+				if first == "" {
+					first = string(v.Error)
+				}
+			}
+		}
+	}
+	return fmt.Errorf("bulk %d of %d documents failed, first error: %s", failed, len(result.Items), first)
+}
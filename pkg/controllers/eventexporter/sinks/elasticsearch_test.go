@@ -0,0 +1,97 @@
+package sinks
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"gitlab.dmall.com/arch/sym-admin/pkg/controllers/eventexporter/kube"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func newTestEvent(name string, ts time.Time) *kube.EnhancedEvent {
+	ev := &kube.EnhancedEvent{
+		Event: corev1.Event{
+			ObjectMeta:    metav1.ObjectMeta{Name: name, Namespace: "default", UID: types.UID("uid-" + name), ResourceVersion: "1"},
+			Reason:        "BackOff",
+			Message:       "Back-off restarting failed container",
+			LastTimestamp: metav1.NewTime(ts),
+		},
+		ClusterName: "tcc-gz01",
+	}
+	ev.InvolvedObject.Kind = "Pod"
+	ev.InvolvedObject.Name = "bbcc-gz01a-0"
+	ev.InvolvedObject.Namespace = "default"
+	ev.InvolvedObject.Labels = map[string]string{"app": "bbcc"}
+	return ev
+}
+
+func TestElasticsearchSendBatch(t *testing.T) {
+	var lines []map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/_bulk" || r.Header.Get("Content-Type") != "application/x-ndjson" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		scanner := bufio.NewScanner(r.Body)
+		for scanner.Scan() {
+			m := map[string]interface{}{}
+			if err := json.Unmarshal(scanner.Bytes(), &m); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			lines = append(lines, m)
+		}
+		_, _ = w.Write([]byte(`{"errors":false,"items":[]}`))
+	}))
+	defer srv.Close()
+
+	// the unreachable host is skipped
+	sink, err := NewElasticsearch(&ElasticsearchConfig{
+		Hosts:  []string{"http://127.0.0.1:1", srv.URL},
+		Layout: map[string]interface{}{"reason": "{{ .Reason }}", "cluster": "{{ .ClusterName }}"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := time.Date(2020, 7, 1, 10, 0, 0, 0, time.UTC)
+	err = sink.(BatchSink).SendBatch(context.TODO(), []*kube.EnhancedEvent{
+		newTestEvent("a", ts), newTestEvent("b", ts.Add(24*time.Hour)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(lines) != 4 {
+		t.Fatalf("expect 4 bulk lines, current: %d", len(lines))
+	}
+	action := lines[0]["index"].(map[string]interface{})
+	if action["_index"] != "sym-events-2020.07.01" || action["_id"] != "uid-a-1" {
+		t.Errorf("unexpected action: %v", action)
+	}
+	if lines[1]["reason"] != "BackOff" || lines[1]["cluster"] != "tcc-gz01" {
+		t.Errorf("unexpected document: %v", lines[1])
+	}
+	if lines[2]["index"].(map[string]interface{})["_index"] != "sym-events-2020.07.02" {
+		t.Errorf("unexpected action: %v", lines[2])
+	}
+}
+
+func TestElasticsearchBulkErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"errors":true,"items":[{"index":{"status":201}},{"index":{"status":400,"error":{"type":"mapper_parsing_exception"}}}]}`))
+	}))
+	defer srv.Close()
+
+	sink, _ := NewElasticsearch(&ElasticsearchConfig{Hosts: []string{srv.URL}})
+	if err := sink.Send(context.TODO(), newTestEvent("a", time.Now())); err == nil {
+		t.Error("expect the error of the failed documents")
+	}
+}
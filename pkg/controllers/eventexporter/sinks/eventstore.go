@@ -0,0 +1,68 @@
+package sinks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+
+	"gitlab.dmall.com/arch/sym-admin/pkg/controllers/eventexporter/kube"
+)
+
+// EventStoreConfig pushes the events to the event store of the api, the endpoint is
+// the url of the api such as http://sym-api/api/v2/events.
+type EventStoreConfig struct {
+	Endpoint string            `json:"endpoint,omitempty" yaml:"endpoint"`
+	Headers  map[string]string `json:"headers,omitempty" yaml:"headers"`
+	TLS      *TLSConfig        `json:"tls,omitempty" yaml:"tls"`
+}
+
+type EventStore struct {
+	cfg    *EventStoreConfig
+	client *http.Client
+}
+
+func NewEventStore(cfg *EventStoreConfig) (Sink, error) {
+	client, err := newHTTPClient(cfg.TLS)
+	if err != nil {
+		return nil, err
+	}
+	return &EventStore{cfg: cfg, client: client}, nil
+}
+
+func (e *EventStore) Close() {
+	// No-op
+}
+
+func (e *EventStore) Send(ctx context.Context, ev *kube.EnhancedEvent) error {
+	return e.SendBatch(ctx, []*kube.EnhancedEvent{ev})
+}
+
+// SendBatch posts the events as a json array.
+func (e *EventStore) SendBatch(ctx context.Context, evs []*kube.EnhancedEvent) error {
+	body, err := json.Marshal(evs)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.cfg.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range e.cfg.Headers {
+		req.Header.Add(k, v)
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	return checkResponse(resp, respBody)
+}
@@ -58,3 +58,13 @@ func (f *File) Send(ctx context.Context, ev *kube.EnhancedEvent) error {
 
 	return f.encoder.Encode(res)
 }
+
+// SendBatch writes the events in order.
+func (f *File) SendBatch(ctx context.Context, evs []*kube.EnhancedEvent) error {
+	for _, ev := range evs {
+		if err := f.Send(ctx, ev); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+package sinks
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/sasl"
+	"github.com/segmentio/kafka-go/sasl/plain"
+	"github.com/segmentio/kafka-go/sasl/scram"
+	"gitlab.dmall.com/arch/sym-admin/pkg/controllers/eventexporter/kube"
+	pkgLabels "gitlab.dmall.com/arch/sym-admin/pkg/labels"
+)
+
+// the message keys of the kafka sink, the events of the same key go to the same partition
+const (
+	KafkaKeyByCluster    = "cluster"
+	KafkaKeyByApp        = "app"
+	KafkaKeyByClusterApp = "cluster/app"
+)
+
+type KafkaConfig struct {
+	Brokers []string               `json:"brokers,omitempty" yaml:"brokers"`
+	Topic   string                 `json:"topic,omitempty" yaml:"topic"`
+	KeyBy   string                 `json:"keyBy,omitempty" yaml:"keyBy"`
+	Layout  map[string]interface{} `json:"layout,omitempty" yaml:"layout"`
+	TLS     *TLSConfig             `json:"tls,omitempty" yaml:"tls"`
+	SASL    *KafkaSASLConfig       `json:"sasl,omitempty" yaml:"sasl"`
+}
+
+// KafkaSASLConfig is the sasl authentication, the mechanism is plain, scram-sha-256 or scram-sha-512.
+type KafkaSASLConfig struct {
+	Mechanism string `json:"mechanism,omitempty" yaml:"mechanism"`
+	Username  string `json:"username,omitempty" yaml:"username"`
+	Password  string `json:"password,omitempty" yaml:"password"`
+}
+
+func (c *KafkaConfig) Validate() error {
+	if len(c.Brokers) == 0 || c.Topic == "" {
+		return fmt.Errorf("kafka brokers or topic is empty")
+	}
+	switch c.KeyBy {
+	case "", KafkaKeyByCluster, KafkaKeyByApp, KafkaKeyByClusterApp:
+	default:
+		return fmt.Errorf("unknown kafka keyBy: %s", c.KeyBy)
+	}
+	if c.SASL != nil {
+		if _, err := c.SASL.mechanism(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *KafkaSASLConfig) mechanism() (sasl.Mechanism, error) {
+	switch strings.ToLower(c.Mechanism) {
+	case "", "plain":
+		return plain.Mechanism{Username: c.Username, Password: c.Password}, nil
+	case "scram-sha-256":
+		return scram.Mechanism(scram.SHA256, c.Username, c.Password)
+	case "scram-sha-512":
+		return scram.Mechanism(scram.SHA512, c.Username, c.Password)
+	default:
+		return nil, fmt.Errorf("unknown kafka sasl mechanism: %s", c.Mechanism)
+	}
+}
+
+// kafkaBatchTimeout is how long the writer waits for more messages of a partition, a batch
+// of the delivery is written at once, so the writer should not wait the default 1s for each.
+const kafkaBatchTimeout = 5 * time.Millisecond
+
+// kafkaWriter is implemented by kafka.Writer.
+type kafkaWriter interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+	Close() error
+}
+
+type Kafka struct {
+	cfg    *KafkaConfig
+	writer kafkaWriter
+}
+
+func NewKafka(cfg *KafkaConfig) (Sink, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	tlsCfg, err := newTLSConfig(cfg.TLS)
+	if err != nil {
+		return nil, err
+	}
+	transport := &kafka.Transport{TLS: tlsCfg}
+	if cfg.SASL != nil {
+		if transport.SASL, err = cfg.SASL.mechanism(); err != nil {
+			return nil, err
+		}
+	}
+
+	return &Kafka{
+		cfg: cfg,
+		writer: &kafka.Writer{
+			Addr:         kafka.TCP(cfg.Brokers...),
+			Topic:        cfg.Topic,
+			Balancer:     &kafka.Hash{},
+			Transport:    transport,
+			BatchTimeout: kafkaBatchTimeout,
+			// the retries are done by the delivery of the receiver
+			MaxAttempts: 1,
+		},
+	}, nil
+}
+
+func (k *Kafka) Close() {
+	_ = k.writer.Close()
+}
+
+func (k *Kafka) key(ev *kube.EnhancedEvent) string {
+	app := ev.InvolvedObject.Labels[pkgLabels.ObserveMustLabelAppName]
+	switch k.cfg.KeyBy {
+	case KafkaKeyByCluster:
+		return ev.ClusterName
+	case KafkaKeyByApp:
+		return app
+	default:
+		return ev.ClusterName + "/" + app
+	}
+}
+
+func (k *Kafka) Send(ctx context.Context, ev *kube.EnhancedEvent) error {
+	return k.SendBatch(ctx, []*kube.EnhancedEvent{ev})
+}
+
+// SendBatch writes the events in one produce request.
+func (k *Kafka) SendBatch(ctx context.Context, evs []*kube.EnhancedEvent) error {
+	msgs := make([]kafka.Message, 0, len(evs))
+	for _, ev := range evs {
+		value, err := serializeEventWithLayout(k.cfg.Layout, ev)
+		if err != nil {
+			return err
+		}
+		msgs = append(msgs, kafka.Message{
+			Key:   []byte(k.key(ev)),
+			Value: value,
+		})
+	}
+	return k.writer.WriteMessages(ctx, msgs...)
+}
@@ -0,0 +1,120 @@
+package sinks
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	metadataAPI "github.com/segmentio/kafka-go/protocol/metadata"
+	produceAPI "github.com/segmentio/kafka-go/protocol/produce"
+	"gitlab.dmall.com/arch/sym-admin/pkg/controllers/eventexporter/kube"
+)
+
+type fakeKafkaWriter struct {
+	msgs []kafka.Message
+}
+
+func (f *fakeKafkaWriter) WriteMessages(ctx context.Context, msgs ...kafka.Message) error {
+	f.msgs = append(f.msgs, msgs...)
+	return nil
+}
+
+func (f *fakeKafkaWriter) Close() error {
+	return nil
+}
+
+func TestKafkaSendBatch(t *testing.T) {
+	cases := []struct {
+		keyBy string
+		key   string
+	}{
+		{"", "tcc-gz01/bbcc"},
+		{KafkaKeyByCluster, "tcc-gz01"},
+		{KafkaKeyByApp, "bbcc"},
+	}
+
+	for _, c := range cases {
+		cfg := &KafkaConfig{
+			Brokers: []string{"127.0.0.1:9092"},
+			Topic:   "sym-events",
+			KeyBy:   c.keyBy,
+			Layout:  map[string]interface{}{"reason": "{{ .Reason }}"},
+			SASL:    &KafkaSASLConfig{Mechanism: "scram-sha-512", Username: "sym", Password: "sym"},
+		}
+		sink, err := NewKafka(cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		writer := &fakeKafkaWriter{}
+		sink.(*Kafka).writer = writer
+
+		err = sink.(BatchSink).SendBatch(context.TODO(), []*kube.EnhancedEvent{
+			newTestEvent("a", time.Now()), newTestEvent("b", time.Now()),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(writer.msgs) != 2 {
+			t.Fatalf("expect 2 messages, current: %d", len(writer.msgs))
+		}
+		if string(writer.msgs[0].Key) != c.key || string(writer.msgs[0].Value) != `{"reason":"BackOff"}` {
+			t.Errorf("keyBy: %q unexpected message: %s %s", c.keyBy, writer.msgs[0].Key, writer.msgs[0].Value)
+		}
+	}
+
+	if err := (&KafkaConfig{Brokers: []string{"a"}, Topic: "t", SASL: &KafkaSASLConfig{Mechanism: "gssapi"}}).Validate(); err == nil {
+		t.Error("expect the error of the unknown mechanism")
+	}
+}
+
+// fakeKafkaTransport is a broker of a topic with one partition.
+type fakeKafkaTransport struct {
+	mu       sync.Mutex
+	produces int
+}
+
+func (f *fakeKafkaTransport) RoundTrip(ctx context.Context, addr net.Addr, req kafka.Request) (kafka.Response, error) {
+	switch r := req.(type) {
+	case *metadataAPI.Request:
+		return &metadataAPI.Response{
+			Topics: []metadataAPI.ResponseTopic{{Name: r.TopicNames[0], Partitions: []metadataAPI.ResponsePartition{{PartitionIndex: 0}}}},
+		}, nil
+	case *produceAPI.Request:
+		f.mu.Lock()
+		f.produces++
+		f.mu.Unlock()
+		return &produceAPI.Response{
+			Topics: []produceAPI.ResponseTopic{{Topic: r.Topics[0].Topic, Partitions: []produceAPI.ResponsePartition{{Partition: 0}}}},
+		}, nil
+	}
+	return nil, fmt.Errorf("unexpected request: %T", req)
+}
+
+func TestKafkaWriterBatch(t *testing.T) {
+	sink, err := NewKafka(&KafkaConfig{Brokers: []string{"127.0.0.1:9092"}, Topic: "sym-events"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+	transport := &fakeKafkaTransport{}
+	sink.(*Kafka).writer.(*kafka.Writer).Transport = transport
+
+	// the batch of the delivery is written in one produce request without waiting for more
+	start := time.Now()
+	err = sink.(BatchSink).SendBatch(context.TODO(), []*kube.EnhancedEvent{
+		newTestEvent("a", time.Now()), newTestEvent("b", time.Now()), newTestEvent("c", time.Now()),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("expect the batch written at once, elapsed: %v", elapsed)
+	}
+	if transport.produces != 1 {
+		t.Errorf("expect 1 produce request, current: %d", transport.produces)
+	}
+}
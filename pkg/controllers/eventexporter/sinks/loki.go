@@ -0,0 +1,187 @@
+package sinks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gitlab.dmall.com/arch/sym-admin/pkg/controllers/eventexporter/kube"
+	pkgLabels "gitlab.dmall.com/arch/sym-admin/pkg/labels"
+)
+
+const lokiPushPath = "/loki/api/v1/push"
+
+var lokiLabelNameRegexp = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// LokiConfig pushes the events to loki, the streams are labeled by the cluster, app and
+// namespace of the event, the extra labels are rendered by the layout templating.
+type LokiConfig struct {
+	Endpoint string                 `json:"endpoint,omitempty" yaml:"endpoint"`
+	TenantID string                 `json:"tenantID,omitempty" yaml:"tenantID"`
+	Username string                 `json:"username,omitempty" yaml:"username"`
+	Password string                 `json:"password,omitempty" yaml:"password"`
+	Labels   map[string]string      `json:"labels,omitempty" yaml:"labels"`
+	Headers  map[string]string      `json:"headers,omitempty" yaml:"headers"`
+	Layout   map[string]interface{} `json:"layout,omitempty" yaml:"layout"`
+	TLS      *TLSConfig             `json:"tls,omitempty" yaml:"tls"`
+}
+
+func (c *LokiConfig) Validate() error {
+	if c.Endpoint == "" {
+		return fmt.Errorf("loki endpoint is empty")
+	}
+	for k := range c.Labels {
+		if !lokiLabelNameRegexp.MatchString(k) {
+			return fmt.Errorf("invalid loki label name: %s", k)
+		}
+	}
+	return nil
+}
+
+type Loki struct {
+	cfg    *LokiConfig
+	client *http.Client
+}
+
+func NewLoki(cfg *LokiConfig) (Sink, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	client, err := newHTTPClient(cfg.TLS)
+	if err != nil {
+		return nil, err
+	}
+	return &Loki{cfg: cfg, client: client}, nil
+}
+
+func (l *Loki) Close() {
+	// No-op
+}
+
+type lokiStream struct {
+	Stream map[string]string `json:"stream"`
+	Values [][2]string       `json:"values"`
+}
+
+type lokiPush struct {
+	Streams []*lokiStream `json:"streams"`
+}
+
+func (l *Loki) labels(ev *kube.EnhancedEvent) (map[string]string, error) {
+	lb := map[string]string{
+		"job":       "sym-event-exporter",
+		"cluster":   ev.ClusterName,
+		"namespace": ev.InvolvedObject.Namespace,
+		"app":       ev.InvolvedObject.Labels[pkgLabels.ObserveMustLabelAppName],
+	}
+	for k, v := range l.cfg.Labels {
+		va, err := GetString(ev, v)
+		if err != nil {
+			return nil, err
+		}
+		lb[k] = va
+	}
+
+	// loki rejects the empty label values
+	for k, v := range lb {
+		if v == "" {
+			delete(lb, k)
+		}
+	}
+	return lb, nil
+}
+
+func labelsKey(lb map[string]string) string {
+	keys := make([]string, 0, len(lb))
+	for k := range lb {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	for _, k := range keys {
+		b.WriteString(k)
+		b.WriteByte('=')
+		b.WriteString(lb[k])
+		b.WriteByte(',')
+	}
+	return b.String()
+}
+
+func (l *Loki) Send(ctx context.Context, ev *kube.EnhancedEvent) error {
+	return l.SendBatch(ctx, []*kube.EnhancedEvent{ev})
+}
+
+// SendBatch pushes the events grouped into streams by the labels.
+func (l *Loki) SendBatch(ctx context.Context, evs []*kube.EnhancedEvent) error {
+	push := &lokiPush{}
+	streams := make(map[string]*lokiStream)
+	for _, ev := range evs {
+		lb, err := l.labels(ev)
+		if err != nil {
+			return err
+		}
+		line, err := serializeEventWithLayout(l.cfg.Layout, ev)
+		if err != nil {
+			return err
+		}
+
+		key := labelsKey(lb)
+		s, ok := streams[key]
+		if !ok {
+			s = &lokiStream{Stream: lb}
+			streams[key] = s
+			push.Streams = append(push.Streams, s)
+		}
+		ts := strconv.FormatInt(ev.GetTimestamp().UnixNano(), 10)
+		s.Values = append(s.Values, [2]string{ts, string(line)})
+	}
+
+	// loki requires the entries of a stream in order
+	for _, s := range push.Streams {
+		sort.SliceStable(s.Values, func(i, j int) bool {
+			a, _ := strconv.ParseInt(s.Values[i][0], 10, 64)
+			b, _ := strconv.ParseInt(s.Values[j][0], 10, 64)
+			return a < b
+		})
+	}
+
+	body, err := json.Marshal(push)
+	if err != nil {
+		return err
+	}
+
+	url := strings.TrimSuffix(l.cfg.Endpoint, "/") + lokiPushPath
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range l.cfg.Headers {
+		req.Header.Add(k, v)
+	}
+	if l.cfg.TenantID != "" {
+		req.Header.Set("X-Scope-OrgID", l.cfg.TenantID)
+	}
+	if l.cfg.Username != "" {
+		req.SetBasicAuth(l.cfg.Username, l.cfg.Password)
+	}
+
+	resp, err := l.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	return checkResponse(resp, respBody)
+}
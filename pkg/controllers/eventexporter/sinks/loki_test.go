@@ -0,0 +1,72 @@
+package sinks
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"gitlab.dmall.com/arch/sym-admin/pkg/controllers/eventexporter/kube"
+)
+
+func TestLokiSendBatch(t *testing.T) {
+	push := &lokiPush{}
+	var tenant string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != lokiPushPath {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		tenant = r.Header.Get("X-Scope-OrgID")
+		if err := json.NewDecoder(r.Body).Decode(push); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	sink, err := NewLoki(&LokiConfig{
+		Endpoint: srv.URL,
+		TenantID: "sym",
+		Labels:   map[string]string{"reason": "{{ .Reason }}"},
+		Layout:   map[string]interface{}{"message": "{{ .Message }}"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := time.Now()
+	other := newTestEvent("c", ts)
+	other.InvolvedObject.Labels["app"] = "aabb"
+	err = sink.(BatchSink).SendBatch(context.TODO(), []*kube.EnhancedEvent{
+		newTestEvent("a", ts.Add(time.Second)), other, newTestEvent("b", ts),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if tenant != "sym" {
+		t.Errorf("expect tenant sym, current: %s", tenant)
+	}
+	if len(push.Streams) != 2 {
+		t.Fatalf("expect 2 streams, current: %d", len(push.Streams))
+	}
+	s := push.Streams[0]
+	if s.Stream["app"] != "bbcc" || s.Stream["cluster"] != "tcc-gz01" || s.Stream["namespace"] != "default" || s.Stream["reason"] != "BackOff" {
+		t.Errorf("unexpected labels: %v", s.Stream)
+	}
+	if len(s.Values) != 2 || s.Values[0][0] != strconv.FormatInt(ts.UnixNano(), 10) {
+		t.Errorf("expect the entries in order, current: %v", s.Values)
+	}
+	if s.Values[0][1] != `{"message":"Back-off restarting failed container"}` {
+		t.Errorf("unexpected line: %s", s.Values[0][1])
+	}
+
+	if _, err := NewLoki(&LokiConfig{Endpoint: srv.URL, Labels: map[string]string{"bad-name": "x"}}); err == nil {
+		t.Error("expect the error of the invalid label name")
+	}
+}
@@ -2,18 +2,88 @@ package sinks
 
 import (
 	"errors"
+	"fmt"
 )
 
 // Receiver allows receiving
 type ReceiverConfig struct {
-	Name         string              `json:"name,omitempty" yaml:"name"`
-	InMemory     *InMemoryConfig     `json:"inMemory,omitempty" yaml:"inMemory"`
-	File         *FileConfig         `json:"file,omitempty" yaml:"file"`
-	Webhook      *WebhookConfig      `json:"webhook,omitempty" yaml:"webhook"`
-	AlertManager *AlertManagerConfig `json:"alertManager,omitempty" yaml:"alertManager"`
+	Name          string               `json:"name,omitempty" yaml:"name"`
+	InMemory      *InMemoryConfig      `json:"inMemory,omitempty" yaml:"inMemory"`
+	File          *FileConfig          `json:"file,omitempty" yaml:"file"`
+	Webhook       *WebhookConfig       `json:"webhook,omitempty" yaml:"webhook"`
+	AlertManager  *AlertManagerConfig  `json:"alertManager,omitempty" yaml:"alertManager"`
+	Kafka         *KafkaConfig         `json:"kafka,omitempty" yaml:"kafka"`
+	Elasticsearch *ElasticsearchConfig `json:"elasticsearch,omitempty" yaml:"elasticsearch"`
+	Loki          *LokiConfig          `json:"loki,omitempty" yaml:"loki"`
+	EventStore    *EventStoreConfig    `json:"eventStore,omitempty" yaml:"eventStore"`
+	Action        *ActionConfig        `json:"action,omitempty" yaml:"action"`
+	Delivery      *DeliveryConfig      `json:"delivery,omitempty" yaml:"delivery"`
 }
 
 func (r *ReceiverConfig) Validate() error {
+	if r.Name == "" {
+		return errors.New("name is empty")
+	}
+
+	var count int
+	if r.InMemory != nil {
+		count++
+	}
+	if r.File != nil {
+		count++
+		if r.File.Path == "" {
+			return fmt.Errorf("receiver: %s file path is empty", r.Name)
+		}
+	}
+	if r.Webhook != nil {
+		count++
+		if r.Webhook.Endpoint == "" {
+			return fmt.Errorf("receiver: %s webhook endpoint is empty", r.Name)
+		}
+	}
+	if r.AlertManager != nil {
+		count++
+		if err := r.AlertManager.Validate(); err != nil {
+			return fmt.Errorf("receiver: %s %v", r.Name, err)
+		}
+	}
+	if r.Kafka != nil {
+		count++
+		if err := r.Kafka.Validate(); err != nil {
+			return fmt.Errorf("receiver: %s %v", r.Name, err)
+		}
+	}
+	if r.Elasticsearch != nil {
+		count++
+		if err := r.Elasticsearch.Validate(); err != nil {
+			return fmt.Errorf("receiver: %s %v", r.Name, err)
+		}
+	}
+	if r.Loki != nil {
+		count++
+		if err := r.Loki.Validate(); err != nil {
+			return fmt.Errorf("receiver: %s %v", r.Name, err)
+		}
+	}
+	if r.EventStore != nil {
+		count++
+		if r.EventStore.Endpoint == "" {
+			return fmt.Errorf("receiver: %s eventStore endpoint is empty", r.Name)
+		}
+	}
+	if r.Action != nil {
+		count++
+		if err := r.Action.Validate(); err != nil {
+			return fmt.Errorf("receiver: %s %v", r.Name, err)
+		}
+	}
+
+	if count != 1 {
+		return fmt.Errorf("receiver: %s must have exactly one sink, current: %d", r.Name, count)
+	}
+	if err := r.Delivery.Validate(); err != nil {
+		return fmt.Errorf("receiver: %s delivery: %v", r.Name, err)
+	}
 	return nil
 }
 
@@ -39,5 +109,25 @@ func (r *ReceiverConfig) GetSink() (Sink, error) {
 		return NewAlertManager(r.AlertManager)
 	}
 
+	if r.Kafka != nil {
+		return NewKafka(r.Kafka)
+	}
+
+	if r.Elasticsearch != nil {
+		return NewElasticsearch(r.Elasticsearch)
+	}
+
+	if r.Loki != nil {
+		return NewLoki(r.Loki)
+	}
+
+	if r.EventStore != nil {
+		return NewEventStore(r.EventStore)
+	}
+
+	if r.Action != nil {
+		return NewAction(r.Action)
+	}
+
 	return nil, errors.New("unknown sink")
 }
@@ -0,0 +1,65 @@
+package sinks
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+const defaultHTTPTimeout = 30 * time.Second
+
+// TLSConfig is the client tls of a sink, the files are mounted from a secret.
+type TLSConfig struct {
+	CAFile             string `json:"caFile,omitempty" yaml:"caFile"`
+	CertFile           string `json:"certFile,omitempty" yaml:"certFile"`
+	KeyFile            string `json:"keyFile,omitempty" yaml:"keyFile"`
+	ServerName         string `json:"serverName,omitempty" yaml:"serverName"`
+	InsecureSkipVerify bool   `json:"insecureSkipVerify,omitempty" yaml:"insecureSkipVerify"`
+}
+
+func newTLSConfig(c *TLSConfig) (*tls.Config, error) {
+	if c == nil {
+		return nil, nil
+	}
+
+	cfg := &tls.Config{
+		ServerName:         c.ServerName,
+		InsecureSkipVerify: c.InsecureSkipVerify,
+	}
+	if c.CAFile != "" {
+		ca, err := ioutil.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("read ca file: %s err: %v", c.CAFile, err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("no certificate found in ca file: %s", c.CAFile)
+		}
+		cfg.RootCAs = pool
+	}
+	if c.CertFile != "" || c.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("load client certificate err: %v", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil
+}
+
+func newHTTPClient(c *TLSConfig) (*http.Client, error) {
+	tlsCfg, err := newTLSConfig(c)
+	if err != nil {
+		return nil, err
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsCfg
+	return &http.Client{
+		Transport: transport,
+		Timeout:   defaultHTTPTimeout,
+	}, nil
+}
@@ -25,6 +25,12 @@ func GetString(event *kube.EnhancedEvent, text string) (string, error) {
 	return buf.String(), nil
 }
 
+// validateTemplate parses the text, GetString renders an invalid template as empty.
+func validateTemplate(text string) error {
+	_, err := template.New("template").Funcs(sprig.TxtFuncMap()).Parse(text)
+	return err
+}
+
 func convertLayoutTemplate(layout map[string]interface{}, ev *kube.EnhancedEvent) (map[string]interface{}, error) {
 	result := make(map[string]interface{})
 
@@ -3,7 +3,6 @@ package sinks
 import (
 	"bytes"
 	"context"
-	"errors"
 	"io/ioutil"
 	"net/http"
 
@@ -34,7 +33,7 @@ func (w *Webhook) Send(ctx context.Context, ev *kube.EnhancedEvent) error {
 		return err
 	}
 
-	req, err := http.NewRequest(http.MethodPost, w.cfg.Endpoint, bytes.NewReader(reqBody))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.cfg.Endpoint, bytes.NewReader(reqBody))
 	if err != nil {
 		return err
 	}
@@ -54,9 +53,5 @@ func (w *Webhook) Send(ctx context.Context, ev *kube.EnhancedEvent) error {
 		return err
 	}
 
-	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
-		return errors.New("not 200/201: " + string(body))
-	}
-
-	return nil
+	return checkResponse(resp, body)
 }
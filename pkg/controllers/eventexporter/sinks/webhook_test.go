@@ -0,0 +1,54 @@
+package sinks
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	lbackoff "github.com/lestrrat-go/backoff"
+	"gitlab.dmall.com/arch/sym-admin/pkg/backoff"
+	"gitlab.dmall.com/arch/sym-admin/pkg/controllers/eventexporter/kube"
+)
+
+func TestWebhookSend(t *testing.T) {
+	cases := []struct {
+		code        int
+		isErr       bool
+		isPermanent bool
+	}{
+		{http.StatusOK, false, false},
+		{http.StatusCreated, false, false},
+		{http.StatusNoContent, false, false},
+		{http.StatusBadRequest, true, true},
+		{http.StatusTooManyRequests, true, false},
+		{http.StatusBadGateway, true, false},
+	}
+
+	for _, c := range cases {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(c.code)
+		}))
+
+		sink, _ := NewWebhook(&WebhookConfig{Endpoint: srv.URL})
+		err := sink.Send(context.TODO(), &kube.EnhancedEvent{})
+		srv.Close()
+
+		if (err != nil) != c.isErr {
+			t.Errorf("code: %d expect error: %v, current: %v", c.code, c.isErr, err)
+		}
+		if err != nil && lbackoff.IsPermanentError(err) != c.isPermanent {
+			t.Errorf("code: %d expect permanent: %v", c.code, c.isPermanent)
+		}
+	}
+
+	// the permanent error is not retried
+	var calls int
+	err := (&RetryConfig{MaxRetries: 3}).Retry(context.TODO(), func() error {
+		calls++
+		return backoff.MarkErrorPermanent(context.Canceled)
+	})
+	if err == nil || calls != 1 {
+		t.Errorf("expect 1 call of the permanent error, current: %d %v", calls, err)
+	}
+}
@@ -0,0 +1,230 @@
+package offlinepod
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"gitlab.dmall.com/arch/sym-admin/pkg/apimanager/model"
+	workloadv1beta1 "gitlab.dmall.com/arch/sym-admin/pkg/apis/workload/v1beta1"
+	k8sclient "gitlab.dmall.com/arch/sym-admin/pkg/k8s/client"
+	"gitlab.dmall.com/arch/sym-admin/pkg/labels"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8slabels "k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+const (
+	// SnapshotDataKey is the key of the snapshot in the ConfigMap
+	SnapshotDataKey = "snapshot"
+
+	snapshotOwner = "offlinePodSnapshot"
+	// DefaultMaxSnapshots is the number of the snapshots kept for each owner
+	DefaultMaxSnapshots = 10
+)
+
+// getSnapshotLabels keys the snapshot by the namespace and the top-level owner of the pod,
+// the long names are hashed like the records.
+func getSnapshotLabels(snap *model.OfflinePodSnapshot) map[string]string {
+	app, _ := labelValue(snap.AppName)
+	cluster, _ := labelValue(snap.ClusterName)
+	return map[string]string{
+		"controllerOwner":              snapshotOwner,
+		labels.LabelNamespace:          snap.Namespace,
+		labels.ObserveMustLabelAppName: app,
+		labels.LabelClusterName:        cluster,
+	}
+}
+
+// Archive keeps the snapshots of the offline pods of each top-level owner in the master
+// cluster, one ConfigMap for each pod, the oldest ones beyond MaxSnapshots are deleted. The
+// snapshots of the AppSets are kept in the namespace of the AppSet and owned by it, the
+// others are kept in Namespace and collected with the records.
+type Archive struct {
+	Client       client.Client
+	Namespace    string
+	MaxSnapshots int
+}
+
+// NewArchive keeps the snapshots not owned by an AppSet in the namespace, DefaultRecordNamespace
+// if empty.
+func NewArchive(c client.Client, namespace string, maxSnapshots int) *Archive {
+	if namespace == "" {
+		namespace = DefaultRecordNamespace
+	}
+	if maxSnapshots <= 0 {
+		maxSnapshots = DefaultMaxSnapshots
+	}
+	return &Archive{Client: c, Namespace: namespace, MaxSnapshots: maxSnapshots}
+}
+
+func snapshotName(snap *model.OfflinePodSnapshot) string {
+	return fmt.Sprintf("offline-%s", snap.UID)
+}
+
+// Save stores the snapshot keyed by the owner of the pod, the snapshot already stored for
+// the pod is kept since it is captured earlier with the logs.
+func (a *Archive) Save(ctx context.Context, snap *model.OfflinePodSnapshot) error {
+	if snap.AppName == "" || snap.UID == "" {
+		return nil
+	}
+
+	namespace := a.Namespace
+	var as *workloadv1beta1.AppSet
+	if snap.OwnerKind == OwnerKindAppSet {
+		as = &workloadv1beta1.AppSet{}
+		err := a.Client.Get(ctx, types.NamespacedName{Namespace: snap.Namespace, Name: snap.AppName}, as)
+		switch {
+		case err == nil:
+			namespace = snap.Namespace
+		case apierrors.IsNotFound(err):
+			klog.V(4).Infof("offline pod: %s/%s has no AppSet in the master cluster", snap.Namespace, snap.Name)
+			as = nil
+		default:
+			return err
+		}
+	}
+
+	raw, err := json.Marshal(snap)
+	if err != nil {
+		return err
+	}
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      snapshotName(snap),
+			Namespace: namespace,
+			Labels:    getSnapshotLabels(snap),
+		},
+		Data: map[string]string{SnapshotDataKey: string(raw)},
+	}
+	if as != nil {
+		if err := controllerutil.SetControllerReference(as, cm, k8sclient.GetScheme()); err != nil {
+			return err
+		}
+	}
+	if err := a.Client.Create(ctx, cm); err != nil {
+		if apierrors.IsAlreadyExists(err) {
+			return nil
+		}
+		return err
+	}
+	return a.prune(ctx, snap.Namespace, snap.AppName)
+}
+
+// Exists reports whether the snapshot of the pod is stored.
+func (a *Archive) Exists(ctx context.Context, namespace string, uid types.UID) (bool, error) {
+	name := snapshotName(&model.OfflinePodSnapshot{UID: string(uid)})
+	for _, ns := range []string{namespace, a.Namespace} {
+		cm := &corev1.ConfigMap{}
+		err := a.Client.Get(ctx, types.NamespacedName{Namespace: ns, Name: name}, cm)
+		if err == nil {
+			return true, nil
+		}
+		if !apierrors.IsNotFound(err) {
+			return false, err
+		}
+	}
+	return false, nil
+}
+
+// list returns the snapshots of the owner in the namespace of the pods, newest first.
+func (a *Archive) list(ctx context.Context, namespace, app string) ([]corev1.ConfigMap, error) {
+	value, hashed := labelValue(app)
+	lb := k8slabels.Set{
+		"controllerOwner":              snapshotOwner,
+		labels.LabelNamespace:          namespace,
+		labels.ObserveMustLabelAppName: value,
+	}
+	namespaces := []string{a.Namespace}
+	if namespace != a.Namespace {
+		namespaces = append(namespaces, namespace)
+	}
+	items := make([]corev1.ConfigMap, 0)
+	for _, ns := range namespaces {
+		cms := &corev1.ConfigMapList{}
+		if err := a.Client.List(ctx, cms, &client.ListOptions{Namespace: ns, LabelSelector: lb.AsSelector()}); err != nil {
+			return nil, err
+		}
+		items = append(items, cms.Items...)
+	}
+	if hashed {
+		// the owners of the same hash are told apart by the snapshot
+		kept := items[:0]
+		for i := range items {
+			snap := &model.OfflinePodSnapshot{}
+			if json.Unmarshal([]byte(items[i].Data[SnapshotDataKey]), snap) == nil && snap.AppName == app {
+				kept = append(kept, items[i])
+			}
+		}
+		items = kept
+	}
+
+	sort.Slice(items, func(i, j int) bool {
+		ti, tj := items[i].CreationTimestamp, items[j].CreationTimestamp
+		if ti.Equal(&tj) {
+			return items[i].Name > items[j].Name
+		}
+		return tj.Before(&ti)
+	})
+	return items, nil
+}
+
+func (a *Archive) prune(ctx context.Context, namespace, app string) error {
+	items, err := a.list(ctx, namespace, app)
+	if err != nil {
+		return err
+	}
+	for i := a.MaxSnapshots; i < len(items); i++ {
+		if err := a.Client.Delete(ctx, &items[i]); err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// GC deletes the snapshots in Namespace created before the time, the snapshots of the
+// AppSets are deleted with them.
+func (a *Archive) GC(ctx context.Context, before time.Time) (int, error) {
+	cms := &corev1.ConfigMapList{}
+	lb := k8slabels.Set{"controllerOwner": snapshotOwner}
+	if err := a.Client.List(ctx, cms, &client.ListOptions{Namespace: a.Namespace, LabelSelector: lb.AsSelector()}); err != nil {
+		return 0, err
+	}
+	deleted := 0
+	for i := range cms.Items {
+		if !cms.Items[i].CreationTimestamp.Time.Before(before) {
+			continue
+		}
+		if err := a.Client.Delete(ctx, &cms.Items[i]); err != nil && !apierrors.IsNotFound(err) {
+			return deleted, err
+		}
+		deleted++
+	}
+	return deleted, nil
+}
+
+// List returns the snapshots of the top-level owner of the pods in the namespace, newest first.
+func (a *Archive) List(ctx context.Context, namespace, app string) ([]*model.OfflinePodSnapshot, error) {
+	items, err := a.list(ctx, namespace, app)
+	if err != nil {
+		return nil, err
+	}
+
+	snaps := make([]*model.OfflinePodSnapshot, 0, len(items))
+	for i := range items {
+		snap := &model.OfflinePodSnapshot{}
+		if err := json.Unmarshal([]byte(items[i].Data[SnapshotDataKey]), snap); err != nil {
+			klog.Errorf("configmap: %s/%s invalid snapshot err: %v", items[i].Namespace, items[i].Name, err)
+			continue
+		}
+		snaps = append(snaps, snap)
+	}
+	return snaps, nil
+}
@@ -5,17 +5,12 @@ import (
 	"fmt"
 	"time"
 
-	json "github.com/json-iterator/go"
 	"gitlab.dmall.com/arch/sym-admin/pkg/apimanager/model"
-	workloadv1beta1 "gitlab.dmall.com/arch/sym-admin/pkg/apis/workload/v1beta1"
 	pkgmanager "gitlab.dmall.com/arch/sym-admin/pkg/manager"
 	corev1 "k8s.io/api/core/v1"
-	"k8s.io/apimachinery/pkg/api/equality"
-	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/klog"
-	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 )
 
@@ -34,19 +29,44 @@ func Add(mgr manager.Manager, cMgr *pkgmanager.DksManager) error {
 	return nil
 }
 
+// GetConfigMapLabels returns the labels of the legacy ConfigMaps of the offline pods,
+// they are migrated to the store when the controller starts.
 func GetConfigMapLabels() map[string]string {
 	return map[string]string{
 		"controllerOwner": "offlinePod",
 	}
 }
 
-func (c *offlinepodImpl) reconciler(ctx context.Context, pod *model.OfflinePod) error {
-	if pod == nil {
+// buildOfflinePod builds the offline pod of the request, the pod is keyed by its top-level owner.
+func buildOfflinePod(req *offlineRequest, owner *Owner, cause, msg string) *model.OfflinePod {
+	pod := req.pod
+	oPod := &model.OfflinePod{
+		Name:            pod.Name,
+		UID:             string(pod.UID),
+		ClusterName:     req.cluster.Name,
+		AppName:         owner.Name,
+		OwnerKind:       owner.Kind,
+		Namespace:       pod.Namespace,
+		NodeName:        pod.Spec.NodeName,
+		HostIP:          pod.Status.HostIP,
+		PodIP:           pod.Status.PodIP,
+		Labels:          pod.Labels,
+		OfflineTime:     req.offlineTime.Format(timeLayout),
+		DeletionCause:   cause,
+		DeletionMessage: msg,
+	}
+	if len(pod.Status.ContainerStatuses) > 0 {
+		oPod.ContainerID = pod.Status.ContainerStatuses[0].ContainerID
+	}
+	return oPod
+}
+
+func (c *offlinepodImpl) reconciler(ctx context.Context, req *offlineRequest) error {
+	if req == nil || req.pod == nil {
 		return nil
 	}
+	pod := req.pod
 
-	key := fmt.Sprintf("%s/%s", pod.Namespace, pod.AppName)
-	logger := c.Log.WithValues("key", key)
 	startTime := time.Now()
 	defer func() {
 		diffTime := time.Since(startTime)
@@ -61,129 +81,46 @@ func (c *offlinepodImpl) reconciler(ctx context.Context, pod *model.OfflinePod)
 		klog.V(logLevel).Infof("##### [%s] reconciling is finished. time taken: %v. ", pod.Name, diffTime)
 	}()
 
-	as := &workloadv1beta1.AppSet{}
-	err := c.Client.Get(ctx, types.NamespacedName{
-		Namespace: pod.Namespace,
-		Name:      pod.AppName,
-	}, as)
+	owner, err := resolveOwner(ctx, req.cluster.KubeCli, pod)
 	if err != nil {
-		if apierrors.IsNotFound(err) {
-			logger.Info("Can't find AppSet")
-			return nil
-		}
-
-		logger.Error(err, "failed to get AppSet")
+		c.Log.Error(err, "failed to resolve the owner of offline pod", "key", fmt.Sprintf("%s/%s", pod.Namespace, pod.Name))
 		return err
 	}
 
-	aggrCm := &corev1.ConfigMap{}
-	err = c.Client.Get(ctx, types.NamespacedName{
-		Namespace: pod.Namespace,
-		Name:      pod.AppName,
-	}, aggrCm)
+	events, err := req.cluster.KubeCli.CoreV1().Events(pod.Namespace).List(ctx, metav1.ListOptions{
+		FieldSelector: fields.OneTermEqualSelector("involvedObject.uid", string(pod.UID)).String(),
+	})
 	if err != nil {
-		if apierrors.IsNotFound(err) {
-			cm := &corev1.ConfigMap{
-				ObjectMeta: metav1.ObjectMeta{
-					Name:      pod.AppName,
-					Labels:    GetConfigMapLabels(),
-					Namespace: pod.Namespace,
-				},
-			}
-
-			err = controllerutil.SetControllerReference(as, cm, c.MasterMgr.GetScheme())
-			if err != nil {
-				logger.Error(err, "failed to set reference with offline configmap")
-				return err
-			}
-
-			err := c.Client.Create(ctx, cm)
-			if err != nil {
-				logger.Error(err, "failed to create offline configmap")
-				return err
-			}
-
-			aggrCm = cm
-		} else {
-			logger.Error(err, "failed to get offline configmap")
-			return err
-		}
-	}
-
-	var (
-		oldRaw string
-		ok     bool
-		cache  *Cache
-		apps   []*model.OfflinePod
-	)
-
-	if aggrCm.Data == nil {
-		aggrCm.Data = make(map[string]string)
-	}
-
-	oldRaw = aggrCm.Data[ConfigDataKey]
-	if cache, ok = c.Cache[key]; !ok {
-		maxOffline := c.MaxOffline
-		if as.Status.AggrStatus.Desired > c.MaxOffline {
-			maxOffline = as.Status.AggrStatus.Desired
-			logger.Info("set cache", "max offline", maxOffline)
-		}
-		cache = New(maxOffline, key, c)
-		c.Cache[key] = cache
-	}
-
-	if as.Status.AggrStatus.Desired > c.MaxOffline && cache.GetMaxEntries() != as.Status.AggrStatus.Desired {
-		cache.SetMaxEntries(as.Status.AggrStatus.Desired)
+		// the cause is still derived from the status
+		klog.Errorf("cluster: %s pod: %s/%s list events err: %v", req.cluster.Name, pod.Namespace, pod.Name, err)
+		events = &corev1.EventList{}
 	}
-	if len(oldRaw) > 0 && cache.Len() == 0 {
-		jerr := json.ConfigCompatibleWithStandardLibrary.Unmarshal([]byte(oldRaw), &apps)
-		if jerr != nil {
-			logger.Error(jerr, "failed to Unmarshal offlineList")
-			return jerr
-		}
+	cause, msg := deletionCause(pod, events.Items, owner)
 
-		logger.Info("add old cache list", "items", len(apps))
-
-		// latst data add to list front
-		for i := len(apps) - 1; i >= 0; i-- {
-			cache.Add(apps[i])
-		}
+	oPod := buildOfflinePod(req, owner, cause, msg)
+	if err := c.Store.Add(ctx, oPod); err != nil {
+		c.Log.Error(err, "failed to record offline pod", "key", fmt.Sprintf("%s/%s", oPod.Namespace, oPod.AppName), "pod", oPod.Name)
+		return err
 	}
+	return nil
+}
 
-	cache.Add(pod)
-	apps = cache.List()
-	appsByte, jerr := json.ConfigCompatibleWithStandardLibrary.MarshalIndent(apps, "", "  ")
-	if jerr != nil {
-		logger.Error(jerr, "failed to Marshal offlineList")
-		return jerr
+// gc deletes the offline pods and the snapshots not owned by the AppSets older than the TTL.
+func (c *offlinepodImpl) gc() {
+	before := time.Now().Add(-c.TTL)
+	deleted, err := c.Store.GC(context.TODO(), before)
+	if err != nil {
+		klog.Errorf("offline pod gc err: %v", err)
 	}
-
-	appsRaw := string(appsByte)
-	if len(oldRaw) > 0 &&
-		equality.Semantic.DeepEqual(appsRaw, oldRaw) {
-		return nil
+	if deleted > 0 {
+		klog.Infof("offline pod gc deleted %d pods older than %v", deleted, c.TTL)
 	}
 
-	// re get
-	upCm := &corev1.ConfigMap{}
-	err = c.Client.Get(ctx, types.NamespacedName{
-		Namespace: pod.Namespace,
-		Name:      pod.AppName,
-	}, upCm)
+	deleted, err = c.Archive.GC(context.TODO(), before)
 	if err != nil {
-		logger.Error(err, "failed to get offlineList configmap")
-		return err
-	}
-	if upCm.Data == nil {
-		upCm.Data = make(map[string]string)
+		klog.Errorf("offline pod snapshot gc err: %v", err)
 	}
-	upCm.Data[ConfigDataKey] = appsRaw
-	uerr := c.Client.Update(ctx, upCm)
-	if uerr != nil {
-		logger.Error(uerr, "failed to update offlineList configmap")
-		return uerr
+	if deleted > 0 {
+		klog.Infof("offline pod gc deleted %d snapshots older than %v", deleted, c.TTL)
 	}
-
-	logger.Info("offline pod update success", "items", len(apps))
-	return nil
 }
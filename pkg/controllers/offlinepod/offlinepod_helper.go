@@ -2,15 +2,18 @@ package offlinepod
 
 import (
 	"context"
+	"fmt"
 	"sync"
 	"time"
 
 	"github.com/go-logr/logr"
-	"gitlab.dmall.com/arch/sym-admin/pkg/apimanager/model"
+	k8smanager "gitlab.dmall.com/arch/sym-admin/pkg/k8s/manager"
 	"gitlab.dmall.com/arch/sym-admin/pkg/labels"
 	pkgmanager "gitlab.dmall.com/arch/sym-admin/pkg/manager"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8slabels "k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/runtime"
 	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/tools/cache"
@@ -23,20 +26,94 @@ import (
 
 const (
 	controllerName = "offlinepod-controller"
-	ConfigDataKey  = "offlineList"
+	// ConfigDataKey is the key of the offline pods in the legacy ConfigMaps
+	ConfigDataKey = "offlineList"
+
+	// DefaultTTL is how long the offline pods are kept
+	DefaultTTL = 7 * 24 * time.Hour
+	gcInterval = 10 * time.Minute
+
+	// AllNamespaces in the observed namespaces observes the pods of all the namespaces
+	AllNamespaces = "*"
 )
 
+// namespaceFilter matches the namespaces observed by name or by the labels of the
+// namespaces in the member clusters.
+type namespaceFilter struct {
+	names    map[string]bool
+	all      bool
+	selector k8slabels.Selector
+}
+
+func newNamespaceFilter(names []string, selector string) (*namespaceFilter, error) {
+	f := &namespaceFilter{names: make(map[string]bool)}
+	for _, name := range names {
+		if name == AllNamespaces {
+			f.all = true
+		}
+		f.names[name] = true
+	}
+	if selector != "" {
+		sel, err := k8slabels.Parse(selector)
+		if err != nil {
+			return nil, fmt.Errorf("invalid namespace selector: %s, err: %v", selector, err)
+		}
+		f.selector = sel
+	}
+	if len(names) == 0 && f.selector == nil {
+		f.all = true
+	}
+	return f, nil
+}
+
+func (f *namespaceFilter) match(ctx context.Context, c client.Client, namespace string) bool {
+	if f.all || f.names[namespace] {
+		return true
+	}
+	if f.selector == nil {
+		return false
+	}
+
+	ns := &corev1.Namespace{}
+	if err := c.Get(ctx, types.NamespacedName{Name: namespace}, ns); err != nil {
+		klog.V(4).Infof("get namespace: %s err: %v", namespace, err)
+		return false
+	}
+	return f.selector.Matches(k8slabels.Set(ns.Labels))
+}
+
 type offlinepodImpl struct {
 	Name       string
-	Namespaces []string
+	Namespaces *namespaceFilter
 	WorkQueue  workqueue.RateLimitingInterface
 	MasterMgr  manager.Manager
 	client.Client
-	Log            logr.Logger
-	Cache          map[string]*Cache
-	OfflinePodPool *sync.Pool
-	sync.RWMutex
-	MaxOffline int32
+	Log   logr.Logger
+	Store Store
+	// TTL is how long the offline pods are kept
+	TTL time.Duration
+
+	Archive     *Archive
+	SnapshotOpt *SnapshotOptions
+	// Workers process the offline pods and the snapshots concurrently
+	Workers int
+	// captured are the uid of the pods with the snapshots queued, they are deleted after
+	// the snapshots so the deletes of the same pods are not queued again
+	captured sync.Map
+}
+
+// offlineRequest records a pod deleted, the owner and the deletion cause are resolved
+// by the worker.
+type offlineRequest struct {
+	cluster     *k8smanager.Cluster
+	pod         *corev1.Pod
+	offlineTime time.Time
+}
+
+// snapshotRequest captures the snapshot of a pod being deleted.
+type snapshotRequest struct {
+	cluster *k8smanager.Cluster
+	pod     *corev1.Pod
 }
 
 func getAppName(lb map[string]string) string {
@@ -49,19 +126,29 @@ func getAppName(lb map[string]string) string {
 }
 
 func NewOfflinepodReconciler(mgr manager.Manager, cMgr *pkgmanager.DksManager) (*offlinepodImpl, error) {
+	namespaces, err := newNamespaceFilter(cMgr.Opt.OfflinePodNamespaces, cMgr.Opt.OfflinePodNsSelector)
+	if err != nil {
+		return nil, err
+	}
+
 	impl := &offlinepodImpl{
-		Name:       controllerName,
-		Namespaces: labels.ObservedNamespace,
-		WorkQueue:  workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), controllerName),
-		MasterMgr:  mgr,
-		Client:     mgr.GetClient(),
-		Log:        ctrl.Log.WithName("controllers").WithName(controllerName),
-		Cache:      make(map[string]*Cache),
-		OfflinePodPool: &sync.Pool{
-			New: func() interface{} {
-				return &model.OfflinePod{}
-			}},
-		MaxOffline: 10,
+		Name:        controllerName,
+		Namespaces:  namespaces,
+		WorkQueue:   workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), controllerName),
+		MasterMgr:   mgr,
+		Client:      mgr.GetClient(),
+		Log:         ctrl.Log.WithName("controllers").WithName(controllerName),
+		Store:       NewRecordStore(mgr.GetClient(), mgr.GetAPIReader(), cMgr.Opt.OfflinePodRecordNs),
+		TTL:         cMgr.Opt.OfflinePodTTL,
+		Archive:     NewArchive(mgr.GetClient(), cMgr.Opt.OfflinePodRecordNs, cMgr.Opt.OfflinePodMaxSnapshots),
+		SnapshotOpt: &SnapshotOptions{LogLines: cMgr.Opt.OfflinePodLogLines},
+		Workers:     cMgr.Opt.OfflinePodWorkers,
+	}
+	if impl.TTL <= 0 {
+		impl.TTL = DefaultTTL
+	}
+	if impl.Workers <= 0 {
+		impl.Workers = DefaultSnapshotWorkers
 	}
 
 	for _, cluster := range cMgr.ClustersMgr.GetAll() {
@@ -71,8 +158,23 @@ func NewOfflinepodReconciler(mgr manager.Manager, cMgr *pkgmanager.DksManager) (
 			continue
 		}
 
-		clusterName := cluster.Name
+		c := cluster
 		podInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+			UpdateFunc: func(oldObj, newObj interface{}) {
+				oldPod, ok := oldObj.(*corev1.Pod)
+				if !ok {
+					return
+				}
+				pod, ok := newObj.(*corev1.Pod)
+				if !ok {
+					return
+				}
+
+				// the logs are still readable while the pod is terminating
+				if oldPod.DeletionTimestamp == nil && pod.DeletionTimestamp != nil {
+					impl.enqueueSnapshot(c, pod)
+				}
+			},
 			DeleteFunc: func(obj interface{}) {
 				var ok bool
 				if _, ok = obj.(metav1.Object); !ok {
@@ -94,24 +196,19 @@ func NewOfflinepodReconciler(mgr manager.Manager, cMgr *pkgmanager.DksManager) (
 					return
 				}
 
-				if !impl.isObserveNamespaces(pod.Namespace) {
+				if !impl.Namespaces.match(context.TODO(), c.Client, pod.Namespace) {
 					return
 				}
 
+				// the pods deleted without terminating are snapshotted by the final state, the
+				// snapshots already saved are skipped by the worker
+				impl.enqueueSnapshot(c, pod)
+
 				if len(pod.Status.HostIP) == 0 || len(pod.Status.PodIP) == 0 {
 					return
 				}
 
-				oPod := impl.GetOfflinePod()
-				oPod.Name = pod.Name
-				oPod.ClusterName = clusterName
-				oPod.AppName = getAppName(pod.Labels)
-				oPod.Namespace = pod.Namespace
-				oPod.HostIP = pod.Status.HostIP
-				oPod.PodIP = pod.Status.PodIP
-				oPod.ContainerID = pod.Status.ContainerStatuses[0].ContainerID
-				oPod.OfflineTime = time.Now().Format("2006-01-02 15:04:05")
-				impl.WorkQueue.Add(oPod)
+				impl.WorkQueue.Add(&offlineRequest{cluster: c, pod: pod.DeepCopy(), offlineTime: time.Now()})
 			},
 		})
 		klog.Infof("cluster name:%s AddEventHandler pod key to queue", cluster.Name)
@@ -119,24 +216,27 @@ func NewOfflinepodReconciler(mgr manager.Manager, cMgr *pkgmanager.DksManager) (
 	return impl, nil
 }
 
-// isObserveNamespaces
-func (c *offlinepodImpl) isObserveNamespaces(ns string) bool {
-	if len(c.Namespaces) < 1 {
-		return true
+func (c *offlinepodImpl) enqueueSnapshot(cluster *k8smanager.Cluster, pod *corev1.Pod) {
+	if !c.Namespaces.match(context.TODO(), cluster.Client, pod.Namespace) {
+		return
 	}
-
-	for _, obNs := range c.Namespaces {
-		if obNs == ns {
-			return true
-		}
+	if _, queued := c.captured.LoadOrStore(pod.UID, struct{}{}); queued {
+		return
 	}
-	return false
+	c.WorkQueue.Add(&snapshotRequest{cluster: cluster, pod: pod.DeepCopy()})
 }
 
 func (c *offlinepodImpl) Start(stopCh <-chan struct{}) error {
 	defer runtime.HandleCrash()
 	defer c.WorkQueue.ShutDown()
-	wait.Until(c.runWorker, time.Second, stopCh)
+
+	if err := migrateConfigMaps(context.TODO(), c.Client, c.Store); err != nil {
+		klog.Errorf("migrate offline pod configmaps err: %v", err)
+	}
+	go wait.Until(c.gc, gcInterval, stopCh)
+	for i := 0; i < c.Workers; i++ {
+		go wait.Until(c.runWorker, time.Second, stopCh)
+	}
 	<-stopCh
 	klog.Infof("Shutting down workers, name: %s", c.Name)
 	return nil
@@ -160,17 +260,23 @@ func (c *offlinepodImpl) processNextWorkItem() bool {
 
 	defer c.WorkQueue.Done(obj)
 
-	var req *model.OfflinePod
+	if req, ok := obj.(*snapshotRequest); ok {
+		c.snapshot(context.TODO(), req)
+		c.WorkQueue.Forget(obj)
+		return true
+	}
+
+	var req *offlineRequest
 	var ok bool
 
-	if req, ok = obj.(*model.OfflinePod); !ok {
+	if req, ok = obj.(*offlineRequest); !ok {
 		c.WorkQueue.Forget(obj)
 		return true
 	}
 
 	if err := c.reconciler(context.TODO(), req); err != nil {
 		c.WorkQueue.AddRateLimited(req)
-		klog.V(3).Infof("name: %s reconciler failed. err: %v", req.Name, err)
+		klog.V(3).Infof("name: %s reconciler failed. err: %v", req.pod.Name, err)
 		return false
 	}
 
@@ -178,19 +284,17 @@ func (c *offlinepodImpl) processNextWorkItem() bool {
 	return true
 }
 
-func (c *offlinepodImpl) GetOfflinePod() *model.OfflinePod {
-	return c.OfflinePodPool.Get().(*model.OfflinePod)
-}
+// snapshot captures and archives the snapshot of the pod unless it is saved already, the
+// pod is removed from the captured after.
+func (c *offlinepodImpl) snapshot(ctx context.Context, req *snapshotRequest) {
+	defer c.captured.Delete(req.pod.UID)
 
-func (c *offlinepodImpl) PutOfflinePod(b *model.OfflinePod) {
-	b.Name = ""
-	b.ClusterName = ""
-	b.Namespace = ""
-	b.AppName = ""
-	b.HostIP = ""
-	b.PodIP = ""
-	b.ContainerID = ""
-	b.OfflineTime = ""
-	b.Labels = nil
-	c.OfflinePodPool.Put(b)
+	exists, err := c.Archive.Exists(ctx, req.pod.Namespace, req.pod.UID)
+	if err != nil {
+		klog.Warningf("cluster: %s pod: %s/%s get snapshot err: %v", req.cluster.Name, req.pod.Namespace, req.pod.Name, err)
+	}
+	if exists {
+		return
+	}
+	saveSnapshot(c.Archive, c.SnapshotOpt, req)
 }
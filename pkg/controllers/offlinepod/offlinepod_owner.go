@@ -0,0 +1,218 @@
+package offlinepod
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gitlab.dmall.com/arch/sym-admin/pkg/labels"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// maxOwnerDepth bounds the walk of the owner chain
+	maxOwnerDepth = 8
+
+	// the top-level owners of the pods
+	OwnerKindAppSet      = "AppSet"
+	OwnerKindDeployment  = "Deployment"
+	OwnerKindStatefulSet = "StatefulSet"
+	OwnerKindDaemonSet   = "DaemonSet"
+	OwnerKindJob         = "Job"
+	OwnerKindCronJob     = "CronJob"
+	OwnerKindPod         = "Pod"
+
+	kindReplicaSet    = "ReplicaSet"
+	kindAdvDeployment = "AdvDeployment"
+
+	deploymentRevisionAnnotation = "deployment.kubernetes.io/revision"
+)
+
+// the causes of the pod deletions
+const (
+	CauseNodeLost        = "NodeLost"
+	CauseEvicted         = "Evicted"
+	CauseUserDeleted     = "UserDeleted"
+	CauseOOMKilled       = "OOMKilled"
+	CauseRolloutReplaced = "RolloutReplaced"
+	CauseUnknown         = "Unknown"
+)
+
+// Owner is the top-level controller of a pod, the pods of the AdvDeployments are owned
+// by the AppSet of the same name.
+type Owner struct {
+	Kind string
+	Name string
+
+	// the controllers found on the walk, e.g. the ReplicaSet and the Deployment
+	replicaSet  *appsv1.ReplicaSet
+	deployment  *appsv1.Deployment
+	statefulSet *appsv1.StatefulSet
+}
+
+func ignoreNotFound(err error) error {
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// getController reads the controller of the kind known, the unknown kinds and the
+// controllers already deleted end the walk with nil.
+func (o *Owner) getController(ctx context.Context, kubeCli kubernetes.Interface, namespace string, ref *metav1.OwnerReference) (metav1.Object, error) {
+	opts := metav1.GetOptions{}
+	switch ref.Kind {
+	case kindReplicaSet:
+		rs, err := kubeCli.AppsV1().ReplicaSets(namespace).Get(ctx, ref.Name, opts)
+		if err != nil {
+			return nil, ignoreNotFound(err)
+		}
+		o.replicaSet = rs
+		return rs, nil
+	case OwnerKindDeployment:
+		deploy, err := kubeCli.AppsV1().Deployments(namespace).Get(ctx, ref.Name, opts)
+		if err != nil {
+			return nil, ignoreNotFound(err)
+		}
+		o.deployment = deploy
+		return deploy, nil
+	case OwnerKindStatefulSet:
+		sts, err := kubeCli.AppsV1().StatefulSets(namespace).Get(ctx, ref.Name, opts)
+		if err != nil {
+			return nil, ignoreNotFound(err)
+		}
+		o.statefulSet = sts
+		return sts, nil
+	case OwnerKindJob:
+		job, err := kubeCli.BatchV1().Jobs(namespace).Get(ctx, ref.Name, opts)
+		if err != nil {
+			return nil, ignoreNotFound(err)
+		}
+		return job, nil
+	}
+	return nil, nil
+}
+
+// resolveOwner walks the controllers of the pod up to the top-level owner.
+func resolveOwner(ctx context.Context, kubeCli kubernetes.Interface, pod *corev1.Pod) (*Owner, error) {
+	owner := &Owner{Kind: OwnerKindPod, Name: pod.Name}
+	ref := metav1.GetControllerOf(pod)
+	for i := 0; ref != nil && i < maxOwnerDepth; i++ {
+		owner.Kind, owner.Name = ref.Kind, ref.Name
+
+		obj, err := owner.getController(ctx, kubeCli, pod.Namespace, ref)
+		if err != nil {
+			return nil, err
+		}
+		if obj == nil {
+			break
+		}
+		ref = metav1.GetControllerOf(obj)
+	}
+
+	switch owner.Kind {
+	case kindAdvDeployment:
+		owner.Kind = OwnerKindAppSet
+	case kindReplicaSet:
+		// the ReplicaSet deleted is named after the Deployment and the pod template hash
+		if hash := pod.Labels[appsv1.DefaultDeploymentUniqueLabelKey]; hash != "" && strings.HasSuffix(owner.Name, "-"+hash) {
+			owner.Kind, owner.Name = OwnerKindDeployment, strings.TrimSuffix(owner.Name, "-"+hash)
+		}
+	}
+	return owner, nil
+}
+
+func terminatedBy(statuses []corev1.ContainerStatus, last bool, reason string) *corev1.ContainerStatus {
+	for i := range statuses {
+		state := statuses[i].State
+		if last {
+			state = statuses[i].LastTerminationState
+		}
+		if state.Terminated != nil && state.Terminated.Reason == reason {
+			return &statuses[i]
+		}
+	}
+	return nil
+}
+
+// rolloutReplaced reports whether the pod is of a revision older than its controller.
+func (o *Owner) rolloutReplaced(pod *corev1.Pod) (string, bool) {
+	if o.replicaSet != nil && o.deployment != nil {
+		rsRevision, err1 := strconv.ParseInt(o.replicaSet.Annotations[deploymentRevisionAnnotation], 10, 64)
+		revision, err2 := strconv.ParseInt(o.deployment.Annotations[deploymentRevisionAnnotation], 10, 64)
+		if err1 == nil && err2 == nil && rsRevision < revision {
+			return fmt.Sprintf("replaced by the revision %d of the Deployment %s", revision, o.deployment.Name), true
+		}
+	}
+	if o.statefulSet != nil {
+		update := o.statefulSet.Status.UpdateRevision
+		if hash := pod.Labels[appsv1.StatefulSetRevisionLabel]; update != "" && hash != "" && hash != update {
+			return fmt.Sprintf("replaced by the revision %s of the StatefulSet %s", update, o.statefulSet.Name), true
+		}
+	}
+	return "", false
+}
+
+func podReady(pod *corev1.Pod) bool {
+	for _, cond := range pod.Status.Conditions {
+		if cond.Type == corev1.PodReady {
+			return cond.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+func findEvent(events []corev1.Event, reasons ...string) *corev1.Event {
+	for i := range events {
+		for _, reason := range reasons {
+			if events[i].Reason == reason {
+				return &events[i]
+			}
+		}
+	}
+	return nil
+}
+
+// deletionCause derives why the pod is deleted from the status, the events and the owner,
+// the causes are checked from the most to the least specific.
+func deletionCause(pod *corev1.Pod, events []corev1.Event, owner *Owner) (string, string) {
+	if pod.Status.Reason == CauseNodeLost {
+		return CauseNodeLost, pod.Status.Message
+	}
+	if e := findEvent(events, "TaintManagerEviction"); e != nil {
+		return CauseNodeLost, e.Message
+	}
+	// the pods of the nodes recovered are not lost
+	if e := findEvent(events, "NodeNotReady"); e != nil && !podReady(pod) {
+		return CauseNodeLost, e.Message
+	}
+
+	if pod.Status.Reason == CauseEvicted {
+		return CauseEvicted, pod.Status.Message
+	}
+	if e := findEvent(events, "Evicted", "Preempted"); e != nil {
+		return CauseEvicted, e.Message
+	}
+
+	if by, ok := pod.Annotations[labels.WorkLoadAnnotationDeletedBy]; ok {
+		return CauseUserDeleted, fmt.Sprintf("deleted by %s", by)
+	}
+
+	if cs := terminatedBy(pod.Status.ContainerStatuses, false, CauseOOMKilled); cs != nil {
+		return CauseOOMKilled, fmt.Sprintf("container %s is OOMKilled", cs.Name)
+	}
+	if owner != nil {
+		if msg, ok := owner.rolloutReplaced(pod); ok {
+			return CauseRolloutReplaced, msg
+		}
+	}
+	if cs := terminatedBy(pod.Status.ContainerStatuses, true, CauseOOMKilled); cs != nil {
+		return CauseOOMKilled, fmt.Sprintf("container %s is OOMKilled, restarted %d times", cs.Name, cs.RestartCount)
+	}
+	return CauseUnknown, ""
+}
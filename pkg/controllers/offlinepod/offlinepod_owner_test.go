@@ -0,0 +1,173 @@
+package offlinepod
+
+import (
+	"context"
+	"testing"
+
+	workloadv1beta1 "gitlab.dmall.com/arch/sym-admin/pkg/apis/workload/v1beta1"
+	"gitlab.dmall.com/arch/sym-admin/pkg/labels"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+)
+
+func controllerRef(gvk schema.GroupVersionKind, name string) []metav1.OwnerReference {
+	isController := true
+	return []metav1.OwnerReference{{
+		APIVersion: gvk.GroupVersion().String(),
+		Kind:       gvk.Kind,
+		Name:       name,
+		UID:        types.UID("uid-" + name),
+		Controller: &isController,
+	}}
+}
+
+func newOwnedPod(name string, owners []metav1.OwnerReference) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            name,
+			Namespace:       "default",
+			Labels:          map[string]string{appsv1.DefaultDeploymentUniqueLabelKey: "7d9f"},
+			OwnerReferences: owners,
+		},
+	}
+}
+
+func TestResolveOwner(t *testing.T) {
+	rsKind := appsv1.SchemeGroupVersion.WithKind(kindReplicaSet)
+	deployKind := appsv1.SchemeGroupVersion.WithKind(OwnerKindDeployment)
+	advKind := workloadv1beta1.GroupVersion.WithKind(kindAdvDeployment)
+
+	rss := []*appsv1.ReplicaSet{
+		{ObjectMeta: metav1.ObjectMeta{Name: "bbcc-7d9f", Namespace: "default", OwnerReferences: controllerRef(deployKind, "bbcc")}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "web-7d9f", Namespace: "default", OwnerReferences: controllerRef(deployKind, "web")}},
+	}
+	deploys := []*appsv1.Deployment{
+		{ObjectMeta: metav1.ObjectMeta{Name: "bbcc", Namespace: "default", OwnerReferences: controllerRef(advKind, "bbcc")}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"}},
+	}
+	job := &batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: "backup-1600000000", Namespace: "default",
+		OwnerReferences: controllerRef(batchv1.SchemeGroupVersion.WithKind(OwnerKindCronJob), "backup")}}
+	kubeCli := kubefake.NewSimpleClientset(rss[0], rss[1], deploys[0], deploys[1], job)
+
+	jobKind := batchv1.SchemeGroupVersion.WithKind(OwnerKindJob)
+	stsKind := appsv1.SchemeGroupVersion.WithKind(OwnerKindStatefulSet)
+	cases := []struct {
+		pod    *corev1.Pod
+		expect Owner
+	}{
+		{newOwnedPod("bbcc-7d9f-x2k4q", controllerRef(rsKind, "bbcc-7d9f")), Owner{Kind: OwnerKindAppSet, Name: "bbcc"}},
+		{newOwnedPod("web-7d9f-x2k4q", controllerRef(rsKind, "web-7d9f")), Owner{Kind: OwnerKindDeployment, Name: "web"}},
+		// the ReplicaSet is deleted
+		{newOwnedPod("api-7d9f-x2k4q", controllerRef(rsKind, "api-7d9f")), Owner{Kind: OwnerKindDeployment, Name: "api"}},
+		{newOwnedPod("backup-1600000000-x2k4q", controllerRef(jobKind, "backup-1600000000")), Owner{Kind: OwnerKindCronJob, Name: "backup"}},
+		{newOwnedPod("redis-0", controllerRef(stsKind, "redis")), Owner{Kind: OwnerKindStatefulSet, Name: "redis"}},
+		{newOwnedPod("debug", nil), Owner{Kind: OwnerKindPod, Name: "debug"}},
+	}
+	for _, c := range cases {
+		owner, err := resolveOwner(context.TODO(), kubeCli, c.pod)
+		if err != nil {
+			t.Fatalf("pod: %s resolve owner err: %v", c.pod.Name, err)
+		}
+		if owner.Kind != c.expect.Kind || owner.Name != c.expect.Name {
+			t.Errorf("pod: %s expect owner: %s/%s, current: %s/%s", c.pod.Name, c.expect.Kind, c.expect.Name, owner.Kind, owner.Name)
+		}
+	}
+}
+
+func TestDeletionCause(t *testing.T) {
+	oom := corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{Reason: CauseOOMKilled}}
+	owner := &Owner{
+		Kind:       OwnerKindDeployment,
+		Name:       "bbcc",
+		replicaSet: &appsv1.ReplicaSet{ObjectMeta: metav1.ObjectMeta{Name: "bbcc-7d9f", Annotations: map[string]string{deploymentRevisionAnnotation: "3"}}},
+		deployment: &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "bbcc", Annotations: map[string]string{deploymentRevisionAnnotation: "4"}}},
+	}
+
+	cases := []struct {
+		name   string
+		pod    *corev1.Pod
+		events []corev1.Event
+		owner  *Owner
+		expect string
+	}{
+		{
+			name:   "node lost",
+			pod:    &corev1.Pod{Status: corev1.PodStatus{Reason: "NodeLost"}},
+			expect: CauseNodeLost,
+		},
+		{
+			name:   "node not ready",
+			pod:    &corev1.Pod{},
+			events: []corev1.Event{{Reason: "NodeNotReady", Message: "Node is not ready"}},
+			expect: CauseNodeLost,
+		},
+		{
+			name:   "evicted",
+			pod:    &corev1.Pod{Status: corev1.PodStatus{Reason: "Evicted", Message: "The node was low on resource: memory."}},
+			owner:  owner,
+			expect: CauseEvicted,
+		},
+		{
+			name:   "preempted",
+			pod:    &corev1.Pod{},
+			events: []corev1.Event{{Reason: "Preempted"}},
+			expect: CauseEvicted,
+		},
+		{
+			name:   "deleted by user",
+			pod:    &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{labels.WorkLoadAnnotationDeletedBy: "sym-admin"}}},
+			owner:  owner,
+			expect: CauseUserDeleted,
+		},
+		{
+			name: "oom killed",
+			pod: &corev1.Pod{Status: corev1.PodStatus{
+				ContainerStatuses: []corev1.ContainerStatus{{Name: "bbcc", State: oom}},
+			}},
+			owner:  owner,
+			expect: CauseOOMKilled,
+		},
+		{
+			name: "rollout replaced",
+			pod: &corev1.Pod{Status: corev1.PodStatus{
+				ContainerStatuses: []corev1.ContainerStatus{{Name: "bbcc", LastTerminationState: oom, RestartCount: 1}},
+			}},
+			owner:  owner,
+			expect: CauseRolloutReplaced,
+		},
+		{
+			name: "oom killed before",
+			pod: &corev1.Pod{Status: corev1.PodStatus{
+				ContainerStatuses: []corev1.ContainerStatus{{Name: "bbcc", LastTerminationState: oom, RestartCount: 1}},
+			}},
+			expect: CauseOOMKilled,
+		},
+		{
+			name: "statefulset rollout replaced",
+			pod:  &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{appsv1.StatefulSetRevisionLabel: "redis-5d8c"}}},
+			owner: &Owner{Kind: OwnerKindStatefulSet, Name: "redis", statefulSet: &appsv1.StatefulSet{
+				ObjectMeta: metav1.ObjectMeta{Name: "redis"},
+				Status:     appsv1.StatefulSetStatus{UpdateRevision: "redis-6f7b"},
+			}},
+			expect: CauseRolloutReplaced,
+		},
+		{
+			name: "ready pod of the node recovered",
+			pod: &corev1.Pod{Status: corev1.PodStatus{
+				Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}},
+			}},
+			events: []corev1.Event{{Reason: "NodeNotReady"}},
+			expect: CauseUnknown,
+		},
+	}
+	for _, c := range cases {
+		if cause, msg := deletionCause(c.pod, c.events, c.owner); cause != c.expect {
+			t.Errorf("case: %s expect cause: %s, current: %s %s", c.name, c.expect, cause, msg)
+		}
+	}
+}
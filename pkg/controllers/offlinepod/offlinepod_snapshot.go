@@ -0,0 +1,205 @@
+package offlinepod
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"sort"
+	"time"
+
+	"gitlab.dmall.com/arch/sym-admin/pkg/apimanager/model"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	timeLayout = "2006-01-02 15:04:05"
+
+	defaultSnapshotLogLines  = 100
+	defaultSnapshotLogBytes  = 32 * 1024
+	defaultSnapshotMaxEvents = 20
+)
+
+// SnapshotOptions bounds the content of the snapshots.
+type SnapshotOptions struct {
+	// LogLines is the number of the last log lines of each container.
+	LogLines int64
+	// LogBytes bounds the logs of each container.
+	LogBytes int64
+	// MaxEvents is the number of the most recent events of the pod.
+	MaxEvents int
+}
+
+func (o *SnapshotOptions) withDefaults() *SnapshotOptions {
+	out := &SnapshotOptions{}
+	if o != nil {
+		*out = *o
+	}
+	if out.LogLines <= 0 {
+		out.LogLines = defaultSnapshotLogLines
+	}
+	if out.LogBytes <= 0 {
+		out.LogBytes = defaultSnapshotLogBytes
+	}
+	if out.MaxEvents <= 0 {
+		out.MaxEvents = defaultSnapshotMaxEvents
+	}
+	return out
+}
+
+func formatTime(t metav1.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(timeLayout)
+}
+
+func terminationSnapshot(t *corev1.ContainerStateTerminated) *model.TerminationSnapshot {
+	if t == nil {
+		return nil
+	}
+	return &model.TerminationSnapshot{
+		ExitCode:   t.ExitCode,
+		Signal:     t.Signal,
+		Reason:     t.Reason,
+		Message:    t.Message,
+		StartedAt:  formatTime(t.StartedAt),
+		FinishedAt: formatTime(t.FinishedAt),
+	}
+}
+
+func containerState(s corev1.ContainerState) string {
+	switch {
+	case s.Running != nil:
+		return "running"
+	case s.Terminated != nil:
+		return "terminated"
+	case s.Waiting != nil:
+		if s.Waiting.Reason != "" {
+			return "waiting: " + s.Waiting.Reason
+		}
+		return "waiting"
+	}
+	return ""
+}
+
+// podLogStream opens the log stream of the pod, it is replaced in the tests since the fake
+// clientset can not stream the logs.
+var podLogStream = func(ctx context.Context, kubeCli kubernetes.Interface, namespace, name string, opts *corev1.PodLogOptions) (io.ReadCloser, error) {
+	return kubeCli.CoreV1().Pods(namespace).GetLogs(name, opts).Stream(ctx)
+}
+
+func readLogs(ctx context.Context, kubeCli kubernetes.Interface, pod *corev1.Pod, container string, previous bool, opt *SnapshotOptions) (string, error) {
+	stream, err := podLogStream(ctx, kubeCli, pod.Namespace, pod.Name, &corev1.PodLogOptions{
+		Container:  container,
+		TailLines:  &opt.LogLines,
+		LimitBytes: &opt.LogBytes,
+		Previous:   previous,
+	})
+	if err != nil {
+		return "", err
+	}
+	defer stream.Close()
+
+	raw, err := ioutil.ReadAll(io.LimitReader(stream, opt.LogBytes))
+	if err != nil {
+		return "", err
+	}
+	return string(raw), nil
+}
+
+// CaptureSnapshot captures the post-mortem state of the pod, the logs and the events
+// failed to read are recorded as the errors of the snapshot.
+func CaptureSnapshot(ctx context.Context, kubeCli kubernetes.Interface, clusterName string, pod *corev1.Pod, opt *SnapshotOptions) *model.OfflinePodSnapshot {
+	opt = opt.withDefaults()
+
+	snap := &model.OfflinePodSnapshot{
+		Name:        pod.Name,
+		UID:         string(pod.UID),
+		ClusterName: clusterName,
+		Namespace:   pod.Namespace,
+		AppName:     getAppName(pod.Labels),
+		NodeName:    pod.Spec.NodeName,
+		HostIP:      pod.Status.HostIP,
+		PodIP:       pod.Status.PodIP,
+		Phase:       string(pod.Status.Phase),
+		Reason:      pod.Status.Reason,
+		Message:     pod.Status.Message,
+		CaptureTime: time.Now().Format(timeLayout),
+	}
+	if pod.Status.StartTime != nil {
+		snap.StartTime = formatTime(*pod.Status.StartTime)
+	}
+
+	images := make(map[string]string, len(pod.Spec.Containers))
+	for _, c := range pod.Spec.Containers {
+		images[c.Name] = c.Image
+	}
+	for i := range pod.Status.ContainerStatuses {
+		status := &pod.Status.ContainerStatuses[i]
+		cs := &model.ContainerSnapshot{
+			Name:            status.Name,
+			Image:           status.Image,
+			ImageID:         status.ImageID,
+			Ready:           status.Ready,
+			RestartCount:    status.RestartCount,
+			State:           containerState(status.State),
+			Termination:     terminationSnapshot(status.State.Terminated),
+			LastTermination: terminationSnapshot(status.LastTerminationState.Terminated),
+		}
+		if cs.Image == "" {
+			cs.Image = images[status.Name]
+		}
+
+		logs, err := readLogs(ctx, kubeCli, pod, status.Name, false, opt)
+		if err != nil {
+			cs.LogsError = err.Error()
+		}
+		cs.Logs = logs
+		if status.RestartCount > 0 {
+			// the logs of the crashed container are the most useful for the crash loops
+			cs.PreviousLogs, _ = readLogs(ctx, kubeCli, pod, status.Name, true, opt)
+		}
+		snap.Containers = append(snap.Containers, cs)
+	}
+
+	events, err := kubeCli.CoreV1().Events(pod.Namespace).List(ctx, metav1.ListOptions{
+		FieldSelector: fields.Set{"involvedObject.uid": string(pod.UID)}.String(),
+	})
+	if err != nil {
+		snap.EventsError = err.Error()
+		return snap
+	}
+	items := events.Items
+	sort.Slice(items, func(i, j int) bool {
+		return eventTime(&items[i]).After(eventTime(&items[j]))
+	})
+	if len(items) > opt.MaxEvents {
+		items = items[:opt.MaxEvents]
+	}
+	for i := range items {
+		e := &items[i]
+		snap.Events = append(snap.Events, &model.PodEventSnapshot{
+			Type:      e.Type,
+			Reason:    e.Reason,
+			Message:   e.Message,
+			Source:    e.Source.Component,
+			Count:     e.Count,
+			FirstTime: formatTime(e.FirstTimestamp),
+			LastTime:  formatTime(metav1.NewTime(eventTime(e))),
+		})
+	}
+	return snap
+}
+
+func eventTime(e *corev1.Event) time.Time {
+	if !e.LastTimestamp.IsZero() {
+		return e.LastTimestamp.Time
+	}
+	if !e.EventTime.IsZero() {
+		return e.EventTime.Time
+	}
+	return e.FirstTimestamp.Time
+}
@@ -0,0 +1,214 @@
+package offlinepod
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	"gitlab.dmall.com/arch/sym-admin/pkg/apimanager/model"
+	workloadv1beta1 "gitlab.dmall.com/arch/sym-admin/pkg/apis/workload/v1beta1"
+	k8sclient "gitlab.dmall.com/arch/sym-admin/pkg/k8s/client"
+	k8smanager "gitlab.dmall.com/arch/sym-admin/pkg/k8s/manager"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func newTerminatingPod() *corev1.Pod {
+	now := metav1.Now()
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "bbcc-7d9f-x2k4q",
+			Namespace:         "default",
+			UID:               types.UID("uid-bbcc"),
+			Labels:            map[string]string{"app": "bbcc"},
+			DeletionTimestamp: &now,
+		},
+		Spec: corev1.PodSpec{
+			NodeName:   "node-1",
+			Containers: []corev1.Container{{Name: "bbcc", Image: "registry/bbcc:v2"}},
+		},
+		Status: corev1.PodStatus{
+			Phase:  corev1.PodRunning,
+			HostIP: "10.0.0.1",
+			PodIP:  "172.16.0.1",
+			ContainerStatuses: []corev1.ContainerStatus{{
+				Name:         "bbcc",
+				RestartCount: 3,
+				State:        corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "CrashLoopBackOff"}},
+				LastTerminationState: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{
+					ExitCode: 137,
+					Reason:   "OOMKilled",
+				}},
+			}},
+		},
+	}
+}
+
+func TestCaptureSnapshot(t *testing.T) {
+	defer func(stream func(context.Context, kubernetes.Interface, string, string, *corev1.PodLogOptions) (io.ReadCloser, error)) {
+		podLogStream = stream
+	}(podLogStream)
+	podLogStream = func(_ context.Context, _ kubernetes.Interface, _, name string, opts *corev1.PodLogOptions) (io.ReadCloser, error) {
+		return ioutil.NopCloser(strings.NewReader(fmt.Sprintf("%s/%s previous: %v", name, opts.Container, opts.Previous))), nil
+	}
+
+	pod := newTerminatingPod()
+	var events []*corev1.Event
+	for i := 0; i < 3; i++ {
+		events = append(events, &corev1.Event{
+			ObjectMeta:     metav1.ObjectMeta{Name: fmt.Sprintf("bbcc.%d", i), Namespace: "default"},
+			InvolvedObject: corev1.ObjectReference{Kind: "Pod", Name: pod.Name, UID: pod.UID},
+			Reason:         fmt.Sprintf("Reason%d", i),
+			LastTimestamp:  metav1.NewTime(time.Now().Add(time.Duration(i) * time.Minute)),
+		})
+	}
+	kubeCli := kubefake.NewSimpleClientset(pod, events[0], events[1], events[2])
+
+	snap := CaptureSnapshot(context.TODO(), kubeCli, "tcc-gz01", pod, &SnapshotOptions{MaxEvents: 2})
+	if snap.AppName != "bbcc" || snap.ClusterName != "tcc-gz01" || snap.NodeName != "node-1" || snap.UID != "uid-bbcc" {
+		t.Errorf("unexpected snapshot: %+v", snap)
+	}
+	if len(snap.Containers) != 1 {
+		t.Fatalf("expect 1 container, current: %d", len(snap.Containers))
+	}
+	cs := snap.Containers[0]
+	if cs.Image != "registry/bbcc:v2" || cs.RestartCount != 3 || cs.State != "waiting: CrashLoopBackOff" {
+		t.Errorf("unexpected container: %+v", cs)
+	}
+	if cs.LastTermination == nil || cs.LastTermination.Reason != "OOMKilled" || cs.LastTermination.ExitCode != 137 {
+		t.Errorf("expect the last termination, current: %+v", cs.LastTermination)
+	}
+	if cs.Logs != "bbcc-7d9f-x2k4q/bbcc previous: false" || cs.PreviousLogs != "bbcc-7d9f-x2k4q/bbcc previous: true" {
+		t.Errorf("expect the logs and the previous logs, current: %+v", cs)
+	}
+	if len(snap.Events) != 2 || snap.Events[0].Reason != "Reason2" {
+		t.Errorf("expect the 2 most recent events, current: %+v", snap.Events)
+	}
+}
+
+func TestArchive(t *testing.T) {
+	app := &workloadv1beta1.AppSet{ObjectMeta: metav1.ObjectMeta{Name: "bbcc", Namespace: "default", UID: "uid-app"}}
+	cli := fake.NewFakeClientWithScheme(k8sclient.GetScheme(), app)
+	archive := NewArchive(cli, "", 2)
+
+	for i := 0; i < 3; i++ {
+		snap := &model.OfflinePodSnapshot{
+			Name:      fmt.Sprintf("bbcc-%d", i),
+			UID:       fmt.Sprintf("uid-%d", i),
+			Namespace: "default",
+			AppName:   "bbcc",
+			OwnerKind: OwnerKindAppSet,
+		}
+		if err := archive.Save(context.TODO(), snap); err != nil {
+			t.Fatalf("Save() error = %v", err)
+		}
+	}
+
+	// the snapshot of the same pod is kept
+	if err := archive.Save(context.TODO(), &model.OfflinePodSnapshot{Name: "other", UID: "uid-2", Namespace: "default", AppName: "bbcc", OwnerKind: OwnerKindAppSet}); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	// the pods of the other owners are kept in the archive namespace
+	if err := archive.Save(context.TODO(), &model.OfflinePodSnapshot{Name: "aabb-0", UID: "uid-aabb", Namespace: "default", AppName: "aabb", OwnerKind: OwnerKindDeployment}); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	snaps, err := archive.List(context.TODO(), "default", "bbcc")
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(snaps) != 2 {
+		t.Fatalf("expect 2 snapshots kept, current: %d", len(snaps))
+	}
+	for _, s := range snaps {
+		if s.Name == "other" {
+			t.Errorf("expect the first snapshot of the pod kept")
+		}
+	}
+	cm := &corev1.ConfigMap{}
+	if err := cli.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: "offline-uid-2"}, cm); err != nil || cm.OwnerReferences[0].UID != app.UID {
+		t.Errorf("expect the snapshot owned by the AppSet, err: %v", err)
+	}
+
+	other, err := archive.List(context.TODO(), "default", "aabb")
+	if err != nil || len(other) != 1 || other[0].OwnerKind != OwnerKindDeployment {
+		t.Fatalf("expect the snapshot of the Deployment, current: %v err: %v", other, err)
+	}
+	if exists, err := archive.Exists(context.TODO(), "default", "uid-aabb"); err != nil || !exists {
+		t.Errorf("expect the snapshot of the Deployment exists, err: %v", err)
+	}
+
+	deleted, err := archive.GC(context.TODO(), time.Now().Add(time.Hour))
+	if err != nil || deleted != 1 {
+		t.Errorf("expect the snapshot of the Deployment collected, deleted: %d err: %v", deleted, err)
+	}
+}
+
+func TestSnapshotter(t *testing.T) {
+	defer func(stream func(context.Context, kubernetes.Interface, string, string, *corev1.PodLogOptions) (io.ReadCloser, error)) {
+		podLogStream = stream
+	}(podLogStream)
+	podLogStream = func(context.Context, kubernetes.Interface, string, string, *corev1.PodLogOptions) (io.ReadCloser, error) {
+		return ioutil.NopCloser(strings.NewReader("log")), nil
+	}
+
+	app := &workloadv1beta1.AppSet{ObjectMeta: metav1.ObjectMeta{Name: "bbcc", Namespace: "default", UID: "uid-app"}}
+	archive := NewArchive(fake.NewFakeClientWithScheme(k8sclient.GetScheme(), app), "", 0)
+	pod := newTerminatingPod()
+	cluster := &k8smanager.Cluster{Name: "tcc-gz01", KubeCli: kubefake.NewSimpleClientset(pod)}
+
+	s := NewSnapshotter(archive, nil, 2)
+	if !s.Enqueue(cluster, pod) {
+		t.Fatalf("expect the snapshot queued")
+	}
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		s.Start(stop)
+		close(done)
+	}()
+	defer func() {
+		close(stop)
+		<-done
+	}()
+
+	err := wait.PollImmediate(10*time.Millisecond, time.Second, func() (bool, error) {
+		return archive.Exists(context.TODO(), "default", pod.UID)
+	})
+	if err != nil {
+		t.Errorf("expect the snapshot saved in the background, err: %v", err)
+	}
+}
+
+func TestSnapshotCaptured(t *testing.T) {
+	defer func(stream func(context.Context, kubernetes.Interface, string, string, *corev1.PodLogOptions) (io.ReadCloser, error)) {
+		podLogStream = stream
+	}(podLogStream)
+	podLogStream = func(context.Context, kubernetes.Interface, string, string, *corev1.PodLogOptions) (io.ReadCloser, error) {
+		return ioutil.NopCloser(strings.NewReader("log")), nil
+	}
+
+	app := &workloadv1beta1.AppSet{ObjectMeta: metav1.ObjectMeta{Name: "bbcc", Namespace: "default", UID: "uid-app"}}
+	archive := NewArchive(fake.NewFakeClientWithScheme(k8sclient.GetScheme(), app), "", 0)
+	pod := newTerminatingPod()
+	cluster := &k8smanager.Cluster{Name: "tcc-gz01", KubeCli: kubefake.NewSimpleClientset(pod)}
+	c := &offlinepodImpl{Archive: archive}
+
+	c.captured.Store(pod.UID, struct{}{})
+	c.snapshot(context.TODO(), &snapshotRequest{cluster: cluster, pod: pod})
+	if _, ok := c.captured.Load(pod.UID); ok {
+		t.Errorf("expect the pod removed from the captured after the snapshot")
+	}
+	if exists, err := archive.Exists(context.TODO(), "default", pod.UID); err != nil || !exists {
+		t.Errorf("expect the snapshot saved, err: %v", err)
+	}
+}
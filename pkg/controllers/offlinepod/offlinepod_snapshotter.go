@@ -0,0 +1,97 @@
+package offlinepod
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	k8smanager "gitlab.dmall.com/arch/sym-admin/pkg/k8s/manager"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/klog"
+)
+
+const (
+	// DefaultSnapshotWorkers is the number of the snapshots captured at a time
+	DefaultSnapshotWorkers = 4
+	// snapshotQueueSize bounds the snapshots waiting, the pods beyond it are not snapshotted
+	snapshotQueueSize = 1000
+	// snapshotTimeout bounds the capture and the save of a snapshot
+	snapshotTimeout = 30 * time.Second
+)
+
+// Snapshotter captures and archives the snapshots of the pods in the background, so the
+// deletes of the api are not held by the snapshots. The pods queued beyond the queue size
+// are dropped.
+type Snapshotter struct {
+	Archive *Archive
+	Opt     *SnapshotOptions
+	Workers int
+
+	queue chan *snapshotRequest
+}
+
+// NewSnapshotter ...
+func NewSnapshotter(archive *Archive, opt *SnapshotOptions, workers int) *Snapshotter {
+	if workers <= 0 {
+		workers = DefaultSnapshotWorkers
+	}
+	return &Snapshotter{
+		Archive: archive,
+		Opt:     opt,
+		Workers: workers,
+		queue:   make(chan *snapshotRequest, snapshotQueueSize),
+	}
+}
+
+// Enqueue queues the snapshot of the pod, false if the queue is full.
+func (s *Snapshotter) Enqueue(cluster *k8smanager.Cluster, pod *corev1.Pod) bool {
+	select {
+	case s.queue <- &snapshotRequest{cluster: cluster, pod: pod.DeepCopy()}:
+		return true
+	default:
+		klog.Warningf("cluster: %s pod: %s/%s snapshot queue is full, skip", cluster.Name, pod.Namespace, pod.Name)
+		return false
+	}
+}
+
+// Start runs the workers until the stop channel is closed.
+func (s *Snapshotter) Start(stop <-chan struct{}) error {
+	var wg sync.WaitGroup
+	for i := 0; i < s.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case req := <-s.queue:
+					saveSnapshot(s.Archive, s.Opt, req)
+				case <-stop:
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	return nil
+}
+
+// saveSnapshot captures and archives the snapshot of the pod within snapshotTimeout keyed by
+// its top-level owner like the records, the failures are only logged since the pod is gone
+// when retried.
+func saveSnapshot(archive *Archive, opt *SnapshotOptions, req *snapshotRequest) {
+	ctx, cancel := context.WithTimeout(context.Background(), snapshotTimeout)
+	defer cancel()
+
+	owner, err := resolveOwner(ctx, req.cluster.KubeCli, req.pod)
+	if err != nil {
+		klog.Errorf("cluster: %s pod: %s/%s resolve owner err: %v", req.cluster.Name, req.pod.Namespace, req.pod.Name, err)
+		return
+	}
+	snap := CaptureSnapshot(ctx, req.cluster.KubeCli, req.cluster.Name, req.pod, opt)
+	snap.AppName, snap.OwnerKind = owner.Name, owner.Kind
+	if err := archive.Save(ctx, snap); err != nil {
+		klog.Errorf("cluster: %s pod: %s/%s save snapshot err: %v", req.cluster.Name, req.pod.Namespace, req.pod.Name, err)
+		return
+	}
+	klog.V(4).Infof("cluster: %s pod: %s/%s snapshot saved", req.cluster.Name, req.pod.Namespace, req.pod.Name)
+}
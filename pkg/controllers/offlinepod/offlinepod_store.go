@@ -0,0 +1,352 @@
+package offlinepod
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strings"
+	"time"
+
+	"gitlab.dmall.com/arch/sym-admin/pkg/apimanager/model"
+	workloadv1beta1 "gitlab.dmall.com/arch/sym-admin/pkg/apis/workload/v1beta1"
+	"gitlab.dmall.com/arch/sym-admin/pkg/labels"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8slabels "k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/klog"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Query selects the offline pods, the empty fields match all.
+type Query struct {
+	Namespace   string
+	AppName     string
+	ClusterName string
+	NodeName    string
+	// Limit is the max number of the pods in a page, 0 means no limit.
+	Limit int
+	// Continue is the token of the next page returned by the previous page.
+	Continue string
+}
+
+// Page is a page of the offline pods, newest first.
+type Page struct {
+	Items []*model.OfflinePod
+	// Continue is the token of the next page, empty for the last page.
+	Continue string
+}
+
+const (
+	// DefaultRecordNamespace is the namespace of the OfflinePodRecords of all the namespaces,
+	// the namespaces of the member clusters are not always in the master cluster.
+	DefaultRecordNamespace = "sym-admin"
+
+	// listChunkSize is the number of the records of a list request
+	listChunkSize = 500
+)
+
+// Store keeps the offline pods, one record for each pod.
+type Store interface {
+	// Add records the offline pod.
+	Add(ctx context.Context, pod *model.OfflinePod) error
+	// List pages through the offline pods matched by the query, a page may hold fewer pods
+	// than the limit.
+	List(ctx context.Context, q *Query) (*Page, error)
+	// Apps returns the apps having offline pods in the namespace, all namespaces if empty.
+	Apps(ctx context.Context, namespace string) ([]string, error)
+	// GC deletes the pods offline before the time and returns the number of the pods deleted.
+	GC(ctx context.Context, before time.Time) (int, error)
+}
+
+// recordStore keeps the offline pods as OfflinePodRecords in the namespace of the master
+// cluster, the records are listed in chunks by the reader.
+type recordStore struct {
+	client    client.Client
+	reader    client.Reader
+	namespace string
+}
+
+var _ Store = &recordStore{}
+
+// NewRecordStore keeps the records in the namespace, DefaultRecordNamespace if empty. The
+// reader should be uncached so the records are not all kept in memory, the client reads
+// them if the reader is nil.
+func NewRecordStore(c client.Client, reader client.Reader, namespace string) Store {
+	if reader == nil {
+		reader = c
+	}
+	if namespace == "" {
+		namespace = DefaultRecordNamespace
+	}
+	return &recordStore{client: c, reader: reader, namespace: namespace}
+}
+
+// listRecords lists the records matched by the options listChunkSize at a time, fn is
+// called on each record until it returns an error.
+func listRecords(ctx context.Context, reader client.Reader, opts *client.ListOptions, fn func(*workloadv1beta1.OfflinePodRecord) error) error {
+	opts.Limit = listChunkSize
+	opts.Continue = ""
+	for {
+		records := &workloadv1beta1.OfflinePodRecordList{}
+		if err := reader.List(ctx, records, opts); err != nil {
+			return err
+		}
+		for i := range records.Items {
+			if err := fn(&records.Items[i]); err != nil {
+				return err
+			}
+		}
+		if records.Continue == "" {
+			return nil
+		}
+		opts.Continue = records.Continue
+	}
+}
+
+// maxRecordTime is beyond the offline times of the records, the records are named by the
+// time left to it so the API server lists them newest first.
+const maxRecordTime = 9999999999
+
+// recordName is unique for each pod and prefixed by the time before maxRecordTime, the pods
+// without uid restored from the legacy ConfigMaps are identified by the offline time.
+func recordName(pod *model.OfflinePod) string {
+	key := pod.UID
+	if key == "" {
+		key = strings.Join([]string{pod.ClusterName, pod.Namespace, pod.Name, pod.OfflineTime}, "/")
+	}
+	h := fnv.New32a()
+	h.Write([]byte(key))
+
+	name := pod.Name
+	if len(name) > 200 {
+		name = name[:200]
+	}
+	left := maxRecordTime - parseOfflineTime(pod.OfflineTime).Unix()
+	return fmt.Sprintf("%010d-%s-%08x", left, strings.TrimSuffix(name, "-"), h.Sum32())
+}
+
+// labelValue returns the value if it is a valid label value, otherwise the value truncated
+// with its hash, or only the hash if still invalid. The records of the values hashed are
+// filtered by the spec since the hashes may collide.
+func labelValue(va string) (string, bool) {
+	if len(validation.IsValidLabelValue(va)) == 0 {
+		return va, false
+	}
+	h := fnv.New32a()
+	h.Write([]byte(va))
+	hash := fmt.Sprintf("%08x", h.Sum32())
+
+	prefix := va
+	if len(prefix) > validation.LabelValueMaxLength-len(hash)-1 {
+		prefix = prefix[:validation.LabelValueMaxLength-len(hash)-1]
+	}
+	hashed := strings.TrimRight(prefix, "-_.") + "-" + hash
+	if len(validation.IsValidLabelValue(hashed)) != 0 {
+		hashed = hash
+	}
+	return hashed, true
+}
+
+func getRecordLabels(pod *model.OfflinePod) map[string]string {
+	app, _ := labelValue(pod.AppName)
+	cluster, _ := labelValue(pod.ClusterName)
+	lb := map[string]string{
+		labels.LabelNamespace:          pod.Namespace,
+		labels.ObserveMustLabelAppName: app,
+		labels.LabelClusterName:        cluster,
+	}
+	// the node names longer than the label values are only kept in the spec
+	if pod.NodeName != "" && len(validation.IsValidLabelValue(pod.NodeName)) == 0 {
+		lb[labels.LabelNodeName] = pod.NodeName
+	}
+	return lb
+}
+
+func parseOfflineTime(s string) metav1.Time {
+	t, err := time.ParseInLocation(timeLayout, s, time.Local)
+	if err != nil {
+		return metav1.Now()
+	}
+	return metav1.NewTime(t)
+}
+
+func toOfflinePod(r *workloadv1beta1.OfflinePodRecord) *model.OfflinePod {
+	return &model.OfflinePod{
+		Name:            r.Spec.PodName,
+		UID:             r.Spec.PodUID,
+		ClusterName:     r.Spec.ClusterName,
+		Namespace:       r.Spec.Namespace,
+		AppName:         r.Spec.AppName,
+		OwnerKind:       r.Spec.OwnerKind,
+		NodeName:        r.Spec.NodeName,
+		HostIP:          r.Spec.HostIP,
+		PodIP:           r.Spec.PodIP,
+		ContainerID:     r.Spec.ContainerID,
+		Labels:          r.Spec.PodLabels,
+		OfflineTime:     formatTime(r.Spec.OfflineTime),
+		DeletionCause:   r.Spec.DeletionCause,
+		DeletionMessage: r.Spec.DeletionMessage,
+	}
+}
+
+func (s *recordStore) Add(ctx context.Context, pod *model.OfflinePod) error {
+	record := &workloadv1beta1.OfflinePodRecord{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      recordName(pod),
+			Namespace: s.namespace,
+			Labels:    getRecordLabels(pod),
+		},
+		Spec: workloadv1beta1.OfflinePodRecordSpec{
+			PodName:         pod.Name,
+			PodUID:          pod.UID,
+			ClusterName:     pod.ClusterName,
+			Namespace:       pod.Namespace,
+			AppName:         pod.AppName,
+			OwnerKind:       pod.OwnerKind,
+			NodeName:        pod.NodeName,
+			HostIP:          pod.HostIP,
+			PodIP:           pod.PodIP,
+			ContainerID:     pod.ContainerID,
+			PodLabels:       pod.Labels,
+			OfflineTime:     parseOfflineTime(pod.OfflineTime),
+			DeletionCause:   pod.DeletionCause,
+			DeletionMessage: pod.DeletionMessage,
+		},
+	}
+	if err := s.client.Create(ctx, record); err != nil && !apierrors.IsAlreadyExists(err) {
+		return err
+	}
+	return nil
+}
+
+// List pages through the records with the Limit and the Continue of the API server, which
+// lists the records by the name, newest first. The records of the hashed labels and of the
+// node names not labeled are filtered after, so a page may hold fewer pods than the limit.
+func (s *recordStore) List(ctx context.Context, q *Query) (*Page, error) {
+	lb := k8slabels.Set{}
+	if q.Namespace != "" {
+		lb[labels.LabelNamespace] = q.Namespace
+	}
+	app, appHashed := labelValue(q.AppName)
+	if q.AppName != "" {
+		lb[labels.ObserveMustLabelAppName] = app
+	}
+	cluster, clusterHashed := labelValue(q.ClusterName)
+	if q.ClusterName != "" {
+		lb[labels.LabelClusterName] = cluster
+	}
+	nodeLabeled := len(validation.IsValidLabelValue(q.NodeName)) == 0
+	if q.NodeName != "" && nodeLabeled {
+		lb[labels.LabelNodeName] = q.NodeName
+	}
+
+	records := &workloadv1beta1.OfflinePodRecordList{}
+	opts := &client.ListOptions{
+		Namespace:     s.namespace,
+		LabelSelector: lb.AsSelector(),
+		Limit:         int64(q.Limit),
+		Continue:      q.Continue,
+	}
+	if err := s.reader.List(ctx, records, opts); err != nil {
+		return nil, err
+	}
+
+	page := &Page{Items: make([]*model.OfflinePod, 0, len(records.Items)), Continue: records.Continue}
+	for i := range records.Items {
+		r := &records.Items[i]
+		switch {
+		case appHashed && r.Spec.AppName != q.AppName:
+		case clusterHashed && r.Spec.ClusterName != q.ClusterName:
+		case q.NodeName != "" && !nodeLabeled && r.Spec.NodeName != q.NodeName:
+		default:
+			page.Items = append(page.Items, toOfflinePod(r))
+		}
+	}
+	return page, nil
+}
+
+func (s *recordStore) Apps(ctx context.Context, namespace string) ([]string, error) {
+	lb := k8slabels.Set{}
+	if namespace != "" {
+		lb[labels.LabelNamespace] = namespace
+	}
+	seen := make(map[string]struct{})
+	apps := make([]string, 0)
+	opts := &client.ListOptions{Namespace: s.namespace, LabelSelector: lb.AsSelector()}
+	err := listRecords(ctx, s.reader, opts, func(r *workloadv1beta1.OfflinePodRecord) error {
+		if _, ok := seen[r.Spec.AppName]; !ok {
+			seen[r.Spec.AppName] = struct{}{}
+			apps = append(apps, r.Spec.AppName)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(apps)
+	return apps, nil
+}
+
+func (s *recordStore) GC(ctx context.Context, before time.Time) (int, error) {
+	deleted := 0
+	err := listRecords(ctx, s.reader, &client.ListOptions{Namespace: s.namespace}, func(r *workloadv1beta1.OfflinePodRecord) error {
+		if !r.Spec.OfflineTime.Time.Before(before) {
+			return nil
+		}
+		if err := s.client.Delete(ctx, r); err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+		deleted++
+		return nil
+	})
+	return deleted, err
+}
+
+// migrateConfigMaps moves the offline pods of the legacy per-app ConfigMaps to the
+// store, the ConfigMaps are deleted once all of the pods are moved.
+func migrateConfigMaps(ctx context.Context, c client.Client, store Store) error {
+	cms := &corev1.ConfigMapList{}
+	lb := k8slabels.Set(GetConfigMapLabels())
+	if err := c.List(ctx, cms, &client.ListOptions{LabelSelector: lb.AsSelector()}); err != nil {
+		return err
+	}
+
+	for i := range cms.Items {
+		cm := &cms.Items[i]
+		var pods []*model.OfflinePod
+		if raw := cm.Data[ConfigDataKey]; raw != "" {
+			if err := json.Unmarshal([]byte(raw), &pods); err != nil {
+				klog.Errorf("configmap: %s/%s invalid offline pods err: %v", cm.Namespace, cm.Name, err)
+				continue
+			}
+		}
+
+		var err error
+		for _, pod := range pods {
+			if pod.Namespace == "" {
+				pod.Namespace = cm.Namespace
+			}
+			if pod.AppName == "" {
+				pod.AppName = cm.Name
+			}
+			// the legacy ConfigMaps only kept the pods of the AppSets
+			pod.OwnerKind = OwnerKindAppSet
+			if err = store.Add(ctx, pod); err != nil {
+				break
+			}
+		}
+		if err != nil {
+			klog.Errorf("configmap: %s/%s migrate offline pods err: %v", cm.Namespace, cm.Name, err)
+			continue
+		}
+		if err := c.Delete(ctx, cm); err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+		klog.Infof("configmap: %s/%s migrated %d offline pods", cm.Namespace, cm.Name, len(pods))
+	}
+	return nil
+}
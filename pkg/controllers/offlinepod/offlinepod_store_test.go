@@ -0,0 +1,243 @@
+package offlinepod
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"gitlab.dmall.com/arch/sym-admin/pkg/apimanager/model"
+	workloadv1beta1 "gitlab.dmall.com/arch/sym-admin/pkg/apis/workload/v1beta1"
+	k8sclient "gitlab.dmall.com/arch/sym-admin/pkg/k8s/client"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// pagingReader pages the records by the name like the API server, the fake client ignores
+// the limit and the continue.
+type pagingReader struct {
+	client.Reader
+}
+
+func (r *pagingReader) List(ctx context.Context, list runtime.Object, opts ...client.ListOption) error {
+	lo := &client.ListOptions{}
+	lo.ApplyOptions(opts)
+	limit, token := lo.Limit, lo.Continue
+	lo.Limit, lo.Continue = 0, ""
+	if err := r.Reader.List(ctx, list, lo); err != nil {
+		return err
+	}
+	records, ok := list.(*workloadv1beta1.OfflinePodRecordList)
+	if !ok {
+		return nil
+	}
+
+	items := records.Items
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].Name < items[j].Name
+	})
+	if token != "" {
+		after, err := base64.RawURLEncoding.DecodeString(token)
+		if err != nil {
+			return apierrors.NewBadRequest("invalid continue token")
+		}
+		items = items[sort.Search(len(items), func(i int) bool { return items[i].Name > string(after) }):]
+	}
+	records.Continue = ""
+	if limit > 0 && int64(len(items)) > limit {
+		items = items[:limit]
+		records.Continue = base64.RawURLEncoding.EncodeToString([]byte(items[limit-1].Name))
+	}
+	records.Items = items
+	return nil
+}
+
+func newOfflinePod(i int, cluster, node string, offline time.Time) *model.OfflinePod {
+	return &model.OfflinePod{
+		Name:        fmt.Sprintf("bbcc-7d9f-%d", i),
+		UID:         fmt.Sprintf("uid-%d", i),
+		ClusterName: cluster,
+		Namespace:   "default",
+		AppName:     "bbcc",
+		NodeName:    node,
+		OfflineTime: offline.Format(timeLayout),
+	}
+}
+
+func TestRecordStorePaging(t *testing.T) {
+	app := &workloadv1beta1.AppSet{ObjectMeta: metav1.ObjectMeta{Name: "bbcc", Namespace: "default", UID: "uid-app"}}
+	cli := fake.NewFakeClientWithScheme(k8sclient.GetScheme(), app)
+	store := NewRecordStore(cli, &pagingReader{cli}, "")
+	ctx := context.TODO()
+
+	now := time.Now()
+	for i := 0; i < 5; i++ {
+		cluster := "tcc-gz01"
+		if i%2 == 1 {
+			cluster = "tcc-rz01"
+		}
+		// pods 3 and 4 are deleted at the same second
+		offline := now.Add(time.Duration(i) * time.Minute)
+		if i == 4 {
+			offline = now.Add(3 * time.Minute)
+		}
+		if err := store.Add(ctx, newOfflinePod(i, cluster, fmt.Sprintf("node-%d", i), offline)); err != nil {
+			t.Fatalf("add err: %v", err)
+		}
+	}
+	// added again by the resync
+	if err := store.Add(ctx, newOfflinePod(0, "tcc-gz01", "node-0", now)); err != nil {
+		t.Fatalf("add err: %v", err)
+	}
+	other := newOfflinePod(9, "tcc-gz01", "node-9", now)
+	other.AppName = "other"
+	other.OwnerKind = OwnerKindDeployment
+	if err := store.Add(ctx, other); err != nil {
+		t.Fatalf("add pod without AppSet err: %v", err)
+	}
+	records := &workloadv1beta1.OfflinePodRecordList{}
+	if err := cli.List(ctx, records, client.InNamespace(DefaultRecordNamespace)); err != nil || len(records.Items) != 6 {
+		t.Errorf("expect 6 records in %s, current: %d, err: %v", DefaultRecordNamespace, len(records.Items), err)
+	}
+
+	var names []string
+	q := &Query{Namespace: "default", AppName: "bbcc", Limit: 2}
+	for pages := 0; ; pages++ {
+		if pages > 3 {
+			t.Fatalf("expect 3 pages")
+		}
+		page, err := store.List(ctx, q)
+		if err != nil {
+			t.Fatalf("list err: %v", err)
+		}
+		for _, p := range page.Items {
+			names = append(names, p.Name)
+		}
+		if page.Continue == "" {
+			break
+		}
+		q.Continue = page.Continue
+	}
+	// newest first, the pods deleted at the same second are ordered by the pod name
+	expect := []string{"bbcc-7d9f-3", "bbcc-7d9f-4", "bbcc-7d9f-2", "bbcc-7d9f-1", "bbcc-7d9f-0"}
+	if fmt.Sprint(names) != fmt.Sprint(expect) {
+		t.Errorf("expect pods: %v, current: %v", expect, names)
+	}
+
+	page, err := store.List(ctx, &Query{Namespace: "default", AppName: "bbcc", ClusterName: "tcc-rz01"})
+	if err != nil || len(page.Items) != 2 || page.Continue != "" {
+		t.Errorf("expect 2 pods of tcc-rz01, current: %+v, err: %v", page, err)
+	}
+	page, err = store.List(ctx, &Query{Namespace: "default", AppName: "bbcc", NodeName: "node-2"})
+	if err != nil || len(page.Items) != 1 || page.Items[0].Name != "bbcc-7d9f-2" || page.Items[0].ClusterName != "tcc-gz01" {
+		t.Errorf("expect the pod of node-2, current: %+v, err: %v", page, err)
+	}
+	if _, err := store.List(ctx, &Query{Continue: "invalid!"}); err == nil {
+		t.Errorf("expect invalid continue token err")
+	}
+
+	apps, err := store.Apps(ctx, "")
+	if err != nil || fmt.Sprint(apps) != "[bbcc other]" {
+		t.Errorf("expect apps [bbcc other], current: %v, err: %v", apps, err)
+	}
+	if apps, err := store.Apps(ctx, "kube-system"); err != nil || len(apps) != 0 {
+		t.Errorf("expect no apps in kube-system, current: %v, err: %v", apps, err)
+	}
+	page, err = store.List(ctx, &Query{Namespace: "default", AppName: "other"})
+	if err != nil || len(page.Items) != 1 || page.Items[0].OwnerKind != OwnerKindDeployment {
+		t.Errorf("expect the pod of the Deployment other, current: %+v, err: %v", page, err)
+	}
+}
+
+func TestRecordStoreLongLabels(t *testing.T) {
+	cli := fake.NewFakeClientWithScheme(k8sclient.GetScheme())
+	store := NewRecordStore(cli, &pagingReader{cli}, "sym-system")
+	ctx := context.TODO()
+
+	long := strings.Repeat("bbcc-", 14) + "cc"
+	now := time.Now()
+	for i, app := range []string{long, long + "-other"} {
+		pod := newOfflinePod(i, "tcc-gz01-"+strings.Repeat("a", 60), "node-1", now)
+		pod.AppName = app
+		if err := store.Add(ctx, pod); err != nil {
+			t.Fatalf("add err: %v", err)
+		}
+	}
+	records := &workloadv1beta1.OfflinePodRecordList{}
+	if err := cli.List(ctx, records, client.InNamespace("sym-system")); err != nil || len(records.Items) != 2 {
+		t.Fatalf("expect 2 records in sym-system, current: %d, err: %v", len(records.Items), err)
+	}
+	for _, r := range records.Items {
+		for k, v := range r.Labels {
+			if errs := validation.IsValidLabelValue(v); len(errs) != 0 {
+				t.Errorf("invalid label %s=%s: %v", k, v, errs)
+			}
+		}
+	}
+
+	page, err := store.List(ctx, &Query{AppName: long, ClusterName: "tcc-gz01-" + strings.Repeat("a", 60)})
+	if err != nil || len(page.Items) != 1 || page.Items[0].AppName != long {
+		t.Errorf("expect the pod of the long app, current: %+v, err: %v", page, err)
+	}
+}
+
+func TestRecordStoreGC(t *testing.T) {
+	app := &workloadv1beta1.AppSet{ObjectMeta: metav1.ObjectMeta{Name: "bbcc", Namespace: "default", UID: "uid-app"}}
+	cli := fake.NewFakeClientWithScheme(k8sclient.GetScheme(), app)
+	store := NewRecordStore(cli, &pagingReader{cli}, "")
+	ctx := context.TODO()
+
+	now := time.Now()
+	for i := 0; i < 3; i++ {
+		if err := store.Add(ctx, newOfflinePod(i, "tcc-gz01", "node-1", now.Add(-time.Duration(i)*24*time.Hour))); err != nil {
+			t.Fatalf("add err: %v", err)
+		}
+	}
+
+	deleted, err := store.GC(ctx, now.Add(-36*time.Hour))
+	if err != nil || deleted != 1 {
+		t.Errorf("expect 1 pod deleted, current: %d, err: %v", deleted, err)
+	}
+	page, err := store.List(ctx, &Query{Namespace: "default", AppName: "bbcc"})
+	if err != nil || len(page.Items) != 2 {
+		t.Errorf("expect 2 pods kept, current: %+v, err: %v", page, err)
+	}
+}
+
+func TestMigrateConfigMaps(t *testing.T) {
+	app := &workloadv1beta1.AppSet{ObjectMeta: metav1.ObjectMeta{Name: "bbcc", Namespace: "default", UID: "uid-app"}}
+	now := time.Now()
+	legacy := []*model.OfflinePod{
+		{Name: "bbcc-7d9f-0", ClusterName: "tcc-gz01", HostIP: "10.0.0.1", OfflineTime: now.Format(timeLayout)},
+		{Name: "bbcc-7d9f-1", ClusterName: "tcc-gz01", HostIP: "10.0.0.2", OfflineTime: now.Add(-time.Minute).Format(timeLayout)},
+	}
+	raw, _ := json.Marshal(legacy)
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "bbcc", Namespace: "default", Labels: GetConfigMapLabels()},
+		Data:       map[string]string{ConfigDataKey: string(raw)},
+	}
+	cli := fake.NewFakeClientWithScheme(k8sclient.GetScheme(), app, cm)
+	store := NewRecordStore(cli, &pagingReader{cli}, "")
+	ctx := context.TODO()
+
+	if err := migrateConfigMaps(ctx, cli, store); err != nil {
+		t.Fatalf("migrate err: %v", err)
+	}
+	page, err := store.List(ctx, &Query{Namespace: "default", AppName: "bbcc"})
+	if err != nil || len(page.Items) != 2 || page.Items[0].Name != "bbcc-7d9f-0" || page.Items[0].HostIP != "10.0.0.1" {
+		t.Errorf("expect the legacy pods migrated, current: %+v, err: %v", page, err)
+	}
+	cms := &corev1.ConfigMapList{}
+	if err := cli.List(ctx, cms, client.InNamespace("default")); err != nil || len(cms.Items) != 0 {
+		t.Errorf("expect the legacy configmap deleted, current: %d, err: %v", len(cms.Items), err)
+	}
+}
@@ -0,0 +1,154 @@
+package eventstore
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"time"
+
+	"emperror.dev/errors"
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	// the records keyed by the LastTime in nanoseconds and the ID
+	recordsBucket = []byte("records")
+	// the keys of the records in recordsBucket by the ID
+	idsBucket = []byte("ids")
+)
+
+// BoltStore keeps the records in a local bolt file.
+type BoltStore struct {
+	db *bolt.DB
+}
+
+var _ Store = &BoltStore{}
+
+// NewBoltStore opens or creates the bolt file.
+func NewBoltStore(path string) (*BoltStore, error) {
+	if path == "" {
+		return nil, errors.New("event store path is empty")
+	}
+
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, errors.Wrapf(err, "open event store: %s", path)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, b := range [][]byte{recordsBucket, idsBucket} {
+			if _, err := tx.CreateBucketIfNotExists(b); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, errors.Wrap(err, "create event store buckets")
+	}
+	return &BoltStore{db: db}, nil
+}
+
+func timeKey(t time.Time) []byte {
+	k := make([]byte, 8)
+	binary.BigEndian.PutUint64(k, uint64(t.UnixNano()))
+	return k
+}
+
+func recordKey(r *Record) []byte {
+	return append(timeKey(r.LastTime), []byte(r.ID)...)
+}
+
+// Put ...
+func (s *BoltStore) Put(records ...*Record) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		rb, ib := tx.Bucket(recordsBucket), tx.Bucket(idsBucket)
+		for _, r := range records {
+			value, err := json.Marshal(r)
+			if err != nil {
+				return err
+			}
+
+			key := recordKey(r)
+			if old := ib.Get([]byte(r.ID)); old != nil && !bytes.Equal(old, key) {
+				if err := rb.Delete(old); err != nil {
+					return err
+				}
+			}
+			if err := rb.Put(key, value); err != nil {
+				return err
+			}
+			if err := ib.Put([]byte(r.ID), key); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Query scans the records backward from the end of the time range.
+func (s *BoltStore) Query(q *Query) ([]*Record, error) {
+	limit := limitOf(q)
+	result := make([]*Record, 0)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(recordsBucket).Cursor()
+
+		var k, v []byte
+		if q.End.IsZero() {
+			k, v = c.Last()
+		} else {
+			// seek to the first key after the end
+			k, v = c.Seek(timeKey(q.End.Add(time.Nanosecond)))
+			if k == nil {
+				k, v = c.Last()
+			} else {
+				k, v = c.Prev()
+			}
+		}
+
+		var start []byte
+		if !q.Start.IsZero() {
+			start = timeKey(q.Start)
+		}
+		for ; k != nil && len(result) < limit; k, v = c.Prev() {
+			if start != nil && bytes.Compare(k[:8], start) < 0 {
+				break
+			}
+			r := &Record{}
+			if err := json.Unmarshal(v, r); err != nil {
+				return errors.Wrapf(err, "decode event record: %s", k[8:])
+			}
+			if q.Match(r) {
+				result = append(result, r)
+			}
+		}
+		return nil
+	})
+	return result, err
+}
+
+// Prune ...
+func (s *BoltStore) Prune(before time.Time) (int, error) {
+	var n int
+	end := timeKey(before)
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		rb, ib := tx.Bucket(recordsBucket), tx.Bucket(idsBucket)
+		c := rb.Cursor()
+		for k, _ := c.First(); k != nil && bytes.Compare(k[:8], end) < 0; k, _ = c.Next() {
+			if err := ib.Delete(k[8:]); err != nil {
+				return err
+			}
+			if err := c.Delete(); err != nil {
+				return err
+			}
+			n++
+		}
+		return nil
+	})
+	return n, err
+}
+
+// Close ...
+func (s *BoltStore) Close() error {
+	return s.db.Close()
+}
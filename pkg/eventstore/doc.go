@@ -0,0 +1,4 @@
+// Package eventstore keeps the history of the warning events of the clusters after
+// they are gone from the api server. The events are pushed by the event exporter of
+// each cluster and expired by the retention.
+package eventstore
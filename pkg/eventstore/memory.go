@@ -0,0 +1,103 @@
+package eventstore
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"k8s.io/klog"
+)
+
+// DefaultMemoryMaxRecords is the max records of a memory store without one.
+const DefaultMemoryMaxRecords = 100000
+
+// MemoryStore keeps the records in memory, the records are lost on restart. The oldest
+// records are evicted once the store is full.
+type MemoryStore struct {
+	mu         sync.RWMutex
+	records    map[string]*Record
+	maxRecords int
+}
+
+var _ Store = &MemoryStore{}
+
+// NewMemoryStore returns the store of at most maxRecords, DefaultMemoryMaxRecords if it is not positive.
+func NewMemoryStore(maxRecords int) *MemoryStore {
+	if maxRecords <= 0 {
+		maxRecords = DefaultMemoryMaxRecords
+	}
+	return &MemoryStore{records: make(map[string]*Record), maxRecords: maxRecords}
+}
+
+// Put ...
+func (s *MemoryStore) Put(records ...*Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, r := range records {
+		s.records[r.ID] = r
+	}
+	if len(s.records) > s.maxRecords {
+		s.evict()
+	}
+	return nil
+}
+
+// evict deletes the oldest records down to 90% of maxRecords, so the records are not
+// sorted on every Put of a full store.
+func (s *MemoryStore) evict() {
+	all := make([]*Record, 0, len(s.records))
+	for _, r := range s.records {
+		all = append(all, r)
+	}
+	sort.Slice(all, func(i, j int) bool {
+		return all[i].LastTime.Before(all[j].LastTime)
+	})
+	n := len(all) - s.maxRecords*9/10
+	for _, r := range all[:n] {
+		delete(s.records, r.ID)
+	}
+	klog.V(4).Infof("evicted %d events of the full memory store", n)
+}
+
+// Query ...
+func (s *MemoryStore) Query(q *Query) ([]*Record, error) {
+	s.mu.RLock()
+	result := make([]*Record, 0)
+	for _, r := range s.records {
+		if q.Match(r) {
+			result = append(result, r)
+		}
+	}
+	s.mu.RUnlock()
+
+	sort.Slice(result, func(i, j int) bool {
+		if !result[i].LastTime.Equal(result[j].LastTime) {
+			return result[i].LastTime.After(result[j].LastTime)
+		}
+		return result[i].ID > result[j].ID
+	})
+	if limit := limitOf(q); len(result) > limit {
+		result = result[:limit]
+	}
+	return result, nil
+}
+
+// Prune ...
+func (s *MemoryStore) Prune(before time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var n int
+	for id, r := range s.records {
+		if r.LastTime.Before(before) {
+			delete(s.records, id)
+			n++
+		}
+	}
+	return n, nil
+}
+
+// Close ...
+func (s *MemoryStore) Close() error {
+	return nil
+}
@@ -0,0 +1,101 @@
+package eventstore
+
+import (
+	"fmt"
+	"time"
+
+	"gitlab.dmall.com/arch/sym-admin/pkg/controllers/eventexporter/kube"
+	pkgLabels "gitlab.dmall.com/arch/sym-admin/pkg/labels"
+)
+
+// Record is an event kept in the store.
+type Record struct {
+	Cluster    string    `json:"cluster"`
+	Namespace  string    `json:"namespace"`
+	App        string    `json:"app,omitempty"`
+	PodSet     string    `json:"podSet,omitempty"`
+	Group      string    `json:"group,omitempty"`
+	ObjectKind string    `json:"objectKind"`
+	ObjectName string    `json:"objectName"`
+	Type       string    `json:"type"`
+	Reason     string    `json:"reason"`
+	Message    string    `json:"message"`
+	Count      int32     `json:"count"`
+	FirstTime  time.Time `json:"firstTime"`
+	LastTime   time.Time `json:"lastTime"`
+	// ID identifies the event in the cluster, the newer version of an event replaces the older.
+	ID string `json:"id"`
+}
+
+// NewRecord builds the record of an event enhanced by the event exporter.
+func NewRecord(ev *kube.EnhancedEvent) *Record {
+	lb := ev.InvolvedObject.Labels
+	r := &Record{
+		Cluster:    ev.ClusterName,
+		Namespace:  ev.Namespace,
+		App:        lb[pkgLabels.ObserveMustLabelAppName],
+		PodSet:     lb[pkgLabels.ObserveMustLabelReleaseName],
+		Group:      lb[pkgLabels.ObserveMustLabelGroupName],
+		ObjectKind: ev.InvolvedObject.Kind,
+		ObjectName: ev.InvolvedObject.Name,
+		Type:       ev.Type,
+		Reason:     ev.Reason,
+		Message:    ev.Message,
+		Count:      ev.Count,
+		FirstTime:  ev.FirstTimestamp.Time,
+		LastTime:   ev.GetTimestamp(),
+	}
+	if r.Namespace == "" {
+		r.Namespace = ev.InvolvedObject.Namespace
+	}
+	if r.FirstTime.IsZero() {
+		r.FirstTime = r.LastTime
+	}
+	if ev.Aggregated > r.Count {
+		r.Count = ev.Aggregated
+	}
+
+	r.ID = fmt.Sprintf("%s/%s/%s", r.Cluster, r.Namespace, ev.Name)
+	if ev.UID != "" {
+		r.ID = fmt.Sprintf("%s/%s", r.Cluster, ev.UID)
+	}
+	return r
+}
+
+// Query selects the records, the empty fields match all.
+type Query struct {
+	Cluster   string
+	Namespace string
+	App       string
+	PodSet    string
+	Reason    string
+	Type      string
+	// Start and End bound the LastTime of the records, the zero values are unbounded.
+	Start time.Time
+	End   time.Time
+	// Limit is the max number of the records returned, the newest first.
+	Limit int
+}
+
+// Match returns whether the record is selected by the query.
+func (q *Query) Match(r *Record) bool {
+	switch {
+	case q.Cluster != "" && q.Cluster != r.Cluster:
+		return false
+	case q.Namespace != "" && q.Namespace != r.Namespace:
+		return false
+	case q.App != "" && q.App != r.App:
+		return false
+	case q.PodSet != "" && q.PodSet != r.PodSet:
+		return false
+	case q.Reason != "" && q.Reason != r.Reason:
+		return false
+	case q.Type != "" && q.Type != r.Type:
+		return false
+	case !q.Start.IsZero() && r.LastTime.Before(q.Start):
+		return false
+	case !q.End.IsZero() && r.LastTime.After(q.End):
+		return false
+	}
+	return true
+}
@@ -0,0 +1,80 @@
+package eventstore
+
+import (
+	"fmt"
+	"time"
+
+	"k8s.io/klog"
+)
+
+// the backends of the store
+const (
+	BackendBolt   = "bolt"
+	BackendMemory = "memory"
+)
+
+// DefaultQueryLimit is the limit of a query without one.
+const DefaultQueryLimit = 500
+
+// Store keeps the records ordered by their LastTime.
+type Store interface {
+	// Put adds the records, a record replaces the stored one with the same ID.
+	Put(records ...*Record) error
+	// Query returns the records selected, the newest first.
+	Query(q *Query) ([]*Record, error)
+	// Prune deletes the records last seen before the time and returns the number deleted.
+	Prune(before time.Time) (int, error)
+	Close() error
+}
+
+// Options ...
+type Options struct {
+	Backend   string
+	Path      string
+	Retention time.Duration
+	// MaxRecords bounds the records of the memory backend
+	MaxRecords int
+}
+
+// New creates the store of the backend.
+func New(opt *Options) (Store, error) {
+	switch opt.Backend {
+	case BackendBolt, "":
+		return NewBoltStore(opt.Path)
+	case BackendMemory:
+		return NewMemoryStore(opt.MaxRecords), nil
+	default:
+		return nil, fmt.Errorf("unknown event store backend: %s", opt.Backend)
+	}
+}
+
+// RunRetention prunes the records older than the retention every interval until the stop channel is closed.
+func RunRetention(s Store, retention, interval time.Duration, stop <-chan struct{}) {
+	if retention <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		n, err := s.Prune(time.Now().Add(-retention))
+		if err != nil {
+			klog.Errorf("prune the event store err: %v", err)
+		} else if n > 0 {
+			klog.V(4).Infof("pruned %d events older than %v", n, retention)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+	}
+}
+
+func limitOf(q *Query) int {
+	if q.Limit <= 0 {
+		return DefaultQueryLimit
+	}
+	return q.Limit
+}
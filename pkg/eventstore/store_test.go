@@ -0,0 +1,147 @@
+package eventstore
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"gitlab.dmall.com/arch/sym-admin/pkg/controllers/eventexporter/kube"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func newEvent(cluster, uid, app, podSet, reason string, ts time.Time) *kube.EnhancedEvent {
+	ev := &kube.EnhancedEvent{
+		Event: corev1.Event{
+			ObjectMeta:    metav1.ObjectMeta{Name: uid, Namespace: "default", UID: types.UID(uid)},
+			Type:          corev1.EventTypeWarning,
+			Reason:        reason,
+			Count:         1,
+			LastTimestamp: metav1.NewTime(ts),
+		},
+		ClusterName: cluster,
+	}
+	ev.InvolvedObject.Kind = "Pod"
+	ev.InvolvedObject.Labels = map[string]string{"app": app, "release": podSet}
+	return ev
+}
+
+func testStore(t *testing.T, s Store) {
+	now := time.Now().Truncate(time.Second)
+	var records []*Record
+	for _, ev := range []*kube.EnhancedEvent{
+		newEvent("tcc-gz01", "1", "bbcc", "bbcc-gz01a", "BackOff", now.Add(-3*time.Hour)),
+		newEvent("tcc-gz01", "2", "bbcc", "bbcc-gz01b", "Unhealthy", now.Add(-2*time.Hour)),
+		newEvent("tcc-bj01", "3", "bbcc", "bbcc-bj01a", "BackOff", now.Add(-time.Hour)),
+		newEvent("tcc-gz01", "4", "aabb", "aabb-gz01a", "BackOff", now),
+	} {
+		records = append(records, NewRecord(ev))
+	}
+	if err := s.Put(records...); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name   string
+		query  *Query
+		expect []string
+	}{
+		{"all newest first", &Query{}, []string{"4", "3", "2", "1"}},
+		{"cluster", &Query{Cluster: "tcc-gz01"}, []string{"4", "2", "1"}},
+		{"app and reason", &Query{App: "bbcc", Reason: "BackOff"}, []string{"3", "1"}},
+		{"podSet", &Query{PodSet: "bbcc-gz01b"}, []string{"2"}},
+		{"type", &Query{Type: corev1.EventTypeNormal}, nil},
+		{"time range", &Query{Start: now.Add(-2 * time.Hour), End: now.Add(-time.Hour)}, []string{"3", "2"}},
+		{"limit", &Query{Limit: 2}, []string{"4", "3"}},
+	}
+	check := func(name string, q *Query, expect []string) {
+		result, err := s.Query(q)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		var ids []string
+		for _, r := range result {
+			ids = append(ids, r.ID[len(r.Cluster)+1:])
+		}
+		if len(ids) != len(expect) {
+			t.Errorf("%s: expect %v, current: %v", name, expect, ids)
+			return
+		}
+		for i := range ids {
+			if ids[i] != expect[i] {
+				t.Errorf("%s: expect %v, current: %v", name, expect, ids)
+				return
+			}
+		}
+	}
+	for _, c := range cases {
+		check(c.name, c.query, c.expect)
+	}
+
+	// the newer version of an event replaces the older
+	updated := newEvent("tcc-gz01", "1", "bbcc", "bbcc-gz01a", "BackOff", now.Add(time.Minute))
+	updated.Count = 5
+	if err := s.Put(NewRecord(updated)); err != nil {
+		t.Fatal(err)
+	}
+	check("updated", &Query{}, []string{"1", "4", "3", "2"})
+	if result, _ := s.Query(&Query{Limit: 1}); result[0].Count != 5 {
+		t.Errorf("expect the count of the updated event, current: %d", result[0].Count)
+	}
+
+	n, err := s.Prune(now.Add(-90 * time.Minute))
+	if err != nil || n != 1 {
+		t.Errorf("expect 1 pruned, current: %d %v", n, err)
+	}
+	check("pruned", &Query{}, []string{"1", "4", "3"})
+}
+
+func TestBoltStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "eventstore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "events.db")
+	s, err := NewBoltStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testStore(t, s)
+	s.Close()
+
+	// the records survive the restart
+	reopened, err := New(&Options{Backend: BackendBolt, Path: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	if result, _ := reopened.Query(&Query{}); len(result) != 3 {
+		t.Errorf("expect 3 records after reopen, current: %d", len(result))
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	testStore(t, NewMemoryStore(0))
+}
+
+func TestMemoryStoreEviction(t *testing.T) {
+	s := NewMemoryStore(10)
+	now := time.Now()
+	for i := 0; i < 11; i++ {
+		s.Put(&Record{ID: strconv.Itoa(i), LastTime: now.Add(time.Duration(i) * time.Minute)})
+	}
+
+	result, _ := s.Query(&Query{})
+	if len(result) != 9 {
+		t.Fatalf("expect evicted to 9 records, current: %d", len(result))
+	}
+	if oldest := result[len(result)-1].ID; oldest != "2" {
+		t.Errorf("expect the oldest records evicted, the oldest kept: %s", oldest)
+	}
+}
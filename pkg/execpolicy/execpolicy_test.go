@@ -0,0 +1,121 @@
+package execpolicy
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestSplit(t *testing.T) {
+	tests := []struct {
+		line   string
+		expect []string
+		err    bool
+	}{
+		{line: "ls -l  /web/logs", expect: []string{"ls", "-l", "/web/logs"}},
+		{line: `grep 'a b' "/web/logs/x y.log"`, expect: []string{"grep", "a b", "/web/logs/x y.log"}},
+		{line: `echo "a\"b" a\ b '$HOME' ""`, expect: []string{"echo", `a"b`, "a b", "$HOME", ""}},
+		{line: "cat /etc/passwd; rm -rf /", expect: []string{"cat", "/etc/passwd;", "rm", "-rf", "/"}},
+		{line: "echo 'a", err: true},
+		{line: `echo a\`, err: true},
+	}
+	for _, tt := range tests {
+		args, err := Split(tt.line)
+		if (err != nil) != tt.err || (!tt.err && !reflect.DeepEqual(args, tt.expect)) {
+			t.Errorf("split %q: expect %q, current %q, err: %v", tt.line, tt.expect, args, err)
+		}
+	}
+}
+
+func TestAllow(t *testing.T) {
+	p, err := New(&Config{
+		Rules: []*Rule{
+			{
+				Groups: []string{"dev"},
+				Commands: []*CommandRule{
+					{Command: "ls", Args: []string{"-*", "/web/logs/**"}},
+					{Command: "cat", Args: []string{"/web/logs/*/*.log"}},
+				},
+			},
+			{Users: []string{"alice"}, Commands: []*CommandRule{{Command: Wildcard}}},
+		},
+		Deny: []*CommandRule{{Command: "rm"}, {Command: Wildcard, Args: []string{"/etc/shadow"}}},
+	})
+	if err != nil {
+		t.Fatalf("new policy err: %v", err)
+	}
+
+	tests := []struct {
+		user   string
+		groups []string
+		argv   []string
+		allow  bool
+	}{
+		{user: "bob", groups: []string{"dev"}, argv: []string{"ls", "-l", "/web/logs/app"}, allow: true},
+		{user: "bob", groups: []string{"dev"}, argv: []string{"/bin/ls", "/web/logs"}, allow: true},
+		{user: "bob", groups: []string{"dev"}, argv: []string{"/tmp/ls", "/web/logs"}},
+		{user: "bob", groups: []string{"dev"}, argv: []string{"ls", "/web/logs/../../etc"}},
+		{user: "bob", groups: []string{"dev"}, argv: []string{"ls", "/etc"}},
+		{user: "bob", groups: []string{"dev"}, argv: []string{"cat", "/web/logs/app/a.log"}, allow: true},
+		{user: "bob", groups: []string{"dev"}, argv: []string{"cat", "/web/logs/../x.log"}},
+		{user: "bob", groups: []string{"dev"}, argv: []string{"sh", "-c", "ls /web/logs"}},
+		{user: "bob", groups: []string{"ops"}, argv: []string{"ls"}},
+		{user: "alice", argv: []string{"sh", "-c", "top -b -n1"}, allow: true},
+		{user: "alice", argv: []string{"rm", "-rf", "/web/logs"}},
+		{user: "alice", argv: []string{"/tmp/rm", "-rf", "/web/logs"}},
+		{user: "alice", argv: []string{"./rm", "-rf", "/web/logs"}},
+		{user: "alice", argv: []string{"cat", "/etc/shadow"}},
+		{user: "alice", argv: nil},
+	}
+	for _, tt := range tests {
+		err := p.Allow(tt.user, tt.groups, tt.argv)
+		if (err == nil) != tt.allow {
+			t.Errorf("user %s exec %q: expect allowed %v, err: %v", tt.user, tt.argv, tt.allow, err)
+		}
+		if err != nil && !IsDenied(err) {
+			t.Errorf("expect denied err, current: %v", err)
+		}
+	}
+
+	var open *Policy
+	if err := open.Allow("", nil, []string{"rm", "-rf", "/"}); err != nil {
+		t.Errorf("expect the nil policy allows all, err: %v", err)
+	}
+	if _, err := New(&Config{Rules: []*Rule{{Commands: []*CommandRule{{Command: "ls"}}}}}); err == nil {
+		t.Errorf("expect the rule without the subjects invalid")
+	}
+}
+
+func TestResolveLogPath(t *testing.T) {
+	var p *Policy
+	for file, expect := range map[string]string{
+		"/web/logs/app/":          "/web/logs/app",
+		"/web/logs/./app/a.log":   "/web/logs/app/a.log",
+		"/web/logs/../etc/passwd": "",
+		"/web/logsx":              "",
+		"web/logs/a.log":          "",
+		"":                        "",
+	} {
+		current, err := p.LogPath(file)
+		if current != expect || (expect == "") != (err != nil) {
+			t.Errorf("log path of %q: expect %q, current %q, err: %v", file, expect, current, err)
+		}
+	}
+
+	links := map[string]string{
+		"/web/logs/app/a.log": "/web/logs/app/a.log",
+		"/web/logs/app/link":  "/etc/passwd",
+	}
+	run := func(argv []string) ([]byte, error) {
+		if target, ok := links[argv[len(argv)-1]]; ok {
+			return []byte(target + "\n"), nil
+		}
+		return nil, errors.New("not found")
+	}
+	if resolved, err := p.ResolveLogPath("/web/logs/app/a.log", run); err != nil || resolved != "/web/logs/app/a.log" {
+		t.Errorf("unexpected resolved path: %s, err: %v", resolved, err)
+	}
+	if _, err := p.ResolveLogPath("/web/logs/app/link", run); !IsDenied(err) {
+		t.Errorf("expect the symlink out of the roots denied, err: %v", err)
+	}
+}
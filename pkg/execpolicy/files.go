@@ -0,0 +1,106 @@
+package execpolicy
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// DefaultLogRoots are the log roots of the policy without them.
+var DefaultLogRoots = []string{"/web/logs"}
+
+func (p *Policy) roots() []string {
+	if p == nil || len(p.logRoots) == 0 {
+		return DefaultLogRoots
+	}
+	return p.logRoots
+}
+
+// LogPath cleans the absolute path and returns a DeniedError if it is out of the log roots.
+func (p *Policy) LogPath(file string) (string, error) {
+	if file == "" {
+		return "", fmt.Errorf("path is required")
+	}
+	if !path.IsAbs(file) {
+		return "", fmt.Errorf("path %s is not absolute", file)
+	}
+	file = path.Clean(file)
+	for _, root := range p.roots() {
+		if file == root || strings.HasPrefix(file, root+"/") {
+			return file, nil
+		}
+	}
+	return "", &DeniedError{Reason: fmt.Sprintf("path %s is out of the log directories %s", file, strings.Join(p.roots(), ", "))}
+}
+
+// ResolveCommand prints the path with the symlinks resolved.
+func ResolveCommand(file string) []string {
+	return []string{"readlink", "-f", "--", file}
+}
+
+// ResolveLogPath confines the path to the log roots before and after its symlinks are
+// resolved by ResolveCommand run in the container, the resolved path is returned.
+func (p *Policy) ResolveLogPath(file string, run func(argv []string) ([]byte, error)) (string, error) {
+	file, err := p.LogPath(file)
+	if err != nil {
+		return "", err
+	}
+	out, err := run(ResolveCommand(file))
+	if err != nil {
+		return "", err
+	}
+	resolved := strings.TrimSpace(string(out))
+	if resolved == "" {
+		return "", fmt.Errorf("path %s not found", file)
+	}
+	return p.LogPath(resolved)
+}
+
+// Split splits the command line into the arguments like a shell without any expansion,
+// the single and the double quotes group the words and the backslash escapes.
+func Split(line string) ([]string, error) {
+	var (
+		args    []string
+		word    strings.Builder
+		inWord  bool
+		quote   rune
+		escaped bool
+	)
+	for _, r := range line {
+		switch {
+		case escaped:
+			// only these are escaped in the double quotes
+			if quote == '"' && !strings.ContainsRune(`"\$`+"`", r) {
+				word.WriteRune('\\')
+			}
+			word.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped, inWord = true, true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				word.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote, inWord = r, true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inWord {
+				args = append(args, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+	if escaped || quote != 0 {
+		return nil, fmt.Errorf("unterminated quote or escape in command: %s", line)
+	}
+	if inWord {
+		args = append(args, word.String())
+	}
+	return args, nil
+}
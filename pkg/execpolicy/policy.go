@@ -0,0 +1,258 @@
+// Package execpolicy decides the commands the users may exec in the containers through the
+// api, and confines the log files read by the built-in file operations to the log roots.
+// The commands are argument vectors, they are never interpolated by a shell.
+package execpolicy
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Wildcard matches all the users, groups or commands of a rule
+const Wildcard = "*"
+
+// binDirs are where the commands named without a path are looked up
+var binDirs = []string{"/bin", "/usr/bin", "/sbin", "/usr/sbin", "/usr/local/bin"}
+
+// Config is the exec policy of the api, a section of the auth config.
+type Config struct {
+	// Rules allow the commands to the users or the members of the groups, the commands
+	// allowed by none are denied.
+	Rules []*Rule `json:"rules,omitempty"`
+	// Deny are the commands denied to everyone even if allowed by the rules.
+	Deny []*CommandRule `json:"deny,omitempty"`
+	// LogRoots are the only directories the log file operations read, /web/logs by default.
+	LogRoots []string `json:"logRoots,omitempty"`
+}
+
+// Rule allows the commands to the users or the members of the groups.
+type Rule struct {
+	Users    []string       `json:"users,omitempty"`
+	Groups   []string       `json:"groups,omitempty"`
+	Commands []*CommandRule `json:"commands"`
+}
+
+// CommandRule matches a command and its arguments.
+type CommandRule struct {
+	// Command is the path or the name of the executable, a name matches the executable of
+	// the same name in the bin directories, or in any directory for a deny rule, a path is
+	// a pattern of path.Match, * matches all.
+	Command string `json:"command"`
+	// Args are the patterns of path.Match, a pattern ending with /** matches the paths
+	// under the directory. Every argument of an allowed command must match one of the
+	// patterns and any argument of a denied command matches one, the command matches
+	// whatever the arguments are without the patterns.
+	Args []string `json:"args,omitempty"`
+}
+
+// DeniedError is returned when the policy denies a command or a path.
+type DeniedError struct {
+	Reason string
+}
+
+func (e *DeniedError) Error() string {
+	return e.Reason
+}
+
+// IsDenied reports whether the error is a DeniedError.
+func IsDenied(err error) bool {
+	_, ok := err.(*DeniedError)
+	return ok
+}
+
+// Policy is the exec policy validated, the nil policy allows all the commands and confines
+// the log files to DefaultLogRoots.
+type Policy struct {
+	rules    []*Rule
+	deny     []*CommandRule
+	logRoots []string
+}
+
+// New validates the config and returns its policy.
+func New(cfg *Config) (*Policy, error) {
+	for i, r := range cfg.Rules {
+		if len(r.Users) == 0 && len(r.Groups) == 0 {
+			return nil, fmt.Errorf("rules[%d]: users or groups are required", i)
+		}
+		if len(r.Commands) == 0 {
+			return nil, fmt.Errorf("rules[%d]: commands are required", i)
+		}
+		for j, cr := range r.Commands {
+			if err := cr.Validate(); err != nil {
+				return nil, fmt.Errorf("rules[%d].commands[%d]: %v", i, j, err)
+			}
+		}
+	}
+	for i, cr := range cfg.Deny {
+		if err := cr.Validate(); err != nil {
+			return nil, fmt.Errorf("deny[%d]: %v", i, err)
+		}
+	}
+
+	p := &Policy{rules: cfg.Rules, deny: cfg.Deny}
+	for _, root := range cfg.LogRoots {
+		if !path.IsAbs(root) {
+			return nil, fmt.Errorf("log root %s is not absolute", root)
+		}
+		p.logRoots = append(p.logRoots, path.Clean(root))
+	}
+	return p, nil
+}
+
+// Validate ...
+func (cr *CommandRule) Validate() error {
+	if cr.Command == "" {
+		return fmt.Errorf("command is required")
+	}
+	for _, pattern := range append([]string{cr.Command}, cr.Args...) {
+		if _, err := path.Match(strings.TrimSuffix(pattern, "/**"), ""); err != nil {
+			return fmt.Errorf("invalid pattern %s: %v", pattern, err)
+		}
+	}
+	return nil
+}
+
+func contains(list []string, v string) bool {
+	for _, e := range list {
+		if e == Wildcard || (v != "" && e == v) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Rule) matchSubject(user string, groups []string) bool {
+	if contains(r.Users, user) {
+		return true
+	}
+	for _, g := range groups {
+		if contains(r.Groups, g) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchCommand matches the executable, a name matches it in the bin directories only so
+// /tmp/ls is not taken as ls.
+func matchCommand(pattern, cmd string) bool {
+	if pattern == Wildcard || pattern == cmd {
+		return true
+	}
+	if strings.Contains(pattern, "/") {
+		ok, _ := path.Match(pattern, cmd)
+		return ok
+	}
+	if path.Base(cmd) != pattern {
+		return false
+	}
+	dir := path.Dir(cmd)
+	for _, d := range binDirs {
+		if dir == d {
+			return true
+		}
+	}
+	return false
+}
+
+// matchDeniedCommand matches the executable of a deny rule, a name matches it in any
+// directory so /tmp/rm is denied as rm.
+func matchDeniedCommand(pattern, cmd string) bool {
+	if !strings.Contains(pattern, "/") && path.Base(cmd) == pattern {
+		return true
+	}
+	return matchCommand(pattern, cmd)
+}
+
+// hasDotDot reports whether the path has a .. element, e.g. /web/logs/../etc.
+func hasDotDot(p string) bool {
+	for _, e := range strings.Split(p, "/") {
+		if e == ".." {
+			return true
+		}
+	}
+	return false
+}
+
+// matchArg matches an argument, the arguments with .. never match the path patterns.
+func matchArg(pattern, arg string) bool {
+	if pattern == arg {
+		return true
+	}
+	if strings.Contains(pattern, "/") && hasDotDot(arg) {
+		return false
+	}
+	if dir := strings.TrimSuffix(pattern, "/**"); dir != pattern {
+		return arg == dir || strings.HasPrefix(arg, dir+"/")
+	}
+	ok, _ := path.Match(pattern, arg)
+	return ok
+}
+
+func matchAnyArg(patterns []string, arg string) bool {
+	for _, pattern := range patterns {
+		if matchArg(pattern, arg) {
+			return true
+		}
+	}
+	return false
+}
+
+// allows reports whether the rule allows the command with all its arguments.
+func (cr *CommandRule) allows(argv []string) bool {
+	if !matchCommand(cr.Command, argv[0]) {
+		return false
+	}
+	if len(cr.Args) == 0 {
+		return true
+	}
+	for _, arg := range argv[1:] {
+		if !matchAnyArg(cr.Args, arg) {
+			return false
+		}
+	}
+	return true
+}
+
+// denies reports whether the rule matches the command with any of its arguments.
+func (cr *CommandRule) denies(argv []string) bool {
+	if !matchDeniedCommand(cr.Command, argv[0]) {
+		return false
+	}
+	if len(cr.Args) == 0 {
+		return true
+	}
+	for _, arg := range argv[1:] {
+		if matchAnyArg(cr.Args, arg) {
+			return true
+		}
+	}
+	return false
+}
+
+// Allow returns a DeniedError unless the user or one of the groups may exec the command.
+func (p *Policy) Allow(user string, groups []string, argv []string) error {
+	if len(argv) == 0 || argv[0] == "" {
+		return &DeniedError{Reason: "no command to exec"}
+	}
+	if p == nil {
+		return nil
+	}
+	for _, cr := range p.deny {
+		if cr.denies(argv) {
+			return &DeniedError{Reason: fmt.Sprintf("command %s is denied", argv[0])}
+		}
+	}
+	for _, r := range p.rules {
+		if !r.matchSubject(user, groups) {
+			continue
+		}
+		for _, cr := range r.Commands {
+			if cr.allows(argv) {
+				return nil
+			}
+		}
+	}
+	return &DeniedError{Reason: fmt.Sprintf("user %s can not exec %s with the arguments %q", user, argv[0], argv[1:])}
+}
@@ -1,7 +1,9 @@
 package manager
 
 import (
+	"fmt"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"context"
@@ -49,10 +51,58 @@ type Cluster struct {
 	internalStopper chan struct{}
 
 	Status ClusterStatusType
+	// maintenance holds the *MaintenanceWindow read from the cluster configmap, it is
+	// replaced by the cluster check while read by the handlers and the controllers
+	maintenance atomic.Value
 	// Started is true if the Informers has been Started
 	Started bool
 }
 
+// MaintenanceWindow describes a planned maintenance of the cluster.
+type MaintenanceWindow struct {
+	Reason string
+	Start  *time.Time
+	End    *time.Time
+}
+
+// Active returns whether t is inside the maintenance window.
+func (w *MaintenanceWindow) Active(t time.Time) bool {
+	if w == nil {
+		return false
+	}
+	if w.Start != nil && t.Before(*w.Start) {
+		return false
+	}
+	if w.End != nil && !t.Before(*w.End) {
+		return false
+	}
+	return true
+}
+
+// MaintainingError is returned when an operation is refused because
+// the cluster is under maintenance.
+type MaintainingError struct {
+	Name   string
+	Window *MaintenanceWindow
+}
+
+func (e *MaintainingError) Error() string {
+	msg := fmt.Sprintf("cluster: %s is under maintenance", e.Name)
+	if e.Window.End != nil {
+		msg = fmt.Sprintf("%s until %s", msg, e.Window.End.Format(time.RFC3339))
+	}
+	if e.Window.Reason != "" {
+		msg = fmt.Sprintf("%s, reason: %s", msg, e.Window.Reason)
+	}
+	return msg
+}
+
+// IsMaintaining returns true if err means the cluster is under maintenance.
+func IsMaintaining(err error) bool {
+	var e *MaintainingError
+	return errors.As(err, &e)
+}
+
 func NewCluster(name string, kubeconfig []byte, log logr.Logger) (*Cluster, error) {
 	cluster := &Cluster{
 		Name:            name,
@@ -75,6 +125,31 @@ func (c *Cluster) GetName() string {
 	return c.Name
 }
 
+// Maintenance returns the maintenance window of the cluster, nil if none.
+func (c *Cluster) Maintenance() *MaintenanceWindow {
+	w, _ := c.maintenance.Load().(*MaintenanceWindow)
+	return w
+}
+
+// SetMaintenance replaces the maintenance window of the cluster.
+func (c *Cluster) SetMaintenance(w *MaintenanceWindow) {
+	c.maintenance.Store(w)
+}
+
+// IsMaintaining returns whether the cluster is in an active maintenance window.
+func (c *Cluster) IsMaintaining() bool {
+	return c.Maintenance().Active(time.Now())
+}
+
+// CheckMaintenance returns a MaintainingError if the cluster is under maintenance.
+func (c *Cluster) CheckMaintenance() error {
+	w := c.Maintenance()
+	if !w.Active(time.Now()) {
+		return nil
+	}
+	return &MaintainingError{Name: c.Name, Window: w}
+}
+
 func (c *Cluster) initK8SClients() error {
 	startTime := time.Now()
 	cfg, err := k8sclient.NewClientConfig(c.RawKubeconfig)
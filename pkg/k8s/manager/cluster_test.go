@@ -0,0 +1,75 @@
+package manager
+
+import (
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestConvertToMaintenance(t *testing.T) {
+	now := time.Now()
+	cases := []struct {
+		name   string
+		data   map[string]string
+		active bool
+	}{
+		{"empty", map[string]string{}, false},
+		{"disable", map[string]string{KeyMaintenance: "false"}, false},
+		{"enable", map[string]string{KeyMaintenance: "true"}, true},
+		{"in window", map[string]string{
+			KeyMaintenance:          "true",
+			KeyMaintenanceStartTime: now.Add(-time.Hour).Format(time.RFC3339),
+			KeyMaintenanceEndTime:   now.Add(time.Hour).Format(time.RFC3339),
+		}, true},
+		{"not start", map[string]string{
+			KeyMaintenance:          "true",
+			KeyMaintenanceStartTime: now.Add(time.Hour).Format(time.RFC3339),
+		}, false},
+		{"finished", map[string]string{
+			KeyMaintenance:        "true",
+			KeyMaintenanceEndTime: now.Add(-time.Hour).Format(time.RFC3339),
+		}, false},
+	}
+
+	for _, c := range cases {
+		cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: c.name}, Data: c.data}
+		cluster := &Cluster{Name: c.name}
+		cluster.SetMaintenance(convertToMaintenance(cm))
+		if cluster.IsMaintaining() != c.active {
+			t.Errorf("case: %s expect active: %v, current: %v", c.name, c.active, cluster.IsMaintaining())
+		}
+
+		err := cluster.CheckMaintenance()
+		if c.active != IsMaintaining(err) {
+			t.Errorf("case: %s expect maintaining err: %v, current: %v", c.name, c.active, err)
+		}
+	}
+}
+
+func TestMaintenanceConcurrent(t *testing.T) {
+	cluster := &Cluster{Name: "tx-test"}
+	if cluster.IsMaintaining() {
+		t.Fatalf("expect not maintaining without the window")
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 1000; i++ {
+			if i%2 == 0 {
+				cluster.SetMaintenance(&MaintenanceWindow{Reason: "upgrade"})
+			} else {
+				cluster.SetMaintenance(nil)
+			}
+		}
+	}()
+	for i := 0; i < 1000; i++ {
+		cluster.CheckMaintenance()
+	}
+	<-done
+	if cluster.IsMaintaining() {
+		t.Errorf("expect the last window cleared")
+	}
+}
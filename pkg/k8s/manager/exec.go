@@ -0,0 +1,110 @@
+package manager
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/httpstream"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/remotecommand"
+	"k8s.io/client-go/transport/spdy"
+)
+
+// maxExecStderr bounds the stderr kept for the errors of the commands.
+const maxExecStderr = 4096
+
+// ExecCommand runs the command in the container without tty and returns the stdout,
+// the command is passed as argument vector and never interpreted by a shell.
+func (c *Cluster) ExecCommand(namespace, pod, container string, cmd []string) ([]byte, error) {
+	var stdout bytes.Buffer
+	err := c.ExecStream(context.Background(), namespace, pod, container, cmd, &stdout)
+	return stdout.Bytes(), err
+}
+
+// ExecStream runs the command like ExecCommand and copies the stdout to the writer as
+// it is produced, the error of a command exited non-zero wraps an exec.ExitError.
+// The connection is closed when the context is done, which stops the command.
+func (c *Cluster) ExecStream(ctx context.Context, namespace, pod, container string, cmd []string, stdout io.Writer) error {
+	req := c.KubeCli.CoreV1().RESTClient().Post().
+		Resource("pods").
+		Name(pod).
+		Namespace(namespace).
+		SubResource("exec")
+
+	req.VersionedParams(&corev1.PodExecOptions{
+		Command:   cmd,
+		Container: container,
+		Stdin:     false,
+		Stdout:    true,
+		Stderr:    true,
+		TTY:       false,
+	}, scheme.ParameterCodec)
+
+	transport, upgrader, err := spdy.RoundTripperFor(c.RestConfig)
+	if err != nil {
+		return errors.Wrapf(err, "cluster: %s create executor", c.Name)
+	}
+	exec, err := remotecommand.NewSPDYExecutorForTransports(transport, &contextUpgrader{Upgrader: upgrader, ctx: ctx}, "POST", req.URL())
+	if err != nil {
+		return errors.Wrapf(err, "cluster: %s create executor", c.Name)
+	}
+
+	stderr := &limitedBuffer{max: maxExecStderr}
+	err = exec.Stream(remotecommand.StreamOptions{
+		Stdout: stdout,
+		Stderr: stderr,
+	})
+	if err != nil {
+		if ctx.Err() != nil {
+			err = ctx.Err()
+		}
+		if stderr.Len() > 0 {
+			return errors.Wrapf(err, "cluster: %s pod: %s/%s exec %q stderr: %s", c.Name, namespace, pod, cmd, stderr.String())
+		}
+		return errors.Wrapf(err, "cluster: %s pod: %s/%s exec %q", c.Name, namespace, pod, cmd)
+	}
+	return nil
+}
+
+// contextUpgrader closes the connection of the exec when the context is done, the stream
+// can not be cancelled otherwise.
+type contextUpgrader struct {
+	spdy.Upgrader
+	ctx context.Context
+}
+
+func (u *contextUpgrader) NewConnection(resp *http.Response) (httpstream.Connection, error) {
+	conn, err := u.Upgrader.NewConnection(resp)
+	if err != nil {
+		return nil, err
+	}
+	go func() {
+		select {
+		case <-u.ctx.Done():
+			conn.Close()
+		case <-conn.CloseChan():
+		}
+	}()
+	return conn, nil
+}
+
+// limitedBuffer keeps the first max bytes written and drops the rest.
+type limitedBuffer struct {
+	bytes.Buffer
+	max int
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if left := b.max - b.Len(); left > 0 {
+		if len(p) > left {
+			b.Buffer.Write(p[:left])
+		} else {
+			b.Buffer.Write(p)
+		}
+	}
+	return len(p), nil
+}
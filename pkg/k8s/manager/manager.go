@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -33,6 +34,12 @@ const (
 	KeyKubeconfig = "kubeconfig.yaml"
 	KeyStauts     = "status"
 	ClustersAll   = "all"
+
+	// maintenance window keys, the times are RFC3339 formatted
+	KeyMaintenance          = "maintenance"
+	KeyMaintenanceReason    = "maintenanceReason"
+	KeyMaintenanceStartTime = "maintenanceStartTime"
+	KeyMaintenanceEndTime   = "maintenanceEndTime"
 )
 
 // ClusterManagerOption ...
@@ -57,8 +64,18 @@ type ClusterManager struct {
 	PreInit        func()
 	Started        bool
 	ClusterAddInfo chan map[string]string
+
+	subMu       sync.Mutex
+	subscribers []chan struct{}
+
+	hookMu         sync.RWMutex
+	podDeleteHooks []PodDeleteHook
 }
 
+// PodDeleteHook is run before a pod is deleted through the api by the user, the user is
+// empty if the authentication is disabled.
+type PodDeleteHook func(ctx context.Context, cluster *Cluster, pod *corev1.Pod, user string)
+
 // DefaultClusterManagerOption ...
 func DefaultClusterManagerOption(isAPI bool, ls map[string]string) *ClusterManagerOption {
 	return &ClusterManagerOption{
@@ -86,6 +103,36 @@ func convertToKubeconfig(cm *corev1.ConfigMap) (string, bool) {
 	return kubeconfig, true
 }
 
+// convertToMaintenance returns the maintenance window of the cluster configmap,
+// nil means the cluster is not in maintenance.
+func convertToMaintenance(cm *corev1.ConfigMap) *MaintenanceWindow {
+	enable, err := strconv.ParseBool(cm.Data[KeyMaintenance])
+	if err != nil || !enable {
+		return nil
+	}
+
+	w := &MaintenanceWindow{
+		Reason: cm.Data[KeyMaintenanceReason],
+	}
+	if va, ok := cm.Data[KeyMaintenanceStartTime]; ok && va != "" {
+		t, err := time.Parse(time.RFC3339, va)
+		if err != nil {
+			klog.Errorf("cluster name: %s parse maintenance start time: %s err: %v", cm.Name, va, err)
+		} else {
+			w.Start = &t
+		}
+	}
+	if va, ok := cm.Data[KeyMaintenanceEndTime]; ok && va != "" {
+		t, err := time.Parse(time.RFC3339, va)
+		if err != nil {
+			klog.Errorf("cluster name: %s parse maintenance end time: %s err: %v", cm.Name, va, err)
+		} else {
+			w.End = &t
+		}
+	}
+	return w
+}
+
 // NewClusterManager ...
 func NewClusterManager(cli MasterClient, opt *ClusterManagerOption) (*ClusterManager, error) {
 	cMgr := &ClusterManager{
@@ -106,6 +153,22 @@ func NewClusterManager(cli MasterClient, opt *ClusterManagerOption) (*ClusterMan
 	return cMgr, nil
 }
 
+// AddPodDeleteHook adds a hook run before the pods are deleted through the api.
+func (m *ClusterManager) AddPodDeleteHook(hook PodDeleteHook) {
+	m.hookMu.Lock()
+	defer m.hookMu.Unlock()
+	m.podDeleteHooks = append(m.podDeleteHooks, hook)
+}
+
+// BeforePodDelete runs the hooks of the pod about to be deleted by the user.
+func (m *ClusterManager) BeforePodDelete(ctx context.Context, cluster *Cluster, pod *corev1.Pod, user string) {
+	m.hookMu.RLock()
+	defer m.hookMu.RUnlock()
+	for _, hook := range m.podDeleteHooks {
+		hook(ctx, cluster, pod, user)
+	}
+}
+
 // AddPreInit ...
 func (m *ClusterManager) AddPreInit(preInit func()) {
 	if m.PreInit != nil {
@@ -245,9 +308,23 @@ func (m *ClusterManager) Delete(name string) error {
 	clusters = append(clusters[:index], clusters[index+1:]...)
 	m.clusters = clusters
 	klog.Infof("cluster: the cluster %s has been deleted.", name)
+	m.notifySubscribers()
 	return nil
 }
 
+// Exist returns whether the cluster of the name is managed, online or not.
+func (m *ClusterManager) Exist(name string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, c := range m.clusters {
+		if c.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
 // Get ...
 func (m *ClusterManager) Get(name string) (*Cluster, error) {
 	m.mu.Lock()
@@ -305,6 +382,7 @@ func (m *ClusterManager) preStart() error {
 			klog.Errorf("cluster: %s check offline", cm.Name)
 			continue
 		}
+		c.SetMaintenance(convertToMaintenance(cm))
 
 		if m.Opt.IsAPI {
 			// add field pod nodeName index must before cache start
@@ -383,17 +461,38 @@ func (m *ClusterManager) cluterCheck() {
 	}
 
 	expectList := map[string]string{}
+	maintenances := map[string]*MaintenanceWindow{}
 	for _, cm := range configmaps {
 		config, _ := convertToKubeconfig(cm)
 		expectList[cm.Name] = config
+		maintenances[cm.Name] = convertToMaintenance(cm)
+	}
+
+	addList, changed := m.updateClusters(expectList, maintenances)
+	if !changed {
+		return
+	}
+
+	// the notifications are sent after unlocking so the slow receivers do not hold the clusters
+	timeout = time.After(time.Second * 5)
+	select {
+	case m.ClusterAddInfo <- addList:
+	case <-timeout:
+		klog.Infof("add cluster info timeout:%+v", addList)
 	}
+	m.notifySubscribers()
+}
 
+// updateClusters connects the clusters added or changed and stops the clusters removed,
+// it returns the clusters added and whether any cluster is added or removed.
+func (m *ClusterManager) updateClusters(expectList map[string]string, maintenances map[string]*MaintenanceWindow) (map[string]string, bool) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
 	currentList := map[string]*Cluster{}
 	for _, c := range m.clusters {
 		currentList[c.Name] = c
+		c.SetMaintenance(maintenances[c.Name])
 	}
 
 	newClusters := make([]*Cluster, 0, 4)
@@ -420,7 +519,7 @@ func (m *ClusterManager) cluterCheck() {
 	}
 
 	if len(delList) == 0 && len(addList) == 0 {
-		return
+		return nil, false
 	}
 
 	healthHandler := healthcheck.GetHealthHandler()
@@ -434,8 +533,9 @@ func (m *ClusterManager) cluterCheck() {
 		klog.Infof("create cluster:%s connect", name)
 		newcls, err := m.addNewClusters(name, conf)
 		if err != nil {
-			return
+			return nil, false
 		}
+		newcls.SetMaintenance(maintenances[name])
 		newClusters = append(newClusters, newcls)
 	}
 
@@ -443,12 +543,32 @@ func (m *ClusterManager) cluterCheck() {
 		return newClusters[i].Name > newClusters[j].Name
 	})
 	m.clusters = newClusters
+	return addList, true
+}
 
-	timeout = time.After(time.Second * 5)
-	select {
-	case m.ClusterAddInfo <- addList:
-	case <-timeout:
-		klog.Infof("add cluster info timeout:%+v", addList)
+// SubscribeClusterChange returns a channel receiving a signal after the clusters are added,
+// reconnected or removed, the subscriber reads the current clusters by GetAll. The signals
+// are coalesced while the subscriber is busy, so the sender never blocks. The channel is
+// closed when the cluster manager is stopped.
+func (m *ClusterManager) SubscribeClusterChange() <-chan struct{} {
+	ch := make(chan struct{}, 1)
+
+	m.subMu.Lock()
+	defer m.subMu.Unlock()
+	m.subscribers = append(m.subscribers, ch)
+	return ch
+}
+
+func (m *ClusterManager) notifySubscribers() {
+	m.subMu.Lock()
+	defer m.subMu.Unlock()
+
+	for _, ch := range m.subscribers {
+		select {
+		case ch <- struct{}{}:
+		default:
+			// a signal is pending
+		}
 	}
 }
 
@@ -488,4 +608,11 @@ func (m *ClusterManager) stop() {
 		cluster.Stop()
 	}
 	close(m.ClusterAddInfo)
+
+	m.subMu.Lock()
+	defer m.subMu.Unlock()
+	for _, ch := range m.subscribers {
+		close(ch)
+	}
+	m.subscribers = nil
 }
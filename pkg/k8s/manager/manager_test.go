@@ -0,0 +1,50 @@
+package manager
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestSubscribeClusterChange(t *testing.T) {
+	m := &ClusterManager{
+		mu:             &sync.RWMutex{},
+		ClusterAddInfo: make(chan map[string]string),
+	}
+	subs := []<-chan struct{}{m.SubscribeClusterChange(), m.SubscribeClusterChange()}
+
+	// the signals are coalesced without blocking while the subscribers are busy
+	m.notifySubscribers()
+	m.notifySubscribers()
+	for i, ch := range subs {
+		<-ch
+		select {
+		case <-ch:
+			t.Errorf("subscriber %d expect the signals coalesced", i)
+		default:
+		}
+	}
+
+	m.stop()
+	for i, ch := range subs {
+		if _, ok := <-ch; ok {
+			t.Errorf("subscriber %d expect closed", i)
+		}
+	}
+}
+
+func TestBeforePodDelete(t *testing.T) {
+	m := &ClusterManager{}
+	var users []string
+	m.AddPodDeleteHook(func(_ context.Context, _ *Cluster, pod *corev1.Pod, user string) {
+		users = append(users, pod.Name+"/"+user)
+	})
+
+	m.BeforePodDelete(context.TODO(), &Cluster{Name: "tcc-gz01"}, &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "bbcc-0"}}, "alice")
+	if len(users) != 1 || users[0] != "bbcc-0/alice" {
+		t.Errorf("expect the hook run with the user, current: %v", users)
+	}
+}
@@ -0,0 +1,70 @@
+package manager
+
+import (
+	"context"
+	"encoding/json"
+
+	pkgLabels "gitlab.dmall.com/arch/sym-admin/pkg/labels"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// PodGroupSelector selects the pods of the app by the group, zone and ldc labels, the
+// empty values are not selected and the app name "all" selects the pods of all apps.
+func PodGroupSelector(appName, group, zone, ldcLabel string) labels.Set {
+	options := labels.Set{}
+	if group != "" {
+		options["sym-group"] = group
+	}
+	if zone != "" {
+		options["sym-zone"] = zone
+	}
+	if ldcLabel != "" {
+		options["sym-ldc"] = ldcLabel
+	}
+	if appName != "all" {
+		options["app"] = appName
+	}
+	return options
+}
+
+// DeletePodsBySelector deletes the pods selected in the namespace, the pods failed to
+// delete are returned, an error is returned only when the pods cannot be listed. The
+// before func is called with each pod before it is deleted if not nil.
+func DeletePodsBySelector(ctx context.Context, cli client.Client, namespace string, selector labels.Set, before func(*corev1.Pod)) ([]*corev1.Pod, error) {
+	podList := &corev1.PodList{}
+	listOptions := &client.ListOptions{Namespace: namespace, LabelSelector: selector.AsSelector()}
+	if err := cli.List(ctx, podList, listOptions); err != nil {
+		return nil, err
+	}
+
+	errorPods := []*corev1.Pod{}
+	for i := range podList.Items {
+		pod := &podList.Items[i]
+		if before != nil {
+			before(pod)
+		}
+		if err := cli.Delete(ctx, pod); err != nil {
+			klog.Errorf("delete pod error: %v", err)
+			errorPods = append(errorPods, pod)
+		}
+	}
+	return errorPods, nil
+}
+
+// MarkPodDeletedBy annotates the pod with who deletes it, the offline pod record of the
+// deletion is then UserDeleted.
+func MarkPodDeletedBy(ctx context.Context, cli client.Client, pod *corev1.Pod, by string) error {
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]string{pkgLabels.WorkLoadAnnotationDeletedBy: by},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	return cli.Patch(ctx, pod, client.RawPatch(types.MergePatchType, patch))
+}
@@ -9,6 +9,8 @@ import (
 const (
 	LabelCreatedBy      = "createdBy"
 	LabelClusterName    = "clusterName"
+	LabelNodeName       = "nodeName"
+	LabelNamespace      = "namespace"
 	LabelLdcName        = "ldc"
 	LabelAzName         = "az"
 	LabelArea           = "area"
@@ -33,6 +35,17 @@ const (
 	ClusterAnnotationLoki        = "k8s.io/loki"
 	WorkLoadAnnotationHpa        = "hpa.autoscaling.dmall.com/Hpa"
 	WorkLoadAnnotationHpaMetrics = "hpa.autoscaling.dmall.com/Metrics"
+	// the owner of the app copied from the AppSet meta to the AdvDeployment of each cluster
+	WorkLoadAnnotationOwner        = "workload.dmall.com/owner"
+	WorkLoadAnnotationOwnerContact = "workload.dmall.com/ownerContact"
+	// the pods deleted through the api are annotated with who deleted them before deleted
+	WorkLoadAnnotationDeletedBy = "workload.dmall.com/deletedBy"
+)
+
+// the keys of the AppSet meta
+const (
+	AppSetMetaOwner        = "owner"
+	AppSetMetaOwnerContact = "ownerContact"
 )
 
 // group items
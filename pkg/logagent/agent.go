@@ -0,0 +1,167 @@
+// Package logagent manages the app logs under /web/logs/app of a node. It runs in the
+// offline-workload DaemonSet, enforces the retention policies written by the master
+// into a ConfigMap and writes the disk usage of the apps into the UsageFile, which the
+// cluster controller collects into the cluster NodeStatus.
+package logagent
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	workloadv1beta1 "gitlab.dmall.com/arch/sym-admin/pkg/apis/workload/v1beta1"
+	"gitlab.dmall.com/arch/sym-admin/pkg/offlinelog"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog"
+)
+
+const (
+	// DefaultInterval is the interval of the retention runs
+	DefaultInterval = 10 * time.Minute
+	// maxReportedApps bounds the apps in the usage file, the largest ones are reported
+	maxReportedApps = 100
+)
+
+// Options ...
+type Options struct {
+	// Root is the app log directory, default offlinelog.AppRoot
+	Root     string
+	Interval time.Duration
+	// Namespace is of the agent pod and the policy ConfigMap
+	Namespace string
+	// NodeName is the node of the agent pod, the directories of its running pods are not
+	// touched, the retention is skipped without it.
+	NodeName string
+	// UsageFile is where the usage is written, default UsageFile
+	UsageFile string
+}
+
+// Agent enforces the retention and reports the usage periodically.
+type Agent struct {
+	opt     *Options
+	kubeCli kubernetes.Interface
+}
+
+// New ...
+func New(kubeCli kubernetes.Interface, opt *Options) *Agent {
+	if opt.Root == "" {
+		opt.Root = offlinelog.AppRoot
+	}
+	if opt.Interval <= 0 {
+		opt.Interval = DefaultInterval
+	}
+	if opt.Namespace == "" {
+		opt.Namespace = offlinelog.Namespace
+	}
+	if opt.UsageFile == "" {
+		opt.UsageFile = UsageFile
+	}
+	return &Agent{opt: opt, kubeCli: kubeCli}
+}
+
+// Start implements the manager.Runnable
+func (a *Agent) Start(stopCh <-chan struct{}) error {
+	klog.Infof("log agent starts, root: %s interval: %v", a.opt.Root, a.opt.Interval)
+	wait.Until(a.run, a.opt.Interval, stopCh)
+	return nil
+}
+
+// loadPolicies reads the policies written by the master, the retention is disabled
+// without the ConfigMap.
+func (a *Agent) loadPolicies(ctx context.Context) (*Policies, error) {
+	cm, err := a.kubeCli.CoreV1().ConfigMaps(a.opt.Namespace).Get(ctx, ConfigMapName, metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return &Policies{}, nil
+		}
+		return nil, err
+	}
+	return ParsePolicies(cm.Data[PolicyKey])
+}
+
+// listLivePods returns the IPs of the pods not terminated on the node.
+func (a *Agent) listLivePods(ctx context.Context) (LivePods, error) {
+	pods, err := a.kubeCli.CoreV1().Pods(metav1.NamespaceAll).List(ctx, metav1.ListOptions{
+		FieldSelector: fields.OneTermEqualSelector("spec.nodeName", a.opt.NodeName).String(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	live := make(LivePods, len(pods.Items))
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+			continue
+		}
+		for _, ip := range pod.Status.PodIPs {
+			live[ip.IP] = true
+		}
+		if pod.Status.PodIP != "" {
+			live[pod.Status.PodIP] = true
+		}
+	}
+	return live, nil
+}
+
+func (a *Agent) run() {
+	ctx := context.TODO()
+	policies, err := a.loadPolicies(ctx)
+	if err != nil {
+		// the usage is still reported
+		klog.Errorf("load log retention policies err: %v", err)
+		policies = &Policies{}
+	}
+
+	var live LivePods
+	if a.opt.NodeName == "" {
+		klog.Warningf("no node name, skip the log retention")
+		policies = &Policies{}
+	} else if live, err = a.listLivePods(ctx); err != nil {
+		klog.Errorf("list the pods of node %s err, skip the log retention: %v", a.opt.NodeName, err)
+		policies = &Policies{}
+	}
+
+	usage, err := Enforce(a.opt.Root, policies, live, time.Now())
+	if err != nil {
+		klog.Errorf("enforce log retention under %s err: %v", a.opt.Root, err)
+		return
+	}
+	klog.V(3).Infof("log agent total: %d bytes of %d apps, reclaimed: %d bytes", usage.TotalBytes, len(usage.Apps), usage.ReclaimedBytes)
+
+	if len(usage.Apps) > maxReportedApps {
+		usage.Apps = usage.Apps[:maxReportedApps]
+	}
+	if err := a.report(usage); err != nil {
+		klog.Errorf("report log usage err: %v", err)
+	}
+}
+
+// report writes the usage into the usage file, the file is replaced by a rename so that
+// the readers never see a partial one.
+func (a *Agent) report(usage *workloadv1beta1.NodeLogUsage) error {
+	raw, err := json.Marshal(usage)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(a.opt.UsageFile), 0755); err != nil {
+		return err
+	}
+	tmp := a.opt.UsageFile + tmpSuffix
+	if err := ioutil.WriteFile(tmp, raw, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, a.opt.UsageFile); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package logagent
+
+import (
+	"encoding/json"
+	"path"
+	"time"
+
+	workloadv1beta1 "gitlab.dmall.com/arch/sym-admin/pkg/apis/workload/v1beta1"
+)
+
+const (
+	// ConfigMapName is the ConfigMap of the retention policies in the namespace of the
+	// offline-workload DaemonSet, written by the cluster controller of the master.
+	ConfigMapName = "offline-workload-policy"
+	// PolicyKey is the key of the LogRetentionSpec json in the ConfigMap
+	PolicyKey = "policy.json"
+	// UsageFile is the NodeLogUsage json written by the agent after each run, it is in an
+	// emptyDir shared by the containers of the agent pod and read by the cluster controller
+	// with an exec, the agent has no write access to the API server.
+	UsageFile = "/var/run/log-agent/usage.json"
+)
+
+// Policy is the retention of an app, the zero fields are not enforced.
+type Policy struct {
+	MaxAge        time.Duration
+	MaxSize       int64
+	CompressAfter time.Duration
+}
+
+// IsZero reports whether nothing is enforced.
+func (p *Policy) IsZero() bool {
+	return p.MaxAge == 0 && p.MaxSize == 0 && p.CompressAfter == 0
+}
+
+func toPolicy(in *workloadv1beta1.LogRetentionPolicy) Policy {
+	var p Policy
+	if in == nil {
+		return p
+	}
+	if in.MaxAge != nil {
+		p.MaxAge = in.MaxAge.Duration
+	}
+	if in.MaxSize != nil {
+		p.MaxSize = in.MaxSize.Value()
+	}
+	if in.CompressAfter != nil {
+		p.CompressAfter = in.CompressAfter.Duration
+	}
+	return p
+}
+
+// Policies resolves the policies of the apps.
+type Policies struct {
+	spec *workloadv1beta1.LogRetentionSpec
+}
+
+// ParsePolicies parses the LogRetentionSpec json, the empty data disables the retention.
+func ParsePolicies(data string) (*Policies, error) {
+	if data == "" {
+		return &Policies{}, nil
+	}
+	spec := &workloadv1beta1.LogRetentionSpec{}
+	if err := json.Unmarshal([]byte(data), spec); err != nil {
+		return nil, err
+	}
+	if err := spec.Validate(); err != nil {
+		return nil, err
+	}
+	return &Policies{spec: spec}, nil
+}
+
+// For returns the policy of the app directory relative to the app root, e.g. dmall/bbcc,
+// the policy of the directory is preferred to the one of the app name.
+func (p *Policies) For(app string) Policy {
+	if p == nil || p.spec == nil || !p.spec.Enable {
+		return Policy{}
+	}
+
+	var byName *workloadv1beta1.AppLogRetention
+	for _, a := range p.spec.Apps {
+		if a.App == app {
+			return toPolicy(&a.LogRetentionPolicy)
+		}
+		if byName == nil && a.App == path.Base(app) {
+			byName = a
+		}
+	}
+	if byName != nil {
+		return toPolicy(&byName.LogRetentionPolicy)
+	}
+	return toPolicy(p.spec.Default)
+}
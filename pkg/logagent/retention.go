@@ -0,0 +1,302 @@
+package logagent
+
+import (
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	workloadv1beta1 "gitlab.dmall.com/arch/sym-admin/pkg/apis/workload/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog"
+)
+
+const (
+	// activeWindow protects the files still written by the pods, the files modified
+	// within it are neither deleted nor compressed.
+	activeWindow = time.Hour
+
+	gzipSuffix = ".gz"
+	tmpSuffix  = ".tmp"
+)
+
+// LivePods is the IPs of the pods running on the node, the pod directories of both
+// layouts start with the pod IP, e.g. 172.16.0.1:8080 and 172.16.0.1_4f2a.
+type LivePods map[string]bool
+
+// owns reports whether the pod directory is of a running pod.
+func (l LivePods) owns(podDir string) bool {
+	if i := strings.IndexAny(podDir, ":_"); i >= 0 {
+		podDir = podDir[:i]
+	}
+	return l[podDir]
+}
+
+// podDirOf returns the pod directory of the path under the app directory.
+func podDirOf(appDir, p string) string {
+	rel, err := filepath.Rel(appDir, p)
+	if err != nil {
+		return ""
+	}
+	return strings.SplitN(filepath.ToSlash(rel), "/", 2)[0]
+}
+
+type logFile struct {
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+// appDirs returns the app directories relative to the root, they are the second level
+// directories of both layouts, e.g. dmall/bbcc and logback/bbcc.
+func appDirs(root string) ([]string, error) {
+	groups, err := ioutil.ReadDir(root)
+	if err != nil {
+		return nil, err
+	}
+
+	var apps []string
+	for _, group := range groups {
+		if !group.IsDir() {
+			continue
+		}
+		children, err := ioutil.ReadDir(filepath.Join(root, group.Name()))
+		if err != nil {
+			klog.Warningf("read log dir %s err: %v", group.Name(), err)
+			continue
+		}
+		for _, child := range children {
+			if child.IsDir() {
+				apps = append(apps, group.Name()+"/"+child.Name())
+			}
+		}
+	}
+	return apps, nil
+}
+
+// listFiles lists the regular files under the directory, the symlinks are not followed.
+func listFiles(dir string) ([]*logFile, error) {
+	var files []*logFile
+	err := filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			// the files removed by the pods while walking
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if info.Mode().IsRegular() {
+			files = append(files, &logFile{path: p, size: info.Size(), modTime: info.ModTime()})
+		}
+		return nil
+	})
+	return files, err
+}
+
+// gzipFile compresses the file to a .gz of the same mtime and removes the file, it
+// returns the size of the .gz.
+func gzipFile(f *logFile) (int64, error) {
+	dst := f.path + gzipSuffix
+	if _, err := os.Lstat(dst); err == nil {
+		return 0, os.ErrExist
+	}
+
+	src, err := os.Open(f.path)
+	if err != nil {
+		return 0, err
+	}
+	defer src.Close()
+
+	tmp := dst + tmpSuffix
+	out, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return 0, err
+	}
+	zw := gzip.NewWriter(out)
+	_, err = io.Copy(zw, src)
+	if cerr := zw.Close(); err == nil {
+		err = cerr
+	}
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chtimes(tmp, f.modTime, f.modTime)
+	}
+	if err == nil {
+		err = os.Rename(tmp, dst)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return 0, err
+	}
+
+	info, err := os.Stat(dst)
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), os.Remove(f.path)
+}
+
+// removeEmptyDirs removes the empty directories under the app directory bottom up, the
+// directories modified within the active window are kept for the pods starting and the
+// directories of the running pods are kept.
+func removeEmptyDirs(appDir string, live LivePods, now time.Time) {
+	var dirs []string
+	filepath.Walk(appDir, func(p string, info os.FileInfo, err error) error {
+		if err != nil || !info.IsDir() || p == appDir {
+			return nil
+		}
+		if live.owns(podDirOf(appDir, p)) {
+			return filepath.SkipDir
+		}
+		dirs = append(dirs, p)
+		return nil
+	})
+	for i := len(dirs) - 1; i >= 0; i-- {
+		info, err := os.Lstat(dirs[i])
+		if err != nil || now.Sub(info.ModTime()) < activeWindow {
+			continue
+		}
+		// fails on the directories not empty
+		os.Remove(dirs[i])
+	}
+}
+
+// enforceApp applies the policy to the files of the app and returns the bytes reclaimed,
+// the files of the running pods are kept whatever the policy.
+func enforceApp(appDir string, files []*logFile, policy Policy, live LivePods, now time.Time) ([]*logFile, int64) {
+	var reclaimed int64
+	kept := files[:0]
+	var protected []*logFile
+	for _, f := range files {
+		if live.owns(podDirOf(appDir, f.path)) {
+			protected = append(protected, f)
+			continue
+		}
+		age := now.Sub(f.modTime)
+		if age < activeWindow {
+			kept = append(kept, f)
+			continue
+		}
+
+		if policy.MaxAge > 0 && age > policy.MaxAge {
+			if err := os.Remove(f.path); err != nil {
+				klog.Warningf("remove log file %s err: %v", f.path, err)
+				kept = append(kept, f)
+				continue
+			}
+			reclaimed += f.size
+			continue
+		}
+
+		if policy.CompressAfter > 0 && age > policy.CompressAfter &&
+			!strings.HasSuffix(f.path, gzipSuffix) && !strings.HasSuffix(f.path, tmpSuffix) {
+			size, err := gzipFile(f)
+			if err != nil {
+				klog.Warningf("compress log file %s err: %v", f.path, err)
+			} else {
+				reclaimed += f.size - size
+				f.path, f.size = f.path+gzipSuffix, size
+			}
+		}
+		kept = append(kept, f)
+	}
+
+	if policy.MaxSize > 0 {
+		// the files of the running pods count in the size but are never removed
+		var total int64
+		for _, f := range protected {
+			total += f.size
+		}
+		for _, f := range kept {
+			total += f.size
+		}
+		sort.SliceStable(kept, func(i, j int) bool {
+			return kept[i].modTime.Before(kept[j].modTime)
+		})
+		remain := kept[:0]
+		for _, f := range kept {
+			if total > policy.MaxSize && now.Sub(f.modTime) >= activeWindow {
+				if err := os.Remove(f.path); err == nil {
+					total -= f.size
+					reclaimed += f.size
+					continue
+				}
+			}
+			remain = append(remain, f)
+		}
+		kept = remain
+	}
+
+	if !policy.IsZero() {
+		removeEmptyDirs(appDir, live, now)
+	}
+	return append(kept, protected...), reclaimed
+}
+
+func countPodDirs(appDir string) int {
+	children, err := ioutil.ReadDir(appDir)
+	if err != nil {
+		return 0
+	}
+	var n int
+	for _, child := range children {
+		if child.IsDir() {
+			n++
+		}
+	}
+	return n
+}
+
+// Enforce applies the policies to the app logs under the root and returns the usage after,
+// the directories of the live pods are left to them.
+func Enforce(root string, policies *Policies, live LivePods, now time.Time) (*workloadv1beta1.NodeLogUsage, error) {
+	apps, err := appDirs(root)
+	if err != nil {
+		return nil, err
+	}
+
+	usage := &workloadv1beta1.NodeLogUsage{
+		Apps:       make([]*workloadv1beta1.AppLogUsage, 0, len(apps)),
+		UpdateTime: metav1.NewTime(now),
+	}
+	for _, app := range apps {
+		appDir := filepath.Join(root, filepath.FromSlash(app))
+		files, err := listFiles(appDir)
+		if err != nil {
+			klog.Warningf("list log files of app %s err: %v", app, err)
+			continue
+		}
+
+		files, reclaimed := enforceApp(appDir, files, policies.For(app), live, now)
+		usage.ReclaimedBytes += reclaimed
+
+		appUsage := &workloadv1beta1.AppLogUsage{
+			App:     app,
+			Files:   len(files),
+			PodDirs: countPodDirs(appDir),
+		}
+		for _, f := range files {
+			appUsage.Bytes += f.size
+			if appUsage.OldestModTime == nil || f.modTime.Before(appUsage.OldestModTime.Time) {
+				t := metav1.NewTime(f.modTime)
+				appUsage.OldestModTime = &t
+			}
+		}
+		usage.TotalBytes += appUsage.Bytes
+		usage.Apps = append(usage.Apps, appUsage)
+	}
+
+	sort.Slice(usage.Apps, func(i, j int) bool {
+		if usage.Apps[i].Bytes != usage.Apps[j].Bytes {
+			return usage.Apps[i].Bytes > usage.Apps[j].Bytes
+		}
+		return usage.Apps[i].App < usage.Apps[j].App
+	})
+	return usage, nil
+}
@@ -0,0 +1,171 @@
+package logagent
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeLog(t *testing.T, root, name string, size int, modTime time.Time) string {
+	p := filepath.Join(root, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		t.Fatalf("mkdir err: %v", err)
+	}
+	if err := ioutil.WriteFile(p, []byte(strings.Repeat("x", size)), 0644); err != nil {
+		t.Fatalf("write err: %v", err)
+	}
+	if err := os.Chtimes(p, modTime, modTime); err != nil {
+		t.Fatalf("chtimes err: %v", err)
+	}
+	return p
+}
+
+func exists(p string) bool {
+	_, err := os.Lstat(p)
+	return err == nil
+}
+
+func TestPoliciesFor(t *testing.T) {
+	policies, err := ParsePolicies(`{"enable":true,"default":{"maxAge":"168h"},"apps":[
+		{"app":"bbcc","maxSize":"1Gi"},
+		{"app":"logback/bbcc","compressAfter":"24h"}]}`)
+	if err != nil {
+		t.Fatalf("parse err: %v", err)
+	}
+
+	data := map[string]Policy{
+		"dmall/bbcc":   {MaxSize: 1 << 30},
+		"logback/bbcc": {CompressAfter: 24 * time.Hour},
+		"dmall/other":  {MaxAge: 168 * time.Hour},
+	}
+	for app, expect := range data {
+		if p := policies.For(app); p != expect {
+			t.Errorf("app: %s expect: %+v, current: %+v", app, expect, p)
+		}
+	}
+
+	if _, err := ParsePolicies(`{"enable":true,"default":{"maxAge":"-1h"}}`); err == nil {
+		t.Errorf("expect invalid maxAge err")
+	}
+	if p := (&Policies{}).For("dmall/bbcc"); !p.IsZero() {
+		t.Errorf("expect no policy, current: %+v", p)
+	}
+}
+
+func TestEnforce(t *testing.T) {
+	root, err := ioutil.TempDir("", "logagent")
+	if err != nil {
+		t.Fatalf("tempdir err: %v", err)
+	}
+	defer os.RemoveAll(root)
+
+	now := time.Now()
+	day := 24 * time.Hour
+	expired := writeLog(t, root, "dmall/bbcc/172.16.0.1:8080/info.2020-09-01.log", 100, now.Add(-10*day))
+	old := writeLog(t, root, "dmall/bbcc/172.16.0.2:8080/info.2020-09-08.log", 1000, now.Add(-3*day))
+	active := writeLog(t, root, "dmall/bbcc/172.16.0.2:8080/info.log", 100, now)
+	oversize := writeLog(t, root, "logback/bbcc/172.16.0.3_4f2a/error.log", 300, now.Add(-2*time.Hour))
+	kept := writeLog(t, root, "logback/bbcc/172.16.0.3_4f2a/info.log", 300, now.Add(-90*time.Minute))
+	untouched := writeLog(t, root, "dmall/other/172.16.0.4:8080/info.log", 500, now.Add(-30*day))
+	// the empty directory of a pod long gone
+	gone := filepath.Join(root, "dmall", "bbcc", "172.16.0.9:8080")
+	if err := os.MkdirAll(gone, 0755); err != nil {
+		t.Fatalf("mkdir err: %v", err)
+	}
+	os.Chtimes(gone, now.Add(-10*day), now.Add(-10*day))
+
+	policies, err := ParsePolicies(`{"enable":true,"apps":[
+		{"app":"dmall/bbcc","maxAge":"168h","compressAfter":"24h"},
+		{"app":"logback/bbcc","maxSize":"400"}]}`)
+	if err != nil {
+		t.Fatalf("parse err: %v", err)
+	}
+	usage, err := Enforce(root, policies, nil, now)
+	if err != nil {
+		t.Fatalf("enforce err: %v", err)
+	}
+
+	if exists(expired) || exists(old) || !exists(old+gzipSuffix) || !exists(active) {
+		t.Errorf("expect the expired file deleted and the old file compressed")
+	}
+	if info, err := os.Stat(old + gzipSuffix); err != nil || info.ModTime().Unix() != now.Add(-3*day).Unix() {
+		t.Errorf("expect the mtime of the compressed file kept, current: %v, err: %v", info, err)
+	}
+	if exists(oversize) || !exists(kept) {
+		t.Errorf("expect the oldest file deleted by the max size")
+	}
+	if !exists(untouched) {
+		t.Errorf("expect the files of the apps without policy kept")
+	}
+	if exists(gone) {
+		t.Errorf("expect the empty directory removed")
+	}
+
+	if len(usage.Apps) != 3 {
+		t.Fatalf("expect 3 apps, current: %+v", usage.Apps)
+	}
+	var total int64
+	for _, app := range usage.Apps {
+		total += app.Bytes
+		switch app.App {
+		case "logback/bbcc":
+			if app.Bytes != 300 || app.Files != 1 || app.PodDirs != 1 {
+				t.Errorf("unexpected usage of logback/bbcc: %+v", app)
+			}
+		case "dmall/other":
+			if app.Bytes != 500 || app.OldestModTime == nil {
+				t.Errorf("unexpected usage of dmall/other: %+v", app)
+			}
+		case "dmall/bbcc":
+			// the directory of the pod of the expired file is just modified
+			if app.Files != 2 || app.PodDirs != 2 {
+				t.Errorf("unexpected usage of dmall/bbcc: %+v", app)
+			}
+		}
+	}
+	if usage.TotalBytes != total || usage.ReclaimedBytes <= 400 {
+		t.Errorf("unexpected usage: total %d, reclaimed %d", usage.TotalBytes, usage.ReclaimedBytes)
+	}
+}
+
+func TestEnforceLivePods(t *testing.T) {
+	root, err := ioutil.TempDir("", "logagent")
+	if err != nil {
+		t.Fatalf("tempdir err: %v", err)
+	}
+	defer os.RemoveAll(root)
+
+	now := time.Now()
+	day := 24 * time.Hour
+	live := writeLog(t, root, "dmall/bbcc/172.16.0.1:8080/info.2020-09-01.log", 500, now.Add(-10*day))
+	liveLogback := writeLog(t, root, "logback/bbcc/172.16.0.1_4f2a/info.log", 500, now.Add(-10*day))
+	dead := writeLog(t, root, "dmall/bbcc/172.16.0.11:8080/info.2020-09-01.log", 100, now.Add(-10*day))
+	// the empty directory of a running pod
+	empty := filepath.Join(root, "dmall", "bbcc", "172.16.0.1:8080", "archive")
+	if err := os.MkdirAll(empty, 0755); err != nil {
+		t.Fatalf("mkdir err: %v", err)
+	}
+	os.Chtimes(empty, now.Add(-10*day), now.Add(-10*day))
+
+	policies, err := ParsePolicies(`{"enable":true,"default":{"maxAge":"168h","maxSize":"100"}}`)
+	if err != nil {
+		t.Fatalf("parse err: %v", err)
+	}
+	usage, err := Enforce(root, policies, LivePods{"172.16.0.1": true}, now)
+	if err != nil {
+		t.Fatalf("enforce err: %v", err)
+	}
+
+	if !exists(live) || !exists(liveLogback) || !exists(empty) {
+		t.Errorf("expect the files and directories of the running pod kept")
+	}
+	if exists(dead) {
+		t.Errorf("expect the expired file of the pod gone deleted")
+	}
+	if usage.TotalBytes != 1000 || usage.ReclaimedBytes != 100 {
+		t.Errorf("unexpected usage: total %d, reclaimed %d", usage.TotalBytes, usage.ReclaimedBytes)
+	}
+}
@@ -0,0 +1,220 @@
+// Package logstream streams the container logs of one or many pods across the clusters,
+// merged line by line with the prefixes of their pods. The lines are read only as fast as
+// the client receives them, nothing is buffered but a line of each pod.
+package logstream
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	k8smanager "gitlab.dmall.com/arch/sym-admin/pkg/k8s/manager"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// MaxSources bounds the pods of a merged stream
+	MaxSources = 100
+	// MaxLineBytes is the longest line sent, the longer lines are split
+	MaxLineBytes = 64 << 10
+)
+
+// Options are the log options of the queries, the empty ones are not set.
+type Options struct {
+	Container    string
+	Follow       bool
+	Previous     bool
+	Timestamps   bool
+	TailLines    *int64
+	SinceSeconds *int64
+	SinceTime    *metav1.Time
+	// LimitBytes is of each container
+	LimitBytes *int64
+}
+
+func parseBool(q url.Values, key string) (bool, error) {
+	s := q.Get(key)
+	if s == "" {
+		return false, nil
+	}
+	v, err := strconv.ParseBool(s)
+	if err != nil {
+		return false, fmt.Errorf("invalid %s: %s", key, s)
+	}
+	return v, nil
+}
+
+func parseInt(q url.Values, key string, min int64) (*int64, error) {
+	s := q.Get(key)
+	if s == "" {
+		return nil, nil
+	}
+	v, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || v < min {
+		return nil, fmt.Errorf("invalid %s: %s, at least %d", key, s, min)
+	}
+	return &v, nil
+}
+
+// parseTime parses the time of RFC3339 or the unix seconds.
+func parseTime(s string) (time.Time, error) {
+	if sec, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Unix(sec, 0), nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
+
+// ParseOptions parses the queries container, follow, previous, timestamps, tail,
+// sinceSeconds, sinceTime and limitBytes.
+func ParseOptions(q url.Values) (*Options, error) {
+	o := &Options{Container: q.Get("container")}
+	var err error
+	if o.Follow, err = parseBool(q, "follow"); err != nil {
+		return nil, err
+	}
+	if o.Previous, err = parseBool(q, "previous"); err != nil {
+		return nil, err
+	}
+	if o.Timestamps, err = parseBool(q, "timestamps"); err != nil {
+		return nil, err
+	}
+	if o.TailLines, err = parseInt(q, "tail", 0); err != nil {
+		return nil, err
+	}
+	if o.SinceSeconds, err = parseInt(q, "sinceSeconds", 1); err != nil {
+		return nil, err
+	}
+	if o.LimitBytes, err = parseInt(q, "limitBytes", 1); err != nil {
+		return nil, err
+	}
+	if s := q.Get("sinceTime"); s != "" {
+		if o.SinceSeconds != nil {
+			return nil, fmt.Errorf("only one of sinceSeconds and sinceTime may be specified")
+		}
+		t, err := parseTime(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid sinceTime: %s, RFC3339 or unix seconds expected", s)
+		}
+		o.SinceTime = &metav1.Time{Time: t}
+	}
+	return o, nil
+}
+
+// PodLogOptions returns the log options of the container.
+func (o *Options) PodLogOptions(container string) *corev1.PodLogOptions {
+	return &corev1.PodLogOptions{
+		Container:    container,
+		Follow:       o.Follow,
+		Previous:     o.Previous,
+		Timestamps:   o.Timestamps,
+		TailLines:    o.TailLines,
+		SinceSeconds: o.SinceSeconds,
+		SinceTime:    o.SinceTime,
+		LimitBytes:   o.LimitBytes,
+	}
+}
+
+// Source is the log of a container.
+type Source struct {
+	Cluster   string
+	Namespace string
+	Pod       string
+	Container string
+	Open      func(ctx context.Context) (io.ReadCloser, error)
+}
+
+// Prefix is put before the lines of the source in a merged stream.
+func (s *Source) Prefix() string {
+	return fmt.Sprintf("[%s/%s/%s] ", s.Cluster, s.Pod, s.Container)
+}
+
+// podLogStream opens the log stream of the pod, it is replaced in the tests since the fake
+// clientset does not stream the logs.
+var podLogStream = func(ctx context.Context, kubeCli kubernetes.Interface, namespace, name string, opts *corev1.PodLogOptions) (io.ReadCloser, error) {
+	return kubeCli.CoreV1().Pods(namespace).GetLogs(name, opts).Stream(ctx)
+}
+
+// PodSource returns the source of the container of the options, the first container of the
+// pod without.
+func PodSource(cluster *k8smanager.Cluster, pod *corev1.Pod, o *Options) *Source {
+	container := o.Container
+	if container == "" && len(pod.Spec.Containers) > 0 {
+		container = pod.Spec.Containers[0].Name
+	}
+	return &Source{
+		Cluster:   cluster.Name,
+		Namespace: pod.Namespace,
+		Pod:       pod.Name,
+		Container: container,
+		Open: func(ctx context.Context) (io.ReadCloser, error) {
+			return podLogStream(ctx, cluster.KubeCli, pod.Namespace, pod.Name, o.PodLogOptions(container))
+		},
+	}
+}
+
+// Line is a line of a source, or the error ending the source.
+type Line struct {
+	Source *Source
+	Text   string
+	Err    error
+}
+
+// Merge reads the sources concurrently and sends their lines in the order read, the
+// channel is closed after all the sources end. A source waits for its line received
+// before reading the next, so the slow clients slow down the reads instead of buffering.
+func Merge(ctx context.Context, sources []*Source) <-chan *Line {
+	out := make(chan *Line)
+	var wg sync.WaitGroup
+	for _, s := range sources {
+		wg.Add(1)
+		go func(s *Source) {
+			defer wg.Done()
+			s.copy(ctx, out)
+		}(s)
+	}
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+	return out
+}
+
+func (s *Source) copy(ctx context.Context, out chan<- *Line) {
+	send := func(l *Line) bool {
+		select {
+		case out <- l:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	rc, err := s.Open(ctx)
+	if err != nil {
+		send(&Line{Source: s, Err: err})
+		return
+	}
+	defer rc.Close()
+
+	r := bufio.NewReaderSize(rc, MaxLineBytes)
+	for {
+		line, _, err := r.ReadLine()
+		if err != nil {
+			if err != io.EOF && ctx.Err() == nil {
+				send(&Line{Source: s, Err: err})
+			}
+			return
+		}
+		if !send(&Line{Source: s, Text: strings.TrimSuffix(string(line), "\r")}) {
+			return
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package logstream
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestParseOptions(t *testing.T) {
+	o, err := ParseOptions(url.Values{
+		"follow":     {"true"},
+		"tail":       {"0"},
+		"sinceTime":  {"2020-10-19T08:00:00Z"},
+		"limitBytes": {"1024"},
+		"container":  {"bbcc"},
+	})
+	if err != nil {
+		t.Fatalf("parse err: %v", err)
+	}
+	po := o.PodLogOptions("bbcc")
+	if !po.Follow || *po.TailLines != 0 || *po.LimitBytes != 1024 || po.SinceSeconds != nil ||
+		!po.SinceTime.Time.Equal(time.Date(2020, 10, 19, 8, 0, 0, 0, time.UTC)) || po.Container != "bbcc" {
+		t.Errorf("unexpected options: %+v", po)
+	}
+	if o, err := ParseOptions(url.Values{"sinceTime": {"1603094400"}}); err != nil || o.SinceTime.Unix() != 1603094400 {
+		t.Errorf("expect the unix sinceTime, options: %+v, err: %v", o, err)
+	}
+
+	for _, q := range []url.Values{
+		{"follow": {"yes"}},
+		{"tail": {"-1"}},
+		{"sinceSeconds": {"0"}},
+		{"limitBytes": {"a"}},
+		{"sinceTime": {"yesterday"}},
+		{"sinceSeconds": {"60"}, "sinceTime": {"1603094400"}},
+	} {
+		if _, err := ParseOptions(q); err == nil {
+			t.Errorf("expect invalid options: %v", q)
+		}
+	}
+}
+
+func testSource(pod, log string, err error) *Source {
+	return &Source{
+		Cluster:   "tx-test",
+		Namespace: "default",
+		Pod:       pod,
+		Container: "app",
+		Open: func(ctx context.Context) (io.ReadCloser, error) {
+			if err != nil {
+				return nil, err
+			}
+			return ioutil.NopCloser(strings.NewReader(log)), nil
+		},
+	}
+}
+
+func TestMerge(t *testing.T) {
+	sources := []*Source{
+		testSource("bbcc-0", "a\r\nb\n", nil),
+		testSource("bbcc-1", "c\n\nd", nil),
+		testSource("bbcc-2", "", errors.New("container not found")),
+	}
+	var lines []string
+	var errs int
+	for l := range Merge(context.Background(), sources) {
+		if l.Err != nil {
+			errs++
+			continue
+		}
+		lines = append(lines, l.Source.Prefix()+l.Text)
+	}
+	sort.Strings(lines)
+	expect := []string{
+		"[tx-test/bbcc-0/app] a",
+		"[tx-test/bbcc-0/app] b",
+		"[tx-test/bbcc-1/app] ",
+		"[tx-test/bbcc-1/app] c",
+		"[tx-test/bbcc-1/app] d",
+	}
+	if strings.Join(lines, "|") != strings.Join(expect, "|") || errs != 1 {
+		t.Errorf("expect lines %q, current %q, errors: %d", expect, lines, errs)
+	}
+
+	// the sources stop reading once canceled, the channel is still closed
+	ctx, cancel := context.WithCancel(context.Background())
+	r, w := io.Pipe()
+	defer w.Close()
+	lines2 := Merge(ctx, []*Source{{Open: func(context.Context) (io.ReadCloser, error) { return r, nil }}})
+	go w.Write([]byte("x\ny\n"))
+	<-lines2
+	cancel()
+	r.Close()
+	select {
+	case <-waitClosed(lines2):
+	case <-time.After(time.Second):
+		t.Errorf("expect the merged channel closed after canceled")
+	}
+}
+
+func waitClosed(lines <-chan *Line) <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		for range lines {
+		}
+		close(done)
+	}()
+	return done
+}
+
+func streamServer(sources []*Source) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Stream(w, r, sources, true)
+	}))
+}
+
+func TestStreamSSE(t *testing.T) {
+	srv := streamServer([]*Source{testSource("bbcc-0", "a\nb\n", nil), testSource("bbcc-1", "", errors.New("gone"))})
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("get err: %v", err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("unexpected content type: %s", ct)
+	}
+
+	var data []string
+	var events []string
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "event: ") {
+			events = append(events, strings.TrimPrefix(line, "event: "))
+		} else if strings.HasPrefix(line, "data: ") {
+			data = append(data, strings.TrimPrefix(line, "data: "))
+		}
+	}
+	if len(events) != 2 || events[len(events)-1] != "end" || len(data) != 4 {
+		t.Errorf("unexpected events: %q, data: %q", events, data)
+	}
+	if !strings.Contains(strings.Join(data, "|"), "[tx-test/bbcc-0/app] a|[tx-test/bbcc-0/app] b") {
+		t.Errorf("expect the lines of bbcc-0 in order: %q", data)
+	}
+}
+
+func TestStreamWebsocket(t *testing.T) {
+	srv := streamServer([]*Source{testSource("bbcc-0", "a\nb\n", nil)})
+	defer srv.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("dial err: %v", err)
+	}
+	defer conn.Close()
+
+	var lines []string
+	for {
+		_, msg, err := conn.ReadMessage()
+		if err != nil {
+			if !websocket.IsCloseError(err, websocket.CloseNormalClosure) {
+				t.Errorf("expect the normal close, err: %v", err)
+			}
+			break
+		}
+		lines = append(lines, string(msg))
+	}
+	if strings.Join(lines, "|") != "[tx-test/bbcc-0/app] a|[tx-test/bbcc-0/app] b" {
+		t.Errorf("unexpected lines: %q", lines)
+	}
+}
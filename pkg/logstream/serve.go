@@ -0,0 +1,220 @@
+package logstream
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"gitlab.dmall.com/arch/sym-admin/pkg/router"
+	"k8s.io/klog"
+)
+
+var (
+	// HeartbeatInterval keeps the idle streams alive through the proxies
+	HeartbeatInterval = 15 * time.Second
+	// WriteTimeout closes the streams of the clients not receiving for the time
+	WriteTimeout = time.Minute
+)
+
+var upgrader = websocket.Upgrader{
+	CheckOrigin: router.CheckOrigin,
+}
+
+// lineWriter sends the lines to a client.
+type lineWriter interface {
+	line(text string) error
+	error(text string) error
+	ping() error
+	// end is sent after all the sources end
+	end() error
+	close()
+}
+
+// Stream sends the merged lines of the sources to the client, over the websocket if the
+// request upgrades, otherwise as the Server-Sent Events. The lines are prefixed with their
+// pods if prefix.
+func Stream(w http.ResponseWriter, r *http.Request, sources []*Source, prefix bool) error {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	var lw lineWriter
+	var err error
+	if websocket.IsWebSocketUpgrade(r) {
+		lw, err = newWSWriter(w, r, cancel)
+	} else {
+		lw, err = newSSEWriter(w, r, cancel)
+	}
+	if err != nil {
+		return err
+	}
+	defer lw.close()
+	return serve(ctx, lw, Merge(ctx, sources), prefix)
+}
+
+func serve(ctx context.Context, w lineWriter, lines <-chan *Line, prefix bool) error {
+	ticker := time.NewTicker(HeartbeatInterval)
+	defer ticker.Stop()
+	for {
+		var err error
+		select {
+		case l, ok := <-lines:
+			if !ok {
+				return w.end()
+			}
+			switch {
+			case l.Err != nil:
+				klog.Errorf("stream the log of pod %s/%s/%s err: %v", l.Source.Cluster, l.Source.Namespace, l.Source.Pod, l.Err)
+				err = w.error(l.Source.Prefix() + l.Err.Error())
+			case prefix:
+				err = w.line(l.Source.Prefix() + l.Text)
+			default:
+				err = w.line(l.Text)
+			}
+		case <-ticker.C:
+			err = w.ping()
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// sseWriter writes the lines as the data of the events, the errors are the error events
+// and the end is the end event.
+type sseWriter struct {
+	w     *bufio.Writer
+	flush func() error
+	conn  net.Conn
+}
+
+// newSSEWriter takes over the connection of http/1 so the write timeout of the server
+// does not end the stream, the stream ends with the connection then.
+func newSSEWriter(w http.ResponseWriter, r *http.Request, cancel func()) (*sseWriter, error) {
+	header := "Content-Type: text/event-stream\r\nCache-Control: no-cache\r\nX-Accel-Buffering: no\r\n"
+	if hj, ok := w.(http.Hijacker); ok && r.ProtoMajor == 1 {
+		conn, rw, err := hj.Hijack()
+		if err != nil {
+			return nil, err
+		}
+		conn.SetDeadline(time.Time{})
+		s := &sseWriter{w: rw.Writer, conn: conn}
+		s.flush = func() error {
+			conn.SetWriteDeadline(time.Now().Add(WriteTimeout))
+			return s.w.Flush()
+		}
+		// the client sends nothing but closes the connection
+		go func() {
+			rw.Reader.ReadByte()
+			cancel()
+		}()
+		fmt.Fprintf(s.w, "HTTP/1.1 200 OK\r\n%sConnection: close\r\n\r\n", header)
+		return s, s.flush()
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return nil, fmt.Errorf("streaming is not supported")
+	}
+	for _, h := range strings.Split(strings.TrimSpace(header), "\r\n") {
+		kv := strings.SplitN(h, ": ", 2)
+		w.Header().Set(kv[0], kv[1])
+	}
+	w.WriteHeader(http.StatusOK)
+	s := &sseWriter{w: bufio.NewWriter(w)}
+	s.flush = func() error {
+		if err := s.w.Flush(); err != nil {
+			return err
+		}
+		flusher.Flush()
+		return nil
+	}
+	return s, s.flush()
+}
+
+func (s *sseWriter) event(name, data string) error {
+	if name != "" {
+		fmt.Fprintf(s.w, "event: %s\n", name)
+	}
+	// a \r ends the data of the event
+	fmt.Fprintf(s.w, "data: %s\n\n", strings.Replace(data, "\r", "", -1))
+	return s.flush()
+}
+
+func (s *sseWriter) line(text string) error {
+	return s.event("", text)
+}
+
+func (s *sseWriter) error(text string) error {
+	return s.event("error", text)
+}
+
+func (s *sseWriter) ping() error {
+	s.w.WriteString(": ping\n\n")
+	return s.flush()
+}
+
+func (s *sseWriter) end() error {
+	return s.event("end", "EOF")
+}
+
+func (s *sseWriter) close() {
+	if s.conn != nil {
+		s.conn.Close()
+	}
+}
+
+// wsWriter writes a text message of each line, the errors are prefixed with [error] and
+// the end is the normal close.
+type wsWriter struct {
+	conn *websocket.Conn
+}
+
+func newWSWriter(w http.ResponseWriter, r *http.Request, cancel func()) (*wsWriter, error) {
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return nil, err
+	}
+	// the reads handle the control messages and find the close
+	go func() {
+		defer cancel()
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+	return &wsWriter{conn: conn}, nil
+}
+
+func (s *wsWriter) write(text string) error {
+	s.conn.SetWriteDeadline(time.Now().Add(WriteTimeout))
+	return s.conn.WriteMessage(websocket.TextMessage, []byte(text))
+}
+
+func (s *wsWriter) line(text string) error {
+	return s.write(text)
+}
+
+func (s *wsWriter) error(text string) error {
+	return s.write("[error] " + text)
+}
+
+func (s *wsWriter) ping() error {
+	return s.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(WriteTimeout))
+}
+
+func (s *wsWriter) end() error {
+	return s.conn.WriteControl(websocket.CloseMessage,
+		websocket.FormatCloseMessage(websocket.CloseNormalClosure, "EOF"), time.Now().Add(time.Second))
+}
+
+func (s *wsWriter) close() {
+	s.conn.Close()
+}
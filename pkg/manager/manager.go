@@ -39,6 +39,9 @@ type ManagerOption struct {
 	Repos              map[string]string
 	DmallChartRepo     string
 	AlertEndpoint      string
+	EventConfigMap     string
+	// ClusterNamespace is the namespace of the Cluster objects
+	ClusterNamespace string
 
 	// use expose /metrics, /read, /live, /pprof.
 	HTTPAddr                string
@@ -51,7 +54,15 @@ type ManagerOption struct {
 	WorkerEnabled           bool
 	ClusterEnabled          bool
 	OfflinePodEnabled       bool
+	OfflinePodLogLines      int64
+	OfflinePodMaxSnapshots  int
+	OfflinePodTTL           time.Duration
+	OfflinePodNamespaces    []string
+	OfflinePodNsSelector    string
+	OfflinePodWorkers       int
+	OfflinePodRecordNs      string
 	EventEnabled            bool
+	EventMultiCluster       bool
 	Debug                   bool
 	Recover                 bool
 }
@@ -79,7 +90,16 @@ func DefaultManagerOption() *ManagerOption {
 		WorkerEnabled:           false,
 		ClusterEnabled:          false,
 		OfflinePodEnabled:       false,
+		OfflinePodLogLines:      100,
+		OfflinePodMaxSnapshots:  10,
+		OfflinePodTTL:           7 * 24 * time.Hour,
+		OfflinePodNamespaces:    labels.ObservedNamespace,
+		OfflinePodWorkers:       4,
+		OfflinePodRecordNs:      "sym-admin",
 		EventEnabled:            false,
+		EventMultiCluster:       false,
+		EventConfigMap:          "sym-admin/sym-event-exporter",
+		ClusterNamespace:        "default",
 		Debug:                   false,
 		Recover:                 false,
 		DmallChartRepo:          "",
@@ -118,7 +138,7 @@ func NewDksManager(masterCli k8smanager.MasterClient, opt *ManagerOption, compon
 		Router:        rt,
 		HealthHandler: healthHandler,
 	}
-	if opt.MasterEnabled || opt.ClusterEnabled || opt.OfflinePodEnabled {
+	if opt.MasterEnabled || opt.ClusterEnabled || opt.OfflinePodEnabled || (opt.EventEnabled && opt.EventMultiCluster) {
 		klog.Info("start to initialize these multi managers of every cluster ... ")
 		clustersMgr, err := k8smanager.NewClusterManager(masterCli, k8smanager.DefaultClusterManagerOption(false, labels.GetClusterLs()))
 		if err != nil {
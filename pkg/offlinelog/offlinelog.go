@@ -0,0 +1,335 @@
+// Package offlinelog browses the log files the pods left on the nodes under Root. The
+// commands are built as argument vectors run in the offline-workload DaemonSet pod of
+// the node, the paths are confined to Root before and after the symlinks are resolved.
+package offlinelog
+
+import (
+	"fmt"
+	"net"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gitlab.dmall.com/arch/sym-admin/pkg/version"
+)
+
+// the offline-workload DaemonSet mounting the /web of the nodes
+const (
+	Namespace     = "sym-admin"
+	DaemonSetName = "offline-workload-ds"
+	ContainerName = "offline-pod-log"
+	// AgentContainerName is the container of the log agent managing the logs of the node
+	AgentContainerName = "log-agent"
+	// ServiceAccountName is the account of the log agent
+	ServiceAccountName = "offline-workload"
+
+	agentImageRepo = "symcn.tencentcloudcr.com/symcn/sym-admin-controller"
+)
+
+// AgentImage is the image of the log agent, the sym-admin-controller of the same release.
+func AgentImage() string {
+	if version.Release == "" || version.Release == "UNKNOWN" {
+		return agentImageRepo + ":latest"
+	}
+	return agentImageRepo + ":" + version.Release
+}
+
+const (
+	// Root is the only directory readable through the offline log APIs
+	Root = "/web/logs"
+	// AppRoot is the directory of the app logs
+	AppRoot = Root + "/app"
+
+	// MaxLines bounds the lines of head and tail
+	MaxLines = 10000
+	// MaxBytes bounds the bytes of the file reads and the grep results
+	MaxBytes = 1 << 20
+)
+
+// Labels are the labels of the offline-workload DaemonSet pods.
+func Labels() map[string]string {
+	return map[string]string{
+		"app": "offline-pod-log",
+	}
+}
+
+// Layout is the directory layout of the logs of a pod.
+type Layout string
+
+const (
+	// LayoutApp is /web/logs/app/$projectCode/$appCode/$podIP:$port/
+	LayoutApp Layout = "app"
+	// LayoutLogback is the legacy /web/logs/app/logback/$appName/$podIP_$containerID/
+	LayoutLogback Layout = "logback"
+)
+
+// Target identifies the log directories of an offline pod.
+type Target struct {
+	ProjectCode string
+	AppCode     string
+	AppName     string
+	PodIP       string
+	ContainerID string
+}
+
+var containerIDRegexp = regexp.MustCompile(`^[0-9a-f]+$`)
+
+// validSegment reports whether the name is a single path element.
+func validSegment(name string) bool {
+	return name != "" && name != "." && name != ".." &&
+		!strings.ContainsAny(name, "/*?[\\") && !strings.HasPrefix(name, "-")
+}
+
+// shortContainerID returns the id without the runtime prefix, e.g. docker://
+func shortContainerID(id string) string {
+	if i := strings.Index(id, "://"); i >= 0 {
+		id = id[i+3:]
+	}
+	return id
+}
+
+// Validate ...
+func (t *Target) Validate() error {
+	if net.ParseIP(t.PodIP) == nil {
+		return fmt.Errorf("invalid pod ip: %q", t.PodIP)
+	}
+	if t.ProjectCode == "" && t.AppCode == "" && t.AppName == "" {
+		return fmt.Errorf("projectCode and appCode or appName is required")
+	}
+	for _, name := range []string{t.ProjectCode, t.AppCode, t.AppName} {
+		if name != "" && !validSegment(name) {
+			return fmt.Errorf("invalid name: %q", name)
+		}
+	}
+	if id := shortContainerID(t.ContainerID); id != "" && !containerIDRegexp.MatchString(id) {
+		return fmt.Errorf("invalid container id: %q", t.ContainerID)
+	}
+	return nil
+}
+
+// Candidate is a directory holding the log directories of the pods, the log
+// directories of the target are the children matched by the patterns.
+type Candidate struct {
+	Layout   Layout
+	Dir      string
+	Patterns []string
+}
+
+// Candidates returns the candidates of both layouts, the new layout first.
+func (t *Target) Candidates() []Candidate {
+	var cs []Candidate
+	if t.ProjectCode != "" && t.AppCode != "" {
+		cs = append(cs, Candidate{
+			Layout:   LayoutApp,
+			Dir:      path.Join(AppRoot, t.ProjectCode, t.AppCode),
+			Patterns: []string{t.PodIP, t.PodIP + ":*"},
+		})
+	}
+	if t.AppName != "" {
+		patterns := []string{t.PodIP + "_*"}
+		// the legacy directories are named by the leading characters of the container id
+		if id := shortContainerID(t.ContainerID); len(id) >= 3 {
+			patterns = []string{t.PodIP + "_" + id[:3] + "*", t.PodIP + "_docker-" + id[:3] + "*"}
+		}
+		cs = append(cs, Candidate{
+			Layout:   LayoutLogback,
+			Dir:      path.Join(AppRoot, "logback", t.AppName),
+			Patterns: patterns,
+		})
+	}
+	return cs
+}
+
+// FindDirsCommand lists the log directories of the candidate.
+func (c *Candidate) FindDirsCommand() []string {
+	cmd := []string{"find", c.Dir, "-mindepth", "1", "-maxdepth", "1", "-type", "d", "("}
+	for i, p := range c.Patterns {
+		if i > 0 {
+			cmd = append(cmd, "-o")
+		}
+		cmd = append(cmd, "-name", p)
+	}
+	return append(cmd, ")", "-print")
+}
+
+// Contains reports whether the clean absolute path is under Root.
+func Contains(p string) bool {
+	return p == Root || strings.HasPrefix(p, Root+"/")
+}
+
+// Clean returns the absolute path of p under Root, p is either absolute or relative to Root.
+func Clean(p string) (string, error) {
+	if p == "" {
+		return "", fmt.Errorf("path is required")
+	}
+	if !path.IsAbs(p) {
+		p = path.Join(Root, p)
+	}
+	p = path.Clean(p)
+	if !Contains(p) {
+		return "", fmt.Errorf("path: %s is out of %s", p, Root)
+	}
+	return p, nil
+}
+
+// ResolveCommand prints the path with the symlinks resolved, it fails if the path is absent.
+func ResolveCommand(p string) []string {
+	return []string{"readlink", "-e", "--", p}
+}
+
+// StatCommand prints the type of the file, e.g. directory, regular file.
+func StatCommand(p string) []string {
+	return []string{"stat", "-c", "%F", "--", p}
+}
+
+// IsDir reports whether the output of StatCommand is a directory.
+func IsDir(stat string) bool {
+	return strings.TrimSpace(stat) == "directory"
+}
+
+// ListFilesCommand lists the files under the directory with the size and the mtime.
+func ListFilesCommand(dir string) []string {
+	return []string{"find", dir, "-type", "f", "-printf", `%P\t%s\t%T@\n`}
+}
+
+// File is a log file.
+type File struct {
+	Name    string    `json:"name"`
+	Path    string    `json:"path"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"modTime"`
+}
+
+// ParseFiles parses the output of ListFilesCommand, the newest files first.
+func ParseFiles(dir, out string) []*File {
+	files := make([]*File, 0)
+	for _, line := range strings.Split(out, "\n") {
+		s := strings.Split(line, "\t")
+		if len(s) != 3 || s[0] == "" {
+			continue
+		}
+		size, _ := strconv.ParseInt(s[1], 10, 64)
+		mtime, _ := strconv.ParseFloat(s[2], 64)
+		files = append(files, &File{
+			Name:    s[0],
+			Path:    path.Join(dir, s[0]),
+			Size:    size,
+			ModTime: time.Unix(int64(mtime), 0),
+		})
+	}
+	sort.SliceStable(files, func(i, j int) bool {
+		if files[i].ModTime.Equal(files[j].ModTime) {
+			return files[i].Name < files[j].Name
+		}
+		return files[i].ModTime.After(files[j].ModTime)
+	})
+	return files
+}
+
+func boundLines(lines int) int {
+	if lines <= 0 || lines > MaxLines {
+		return MaxLines
+	}
+	return lines
+}
+
+// HeadCommand prints the first lines of the file.
+func HeadCommand(p string, lines int) []string {
+	return []string{"head", "-n", strconv.Itoa(boundLines(lines)), "--", p}
+}
+
+// TailCommand prints the last lines of the file.
+func TailCommand(p string, lines int) []string {
+	return []string{"tail", "-n", strconv.Itoa(boundLines(lines)), "--", p}
+}
+
+// RangeCommand prints length bytes of the file from the offset.
+func RangeCommand(p string, offset, length int64) []string {
+	if length <= 0 || length > MaxBytes {
+		length = MaxBytes
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	return []string{"dd", "if=" + p, "iflag=skip_bytes,count_bytes",
+		"skip=" + strconv.FormatInt(offset, 10), "count=" + strconv.FormatInt(length, 10), "status=none"}
+}
+
+// GrepOptions ...
+type GrepOptions struct {
+	IgnoreCase bool
+	// Regexp matches the pattern as an extended regular expression instead of a fixed string.
+	Regexp bool
+	// MaxCount bounds the matched lines of each file.
+	MaxCount int
+}
+
+// GrepCommand searches the file or the files under the directory, the symlinks
+// under the directory are not followed.
+func GrepCommand(p, pattern string, opt *GrepOptions) []string {
+	maxCount := opt.MaxCount
+	if maxCount <= 0 || maxCount > MaxLines {
+		maxCount = MaxLines
+	}
+	cmd := []string{"grep", "-r", "-n", "-I", "-m", strconv.Itoa(maxCount)}
+	if opt.IgnoreCase {
+		cmd = append(cmd, "-i")
+	}
+	if opt.Regexp {
+		cmd = append(cmd, "-E")
+	} else {
+		cmd = append(cmd, "-F")
+	}
+	return append(cmd, "-e", pattern, "--", p)
+}
+
+// CatCommand prints the file.
+func CatCommand(p string) []string {
+	return []string{"cat", "--", p}
+}
+
+// TarCommand writes the tar.gz of the directory to the stdout, the symlinks are archived
+// as links.
+func TarCommand(dir string) []string {
+	return []string{"tar", "-czf", "-", "-C", path.Dir(dir), "./" + path.Base(dir)}
+}
+
+// LimitWriter keeps the first Max bytes written and drops the rest, Cancel is called once
+// it is truncated if not nil, e.g. to stop the command writing.
+type LimitWriter struct {
+	Max       int
+	Truncated bool
+	Cancel    func()
+	buf       strings.Builder
+}
+
+func (w *LimitWriter) Write(p []byte) (int, error) {
+	left := w.Max - w.buf.Len()
+	if len(p) > left {
+		if !w.Truncated && w.Cancel != nil {
+			w.Cancel()
+		}
+		w.Truncated = true
+		if left > 0 {
+			w.buf.Write(p[:left])
+		}
+		return len(p), nil
+	}
+	w.buf.Write(p)
+	return len(p), nil
+}
+
+// String ...
+func (w *LimitWriter) String() string {
+	return w.buf.String()
+}
+
+// Dir is a log directory of a pod.
+type Dir struct {
+	Layout Layout  `json:"layout"`
+	Path   string  `json:"path"`
+	Files  []*File `json:"files"`
+}
@@ -0,0 +1,123 @@
+package offlinelog
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestClean(t *testing.T) {
+	data := map[string]string{
+		"app/dmall/bbcc/172.16.0.1:8080/info.log": "/web/logs/app/dmall/bbcc/172.16.0.1:8080/info.log",
+		"/web/logs/app/logback/bbcc/":             "/web/logs/app/logback/bbcc",
+		"/web/logs":                               "/web/logs",
+		"app/../app/./bbcc":                       "/web/logs/app/bbcc",
+		"../../etc/passwd":                        "",
+		"/web/logs/../../etc/passwd":              "",
+		"/web/logsx/info.log":                     "",
+		"/etc/shadow":                             "",
+		"":                                        "",
+		"app/dmall/bbcc/172.16.0.1:8080/../../../../../..": "",
+	}
+	for input, expect := range data {
+		p, err := Clean(input)
+		if expect == "" {
+			if err == nil {
+				t.Errorf("input: %q expect err, current: %s", input, p)
+			}
+			continue
+		}
+		if err != nil || p != expect {
+			t.Errorf("input: %q expect: %s, current: %s, err: %v", input, expect, p, err)
+		}
+	}
+}
+
+func TestTargetCandidates(t *testing.T) {
+	target := &Target{
+		ProjectCode: "dmall",
+		AppCode:     "bbcc",
+		AppName:     "bbcc-svc",
+		PodIP:       "172.16.0.1",
+		ContainerID: "docker://4f2a9c0d1e",
+	}
+	if err := target.Validate(); err != nil {
+		t.Fatalf("validate err: %v", err)
+	}
+
+	cs := target.Candidates()
+	if len(cs) != 2 {
+		t.Fatalf("expect 2 candidates, current: %+v", cs)
+	}
+	if cs[0].Layout != LayoutApp || cs[0].Dir != "/web/logs/app/dmall/bbcc" {
+		t.Errorf("unexpected new layout candidate: %+v", cs[0])
+	}
+	expect := "[find /web/logs/app/dmall/bbcc -mindepth 1 -maxdepth 1 -type d ( -name 172.16.0.1 -o -name 172.16.0.1:* ) -print]"
+	if cmd := fmt.Sprint(cs[0].FindDirsCommand()); cmd != expect {
+		t.Errorf("expect: %s, current: %s", expect, cmd)
+	}
+	if cs[1].Layout != LayoutLogback || cs[1].Dir != "/web/logs/app/logback/bbcc-svc" ||
+		fmt.Sprint(cs[1].Patterns) != "[172.16.0.1_4f2* 172.16.0.1_docker-4f2*]" {
+		t.Errorf("unexpected legacy layout candidate: %+v", cs[1])
+	}
+
+	invalid := []*Target{
+		{AppName: "bbcc", PodIP: "172.16.0.1; rm -rf /"},
+		{AppName: "../bbcc", PodIP: "172.16.0.1"},
+		{AppName: "*", PodIP: "172.16.0.1"},
+		{ProjectCode: "-name", AppCode: "bbcc", PodIP: "172.16.0.1"},
+		{PodIP: "172.16.0.1"},
+		{AppName: "bbcc", PodIP: "172.16.0.1", ContainerID: "docker://4f2*"},
+	}
+	for _, target := range invalid {
+		if err := target.Validate(); err == nil {
+			t.Errorf("target: %+v expect err", target)
+		}
+	}
+}
+
+func TestParseFiles(t *testing.T) {
+	out := "info.log\t1024\t1600000000.5\narchive/info.2020-09-01.log.gz\t20\t1500000000.0\nerror.log\t0\t1600000000.1\n\n"
+	files := ParseFiles("/web/logs/app/dmall/bbcc/172.16.0.1:8080", out)
+	if len(files) != 3 {
+		t.Fatalf("expect 3 files, current: %d", len(files))
+	}
+	if files[0].Name != "error.log" || files[1].Name != "info.log" || files[2].Name != "archive/info.2020-09-01.log.gz" {
+		t.Errorf("expect the newest files first, current: %s %s %s", files[0].Name, files[1].Name, files[2].Name)
+	}
+	if files[1].Size != 1024 || files[1].Path != "/web/logs/app/dmall/bbcc/172.16.0.1:8080/info.log" {
+		t.Errorf("unexpected file: %+v", files[1])
+	}
+}
+
+func TestCommands(t *testing.T) {
+	data := map[string][]string{
+		"[tail -n 100 -- /web/logs/a.log]":   TailCommand("/web/logs/a.log", 100),
+		"[head -n 10000 -- /web/logs/a.log]": HeadCommand("/web/logs/a.log", 1000000),
+		"[dd if=/web/logs/a.log iflag=skip_bytes,count_bytes skip=10 count=1048576 status=none]": RangeCommand("/web/logs/a.log", 10, 0),
+		"[grep -r -n -I -m 10 -i -F -e -v -- /web/logs/app]":                                     GrepCommand("/web/logs/app", "-v", &GrepOptions{IgnoreCase: true, MaxCount: 10}),
+		"[grep -r -n -I -m 10000 -E -e a|b -- /web/logs/app]":                                    GrepCommand("/web/logs/app", "a|b", &GrepOptions{Regexp: true}),
+		"[tar -czf - -C /web/logs/app/dmall ./bbcc]":                                             TarCommand("/web/logs/app/dmall/bbcc"),
+	}
+	for expect, cmd := range data {
+		if fmt.Sprint(cmd) != expect {
+			t.Errorf("expect: %s, current: %v", expect, cmd)
+		}
+	}
+}
+
+func TestLimitWriter(t *testing.T) {
+	cancelled := 0
+	w := &LimitWriter{Max: 8, Cancel: func() { cancelled++ }}
+	w.Write([]byte("12345"))
+	if w.Truncated || cancelled != 0 {
+		t.Errorf("expect not truncated")
+	}
+	n, err := w.Write([]byte("67890"))
+	if n != 5 || err != nil || !w.Truncated || w.String() != "12345678" {
+		t.Errorf("unexpected write: %d %v %v %q", n, err, w.Truncated, w.String())
+	}
+	w.Write([]byte("abc"))
+	if cancelled != 1 {
+		t.Errorf("expect cancelled once, current: %d", cancelled)
+	}
+}
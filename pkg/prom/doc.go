@@ -0,0 +1,3 @@
+// Package prom queries the prometheus of the member clusters, the query is fanned
+// out to the clusters concurrently and the results are merged and tagged with the cluster.
+package prom
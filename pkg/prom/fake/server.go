@@ -0,0 +1,105 @@
+// Package fake provides a local prometheus http api server for tests.
+package fake
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+
+	"github.com/prometheus/common/model"
+)
+
+// Server serves /api/v1/query and /api/v1/query_range with the results set by SetResult.
+type Server struct {
+	*httptest.Server
+
+	mu      sync.Mutex
+	results map[string]model.Value
+	errs    map[string]string
+	queries []string
+}
+
+// NewServer starts a fake prometheus, close it after the test.
+func NewServer() *Server {
+	s := &Server{
+		results: make(map[string]model.Value),
+		errs:    make(map[string]string),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/query", s.handle)
+	mux.HandleFunc("/api/v1/query_range", s.handle)
+	s.Server = httptest.NewServer(mux)
+	return s
+}
+
+// SetResult sets the result of the query, the query not set returns an empty vector.
+func (s *Server) SetResult(query string, v model.Value) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.results[query] = v
+}
+
+// SetError makes the query fail with the message.
+func (s *Server) SetError(query string, msg string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.errs[query] = msg
+}
+
+// Queries returns the queries received.
+func (s *Server) Queries() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.queries...)
+}
+
+type response struct {
+	Status    string      `json:"status"`
+	Data      interface{} `json:"data,omitempty"`
+	ErrorType string      `json:"errorType,omitempty"`
+	Error     string      `json:"error,omitempty"`
+}
+
+type queryData struct {
+	ResultType model.ValueType `json:"resultType"`
+	Result     model.Value     `json:"result"`
+}
+
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeJSON(w, http.StatusBadRequest, &response{Status: "error", ErrorType: "bad_data", Error: err.Error()})
+		return
+	}
+	query := r.Form.Get("query")
+
+	s.mu.Lock()
+	s.queries = append(s.queries, query)
+	v, ok := s.results[query]
+	msg, isErr := s.errs[query]
+	s.mu.Unlock()
+
+	if isErr {
+		writeJSON(w, http.StatusUnprocessableEntity, &response{Status: "error", ErrorType: "execution", Error: msg})
+		return
+	}
+	if !ok {
+		if r.URL.Path == "/api/v1/query_range" {
+			v = model.Matrix{}
+		} else {
+			v = model.Vector{}
+		}
+	}
+
+	writeJSON(w, http.StatusOK, &response{
+		Status: "success",
+		Data:   &queryData{ResultType: v.Type(), Result: v},
+	})
+}
+
+func writeJSON(w http.ResponseWriter, code int, resp *response) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(resp)
+}
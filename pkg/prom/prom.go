@@ -0,0 +1,208 @@
+package prom
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/prometheus/client_golang/api"
+	promv1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+	"k8s.io/klog"
+)
+
+// Target is the prometheus of a cluster.
+type Target struct {
+	Cluster  string
+	Endpoint string
+}
+
+// Point is a sample of a series.
+type Point struct {
+	// Time is the unix timestamp in milliseconds
+	Time  int64   `json:"time"`
+	Value float64 `json:"value"`
+}
+
+// Series is a series of the query result of a cluster.
+type Series struct {
+	Cluster string            `json:"cluster"`
+	Labels  map[string]string `json:"labels"`
+	Points  []Point           `json:"points"`
+}
+
+// Result is the merged query result of the clusters, the clusters failed are
+// listed in Errors and the results of the others are still returned.
+type Result struct {
+	Series   []*Series         `json:"series"`
+	Errors   map[string]string `json:"errors,omitempty"`
+	Warnings []string          `json:"warnings,omitempty"`
+}
+
+// Client fans the queries out to the prometheus of the clusters.
+type Client struct {
+	Timeout time.Duration
+
+	mu   sync.Mutex
+	apis map[string]promv1.API
+}
+
+// NewClient ...
+func NewClient(timeout time.Duration) *Client {
+	return &Client{
+		Timeout: timeout,
+		apis:    make(map[string]promv1.API),
+	}
+}
+
+func (c *Client) api(endpoint string) (promv1.API, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if a, ok := c.apis[endpoint]; ok {
+		return a, nil
+	}
+
+	cli, err := api.NewClient(api.Config{Address: endpoint})
+	if err != nil {
+		return nil, errors.Wrapf(err, "new prometheus client: %s", endpoint)
+	}
+	a := promv1.NewAPI(cli)
+	c.apis[endpoint] = a
+	return a, nil
+}
+
+// Query runs the instant query against all the targets and merges the results.
+func (c *Client) Query(ctx context.Context, targets []Target, query string, ts time.Time) *Result {
+	return c.fanOut(ctx, targets, func(ctx context.Context, a promv1.API) (model.Value, promv1.Warnings, error) {
+		return a.Query(ctx, query, ts)
+	})
+}
+
+// QueryRange runs the range query against all the targets and merges the results.
+func (c *Client) QueryRange(ctx context.Context, targets []Target, query string, r promv1.Range) *Result {
+	return c.fanOut(ctx, targets, func(ctx context.Context, a promv1.API) (model.Value, promv1.Warnings, error) {
+		return a.QueryRange(ctx, query, r)
+	})
+}
+
+type queryFunc func(ctx context.Context, a promv1.API) (model.Value, promv1.Warnings, error)
+
+type targetResult struct {
+	cluster  string
+	series   []*Series
+	warnings promv1.Warnings
+	err      error
+}
+
+func (c *Client) fanOut(ctx context.Context, targets []Target, fn queryFunc) *Result {
+	if c.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.Timeout)
+		defer cancel()
+	}
+
+	ch := make(chan *targetResult, len(targets))
+	for _, t := range targets {
+		go func(t Target) {
+			tr := &targetResult{cluster: t.Cluster}
+			defer func() { ch <- tr }()
+
+			if t.Endpoint == "" {
+				tr.err = fmt.Errorf("cluster: %s has no prometheus endpoint", t.Cluster)
+				return
+			}
+			a, err := c.api(t.Endpoint)
+			if err != nil {
+				tr.err = err
+				return
+			}
+
+			v, warnings, err := fn(ctx, a)
+			if err != nil {
+				tr.err = errors.Wrapf(err, "cluster: %s query prometheus: %s", t.Cluster, t.Endpoint)
+				return
+			}
+			tr.warnings = warnings
+			tr.series, tr.err = convertValue(t.Cluster, v)
+		}(t)
+	}
+
+	result := &Result{Series: []*Series{}}
+	for range targets {
+		tr := <-ch
+		if tr.err != nil {
+			klog.Warningf("prometheus fan out query err: %v", tr.err)
+			if result.Errors == nil {
+				result.Errors = make(map[string]string)
+			}
+			result.Errors[tr.cluster] = tr.err.Error()
+			continue
+		}
+		result.Series = append(result.Series, tr.series...)
+		for _, w := range tr.warnings {
+			result.Warnings = append(result.Warnings, fmt.Sprintf("%s: %s", tr.cluster, w))
+		}
+	}
+
+	sortSeries(result.Series)
+	sort.Strings(result.Warnings)
+	return result
+}
+
+func convertLabels(m model.Metric) map[string]string {
+	lb := make(map[string]string, len(m))
+	for k, v := range m {
+		lb[string(k)] = string(v)
+	}
+	return lb
+}
+
+func convertValue(cluster string, v model.Value) ([]*Series, error) {
+	switch val := v.(type) {
+	case model.Vector:
+		series := make([]*Series, 0, len(val))
+		for _, s := range val {
+			series = append(series, &Series{
+				Cluster: cluster,
+				Labels:  convertLabels(s.Metric),
+				Points:  []Point{{Time: int64(s.Timestamp), Value: float64(s.Value)}},
+			})
+		}
+		return series, nil
+	case model.Matrix:
+		series := make([]*Series, 0, len(val))
+		for _, s := range val {
+			points := make([]Point, 0, len(s.Values))
+			for _, p := range s.Values {
+				points = append(points, Point{Time: int64(p.Timestamp), Value: float64(p.Value)})
+			}
+			series = append(series, &Series{
+				Cluster: cluster,
+				Labels:  convertLabels(s.Metric),
+				Points:  points,
+			})
+		}
+		return series, nil
+	case *model.Scalar:
+		return []*Series{{
+			Cluster: cluster,
+			Labels:  map[string]string{},
+			Points:  []Point{{Time: int64(val.Timestamp), Value: float64(val.Value)}},
+		}}, nil
+	default:
+		return nil, fmt.Errorf("cluster: %s unsupported result type: %s", cluster, v.Type())
+	}
+}
+
+func sortSeries(series []*Series) {
+	sort.SliceStable(series, func(i, j int) bool {
+		if series[i].Cluster != series[j].Cluster {
+			return series[i].Cluster < series[j].Cluster
+		}
+		return model.LabelsToSignature(series[i].Labels) < model.LabelsToSignature(series[j].Labels)
+	})
+}
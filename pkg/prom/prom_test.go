@@ -0,0 +1,97 @@
+package prom
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	promv1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+	"gitlab.dmall.com/arch/sym-admin/pkg/prom/fake"
+)
+
+func TestClientQuery(t *testing.T) {
+	query := `sum by (group) (rate(cpu[5m]))`
+	now := model.Now()
+
+	s1 := fake.NewServer()
+	defer s1.Close()
+	s1.SetResult(query, model.Vector{
+		{Metric: model.Metric{"group": "blue"}, Value: 1, Timestamp: now},
+		{Metric: model.Metric{"group": "green"}, Value: 2, Timestamp: now},
+	})
+
+	s2 := fake.NewServer()
+	defer s2.Close()
+	s2.SetResult(query, model.Vector{
+		{Metric: model.Metric{"group": "blue"}, Value: 3, Timestamp: now},
+	})
+
+	s3 := fake.NewServer()
+	defer s3.Close()
+	s3.SetError(query, "query timed out")
+
+	targets := []Target{
+		{Cluster: "c2", Endpoint: s2.URL},
+		{Cluster: "c1", Endpoint: s1.URL},
+		{Cluster: "c3", Endpoint: s3.URL},
+		{Cluster: "c4"},
+	}
+
+	result := NewClient(5*time.Second).Query(context.TODO(), targets, query, now.Time())
+	if len(result.Series) != 3 {
+		t.Fatalf("expect 3 series, current: %d", len(result.Series))
+	}
+
+	expects := []struct {
+		cluster string
+		group   string
+		value   float64
+	}{
+		{"c1", "blue", 1},
+		{"c1", "green", 2},
+		{"c2", "blue", 3},
+	}
+	for i, e := range expects {
+		s := result.Series[i]
+		if s.Cluster != e.cluster || s.Labels["group"] != e.group || s.Points[0].Value != e.value {
+			t.Errorf("series[%d] expect: %+v, current: %+v", i, e, s)
+		}
+	}
+
+	if len(result.Errors) != 2 || result.Errors["c3"] == "" || result.Errors["c4"] == "" {
+		t.Errorf("expect errors of c3 and c4, current: %v", result.Errors)
+	}
+	if q := s1.Queries(); len(q) != 1 || q[0] != query {
+		t.Errorf("unexpected queries: %v", q)
+	}
+}
+
+func TestClientQueryRange(t *testing.T) {
+	query := `sum(rate(requests[5m]))`
+	start := model.Now().Add(-time.Minute)
+
+	s := fake.NewServer()
+	defer s.Close()
+	s.SetResult(query, model.Matrix{
+		{
+			Metric: model.Metric{},
+			Values: []model.SamplePair{
+				{Timestamp: start, Value: 1},
+				{Timestamp: start.Add(30 * time.Second), Value: 2},
+			},
+		},
+	})
+
+	result := NewClient(5*time.Second).QueryRange(context.TODO(), []Target{{Cluster: "c1", Endpoint: s.URL}}, query, promv1.Range{
+		Start: start.Time(),
+		End:   start.Add(time.Minute).Time(),
+		Step:  30 * time.Second,
+	})
+	if len(result.Errors) != 0 {
+		t.Fatalf("unexpected errors: %v", result.Errors)
+	}
+	if len(result.Series) != 1 || len(result.Series[0].Points) != 2 || result.Series[0].Points[1].Value != 2 {
+		t.Errorf("unexpected series: %+v", result.Series)
+	}
+}
@@ -0,0 +1,297 @@
+package router
+
+import (
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"github.com/gin-gonic/gin"
+	"gitlab.dmall.com/arch/sym-admin/pkg/execpolicy"
+)
+
+// UserKey is the key of the authenticated user in the gin context
+const UserKey = "user"
+
+// User is the identity of a request.
+type User struct {
+	Name   string   `json:"name"`
+	Groups []string `json:"groups,omitempty"`
+	// Method is how the user is authenticated, e.g. token, jwt, cert
+	Method string `json:"method"`
+}
+
+// GetUser returns the authenticated user of the request, nil if the authentication is disabled.
+func GetUser(c *gin.Context) *User {
+	if v, ok := c.Get(UserKey); ok {
+		if u, ok := v.(*User); ok {
+			return u
+		}
+	}
+	return nil
+}
+
+// UserName returns the name of the authenticated user, empty if the authentication is disabled.
+func UserName(c *gin.Context) string {
+	if u := GetUser(c); u != nil {
+		return u.Name
+	}
+	return ""
+}
+
+// errNoCredentials is returned by the authenticators when the request carries none of
+// their credentials, the next authenticator is tried.
+var errNoCredentials = errors.New("no credentials")
+
+// Authenticator identifies the user of a request.
+type Authenticator interface {
+	Authenticate(r *http.Request) (*User, error)
+}
+
+// AuthConfig is the authentication and the authorization of the api server, loaded from
+// a yaml or json file.
+type AuthConfig struct {
+	Authentication AuthenticationConfig `json:"authentication"`
+	Authorization  *AuthorizationConfig `json:"authorization,omitempty"`
+	// AllowedOrigins are the origins of the websocket requests accepted besides the same
+	// origin, "*" accepts all.
+	AllowedOrigins []string `json:"allowedOrigins,omitempty"`
+	// Exec is the policy of the commands exec in the containers, all the commands are
+	// allowed without it.
+	Exec *execpolicy.Config `json:"exec,omitempty"`
+}
+
+// AuthenticationConfig enables the authenticators, they are tried in the order of the
+// client certificate, the static tokens and the jwt.
+type AuthenticationConfig struct {
+	Tokens []*StaticToken `json:"tokens,omitempty"`
+	JWT    *JWTConfig     `json:"jwt,omitempty"`
+	MTLS   *MTLSConfig    `json:"mtls,omitempty"`
+}
+
+// StaticToken is a bearer token of a user, e.g. of the event exporters.
+type StaticToken struct {
+	Token  string   `json:"token"`
+	User   string   `json:"user"`
+	Groups []string `json:"groups,omitempty"`
+}
+
+// MTLSConfig authenticates the client certificates signed by the CA, the user is the
+// common name and the groups are the organizations.
+type MTLSConfig struct {
+	ClientCAFile string `json:"clientCAFile"`
+}
+
+// LoadAuthConfig ...
+func LoadAuthConfig(path string) (*AuthConfig, error) {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	cfg := &AuthConfig{}
+	if err := yaml.Unmarshal(raw, cfg); err != nil {
+		return nil, fmt.Errorf("invalid auth config %s: %v", path, err)
+	}
+	return cfg, nil
+}
+
+// bearerToken returns the bearer token of the request, the browsers can not set the
+// header of the websocket requests so the access_token query is accepted for them.
+func bearerToken(r *http.Request) string {
+	auth := r.Header.Get("Authorization")
+	if len(auth) > 7 && strings.EqualFold(auth[:7], "Bearer ") {
+		return strings.TrimSpace(auth[7:])
+	}
+	if strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		return r.URL.Query().Get("access_token")
+	}
+	return ""
+}
+
+type tokenAuthenticator struct {
+	tokens []*StaticToken
+}
+
+func (a *tokenAuthenticator) Authenticate(r *http.Request) (*User, error) {
+	token := bearerToken(r)
+	if token == "" {
+		return nil, errNoCredentials
+	}
+	var matched *StaticToken
+	for _, t := range a.tokens {
+		// compare all the tokens in constant time
+		if subtle.ConstantTimeCompare([]byte(t.Token), []byte(token)) == 1 {
+			matched = t
+		}
+	}
+	if matched == nil {
+		return nil, errNoCredentials
+	}
+	return &User{Name: matched.User, Groups: matched.Groups, Method: "token"}, nil
+}
+
+type certAuthenticator struct{}
+
+func (a *certAuthenticator) Authenticate(r *http.Request) (*User, error) {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return nil, errNoCredentials
+	}
+	cert := r.TLS.VerifiedChains[0][0]
+	if cert.Subject.CommonName == "" {
+		return nil, errors.New("client certificate without common name")
+	}
+	return &User{Name: cert.Subject.CommonName, Groups: cert.Subject.Organization, Method: "cert"}, nil
+}
+
+// unionAuthenticator tries the authenticators in order.
+type unionAuthenticator []Authenticator
+
+func (u unionAuthenticator) Authenticate(r *http.Request) (*User, error) {
+	for _, a := range u {
+		user, err := a.Authenticate(r)
+		if err == errNoCredentials {
+			continue
+		}
+		return user, err
+	}
+	return nil, errNoCredentials
+}
+
+// NewAuthenticator builds the authenticator of the config, nil if none is enabled.
+func NewAuthenticator(cfg *AuthenticationConfig) (Authenticator, error) {
+	var union unionAuthenticator
+	if cfg.MTLS != nil {
+		union = append(union, &certAuthenticator{})
+	}
+	if len(cfg.Tokens) > 0 {
+		for i, t := range cfg.Tokens {
+			if t.Token == "" || t.User == "" {
+				return nil, fmt.Errorf("tokens[%d]: token and user are required", i)
+			}
+		}
+		union = append(union, &tokenAuthenticator{tokens: cfg.Tokens})
+	}
+	if cfg.JWT != nil {
+		a, err := newJWTAuthenticator(cfg.JWT)
+		if err != nil {
+			return nil, err
+		}
+		union = append(union, a)
+	}
+	if len(union) == 0 {
+		return nil, nil
+	}
+	return union, nil
+}
+
+// clientTLSConfig verifies the client certificates given by the CA of the mtls config.
+func clientTLSConfig(cfg *MTLSConfig, tlsCfg *tls.Config) error {
+	raw, err := ioutil.ReadFile(cfg.ClientCAFile)
+	if err != nil {
+		return err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(raw) {
+		return fmt.Errorf("no certificate in %s", cfg.ClientCAFile)
+	}
+	tlsCfg.ClientCAs = pool
+	// the other authenticators are still accepted
+	tlsCfg.ClientAuth = tls.VerifyClientCertIfGiven
+	return nil
+}
+
+// allowedOrigins are the origins of the websocket requests accepted besides the same origin.
+var allowedOrigins []string
+
+// CheckOrigin accepts the websocket requests of the same origin or the allowed origins,
+// the requests without the Origin header are not from the browsers.
+func CheckOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	for _, allowed := range allowedOrigins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(u.Host, r.Host)
+}
+
+// trustedProxies are the proxies whose X-Forwarded-For header is trusted.
+var trustedProxies []*net.IPNet
+
+// setupTrustedProxies parses the addresses and the CIDRs of the trusted proxies.
+func setupTrustedProxies(proxies []string) error {
+	nets := make([]*net.IPNet, 0, len(proxies))
+	for _, p := range proxies {
+		if !strings.Contains(p, "/") {
+			ip := net.ParseIP(p)
+			if ip == nil {
+				return fmt.Errorf("invalid proxy address %q", p)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(p)
+		if err != nil {
+			return fmt.Errorf("invalid proxy CIDR %q: %v", p, err)
+		}
+		nets = append(nets, n)
+	}
+	trustedProxies = nets
+	return nil
+}
+
+func isTrustedProxy(addr string) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+	for _, n := range trustedProxies {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// SourceIP returns the peer address of the request. The X-Forwarded-For header is only
+// read when the peer is a trusted proxy, the hops are read from the right and the first
+// one not trusted is the source.
+func SourceIP(r *http.Request) string {
+	source, _, err := net.SplitHostPort(strings.TrimSpace(r.RemoteAddr))
+	if err != nil {
+		source = strings.TrimSpace(r.RemoteAddr)
+	}
+	if !isTrustedProxy(source) {
+		return source
+	}
+	hops := strings.Split(r.Header.Get("X-Forwarded-For"), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if net.ParseIP(hop) == nil {
+			break
+		}
+		source = hop
+		if !isTrustedProxy(hop) {
+			break
+		}
+	}
+	return source
+}
@@ -0,0 +1,305 @@
+package router
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func b64(raw []byte) string {
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func signJWT(t *testing.T, alg, kid string, key crypto.Signer, claims map[string]interface{}) string {
+	header, _ := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	input := b64(header) + "." + b64(payload)
+	digest := sha256.Sum256([]byte(input))
+
+	var sig []byte
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		s, err := rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, digest[:])
+		if err != nil {
+			t.Fatalf("sign err: %v", err)
+		}
+		sig = s
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, k, digest[:])
+		if err != nil {
+			t.Fatalf("sign err: %v", err)
+		}
+		sig = make([]byte, 64)
+		rb, sb := r.Bytes(), s.Bytes()
+		copy(sig[32-len(rb):32], rb)
+		copy(sig[64-len(sb):], sb)
+	}
+	return input + "." + b64(sig)
+}
+
+func writeJWKS(t *testing.T, dir string, rsaKey *rsa.PrivateKey, ecKey *ecdsa.PrivateKey) string {
+	set := map[string]interface{}{
+		"keys": []map[string]string{
+			{"kty": "RSA", "kid": "rsa", "use": "sig", "n": b64(rsaKey.N.Bytes()), "e": b64(big.NewInt(int64(rsaKey.E)).Bytes())},
+			{"kty": "EC", "kid": "ec", "crv": "P-256", "x": b64(ecKey.X.Bytes()), "y": b64(ecKey.Y.Bytes())},
+		},
+	}
+	raw, _ := json.Marshal(set)
+	p := filepath.Join(dir, "jwks.json")
+	if err := ioutil.WriteFile(p, raw, 0644); err != nil {
+		t.Fatalf("write jwks err: %v", err)
+	}
+	return p
+}
+
+func TestJWTAuthenticator(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jwks")
+	if err != nil {
+		t.Fatalf("tempdir err: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	a, err := newJWTAuthenticator(&JWTConfig{
+		Issuer:    "https://sso.dmall.com",
+		Audiences: []string{"sym-api"},
+		JWKSFile:  writeJWKS(t, dir, rsaKey, ecKey),
+	})
+	if err != nil {
+		t.Fatalf("new authenticator err: %v", err)
+	}
+
+	now := time.Now()
+	claims := func(modify func(map[string]interface{})) map[string]interface{} {
+		c := map[string]interface{}{
+			"iss":    "https://sso.dmall.com",
+			"aud":    []string{"sym-api", "other"},
+			"sub":    "alice",
+			"groups": []string{"sre"},
+			"exp":    now.Add(time.Hour).Unix(),
+		}
+		if modify != nil {
+			modify(c)
+		}
+		return c
+	}
+
+	data := []struct {
+		name   string
+		token  string
+		expect bool
+	}{
+		{"rs256", signJWT(t, "RS256", "rsa", rsaKey, claims(nil)), true},
+		{"es256", signJWT(t, "ES256", "ec", ecKey, claims(nil)), true},
+		{"expired", signJWT(t, "RS256", "rsa", rsaKey, claims(func(c map[string]interface{}) {
+			c["exp"] = now.Add(-time.Hour).Unix()
+		})), false},
+		{"issuer", signJWT(t, "RS256", "rsa", rsaKey, claims(func(c map[string]interface{}) {
+			c["iss"] = "https://evil.com"
+		})), false},
+		{"audience", signJWT(t, "RS256", "rsa", rsaKey, claims(func(c map[string]interface{}) {
+			c["aud"] = "other"
+		})), false},
+		{"key mismatch", signJWT(t, "RS256", "ec", rsaKey, claims(nil)), false},
+		{"alg none", b64([]byte(`{"alg":"none","kid":"rsa"}`)) + "." + b64([]byte(`{"sub":"alice"}`)) + ".", false},
+	}
+	for _, d := range data {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Authorization", "Bearer "+d.token)
+		user, err := a.Authenticate(req)
+		if d.expect != (err == nil) {
+			t.Errorf("%s: expect ok %v, err: %v", d.name, d.expect, err)
+			continue
+		}
+		if d.expect && (user.Name != "alice" || len(user.Groups) != 1 || user.Groups[0] != "sre") {
+			t.Errorf("%s: unexpected user: %+v", d.name, user)
+		}
+	}
+}
+
+func TestRuleAuthorizer(t *testing.T) {
+	a, err := NewAuthorizer(&AuthorizationConfig{Rules: []*Rule{
+		{Groups: []string{"sre"}, Verbs: []string{Wildcard}},
+		{Users: []string{"bob"}, Clusters: []string{"tx-test"}, Namespaces: []string{"dmall-inner"}, Apps: []string{"bbcc"}, Verbs: []string{VerbRead, VerbRestart}},
+	}})
+	if err != nil {
+		t.Fatalf("new authorizer err: %v", err)
+	}
+
+	bob := &User{Name: "bob"}
+	data := []struct {
+		attrs  Attributes
+		expect bool
+	}{
+		{Attributes{User: &User{Name: "alice", Groups: []string{"sre"}}, Verb: VerbExec, Cluster: "tx-prod"}, true},
+		{Attributes{User: bob, Verb: VerbRestart, Cluster: "tx-test", Namespace: "dmall-inner", App: "bbcc"}, true},
+		{Attributes{User: bob, Verb: VerbDelete, Cluster: "tx-test", Namespace: "dmall-inner", App: "bbcc"}, false},
+		{Attributes{User: bob, Verb: VerbRead, Cluster: "tx-test", Namespace: "dmall-inner", App: "other"}, false},
+		{Attributes{User: bob, Verb: VerbRead, Cluster: "tx-prod", Namespace: "dmall-inner", App: "bbcc"}, false},
+		// the unknown app is only matched by the wildcard
+		{Attributes{User: bob, Verb: VerbRead, Cluster: "tx-test", Namespace: "dmall-inner"}, false},
+	}
+	for i, d := range data {
+		if ok, _ := a.Authorize(&d.attrs); ok != d.expect {
+			t.Errorf("case %d: expect %v, current %v", i, d.expect, ok)
+		}
+	}
+
+	if _, err := NewAuthorizer(&AuthorizationConfig{Rules: []*Rule{{Users: []string{"bob"}, Verbs: []string{"write"}}}}); err == nil {
+		t.Errorf("expect unknown verb err")
+	}
+}
+
+func TestAuthHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := NewRouter(&Options{Auth: &AuthConfig{
+		Authentication: AuthenticationConfig{Tokens: []*StaticToken{
+			{Token: "t-alice", User: "alice", Groups: []string{"sre"}},
+			{Token: "t-bob", User: "bob"},
+		}},
+		Authorization: &AuthorizationConfig{Rules: []*Rule{
+			{Groups: []string{"sre"}, Verbs: []string{Wildcard}},
+			{Users: []string{"bob"}, Apps: []string{"bbcc"}, Verbs: []string{VerbRead}},
+		}},
+	}})
+	r.AppResolver = func(cluster, namespace, pod string) string {
+		if pod == "bbcc-0" {
+			return "bbcc"
+		}
+		return "other"
+	}
+	ok := func(c *gin.Context) {
+		user := GetUser(c)
+		c.String(http.StatusOK, user.Name)
+	}
+	r.AddRoutes("index", []*Route{{Method: "GET", Path: "/", Handler: func(c *gin.Context) { c.String(http.StatusOK, "index") }}})
+	r.AddRoutes("cluster", []*Route{
+		{Method: "GET", Path: "/api/cluster/:name/namespace/:namespace/pod/:podName", Handler: ok},
+		{Method: "DELETE", Path: "/api/cluster/:name/namespace/:namespace/pod/:podName", Handler: ok, Verb: VerbDelete},
+		{Method: "GET", Path: "/api/cluster/:name/terminal", Handler: ok, Queries: &Queries{Namespace: "namespace", DefaultNamespace: "default", Pod: "pod"}},
+		{Method: "GET", Path: "/api/pod/logfiles", Handler: ok, Queries: &Queries{Cluster: "clusterCode", Namespace: "namespace", App: "appName", Pod: "podName"}},
+	})
+
+	data := []struct {
+		method string
+		path   string
+		token  string
+		expect int
+	}{
+		{"GET", "/", "", http.StatusOK},
+		{"GET", "/api/cluster/tx-test/namespace/dmall-inner/pod/bbcc-0", "", http.StatusUnauthorized},
+		{"GET", "/api/cluster/tx-test/namespace/dmall-inner/pod/bbcc-0", "invalid", http.StatusUnauthorized},
+		{"GET", "/api/cluster/tx-test/namespace/dmall-inner/pod/bbcc-0", "t-bob", http.StatusOK},
+		{"GET", "/api/cluster/tx-test/namespace/dmall-inner/pod/other-0", "t-bob", http.StatusForbidden},
+		{"DELETE", "/api/cluster/tx-test/namespace/dmall-inner/pod/bbcc-0", "t-bob", http.StatusForbidden},
+		{"DELETE", "/api/cluster/tx-test/namespace/dmall-inner/pod/bbcc-0", "t-alice", http.StatusOK},
+		// the queries not read by the handler are ignored
+		{"GET", "/api/cluster/tx-test/namespace/dmall-inner/pod/other-0?appName=bbcc", "t-bob", http.StatusForbidden},
+		{"GET", "/api/cluster/tx-test/terminal?pod=other-0&appName=bbcc", "t-bob", http.StatusForbidden},
+		{"GET", "/api/cluster/tx-test/terminal?pod=bbcc-0", "t-bob", http.StatusOK},
+		// the app of the request must be the app of the pod
+		{"GET", "/api/pod/logfiles?clusterCode=tx-test&podName=other-0&appName=bbcc", "t-bob", http.StatusForbidden},
+		{"GET", "/api/pod/logfiles?clusterCode=tx-test&podName=bbcc-0&appName=bbcc", "t-bob", http.StatusOK},
+	}
+	for _, d := range data {
+		req := httptest.NewRequest(d.method, d.path, nil)
+		if d.token != "" {
+			req.Header.Set("Authorization", "Bearer "+d.token)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != d.expect {
+			t.Errorf("%s %s token %q: expect %d, current %d %s", d.method, d.path, d.token, d.expect, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestRedactPath(t *testing.T) {
+	data := []struct {
+		path   string
+		expect string
+	}{
+		{"/api/cluster/tx-test/terminal", "/api/cluster/tx-test/terminal"},
+		{"/api/cluster/tx-test/terminal?pod=bbcc-0", "/api/cluster/tx-test/terminal?pod=bbcc-0"},
+		{"/api/cluster/tx-test/terminal?pod=bbcc-0&access_token=secret", "/api/cluster/tx-test/terminal?access_token=REDACTED&pod=bbcc-0"},
+	}
+	for _, d := range data {
+		if current := redactPath(d.path); current != d.expect {
+			t.Errorf("path %q: expect %q, current %q", d.path, d.expect, current)
+		}
+	}
+}
+
+func TestSourceIP(t *testing.T) {
+	defer func() { trustedProxies = nil }()
+	if err := setupTrustedProxies([]string{"10.0.0.0/8", "192.168.1.1"}); err != nil {
+		t.Fatalf("setup trusted proxies err: %v", err)
+	}
+	if err := setupTrustedProxies([]string{"proxy"}); err == nil {
+		t.Errorf("expect invalid proxy err")
+	}
+
+	data := []struct {
+		remoteAddr string
+		forwarded  string
+		expect     string
+	}{
+		{"172.16.0.1:1234", "", "172.16.0.1"},
+		// the header of the untrusted peers is ignored
+		{"172.16.0.1:1234", "1.1.1.1", "172.16.0.1"},
+		{"10.1.1.1:1234", "1.1.1.1", "1.1.1.1"},
+		{"10.1.1.1:1234", "1.1.1.1, 2.2.2.2, 192.168.1.1", "2.2.2.2"},
+		{"192.168.1.1:1234", "", "192.168.1.1"},
+		{"10.1.1.1:1234", "invalid", "10.1.1.1"},
+	}
+	for _, d := range data {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = d.remoteAddr
+		if d.forwarded != "" {
+			req.Header.Set("X-Forwarded-For", d.forwarded)
+		}
+		if current := SourceIP(req); current != d.expect {
+			t.Errorf("%s forwarded %q: expect %s, current %s", d.remoteAddr, d.forwarded, d.expect, current)
+		}
+	}
+}
+
+func TestCheckOrigin(t *testing.T) {
+	defer func() { allowedOrigins = nil }()
+	allowedOrigins = []string{"https://sym.dmall.com"}
+
+	data := []struct {
+		origin string
+		expect bool
+	}{
+		{"", true},
+		{"http://api.dmall.com:8080", true},
+		{"https://sym.dmall.com", true},
+		{"https://evil.com", false},
+	}
+	for _, d := range data {
+		req := httptest.NewRequest("GET", "http://api.dmall.com:8080/api/cluster/tx-test/terminal", nil)
+		if d.origin != "" {
+			req.Header.Set("Origin", d.origin)
+		}
+		if CheckOrigin(req) != d.expect {
+			t.Errorf("origin %q: expect %v", d.origin, d.expect)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package router
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// Verbs of the routes
+const (
+	VerbRead    = "read"
+	VerbRestart = "restart"
+	VerbDelete  = "delete"
+	VerbExec    = "exec"
+	// VerbAdmin is of the routes changing the clusters, e.g. deploying the DaemonSets
+	VerbAdmin = "admin"
+)
+
+// Wildcard matches all the names of a rule field
+const Wildcard = "*"
+
+// Attributes are what a request acts on, the empty ones are unknown and only matched by
+// the wildcard.
+type Attributes struct {
+	User      *User
+	Verb      string
+	Cluster   string
+	Namespace string
+	App       string
+	// Pod is of the requests acting on a pod
+	Pod string
+}
+
+// Authorizer decides if a request is allowed.
+type Authorizer interface {
+	Authorize(attrs *Attributes) (bool, string)
+}
+
+// AuthorizationConfig ...
+type AuthorizationConfig struct {
+	Rules []*Rule `json:"rules"`
+}
+
+// Rule allows the users or the members of the groups the verbs on the apps. The omitted
+// clusters, namespaces and apps match all, the verbs are required.
+type Rule struct {
+	Users      []string `json:"users,omitempty"`
+	Groups     []string `json:"groups,omitempty"`
+	Clusters   []string `json:"clusters,omitempty"`
+	Namespaces []string `json:"namespaces,omitempty"`
+	Apps       []string `json:"apps,omitempty"`
+	Verbs      []string `json:"verbs"`
+}
+
+func contains(list []string, v string) bool {
+	for _, e := range list {
+		if e == Wildcard || (v != "" && e == v) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchScope(list []string, v string) bool {
+	return len(list) == 0 || contains(list, v)
+}
+
+func (r *Rule) matchSubject(u *User) bool {
+	if contains(r.Users, u.Name) {
+		return true
+	}
+	for _, g := range u.Groups {
+		if contains(r.Groups, g) {
+			return true
+		}
+	}
+	return false
+}
+
+// Matches ...
+func (r *Rule) Matches(attrs *Attributes) bool {
+	return attrs.User != nil && r.matchSubject(attrs.User) &&
+		contains(r.Verbs, attrs.Verb) &&
+		matchScope(r.Clusters, attrs.Cluster) &&
+		matchScope(r.Namespaces, attrs.Namespace) &&
+		matchScope(r.Apps, attrs.App)
+}
+
+// needsApp reports if the rule restricts the apps.
+func (r *Rule) needsApp() bool {
+	return len(r.Apps) > 0 && !contains(r.Apps, "")
+}
+
+// Validate ...
+func (r *Rule) Validate() error {
+	if len(r.Users) == 0 && len(r.Groups) == 0 {
+		return fmt.Errorf("users or groups are required")
+	}
+	if len(r.Verbs) == 0 {
+		return fmt.Errorf("verbs are required")
+	}
+	for _, v := range r.Verbs {
+		switch v {
+		case VerbRead, VerbRestart, VerbDelete, VerbExec, VerbAdmin, Wildcard:
+		default:
+			return fmt.Errorf("unknown verb %s", v)
+		}
+	}
+	return nil
+}
+
+type ruleAuthorizer struct {
+	rules []*Rule
+}
+
+// NewAuthorizer ...
+func NewAuthorizer(cfg *AuthorizationConfig) (Authorizer, error) {
+	for i, r := range cfg.Rules {
+		if err := r.Validate(); err != nil {
+			return nil, fmt.Errorf("rules[%d]: %v", i, err)
+		}
+	}
+	return &ruleAuthorizer{rules: cfg.Rules}, nil
+}
+
+// Authorize allows the request matched by any rule.
+func (a *ruleAuthorizer) Authorize(attrs *Attributes) (bool, string) {
+	for _, r := range a.rules {
+		if r.Matches(attrs) {
+			return true, ""
+		}
+	}
+	return false, fmt.Sprintf("user %s can not %s app %s of namespace %s cluster %s",
+		attrs.User.Name, attrs.Verb, attrs.App, attrs.Namespace, attrs.Cluster)
+}
+
+// needsApp reports if any rule restricts the apps, the apps of the requests without
+// are resolved by the pods then.
+func (a *ruleAuthorizer) needsApp() bool {
+	for _, r := range a.rules {
+		if r.needsApp() {
+			return true
+		}
+	}
+	return false
+}
+
+// defaultVerb is of the routes without the verb, the reads are the GETs.
+func defaultVerb(method string) string {
+	if method == http.MethodGet {
+		return VerbRead
+	}
+	return VerbAdmin
+}
@@ -0,0 +1,296 @@
+package router
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// JWTConfig authenticates the id tokens of an OIDC issuer, the tokens are verified by the
+// keys of a local JWKS file.
+type JWTConfig struct {
+	Issuer    string   `json:"issuer"`
+	Audiences []string `json:"audiences,omitempty"`
+	JWKSFile  string   `json:"jwksFile"`
+	// UsernameClaim default sub
+	UsernameClaim string `json:"usernameClaim,omitempty"`
+	// GroupsClaim default groups
+	GroupsClaim string `json:"groupsClaim,omitempty"`
+}
+
+// clockSkew is tolerated in the exp and nbf of the tokens
+const clockSkew = time.Minute
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(raw), nil
+}
+
+func (k *jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		if !e.IsInt64() || e.Int64() > 1<<31-1 {
+			return nil, errors.New("invalid rsa exponent")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %s", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, errors.New("invalid ec point")
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %s", k.Kty)
+}
+
+// loadJWKS reads the signing keys of the JWKS file by the kid.
+func loadJWKS(path string) (map[string]crypto.PublicKey, error) {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	set := struct {
+		Keys []*jwk `json:"keys"`
+	}{}
+	if err := json.Unmarshal(raw, &set); err != nil {
+		return nil, fmt.Errorf("invalid jwks %s: %v", path, err)
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		pub, err := k.publicKey()
+		if err != nil {
+			return nil, fmt.Errorf("invalid jwk %s: %v", k.Kid, err)
+		}
+		keys[k.Kid] = pub
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no signing key in %s", path)
+	}
+	return keys, nil
+}
+
+type jwtAuthenticator struct {
+	cfg  *JWTConfig
+	keys map[string]crypto.PublicKey
+	now  func() time.Time
+}
+
+func newJWTAuthenticator(cfg *JWTConfig) (*jwtAuthenticator, error) {
+	if cfg.Issuer == "" || cfg.JWKSFile == "" {
+		return nil, errors.New("jwt: issuer and jwksFile are required")
+	}
+	keys, err := loadJWKS(cfg.JWKSFile)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.UsernameClaim == "" {
+		cfg.UsernameClaim = "sub"
+	}
+	if cfg.GroupsClaim == "" {
+		cfg.GroupsClaim = "groups"
+	}
+	return &jwtAuthenticator{cfg: cfg, keys: keys, now: time.Now}, nil
+}
+
+// verifySignature checks the signature of the signing input by the alg, only the
+// asymmetric algs are accepted.
+func verifySignature(alg string, key crypto.PublicKey, input, sig []byte) error {
+	var hash crypto.Hash
+	switch alg[2:] {
+	case "256":
+		hash = crypto.SHA256
+	case "384":
+		hash = crypto.SHA384
+	case "512":
+		hash = crypto.SHA512
+	}
+	if hash == 0 || !hash.Available() {
+		return fmt.Errorf("unsupported alg %s", alg)
+	}
+	h := hash.New()
+	h.Write(input)
+	digest := h.Sum(nil)
+
+	switch alg[:2] {
+	case "RS":
+		pub, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return errors.New("key type mismatch")
+		}
+		return rsa.VerifyPKCS1v15(pub, hash, digest, sig)
+	case "ES":
+		pub, ok := key.(*ecdsa.PublicKey)
+		if !ok {
+			return errors.New("key type mismatch")
+		}
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		if len(sig) != 2*size {
+			return errors.New("invalid signature length")
+		}
+		r := new(big.Int).SetBytes(sig[:size])
+		s := new(big.Int).SetBytes(sig[size:])
+		if !ecdsa.Verify(pub, digest, r, s) {
+			return errors.New("invalid signature")
+		}
+		return nil
+	}
+	return fmt.Errorf("unsupported alg %s", alg)
+}
+
+func numericDate(claims map[string]interface{}, name string) (time.Time, bool) {
+	v, ok := claims[name].(float64)
+	if !ok {
+		return time.Time{}, false
+	}
+	return time.Unix(int64(v), 0), true
+}
+
+func stringList(v interface{}) []string {
+	switch t := v.(type) {
+	case string:
+		return []string{t}
+	case []interface{}:
+		var list []string
+		for _, e := range t {
+			if s, ok := e.(string); ok {
+				list = append(list, s)
+			}
+		}
+		return list
+	}
+	return nil
+}
+
+// verify checks the signature and the claims of the token and returns the claims.
+func (a *jwtAuthenticator) verify(token string) (map[string]interface{}, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed jwt")
+	}
+
+	header := struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}{}
+	raw, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil || json.Unmarshal(raw, &header) != nil {
+		return nil, errors.New("malformed jwt header")
+	}
+	if len(header.Alg) != 5 {
+		return nil, fmt.Errorf("unsupported alg %s", header.Alg)
+	}
+	key, ok := a.keys[header.Kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown kid %s", header.Kid)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errors.New("malformed jwt signature")
+	}
+	if err := verifySignature(header.Alg, key, []byte(parts[0]+"."+parts[1]), sig); err != nil {
+		return nil, err
+	}
+
+	claims := make(map[string]interface{})
+	raw, err = base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil || json.Unmarshal(raw, &claims) != nil {
+		return nil, errors.New("malformed jwt claims")
+	}
+
+	now := a.now()
+	exp, ok := numericDate(claims, "exp")
+	if !ok || now.After(exp.Add(clockSkew)) {
+		return nil, errors.New("jwt expired")
+	}
+	if nbf, ok := numericDate(claims, "nbf"); ok && now.Add(clockSkew).Before(nbf) {
+		return nil, errors.New("jwt not valid yet")
+	}
+	if iss, _ := claims["iss"].(string); iss != a.cfg.Issuer {
+		return nil, fmt.Errorf("unexpected issuer %s", iss)
+	}
+	if len(a.cfg.Audiences) > 0 && !intersects(a.cfg.Audiences, stringList(claims["aud"])) {
+		return nil, errors.New("unexpected audience")
+	}
+	return claims, nil
+}
+
+func intersects(a, b []string) bool {
+	for _, x := range a {
+		for _, y := range b {
+			if x == y {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (a *jwtAuthenticator) Authenticate(r *http.Request) (*User, error) {
+	token := bearerToken(r)
+	if strings.Count(token, ".") != 2 {
+		return nil, errNoCredentials
+	}
+	claims, err := a.verify(token)
+	if err != nil {
+		return nil, err
+	}
+	name, _ := claims[a.cfg.UsernameClaim].(string)
+	if name == "" {
+		return nil, fmt.Errorf("jwt without claim %s", a.cfg.UsernameClaim)
+	}
+	return &User{Name: name, Groups: stringList(claims[a.cfg.GroupsClaim]), Method: "jwt"}, nil
+}
@@ -1,11 +1,18 @@
 package router
 
 import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
 	"sync/atomic"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gofrs/uuid"
+	"k8s.io/klog"
 )
 
 var (
@@ -13,6 +20,48 @@ var (
 	requestServedMessage = "Request served"
 )
 
+// redactedQueries are not written in the access log
+var redactedQueries = []string{"access_token"}
+
+// redactPath replaces the values of the redacted queries of the path.
+func redactPath(path string) string {
+	i := strings.IndexByte(path, '?')
+	if i < 0 {
+		return path
+	}
+	values, err := url.ParseQuery(path[i+1:])
+	if err != nil {
+		return path[:i]
+	}
+	var redacted bool
+	for _, key := range redactedQueries {
+		if _, ok := values[key]; ok {
+			values.Set(key, "REDACTED")
+			redacted = true
+		}
+	}
+	if !redacted {
+		return path
+	}
+	return path[:i+1] + values.Encode()
+}
+
+// logFormatter is the format of the default gin logger without the redacted queries.
+func logFormatter(param gin.LogFormatterParams) string {
+	if param.Latency > time.Minute {
+		param.Latency = param.Latency - param.Latency%time.Second
+	}
+	return fmt.Sprintf("[GIN] %v | %3d | %13v | %15s | %-7s %#v\n%s",
+		param.TimeStamp.Format("2006/01/02 - 15:04:05"),
+		param.StatusCode,
+		param.Latency,
+		param.ClientIP,
+		param.Method,
+		redactPath(param.Path),
+		param.ErrorMessage,
+	)
+}
+
 func setupContext(c *gin.Context) {
 	reqCount := strconv.FormatInt(atomic.AddInt64(&requestCount, 1), 10)
 	c.Set("requestcount", reqCount)
@@ -23,3 +72,136 @@ func setupContext(c *gin.Context) {
 	c.Set("requestid", reqID)
 	c.Writer.Header().Set("X-Request-Id", reqID)
 }
+
+// AppResolver returns the app of the pod, empty if unknown.
+type AppResolver func(cluster, namespace, pod string) string
+
+// setupAuth builds the authenticator and the authorizer of the config.
+func (r *Router) setupAuth(cfg *AuthConfig) error {
+	if cfg == nil {
+		klog.Warningf("the api is not authenticated without the auth config")
+		return nil
+	}
+	allowedOrigins = cfg.AllowedOrigins
+
+	authenticator, err := NewAuthenticator(&cfg.Authentication)
+	if err != nil {
+		return err
+	}
+	if authenticator == nil {
+		return errors.New("no authenticator is configured")
+	}
+	r.authenticator = authenticator
+
+	if cfg.Authorization == nil {
+		klog.Warningf("all the authenticated users are allowed without the authorization rules")
+		return nil
+	}
+	r.authorizer, err = NewAuthorizer(cfg.Authorization)
+	return err
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// AttributesKey is the key of the request attributes in the gin context
+const AttributesKey = "attributes"
+
+// GetAttributes returns the attributes of the request of the api groups.
+func GetAttributes(c *gin.Context) *Attributes {
+	if v, ok := c.Get(AttributesKey); ok {
+		if attrs, ok := v.(*Attributes); ok {
+			return attrs
+		}
+	}
+	return nil
+}
+
+// GetVerb returns the verb of the route, default read for GET and admin for the others.
+func (route *Route) GetVerb() string {
+	if route.Verb != "" {
+		return route.Verb
+	}
+	return defaultVerb(route.Method)
+}
+
+// attributesHandler reads the cluster, the namespace, the app and the pod of the request
+// from the path params and the queries read by the handler of the route.
+func (r *Router) attributesHandler(route *Route) gin.HandlerFunc {
+	verb := route.GetVerb()
+	q := route.Queries
+	if q == nil {
+		q = &Queries{}
+	}
+	query := func(c *gin.Context, key string) string {
+		if key == "" {
+			return ""
+		}
+		return c.Query(key)
+	}
+	return func(c *gin.Context) {
+		c.Set(AttributesKey, &Attributes{
+			Verb:      verb,
+			Cluster:   firstNonEmpty(c.Param("name"), c.Param("clusterCode"), query(c, q.Cluster)),
+			Namespace: firstNonEmpty(c.Param("namespace"), query(c, q.Namespace), q.DefaultNamespace),
+			App:       firstNonEmpty(c.Param("appName"), c.Param("appname"), query(c, q.App)),
+			Pod:       firstNonEmpty(c.Param("podName"), query(c, q.Pod)),
+		})
+	}
+}
+
+func abortAuth(c *gin.Context, code int, msg string, err error) {
+	resp := gin.H{"code": code, "success": false, "message": msg}
+	if err != nil {
+		resp["error"] = err.Error()
+	}
+	c.AbortWithStatusJSON(code, resp)
+}
+
+// authHandler authenticates the requests of the route and authorizes the verb of the
+// route on the app of the request.
+func (r *Router) authHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, err := r.authenticator.Authenticate(c.Request)
+		if err != nil {
+			if err == errNoCredentials {
+				err = nil
+			}
+			klog.V(4).Infof("unauthenticated request %s %s err: %v", c.Request.Method, c.Request.URL.Path, err)
+			c.Header("WWW-Authenticate", `Bearer realm="sym-api"`)
+			abortAuth(c, http.StatusUnauthorized, "unauthorized", err)
+			return
+		}
+		c.Set(UserKey, user)
+
+		if r.authorizer == nil {
+			return
+		}
+		attrs := GetAttributes(c)
+		attrs.User = user
+		// the app of the pod is authorized, the app of the request must be the same
+		if attrs.Pod != "" && r.AppResolver != nil {
+			if a, ok := r.authorizer.(*ruleAuthorizer); !ok || a.needsApp() {
+				app := r.AppResolver(attrs.Cluster, attrs.Namespace, attrs.Pod)
+				if attrs.App != "" && attrs.App != app {
+					reason := fmt.Sprintf("the pod %s/%s is not of the app %s", attrs.Namespace, attrs.Pod, attrs.App)
+					klog.Warningf("forbidden request %s %s: %s", c.Request.Method, c.Request.URL.Path, reason)
+					abortAuth(c, http.StatusForbidden, "forbidden", errors.New(reason))
+					return
+				}
+				attrs.App = app
+			}
+		}
+		if ok, reason := r.authorizer.Authorize(attrs); !ok {
+			klog.Warningf("forbidden request %s %s: %s", c.Request.Method, c.Request.URL.Path, reason)
+			abortAuth(c, http.StatusForbidden, "forbidden", errors.New(reason))
+			return
+		}
+	}
+}
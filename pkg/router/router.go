@@ -43,6 +43,13 @@ type Options struct {
 	// 	Password      string
 	CertFilePath string
 	KeyFilePath  string
+
+	// Auth enables the authentication and the authorization of the api groups, all the
+	// requests are allowed without it.
+	Auth *AuthConfig
+	// TrustedProxies are the addresses or the CIDRs of the proxies whose X-Forwarded-For
+	// header is trusted for the source of the requests.
+	TrustedProxies []string
 }
 
 // Router handles all incoming HTTP requests
@@ -52,6 +59,12 @@ type Router struct {
 	httpServer          *http.Server
 	ProfileDescriptions []*Profile
 	Opt                 *Options
+
+	authenticator    Authenticator
+	authorizer       Authorizer
+	routeMiddlewares []RouteMiddleware
+	// AppResolver resolves the app of the requests acting on the pods for the authorization
+	AppResolver AppResolver
 }
 
 // Profile ...
@@ -61,12 +74,41 @@ type Profile struct {
 	Desc string
 }
 
+// RouteMiddleware returns the middleware of a route of the api groups, nil to skip it.
+type RouteMiddleware func(route *Route) gin.HandlerFunc
+
 // Route represents an application route
 type Route struct {
 	Method  string
 	Path    string
 	Handler gin.HandlerFunc
 	Desc    string
+	// Verb is authorized for the route, default read for GET and admin for the others
+	Verb string
+	// Queries names the queries read by the handler for the attributes of the request,
+	// only the path params are read without it.
+	Queries *Queries
+	// Audit overrides the audit of the route, default the verbs other than read are audited
+	Audit string
+}
+
+// the audit of the routes overriding the default of the verb
+const (
+	// AuditAlways is of the reads exec in the containers
+	AuditAlways = "always"
+	// AuditNever is of the writes too frequent to audit, e.g. the events pushed
+	AuditNever = "never"
+)
+
+// Queries are the names of the queries of the cluster, the namespace, the app and the
+// pod read by the handler of a route, the empty ones are not read.
+type Queries struct {
+	Cluster   string
+	Namespace string
+	// DefaultNamespace is the namespace of the handler without the namespace query
+	DefaultNamespace string
+	App              string
+	Pod              string
 }
 
 // NewRouter creates a new Router instance
@@ -79,6 +121,7 @@ func NewRouter(opt *Options) *Router {
 		gin.SetMode(gin.ReleaseMode)
 	} else {
 		conf := gin.LoggerConfig{
+			Formatter: logFormatter,
 			SkipPaths: opt.GinLogSkipPath,
 		}
 		engine.Use(gin.LoggerWithConfig(conf))
@@ -113,6 +156,13 @@ func NewRouter(opt *Options) *Router {
 			<a href="/debug/pprof/goroutine?debug=2">full goroutine stack dump</a>`)
 	}
 
+	if err := r.setupAuth(opt.Auth); err != nil {
+		klog.Fatalf("setup auth err: %v", err)
+	}
+	if err := setupTrustedProxies(opt.TrustedProxies); err != nil {
+		klog.Fatalf("setup trusted proxies err: %v", err)
+	}
+
 	r.Opt = opt
 	r.NoRoute(r.masterHandler)
 	return r
@@ -156,6 +206,14 @@ func (r *Router) Start(stopCh <-chan struct{}) error {
 			return err
 		}
 		r.httpServer.TLSConfig = &tls.Config{Certificates: []tls.Certificate{cert}}
+		if r.Opt.Auth != nil && r.Opt.Auth.Authentication.MTLS != nil {
+			if err := clientTLSConfig(r.Opt.Auth.Authentication.MTLS, r.httpServer.TLSConfig); err != nil {
+				klog.Errorf("load client CA err:%+v", err)
+				return err
+			}
+		}
+	} else if r.Opt.Auth != nil && r.Opt.Auth.Authentication.MTLS != nil {
+		klog.Warningf("the client certificates are not authenticated without the server certificate")
 	}
 
 	errCh := make(chan error)
@@ -212,19 +270,45 @@ func (r *Router) AddProfile(method, href, desc string) {
 	})
 }
 
+// UseRoute adds the route middleware to the routes added after, they run after the
+// attributes of the request are read and before the authentication.
+func (r *Router) UseRoute(m RouteMiddleware) {
+	r.routeMiddlewares = append(r.routeMiddlewares, m)
+}
+
+// wrapHandlers returns the handlers of the route of the api groups.
+func (r *Router) wrapHandlers(route *Route) []gin.HandlerFunc {
+	handlers := []gin.HandlerFunc{r.attributesHandler(route)}
+	for _, m := range r.routeMiddlewares {
+		if h := m(route); h != nil {
+			handlers = append(handlers, h)
+		}
+	}
+	if r.authenticator != nil {
+		handlers = append(handlers, r.authHandler())
+	}
+	return append(handlers, route.Handler)
+}
+
 // AddRoutes applies list of routes
 func (r *Router) AddRoutes(apiGroup string, routes []*Route) {
 	klog.V(3).Infof("load apiGroup:%s", apiGroup)
 	for _, route := range routes {
+		handlers := []gin.HandlerFunc{route.Handler}
+		// the index and the health checks are open
+		if apiGroup != "index" && apiGroup != "health" {
+			handlers = r.wrapHandlers(route)
+		}
+
 		switch route.Method {
 		case "GET":
-			r.GET(route.Path, route.Handler)
+			r.GET(route.Path, handlers...)
 		case "POST":
-			r.POST(route.Path, route.Handler)
+			r.POST(route.Path, handlers...)
 		case "DELETE":
-			r.DELETE(route.Path, route.Handler)
+			r.DELETE(route.Path, handlers...)
 		case "Any":
-			r.Any(route.Path, route.Handler)
+			r.Any(route.Path, handlers...)
 		default:
 			klog.Warningf("no method:%s apiGroup:%s", route.Method, apiGroup)
 		}
@@ -292,8 +376,8 @@ func (r *Router) DefaultRoutes() []*Route {
 	var routes []*Route
 
 	appRoutes := []*Route{
-		{"GET", "/", r.IndexHandler, ""},
-		{"GET", VersionPath, VersionHandler, ""},
+		{"GET", "/", r.IndexHandler, "", VerbRead, nil, ""},
+		{"GET", VersionPath, VersionHandler, "", VerbRead, nil, ""},
 	}
 
 	routes = append(routes, appRoutes...)
@@ -0,0 +1,139 @@
+// Package termrec records the interactive terminal sessions of the api in the asciicast
+// v2 format (https://github.com/asciinema/asciinema/blob/develop/doc/asciicast-v2.md)
+// so they can be replayed by the asciinema player, and keeps them with their metadata.
+package termrec
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// the event types of asciicast v2
+const (
+	EventOutput = "o"
+	EventInput  = "i"
+	EventResize = "r"
+)
+
+// default size of the terminal before resized by the client
+const (
+	DefaultWidth  = 80
+	DefaultHeight = 24
+)
+
+// Header is the first line of an asciicast v2 file.
+type Header struct {
+	Version   int               `json:"version"`
+	Width     int               `json:"width"`
+	Height    int               `json:"height"`
+	Timestamp int64             `json:"timestamp"`
+	Title     string            `json:"title,omitempty"`
+	Env       map[string]string `json:"env,omitempty"`
+}
+
+// Recorder writes the events of a session, it is safe for the concurrent input and output.
+type Recorder struct {
+	mu    sync.Mutex
+	w     *bufio.Writer
+	start time.Time
+	// pending keeps the incomplete utf-8 tail of the output until the next write
+	pending []byte
+	bytes   int64
+	err     error
+}
+
+// NewRecorder writes the header and returns the recorder of the events.
+func NewRecorder(w io.Writer, title string, start time.Time) (*Recorder, error) {
+	r := &Recorder{w: bufio.NewWriter(w), start: start}
+	raw, err := json.Marshal(&Header{
+		Version:   2,
+		Width:     DefaultWidth,
+		Height:    DefaultHeight,
+		Timestamp: start.Unix(),
+		Title:     title,
+		Env:       map[string]string{"TERM": "xterm", "SHELL": "/bin/sh"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if _, err := r.w.Write(append(raw, '\n')); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *Recorder) event(typ, data string) {
+	if r.err != nil {
+		return
+	}
+	raw, err := json.Marshal([]interface{}{
+		float64(time.Since(r.start).Microseconds()) / 1e6, typ, data,
+	})
+	if err != nil {
+		r.err = err
+		return
+	}
+	n, err := r.w.Write(append(raw, '\n'))
+	r.bytes += int64(n)
+	r.err = err
+}
+
+// splitUTF8 returns the complete runes of the data and the incomplete tail.
+func splitUTF8(data []byte) ([]byte, []byte) {
+	for i := 1; i < utf8.UTFMax && i <= len(data); i++ {
+		c := data[len(data)-i]
+		if !utf8.RuneStart(c) {
+			continue
+		}
+		if !utf8.FullRune(data[len(data)-i:]) {
+			return data[:len(data)-i], data[len(data)-i:]
+		}
+		break
+	}
+	return data, nil
+}
+
+// Output records the data written to the terminal.
+func (r *Recorder) Output(data []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	data = append(r.pending, data...)
+	data, r.pending = splitUTF8(data)
+	r.pending = append([]byte(nil), r.pending...)
+	if len(data) > 0 {
+		r.event(EventOutput, string(data))
+	}
+}
+
+// Input records the data typed by the user.
+func (r *Recorder) Input(data string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.event(EventInput, data)
+}
+
+// Resize records the new size of the terminal.
+func (r *Recorder) Resize(width, height int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.event(EventResize, fmt.Sprintf("%dx%d", width, height))
+}
+
+// Flush writes the buffered events and returns the bytes of the events recorded.
+func (r *Recorder) Flush() (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.pending) > 0 {
+		r.event(EventOutput, string(r.pending))
+		r.pending = nil
+	}
+	if r.err != nil {
+		return r.bytes, r.err
+	}
+	return r.bytes, r.w.Flush()
+}
@@ -0,0 +1,269 @@
+package termrec
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"time"
+
+	"k8s.io/klog"
+)
+
+const (
+	castSuffix = ".cast"
+	metaSuffix = ".json"
+	dayLayout  = "20060102"
+	// DefaultQueryLimit is the limit of a list without one.
+	DefaultQueryLimit = 100
+)
+
+// the ids are <yyyymmddhhmmss>-<random hex>, the day of the id is its directory
+var idPattern = regexp.MustCompile(`^(\d{8})\d{6}-[0-9a-f]{16}$`)
+
+// Session is the metadata of a recorded session.
+type Session struct {
+	ID        string    `json:"id"`
+	User      string    `json:"user,omitempty"`
+	SourceIP  string    `json:"sourceIP,omitempty"`
+	Cluster   string    `json:"cluster"`
+	Namespace string    `json:"namespace"`
+	Pod       string    `json:"pod"`
+	Container string    `json:"container,omitempty"`
+	Command   []string  `json:"command,omitempty"`
+	StartTime time.Time `json:"startTime"`
+	EndTime   time.Time `json:"endTime,omitempty"`
+	// CloseReason is why the session ends, e.g. closed, idle timeout, max duration
+	CloseReason string `json:"closeReason,omitempty"`
+	Bytes       int64  `json:"bytes"`
+}
+
+// Query selects the sessions, the empty fields match all.
+type Query struct {
+	User      string
+	Cluster   string
+	Namespace string
+	Pod       string
+	Start     time.Time
+	End       time.Time
+	Limit     int
+}
+
+// Matches ...
+func (q *Query) Matches(s *Session) bool {
+	return (q.User == "" || q.User == s.User) &&
+		(q.Cluster == "" || q.Cluster == s.Cluster) &&
+		(q.Namespace == "" || q.Namespace == s.Namespace) &&
+		(q.Pod == "" || q.Pod == s.Pod) &&
+		(q.Start.IsZero() || !s.StartTime.Before(q.Start)) &&
+		(q.End.IsZero() || !s.StartTime.After(q.End))
+}
+
+// Store keeps the recordings under a directory by the day.
+type Store struct {
+	dir string
+}
+
+// NewStore ...
+func NewStore(dir string) (*Store, error) {
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return nil, err
+	}
+	return &Store{dir: dir}, nil
+}
+
+func newID(now time.Time) string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return now.Format("20060102150405") + "-" + hex.EncodeToString(b)
+}
+
+// path returns the file of the session, the ids are validated against the traversal.
+func (s *Store) path(id, suffix string) (string, error) {
+	m := idPattern.FindStringSubmatch(id)
+	if m == nil {
+		return "", fmt.Errorf("invalid session id: %s", id)
+	}
+	return filepath.Join(s.dir, m[1], id+suffix), nil
+}
+
+// Recording is a session being recorded.
+type Recording struct {
+	*Recorder
+	Session *Session
+	store   *Store
+	file    *os.File
+}
+
+// Create starts the recording of the session, the id and the start time are set.
+func (s *Store) Create(session *Session) (*Recording, error) {
+	now := time.Now()
+	session.ID = newID(now)
+	session.StartTime = now
+
+	p, _ := s.path(session.ID, castSuffix)
+	if err := os.MkdirAll(filepath.Dir(p), 0750); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(p, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0640)
+	if err != nil {
+		return nil, err
+	}
+	title := fmt.Sprintf("%s %s/%s/%s", session.User, session.Cluster, session.Namespace, session.Pod)
+	rec, err := NewRecorder(file, title, now)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	r := &Recording{Recorder: rec, Session: session, store: s, file: file}
+	// the metadata of the sessions alive are listed too
+	if err := s.writeMeta(session); err != nil {
+		klog.Errorf("write the metadata of session %s err: %v", session.ID, err)
+	}
+	return r, nil
+}
+
+// Close ends the recording with the reason.
+func (r *Recording) Close(reason string) error {
+	bytes, err := r.Flush()
+	if cerr := r.file.Close(); err == nil {
+		err = cerr
+	}
+	r.Session.EndTime = time.Now()
+	r.Session.CloseReason = reason
+	r.Session.Bytes = bytes
+	if merr := r.store.writeMeta(r.Session); err == nil {
+		err = merr
+	}
+	return err
+}
+
+func (s *Store) writeMeta(session *Session) error {
+	p, err := s.path(session.ID, metaSuffix)
+	if err != nil {
+		return err
+	}
+	raw, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+	tmp := p + ".tmp"
+	if err := ioutil.WriteFile(tmp, raw, 0640); err != nil {
+		return err
+	}
+	return os.Rename(tmp, p)
+}
+
+// Get returns the metadata of the session.
+func (s *Store) Get(id string) (*Session, error) {
+	p, err := s.path(id, metaSuffix)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := ioutil.ReadFile(p)
+	if err != nil {
+		return nil, err
+	}
+	session := &Session{}
+	if err := json.Unmarshal(raw, session); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// CastPath returns the asciicast file of the session.
+func (s *Store) CastPath(id string) (string, error) {
+	p, err := s.path(id, castSuffix)
+	if err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(p); err != nil {
+		return "", err
+	}
+	return p, nil
+}
+
+// days returns the day directories, the newest first.
+func (s *Store) days() ([]string, error) {
+	infos, err := ioutil.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+	var days []string
+	for _, info := range infos {
+		if _, err := time.Parse(dayLayout, info.Name()); err == nil && info.IsDir() {
+			days = append(days, info.Name())
+		}
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(days)))
+	return days, nil
+}
+
+// List returns the sessions of the query, the newest first.
+func (s *Store) List(q *Query) ([]*Session, error) {
+	limit := q.Limit
+	if limit <= 0 {
+		limit = DefaultQueryLimit
+	}
+	days, err := s.days()
+	if err != nil {
+		return nil, err
+	}
+
+	var sessions []*Session
+	for _, day := range days {
+		t, _ := time.ParseInLocation(dayLayout, day, time.Local)
+		if !q.End.IsZero() && t.After(q.End) {
+			continue
+		}
+		if !q.Start.IsZero() && t.Add(24*time.Hour).Before(q.Start) {
+			break
+		}
+
+		matches, _ := filepath.Glob(filepath.Join(s.dir, day, "*"+metaSuffix))
+		var daySessions []*Session
+		for _, p := range matches {
+			session, err := s.Get(filepath.Base(p[:len(p)-len(metaSuffix)]))
+			if err != nil {
+				continue
+			}
+			if q.Matches(session) {
+				daySessions = append(daySessions, session)
+			}
+		}
+		sort.Slice(daySessions, func(i, j int) bool {
+			return daySessions[i].StartTime.After(daySessions[j].StartTime)
+		})
+		sessions = append(sessions, daySessions...)
+		if len(sessions) >= limit {
+			return sessions[:limit], nil
+		}
+	}
+	return sessions, nil
+}
+
+// Prune deletes the recordings of the days before the time and returns the days deleted.
+func (s *Store) Prune(before time.Time) (int, error) {
+	days, err := s.days()
+	if err != nil {
+		return 0, err
+	}
+	var n int
+	for _, day := range days {
+		t, _ := time.ParseInLocation(dayLayout, day, time.Local)
+		if !t.Add(24 * time.Hour).Before(before) {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(s.dir, day)); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
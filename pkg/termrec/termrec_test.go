@@ -0,0 +1,105 @@
+package termrec
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRecorder(t *testing.T) {
+	var buf bytes.Buffer
+	r, err := NewRecorder(&buf, "alice tx-test/default/bbcc-0", time.Now())
+	if err != nil {
+		t.Fatalf("new recorder err: %v", err)
+	}
+	r.Resize(120, 40)
+	r.Input("ls\r")
+	// a rune split between the writes
+	word := []byte("日志")
+	r.Output(word[:4])
+	r.Output(word[4:])
+	if _, err := r.Flush(); err != nil {
+		t.Fatalf("flush err: %v", err)
+	}
+
+	scanner := bufio.NewScanner(&buf)
+	scanner.Scan()
+	header := &Header{}
+	if err := json.Unmarshal(scanner.Bytes(), header); err != nil || header.Version != 2 || header.Width != DefaultWidth {
+		t.Fatalf("unexpected header: %s, err: %v", scanner.Text(), err)
+	}
+
+	expect := [][2]string{{EventResize, "120x40"}, {EventInput, "ls\r"}, {EventOutput, "日"}, {EventOutput, "志"}}
+	var i int
+	for ; scanner.Scan(); i++ {
+		var ev []interface{}
+		if err := json.Unmarshal(scanner.Bytes(), &ev); err != nil || len(ev) != 3 {
+			t.Fatalf("unexpected event: %s, err: %v", scanner.Text(), err)
+		}
+		if i >= len(expect) || ev[1] != expect[i][0] || ev[2] != expect[i][1] {
+			t.Errorf("event %d: expect %v, current %v", i, expect, ev)
+		}
+	}
+	if i != len(expect) {
+		t.Errorf("expect %d events, current %d", len(expect), i)
+	}
+}
+
+func TestStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "termrec")
+	if err != nil {
+		t.Fatalf("tempdir err: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	s, err := NewStore(dir)
+	if err != nil {
+		t.Fatalf("new store err: %v", err)
+	}
+	for _, user := range []string{"alice", "bob"} {
+		rec, err := s.Create(&Session{User: user, Cluster: "tx-test", Namespace: "default", Pod: "bbcc-0"})
+		if err != nil {
+			t.Fatalf("create err: %v", err)
+		}
+		rec.Output([]byte("hello"))
+		if err := rec.Close("closed"); err != nil {
+			t.Fatalf("close err: %v", err)
+		}
+	}
+
+	sessions, err := s.List(&Query{Cluster: "tx-test"})
+	if err != nil || len(sessions) != 2 {
+		t.Fatalf("expect 2 sessions, current: %v, err: %v", sessions, err)
+	}
+	if sessions, _ := s.List(&Query{User: "bob"}); len(sessions) != 1 || sessions[0].CloseReason != "closed" || sessions[0].Bytes == 0 {
+		t.Errorf("unexpected sessions of bob: %+v", sessions)
+	}
+	if sessions, _ := s.List(&Query{Limit: 1}); len(sessions) != 1 {
+		t.Errorf("expect 1 session by the limit, current: %d", len(sessions))
+	}
+
+	id := sessions[0].ID
+	if p, err := s.CastPath(id); err != nil || filepath.Dir(filepath.Dir(p)) != dir {
+		t.Errorf("unexpected cast path: %s, err: %v", p, err)
+	}
+	for _, invalid := range []string{"../../etc/passwd", "20201019000000-zz", ""} {
+		if _, err := s.Get(invalid); err == nil {
+			t.Errorf("expect invalid id err of %q", invalid)
+		}
+	}
+
+	if n, err := s.Prune(time.Now()); err != nil || n != 0 {
+		t.Errorf("expect the recordings of today kept, pruned: %d, err: %v", n, err)
+	}
+	if n, err := s.Prune(time.Now().Add(48 * time.Hour)); err != nil || n != 1 {
+		t.Errorf("expect the recordings pruned, pruned: %d, err: %v", n, err)
+	}
+	if _, err := s.Get(id); !os.IsNotExist(err) {
+		t.Errorf("expect the session pruned, err: %v", err)
+	}
+}